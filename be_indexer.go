@@ -1,49 +1,333 @@
 package be_indexer
 
 import (
+	"context"
 	"fmt"
 	"github.com/echoface/be_indexer/parser"
+	"github.com/echoface/be_indexer/util"
+	"io"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	wildcardField BEField = "__wildcard__"
 )
 
 type (
 	FieldDesc struct {
-		ID     uint64
-		Field  BEField
-		Parser parser.FieldValueParser
+		ID         uint64
+		Field      BEField
+		Parser     parser.FieldValueParser
+		ParserName string
+
+		option      FieldOption
+		autoCreated bool // created when indexing a field not configured, see newFieldDescIfNeeded
 	}
 
 	FieldOption struct {
 		Parser string
+
+		// ParserParams passed to parser implement parser.Configurable, eg: {"threshold": "0.8"} for parser.VectorSimParser
+		ParserParams map[string]string
+
+		// RangeQuery the field is queried with parser.RangeValue, it require a parser declare
+		// parser.RangeAware, checked when configuring instead of failing every range query later
+		RangeQuery bool
+
+		// OrderWeight how valuable a match on the field is, Retrieve order docs by the sum of weights
+		// of fields matched once any field weighted, see weightedOrderCollector
+		OrderWeight float64
+
+		// Verify post-verification for field of parser.Approximate parser, a conjunction whose only
+		// support from the field is approximate accepted only when it verified, see WithVerifier
+		Verify VerifyFunc
+
+		// Canonicalizer normalize values of the field before parsed, applied to document and query
+		// values alike(see CanonicalizeFunc, VerifyCanonicalizer); a document value it fail on is a
+		// bad conjunction(see BadConjBehavior), a query value fail the query unless the field can be
+		// dropped(see WithBestEffortRetrieval). a func can't be serialized, the manifest field
+		// fingerprint only record whether it's set: code loading an index config must set it again,
+		// a config lost it fail VerifyManifest, but a different func of same field is not detected
+		Canonicalizer CanonicalizeFunc
+
+		// RequiresField a query-planning hint: every conjunction with an expression on the field also
+		// include RequiresField, so no cursor of the field created for query not assign RequiresField;
+		// a conjunction break the promise is missed by such queries. not inherited from default option
+		RequiresField BEField
+
+		// MaxKeyEntries a posting list of the field longer than it is a hot key(eg: device=android),
+		// reported by a log when building, EventHotKey and IndexStatistics.HotKeys; <= 0 means no cap.
+		// it's a diagnostic only: a hot list is not split, the entries of a value a query assigned must
+		// all be scanned however they are sharded(within or across K groups), only the data can fix it
+		MaxKeyEntries int
 	}
 
 	IndexerSettings struct {
 		FieldConfig map[BEField]FieldOption
+
+		// DefaultFieldOption base of every FieldConfig and the option of fields not configured,
+		// see WithDefaultFieldOption; zero value means the package default(parser.CommonParser)
+		DefaultFieldOption FieldOption
+
+		// SharePostingLists keys with identical posting list share one Entries, see WithSharedPostingLists
+		SharePostingLists bool
+
+		// NoPanics the index recover panics of retrieving for every query, see WithNoPanics
+		NoPanics bool
+
+		// LazyCompile posting lists sorted on first use instead of when built, see WithLazyCompile
+		LazyCompile       bool
+		BackgroundCompile bool
+
+		// SchemaVersion application defined schema version stamped onto the index, see WithSchemaVersion
+		SchemaVersion string
+
+		// NearMissAnalysis fields of conjunctions recorded for RetrieveWithNearMiss, see WithNearMissAnalysis
+		NearMissAnalysis bool
+
+		// ValueReverseLookup values decodable from their ids, see WithValueReverseLookup
+		ValueReverseLookup bool
 	}
 
 	RetrieveContext struct {
 		idAssigns map[BEField][]uint64
+
+		bitsetAssigns map[BEField]*util.Bitmap // see WithBitsetAssign
+		rawIDAssigns  map[BEField][]uint64     // see WithRawIDAssign
+
+		// cursor advancements budget, see WithMaxCursorAdvancements
+		maxAdvancements       int64
+		advancements          int64
+		partialOnBudgetExceed bool
+
+		// memory budget, see WithRetrieveMemoryBudget
+		memoryBudget    int64
+		scratchMemory   int64
+		collectorMemory int64
+
+		// best effort retrieving, see WithBestEffortRetrieval
+		bestEffort    bool
+		droppedFields map[BEField]error
+
+		binarySearchCursors bool // see WithBinarySearchCursors
+
+		recoverPanic bool // see WithRecover
+		collecting   bool // a collector is adding a hit, its panic is a ErrCollectorPanic, see collect
+
+		requirePositive bool // see WithRequirePositive
+
+		trace *RetrieveTrace // see WithRetrieveTrace
+
+		analysis *RetrievalAnalysis // see RetrieveWithAnalysis
+
+		tieBreakOrder map[BEField]int // see WithFieldTieBreakOrder
+
+		orderBySortKey bool            // see WithOrderBySortKey
+		sortKeyDesc    bool            // see WithOrderBySortKey
+		sortKeys       map[DocID]int64 // sort key of docs, resolved by index
+
+		// see WithValueContribution, keyQValues: the query values every posting list key parsed from
+		valueContribution map[QKey]int
+		keyQValues        map[Key][]QKey
+		contributed       map[qkeyDoc]struct{}
+
+		// see WithVerifier, approxKeys: the verification of posting list keys parsed by approximate parser
+		verifiers  map[BEField]VerifyFunc
+		approxKeys map[Key]*approxSupport
+
+		labelFilter []string             // see WithLabelFilter
+		labelDocs   []map[DocID]struct{} // docs of every label in labelFilter, resolved by index
+
+		// two pass retrieving, see WithTwoPassRetrieval; candidates sorted, nil means no filter
+		coarseQueries Assignments
+		twoPass       bool
+		candidates    DocIDList
+
+		// result arrangement, see WithResultSort/WithMaxResults
+		resultLess func(a, b DocID) bool
+		maxResults int
+
+		complement bool // see WithComplementResults
+
+		namespace     string           // see WithQueryNamespace
+		docNamespaces map[DocID]string // namespace of docs, resolved by index
+
+		expectedSchema *string // see WithExpectedSchema
+
+		// see WithKeepMostSpecific, specificOrder: docs in order of first hit
+		specificHits  map[DocID]*specificHit
+		specificOrder DocIDList
 	}
 
-	BEIndex interface {
-		//interface used by builder
+	// docIndexer interface used by builder to index a document, see IndexerBuilder.buildDocEntries
+	docIndexer interface {
 		appendWildcardEntryID(id EntryID)
+		appendDocConjunction(id ConjID)
+		appendDocLabels(id DocID, labels []string)
+		appendDocNamespace(id DocID, ns string)
+		appendDocSortKey(id DocID, key int64)
+		appendConjFields(id ConjID, fields []uint64)
+		appendConjHash(id ConjID, hash uint64)
 		newFieldDescIfNeeded(field BEField) *FieldDesc
+		newAllOfFieldDescIfNeeded(field BEField, i int) *FieldDesc
+		newAbsentFieldDescIfNeeded(field BEField) *FieldDesc
 		newPostingEntriesIfNeeded(k int) *PostingEntries
+	}
+
+	BEIndex interface {
+		//interface used by builder
+		docIndexer
+		setMutationSeq(seq uint64)
 		completeIndex()
 
+		// interface used by auto compaction, see WithAutoCompact
+		cloneIndex() BEIndex
+		fragmentation() float64
+
+		// interface used by build report, see IndexerBuilder.BuildReport
+		fieldBuildReports() []FieldBuildReport
+
+		// interface used by manifest verification, see WithValueReverseLookup
+		valueReverseLookup() bool
+
 		//ConfigureIndexer public Interface
 		ConfigureIndexer(settings *IndexerSettings)
-		Retrieve(queries Assignments) (result DocIDList, err error)
+
+		// Retrieve docs matched by queries, a doc is listed once per conjunction matched it, see DocIDCollector
+		Retrieve(queries Assignments, opts ...IndexOpt) (result DocIDList, err error)
+
+		// RetrieveWithCollector a collector panic(*ErrCollectorPanic) or FallibleCollector error abort
+		// the retrieving and be returned, docs collected before kept in collector until Reset
+		RetrieveWithCollector(queries Assignments, collector ResultCollector, opts ...IndexOpt) error
+
+		// RetrieveRich like Retrieve but list a doc once, along with the most specific conjunction matched it, see RichDocID
+		RetrieveRich(queries Assignments, opts ...IndexOpt) (RichDocIDList, error)
+
+		// RetrieveWithAnalysis like Retrieve, along with the measured work of it, see RetrievalAnalysis
+		RetrieveWithAnalysis(queries Assignments, opts ...IndexOpt) (DocIDList, RetrievalAnalysis, error)
+
+		// RetrieveScoredPage a page of matched docs ordered by match quality(matched conjunction size,
+		// then sum of FieldOption.OrderWeight, then DocID), pass the NextToken of a page to get the
+		// next one, see ScoredPage; WithResultSort/WithMaxResults are ignored
+		RetrieveScoredPage(queries Assignments, pageSize int, token string, opts ...IndexOpt) (*ScoredPage, error)
+
+		// RetrieveCount count the docs Retrieve would return(WithMaxResults respected), without building the DocIDList
+		RetrieveCount(queries Assignments, opts ...IndexOpt) (int, error)
+
+		// EstimateCost a dry-run of Retrieve, estimate its cost by posting list lengths without
+		// scanning, so heavy queries(huge value lists) can be rejected or rerouted before running
+		EstimateCost(queries Assignments) (CostEstimate, error)
+
+		// DocConjunctions return the ConjIDs of document indexed, false if doc not in index
+		DocConjunctions(id DocID) ([]ConjID, bool)
+
+		// FindByConjunction return docs having a conjunction identical to conj(see Conjunction.Hash),
+		// eg: detect duplicated campaigns
+		FindByConjunction(conj *Conjunction) DocIDList
+
+		// WildcardEntries return a copy of the wildcard(size-0 conjunction) entries for auditing,
+		// decode them by EntryID/ConjID accessors, eg: GetConjID().Index(), IsInclude()
+		WildcardEntries() Entries
+
+		// WildcardDocs a page of docs matched by a empty assignment(what serve to everyone) sorted by
+		// id and the total count, offset >= 0 and limit > 0 required; pages are stable, so the pages
+		// concatenated are the whole set
+		WildcardDocs(offset, limit int) (DocIDList, int, error)
+
+		// FindOrphanEntries posting list entries of docs not in index(eg: left by a buggy migration),
+		// a diagnostic full scan for index integrity verification, see OrphanEntry
+		FindOrphanEntries() []OrphanEntry
+
+		// Compact drop duplicated entries and shrink posting lists to reduce memory, every group of
+		// posting lists rewritten as a copy then swapped in atomically, so Retrieve can run meanwhile;
+		// don't run two Compact of a index concurrently
+		Compact() (CompactStats, error)
+
+		// Stats return summary statistics of index, FieldDescriptions the configured fields
+		Stats() IndexStatistics
+		FieldDescriptions() FieldDescriptions
+
+		// SchemaVersion the schema version index built with, see WithSchemaVersion
+		SchemaVersion() string
+
+		// FieldFingerprint the fingerprint of field configuration index built with, see Manifest
+		FieldFingerprint() string
+
+		// StreamStats send a Stats snapshot right away then every interval for monitoring, a slow
+		// consumer get the latest one taken; the feeding goroutine stop(and close the channel) when
+		// ctx cancelled, interval <= 0 give a closed channel
+		StreamStats(ctx context.Context, interval time.Duration) <-chan IndexStatistics
+
+		// ExportDictionary write value->id mappings of field, so other system can tokenize query
+		// values(see parser.LoadDictionary and WithRawIDAssign)
+		ExportDictionary(field BEField, w io.Writer) error
+
+		// ExportInverted return value->docs(sorted) of field for offline analysis, value decoded by the
+		// field parser(must implement parser.Reversible) of index built WithValueReverseLookup; only
+		// include(In) postings exported
+		ExportInverted(field BEField) (map[string]DocIDList, error)
+
+		// RetrieveWithNearMiss retrieve matched docs along with conjunctions of unmatched docs missed
+		// by exactly one predicate(with the failing field), for loosening rules; it's a analysis tool
+		// of index built WithNearMissAnalysis
+		RetrieveWithNearMiss(queries Assignments, opts ...IndexOpt) (DocIDList, []NearMiss, error)
+
+		// DumpField dump posting lists of field page by page for bounded message size(eg: over RPC),
+		// pass the NextToken of a page to get the next one, see FieldDumpPage
+		DumpField(field BEField, token string, maxBytes int) (*FieldDumpPage, error)
+
+		// DumpFieldEntries debug api, all posting lists of field as a json array of KeyEntriesDump,
+		// for automating correctness checks across index versions
+		DumpFieldEntries(field BEField) ([]byte, error)
 
 		//DumpEntries debug api
 		DumpEntries() string
 		DumpEntriesSummary() string
+
+		// DumpQueryPlan debug api, the query side complement of DumpEntries: posting lists every
+		// field/value of queries resolved to
+		DumpQueryPlan(queries Assignments) string
 	}
 
 	indexBase struct {
 		idAllocator parser.IDAllocator
 		fieldDesc   map[BEField]*FieldDesc
 		idToField   map[uint64]*FieldDesc
+		docConjs    map[DocID][]ConjID // doc -> indexed conjunctions, ConjID carry index and size
+		allOfFields map[BEField]int    // field -> count of its InAll sub-fields, see allOfField
+
+		absentFields map[BEField]struct{} // fields have Absent expressions, see absentField
+
+		requiredFields map[BEField]BEField // field -> its prerequisite, see FieldOption.RequiresField
+
+		defaultOption FieldOption // option of fields not configured, see IndexerSettings.DefaultFieldOption
+
+		sharePostingLists bool // see IndexerSettings.SharePostingLists
+		noPanics          bool // see IndexerSettings.NoPanics
+		lazyCompile       bool // see IndexerSettings.LazyCompile
+		backgroundCompile bool // see IndexerSettings.BackgroundCompile
+		orderWeighted     bool // any field has FieldOption.OrderWeight
+
+		labelDocs map[string]map[DocID]struct{} // label -> docs carry it, see Document.Labels
+
+		docNamespaces map[DocID]string // docs not in default namespace, see IndexerBuilder.WithFieldNamespace
+
+		sortKeys map[DocID]int64 // see Document.SetSortKey
+
+		conjFields       map[ConjID][]uint64 // field ids of In expressions of conjunction, see nearMisses
+		nearMissAnalysis bool                // see IndexerSettings.NearMissAnalysis
+
+		conjHashes map[uint64][]ConjID // Conjunction.Hash -> indexed conjunctions, see FindByConjunction
+
+		mutationSeq uint64 // see IndexStatistics.MutationSeq
+
+		schemaVersion string // see IndexerSettings.SchemaVersion
+		fingerprint   string // see Manifest.FieldFingerprint
+		reverseLookup bool   // see IndexerSettings.ValueReverseLookup
+
+		wildcardDocs atomic.Value // DocIDList sorted, see WildcardDocs
 	}
 )
 
@@ -52,30 +336,178 @@ func (bi *indexBase) configureField(field BEField, option FieldOption) *FieldDes
 		panic(fmt.Errorf("can't configure field twice, bz field id can only match one ID"))
 	}
 
-	valueParser := parser.NewParser(option.Parser, bi.idAllocator)
-	if valueParser == nil {
-		valueParser = parser.NewParser(parser.CommonParser, bi.idAllocator)
+	valueParser, parserName, err := newFieldParser(field, option, bi.idAllocator)
+	if err != nil {
+		panic(err)
 	}
 	desc := &FieldDesc{
-		Field:  field,
-		Parser: valueParser,
-		ID:     uint64(len(bi.fieldDesc)),
+		Field:      field,
+		Parser:     valueParser,
+		ParserName: parserName,
+		ID:         uint64(len(bi.fieldDesc)),
+		option:     option,
 	}
 
 	bi.fieldDesc[field] = desc
 	bi.idToField[desc.ID] = desc
+	if option.OrderWeight != 0 {
+		bi.orderWeighted = true
+	}
+	bi.configureRequiredField(field, option.RequiresField)
 	Logger.Infof("configure field:%s, fieldID:%d\n", field, desc.ID)
 
 	return desc
 }
 
+// newFieldParser the parser of field with option, as the index configure it: a parser not registered
+// fall back to parser.CommonParser, ParserParams applied; it return the name of parser created
+func newFieldParser(field BEField, option FieldOption, alloc parser.IDAllocator) (parser.FieldValueParser, string, error) {
+	parserName := option.Parser
+	valueParser := parser.NewParser(parserName, alloc)
+	if valueParser == nil {
+		parserName = parser.CommonParser
+		valueParser = parser.NewParser(parserName, alloc)
+	}
+	if err := checkParserCapability(field, option, parserName, valueParser); err != nil {
+		return nil, "", err
+	}
+	if len(option.ParserParams) > 0 {
+		configurable, ok := valueParser.(parser.Configurable)
+		if !ok {
+			return nil, "", fmt.Errorf("field:%s parser:%s not accept params", field, option.Parser)
+		}
+		if err := configurable.Configure(option.ParserParams); err != nil {
+			return nil, "", fmt.Errorf("field:%s configure parser fail:%s", field, err.Error())
+		}
+	}
+	return valueParser, parserName, nil
+}
+
+// checkParserCapability check the parser of field provide the capabilities option required
+func checkParserCapability(field BEField, option FieldOption, parserName string, valueParser parser.FieldValueParser) error {
+	if !option.RangeQuery {
+		return nil
+	}
+	if aware, ok := valueParser.(parser.RangeAware); !ok || !aware.RangeAware() {
+		return fmt.Errorf("field:%s option RangeQuery require a range aware parser, parser:%s is not", field, parserName)
+	}
+	return nil
+}
+
+/*
+mergeFieldOption explicit option override the base: base.Parser(OrderWeight, Verify, MaxKeyEntries) used if option not set it;
+ParserParams merged(explicit one win) only when both use the same parser, params of a
+different parser are meaningless(even invalid) for the explicit one.
+*/
+func mergeFieldOption(base, option FieldOption) FieldOption {
+	if option.Parser == "" {
+		option.Parser = base.Parser
+	}
+	if option.OrderWeight == 0 {
+		option.OrderWeight = base.OrderWeight
+	}
+	if option.Verify == nil {
+		option.Verify = base.Verify
+	}
+	if option.Canonicalizer == nil {
+		option.Canonicalizer = base.Canonicalizer
+	}
+	if option.MaxKeyEntries == 0 {
+		option.MaxKeyEntries = base.MaxKeyEntries
+	}
+	if option.Parser != base.Parser || len(base.ParserParams) == 0 {
+		return option
+	}
+	params := make(map[string]string, len(base.ParserParams)+len(option.ParserParams))
+	for k, v := range base.ParserParams {
+		params[k] = v
+	}
+	for k, v := range option.ParserParams {
+		params[k] = v
+	}
+	option.ParserParams = params
+	return option
+}
+
+func (bi *indexBase) configureFields(settings *IndexerSettings) {
+	bi.defaultOption = settings.DefaultFieldOption
+	bi.defaultOption.RequiresField = "" // a hint of specific field, see FieldOption.RequiresField
+	bi.sharePostingLists = settings.SharePostingLists
+	bi.noPanics = settings.NoPanics
+	bi.lazyCompile = settings.LazyCompile
+	bi.backgroundCompile = settings.BackgroundCompile
+	bi.schemaVersion = settings.SchemaVersion
+	bi.fingerprint = fieldFingerprint(settings)
+	bi.reverseLookup = settings.ValueReverseLookup
+	bi.nearMissAnalysis = settings.NearMissAnalysis
+	for field, option := range settings.FieldConfig {
+		bi.configureField(field, mergeFieldOption(settings.DefaultFieldOption, option))
+	}
+}
+
 func (bi *indexBase) newFieldDescIfNeeded(field BEField) *FieldDesc {
 	if desc, ok := bi.fieldDesc[field]; ok {
 		return desc
 	}
-	return bi.configureField(field, FieldOption{
-		Parser: parser.CommonParser,
-	})
+	desc := bi.configureField(field, bi.defaultOption)
+	desc.autoCreated = true
+	return desc
+}
+
+// absentField the synthetic sub-field of Absent expressions on field, it has only one key(value id 0)
+// assigned by query which not include field, see parseQueries
+func absentField(field BEField) BEField {
+	return BEField(fmt.Sprintf("%s#absent", field))
+}
+
+func (bi *indexBase) newAbsentFieldDescIfNeeded(field BEField) *FieldDesc {
+	sub := absentField(field)
+	if desc, ok := bi.fieldDesc[sub]; ok {
+		return desc
+	}
+	if bi.absentFields == nil {
+		bi.absentFields = make(map[BEField]struct{})
+	}
+	bi.absentFields[field] = struct{}{}
+	return bi.configureField(sub, FieldOption{Parser: parser.CommonParser})
+}
+
+// allOfField the synthetic sub-field holding the i-th value of InAll expressions on field
+func allOfField(field BEField, i int) BEField {
+	return BEField(fmt.Sprintf("%s#all[%d]", field, i))
+}
+
+// newAllOfFieldDescIfNeeded sub-field share the parser option of field, so values get same ids
+func (bi *indexBase) newAllOfFieldDescIfNeeded(field BEField, i int) *FieldDesc {
+	sub := allOfField(field, i)
+	if desc, ok := bi.fieldDesc[sub]; ok {
+		return desc
+	}
+	option := bi.defaultOption
+	if desc, ok := bi.fieldDesc[field]; ok {
+		option = desc.option
+	}
+	if bi.allOfFields == nil {
+		bi.allOfFields = make(map[BEField]int)
+	}
+	if bi.allOfFields[field] < i+1 {
+		bi.allOfFields[field] = i + 1
+	}
+	return bi.configureField(sub, option)
+}
+
+func (bi *indexBase) appendDocConjunction(id ConjID) {
+	bi.docConjs[id.DocID()] = append(bi.docConjs[id.DocID()], id)
+}
+
+func (bi *indexBase) DocConjunctions(id DocID) ([]ConjID, bool) {
+	conjs, ok := bi.docConjs[id]
+	if !ok {
+		return nil, false
+	}
+	res := make([]ConjID, len(conjs))
+	copy(res, conjs)
+	return res, true
 }
 
 func (bi *indexBase) hasField(field BEField) bool {
@@ -94,9 +526,38 @@ func (bi *indexBase) getFieldFromID(v uint64) BEField {
 	return ""
 }
 
-// parse queries value to value id list
-func (bi *indexBase) parseQueries(queries Assignments) (map[BEField][]uint64, error) {
-	idAssigns := make(map[BEField][]uint64, len(queries))
+// fieldValueIDs all value ids of field indexed in lists, a WildcardValue assigned resolve to them
+func (bi *indexBase) fieldValueIDs(desc *FieldDesc, lists ...*PostingEntries) []uint64 {
+	seen := make(map[uint64]struct{})
+	for _, pl := range lists {
+		pl.eachList(func(key Key, _ Entries) {
+			if key.GetFieldID() == desc.ID {
+				seen[key.GetValueID()] = struct{}{}
+			}
+		})
+	}
+	ids := make([]uint64, 0, len(seen))
+	for id := range seen {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i] < ids[j]
+	})
+	return ids
+}
+
+// parseAssign parse a query value of field to value ids, WildcardValue resolved by lists
+func (bi *indexBase) parseAssign(desc *FieldDesc, value interface{}, lists ...*PostingEntries) ([]uint64, error) {
+	if value == WildcardValue {
+		return bi.fieldValueIDs(desc, lists...), nil
+	}
+	return desc.parseAssign(value)
+}
+
+// parse queries value to value id list, lists the posting lists retrieving on, see WildcardValue
+func (bi *indexBase) parseQueries(ctx *RetrieveContext, queries Assignments, lists ...*PostingEntries) (map[BEField][]uint64, error) {
+	queries = ctx.namespaceQueries(queries)
+	idAssigns := make(map[BEField][]uint64, len(queries)+len(ctx.bitsetAssigns))
 
 	for field, values := range queries {
 		if !bi.hasField(field) {
@@ -110,14 +571,122 @@ func (bi *indexBase) parseQueries(queries Assignments) (map[BEField][]uint64, er
 
 		res := make([]uint64, 0, len(values))
 		for _, value := range values {
-			ids, err := desc.Parser.ParseAssign(value)
+			ids, err := bi.parseAssign(desc, value, lists...)
 			if err != nil {
 				Logger.Errorf("field:%s, value:%+v can't be parsed, err:%s\n", field, value, err.Error())
-				return nil, fmt.Errorf("query assign parse fail,field:%s e:%s\n", field, err.Error())
+				err = fmt.Errorf("query assign parse fail,field:%s e:%w\n", field, err)
+				if ctx.dropField(field, err) {
+					break
+				}
+				return nil, err
 			}
+			ctx.recordQValue(desc, field, value, ids)
+			ctx.recordApproximate(desc, field, value, ids)
 			res = append(res, ids...)
 		}
 		idAssigns[field] = res
 	}
+
+	for field, cnt := range bi.allOfFields {
+		values, ok := queries[field]
+		if !ok {
+			continue
+		}
+		for i := 0; i < cnt; i++ {
+			sub := allOfField(field, i)
+			desc := bi.fieldDesc[sub]
+			res := make([]uint64, 0, len(values))
+			for _, value := range values {
+				ids, err := bi.parseAssign(desc, value, lists...)
+				if err != nil {
+					err = fmt.Errorf("query assign parse fail,field:%s e:%w\n", sub, err)
+					if ctx.dropField(field, err) {
+						break
+					}
+					return nil, err
+				}
+				ctx.recordQValue(desc, field, value, ids)
+				ctx.recordApproximate(desc, field, value, ids)
+				res = append(res, ids...)
+			}
+			idAssigns[sub] = res
+		}
+	}
+
+	for field, bits := range ctx.bitsetAssigns {
+		desc, ok := bi.fieldDesc[field]
+		if !ok {
+			continue
+		}
+		var err error
+		res := idAssigns[field]
+		bits.ForEach(func(v uint64) bool {
+			var ids []uint64
+			if ids, err = desc.parseAssign(v); err != nil {
+				err = fmt.Errorf("query bitset parse fail,field:%s value:%d e:%s\n", field, v, err.Error())
+				return false
+			}
+			ctx.recordQValue(desc, field, v, ids)
+			ctx.recordApproximate(desc, field, v, ids)
+			res = append(res, ids...)
+			return true
+		})
+		if err != nil {
+			Logger.Errorf(err.Error())
+			if !ctx.dropField(field, err) {
+				return nil, err
+			}
+		}
+		idAssigns[field] = res
+	}
+
+	for field, ids := range ctx.rawIDAssigns {
+		if !bi.hasField(field) {
+			continue
+		}
+		for _, id := range ids {
+			if id > MaxBEValueID {
+				err := fmt.Errorf("raw id assign out of value range,field:%s id:%d", field, id)
+				if ctx.dropField(field, err) {
+					break
+				}
+				return nil, err
+			}
+			ctx.recordQValue(bi.fieldDesc[field], field, id, []uint64{id})
+		}
+		idAssigns[field] = append(idAssigns[field], ids...)
+	}
+
+	// dropped fields are still assigned(not absent), but no cursor created for them
+	for field := range ctx.droppedFields {
+		bi.removeAssign(idAssigns, field)
+	}
+	bi.skipDependentFields(ctx, queries, idAssigns)
+
+	for field := range bi.absentFields {
+		if ctx.assigned(queries, field) {
+			continue
+		}
+		idAssigns[absentField(field)] = []uint64{0}
+	}
 	return idAssigns, nil
 }
+
+// hasPositivePredicate whether any assigned value hit a include entry of lists, which means the
+// query can select a conjunction by its In expressions, not only by wildcard(size-0) conjunctions
+func (bi *indexBase) hasPositivePredicate(idAssigns map[BEField][]uint64, lists ...*PostingEntries) bool {
+	for field, ids := range idAssigns {
+		desc := bi.fieldDesc[field]
+		for _, id := range ids {
+			key := NewKey(desc.ID, id)
+			for _, pl := range lists {
+				for _, eid := range pl.getEntries(key) {
+					if eid.IsInclude() {
+						return true
+					}
+				}
+			}
+		}
+	}
+	return false
+}
@@ -11,7 +11,7 @@ type (
 	CompactedBEIndex struct {
 		indexBase
 		wildcardKey     Key
-		wildcardEntries Entries
+		wildcardEntries atomicEntries
 		postingList     *PostingEntries
 	}
 )
@@ -22,12 +22,11 @@ func NewCompactedBEIndex(idGen parser.IDAllocator) BEIndex {
 			idAllocator: idGen,
 			fieldDesc:   make(map[BEField]*FieldDesc),
 			idToField:   make(map[uint64]*FieldDesc),
+			docConjs:    make(map[DocID][]ConjID),
 		},
-		postingList: &PostingEntries{
-			plEntries: make(map[Key]Entries, 0),
-		},
+		postingList: newPostingEntries(make(map[Key]Entries)),
 	}
-	wildcardDesc := index.configureField("__wildcard__", FieldOption{
+	wildcardDesc := index.configureField(wildcardField, FieldOption{
 		Parser: parser.CommonParser,
 	})
 	index.wildcardKey = NewKey(wildcardDesc.ID, 0)
@@ -35,13 +34,11 @@ func NewCompactedBEIndex(idGen parser.IDAllocator) BEIndex {
 }
 
 func (bi *CompactedBEIndex) ConfigureIndexer(settings *IndexerSettings) {
-	for field, option := range settings.FieldConfig {
-		bi.configureField(field, option)
-	}
+	bi.configureFields(settings)
 }
 
 func (bi *CompactedBEIndex) appendWildcardEntryID(id EntryID) {
-	bi.wildcardEntries = append(bi.wildcardEntries, id)
+	bi.wildcardEntries.store(append(bi.wildcardEntries.load(), id))
 }
 
 //newPostingEntriesIfNeeded(k int) *PostingEntries
@@ -51,57 +48,100 @@ func (bi *CompactedBEIndex) newPostingEntriesIfNeeded(k int) *PostingEntries {
 }
 
 func (bi *CompactedBEIndex) completeIndex() {
-	if bi.wildcardEntries.Len() > 0 {
-		sort.Sort(bi.wildcardEntries)
+	if wildcards := bi.wildcardEntries.load(); wildcards.Len() > 0 {
+		sort.Sort(wildcards)
+	}
+	if bi.lazyCompile && !bi.sharePostingLists {
+		bi.postingList.lazy = &lazyCompile{}
+		if bi.backgroundCompile {
+			go compileGroups(bi.postingList)
+		}
+		return
 	}
 	bi.postingList.makeEntriesSorted()
+	if bi.sharePostingLists {
+		bi.postingList.shareEntries()
+	} else {
+		bi.postingList.inlineSmallLists()
+	}
 }
 
-// parse queries value to value id list
-func (bi *CompactedBEIndex) parseQueries(queries Assignments) (map[BEField][]uint64, error) {
-	idAssigns := make(map[BEField][]uint64, len(queries))
-
-	for field, values := range queries {
-		if !bi.hasField(field) {
-			continue
-		}
+func (bi *CompactedBEIndex) Compact() (stats CompactStats, err error) {
+	compileGroups(bi.postingList)
+	bi.wildcardEntries.store(compactEntries(bi.wildcardEntries.load(), &stats))
+	bi.postingList.compact(&stats)
+	return stats, nil
+}
 
-		desc, ok := bi.fieldDesc[field]
-		if !ok { //no such field, ignore it(ps: bz it will not match any doc)
-			continue
+func (bi *CompactedBEIndex) Retrieve(queries Assignments, opts ...IndexOpt) (result DocIDList, err error) {
+	ctx := newRetrieveContext(opts...)
+	collector := bi.newDefaultCollector(ctx)
+	if err = bi.retrieve(ctx, queries, collector); err != nil {
+		if ctx.partialResult(err) && !ctx.complement {
+			return ctx.arrangeResult(collector.GetDocIDs()), err
 		}
+		return nil, err
+	}
+	return bi.retrieveResult(ctx, collector.GetDocIDs()), nil
+}
 
-		res := make([]uint64, 0, len(values))
-		for _, value := range values {
-			ids, err := desc.Parser.ParseAssign(value)
-			if err != nil {
-				Logger.Errorf("field:%s, value:%+v can't be parsed, err:%s\n", field, value, err.Error())
-				return nil, fmt.Errorf("query assign parse fail,field:%s e:%s\n", field, err.Error())
-			}
-			res = append(res, ids...)
+func (bi *CompactedBEIndex) RetrieveCount(queries Assignments, opts ...IndexOpt) (count int, err error) {
+	ctx := newRetrieveContext(opts...)
+	collector := &countCollector{}
+	if err = bi.retrieve(ctx, queries, collector); err != nil {
+		if ctx.partialResult(err) {
+			return ctx.arrangeCount(collector.Count()), err
 		}
-		idAssigns[field] = res
+		return 0, err
 	}
-	return idAssigns, nil
+	return ctx.arrangeCount(collector.Count()), nil
 }
 
-func (bi *CompactedBEIndex) Retrieve(queries Assignments) (result DocIDList, err error) {
+func (bi *CompactedBEIndex) RetrieveWithCollector(queries Assignments, collector ResultCollector, opts ...IndexOpt) (err error) {
+	return bi.retrieve(newRetrieveContext(opts...), queries, collector)
+}
 
-	idAssigns, err := bi.parseQueries(queries)
-	if err != nil {
-		Logger.Errorf("invalid query assigns:%s", err.Error())
-		return nil, err
+func (bi *CompactedBEIndex) retrieve(ctx *RetrieveContext, queries Assignments, collector ResultCollector) (err error) {
+	if bi.noPanics {
+		ctx.recoverPanic = true
+	}
+	defer ctx.recoverFromPanic(&err)
+	defer ctx.finishTrace()
+	defer ctx.warnDroppedFields(&err)
+	defer ctx.flushMostSpecific(collector, &err)
+
+	if err = bi.checkSchema(ctx); err != nil {
+		return err
+	}
+	if ctx.twoPass {
+		if err = ctx.coarsePass(bi.retrieve); err != nil {
+			return err
+		}
+		if len(ctx.candidates) == 0 {
+			return nil
+		}
 	}
 
-	ctx := &RetrieveContext{
-		idAssigns: idAssigns,
+	if ctx.idAssigns, err = bi.parseQueries(ctx, queries, bi.postingList); err != nil {
+		Logger.Errorf("invalid query assigns:%s", err.Error())
+		return err
+	}
+	ctx.sortKeys = bi.sortKeys
+	ctx.docNamespaces = bi.docNamespaces
+	ctx.chargeIDAssigns()
+	if !ctx.resolveLabelFilter(&bi.indexBase) {
+		return nil // some label carried by no doc, nothing can pass the filter
+	}
+	bi.postingList.compile()
+	if ctx.requirePositive && !bi.hasPositivePredicate(ctx.idAssigns, bi.postingList) {
+		return ErrNoPositivePredicate
 	}
 
 	fieldScanners := make(FieldScanners, 0, len(ctx.idAssigns))
 
-	if len(bi.wildcardEntries) > 0 {
-		pl := NewEntriesCursor(bi.wildcardKey, bi.wildcardEntries)
-		fieldScanners = append(fieldScanners, NewFieldScanner(pl))
+	if len(bi.wildcardEntries.load()) > 0 {
+		pl := ctx.newEntriesCursor(bi.wildcardKey, bi.wildcardEntries.load())
+		fieldScanners = append(fieldScanners, ctx.newFieldScanner(bi.fieldDesc[wildcardField], pl))
 	}
 
 	for field, ids := range ctx.idAssigns {
@@ -109,22 +149,29 @@ func (bi *CompactedBEIndex) Retrieve(queries Assignments) (result DocIDList, err
 
 		entriesScanners := make(CursorGroup, 0, len(ids))
 		for _, id := range ids {
-			key := NewKey(desc.ID, id)
-			if entries := bi.postingList.getEntries(key); len(entries) > 0 {
-				entriesScanners = append(entriesScanners, NewEntriesCursor(key, entries))
-			}
+			entriesScanners = ctx.appendKeyCursors(entriesScanners, NewKey(desc.ID, id), bi.postingList)
 		}
 		if len(entriesScanners) > 0 {
-			fieldScanners = append(fieldScanners, NewFieldScanner(entriesScanners...))
+			fieldScanners = append(fieldScanners, ctx.newFieldScanner(desc, entriesScanners...))
 		}
 	}
 
 	if len(fieldScanners) == 0 {
-		return result, nil
+		return nil
 	}
+	if ctx.budgetExceeded() {
+		return ErrBudgetExceeded
+	}
+	ctx.traceGroupScanned()
+	return retrieveMixedSize(ctx, fieldScanners, collector)
+}
 
-	result = make([]DocID, 0, 128)
-
+/*
+retrieveMixedSize retrieve from fieldScanners holding entries of conjunctions with different size,
+K of every conjunction is taken from its ConjID; ConjID ordered by size first, so once the K of
+min entry exceed the count of scanners, no more conjunction can match
+*/
+func retrieveMixedSize(ctx *RetrieveContext, fieldScanners FieldScanners, collector ResultCollector) (err error) {
 	fieldScanners.Sort()
 RETRIEVE:
 	for {
@@ -149,13 +196,19 @@ RETRIEVE:
 		endEID := fieldScanners[k-1].GetCurEntryID()
 
 		nextID := NewEntryID(endEID.GetConjID(), false)
-		if endEID.GetConjID() == eid.GetConjID() {
+		if skip, next := ctx.filterCandidate(eid.GetConjID()); skip {
+			if skipID := NewEntryID(next, false); skipID > nextID {
+				nextID = skipID
+			}
+		} else if endEID.GetConjID() == eid.GetConjID() {
 
 			nextID = endEID + 1
 
 			if eid.IsInclude() {
 
-				result = append(result, eid.GetConjID().DocID())
+				if err = ctx.collect(collector, eid.GetConjID().DocID(), eid.GetConjID(), fieldScanners[:k]); err != nil {
+					return err
+				}
 
 			} else { //exclude
 
@@ -164,6 +217,10 @@ RETRIEVE:
 						break
 					}
 					fieldScanners[i].Skip(nextID)
+					ctx.advancements++
+					if ctx.analysis != nil {
+						ctx.analyzeAdvance(fieldScanners[i])
+					}
 				}
 			}
 		}
@@ -171,35 +228,49 @@ RETRIEVE:
 		for i := 0; i < k; i++ {
 			fieldScanners[i].SkipTo(nextID)
 		}
+		ctx.advancements += int64(k)
+		if ctx.analysis != nil {
+			ctx.analyzeIteration(eid.GetConjID().Size(), fieldScanners[:k])
+		}
+		if ctx.budgetExceeded() {
+			return ErrBudgetExceeded
+		}
 
 		fieldScanners.Sort()
 	}
-	return result, nil
+	return nil
 }
 
 func (bi *CompactedBEIndex) DumpEntriesSummary() string {
+	bi.postingList.compile()
 	sb := strings.Builder{}
-	sb.WriteString(fmt.Sprintf("wildcard entries length:%d >>>>>>\n", len(bi.wildcardEntries)))
+	sb.WriteString(fmt.Sprintf("wildcard entries length:%d >>>>>>\n", len(bi.wildcardEntries.load())))
 	ues := bi.postingList
-	sb.WriteString(fmt.Sprintf("postingList avgLen:%d maxLen:%d >>>>>>\n", ues.avgLen, ues.maxLen))
+	avgLen, maxLen := ues.lenStats()
+	sb.WriteString(fmt.Sprintf("postingList avgLen:%d maxLen:%d >>>>>>\n", avgLen, maxLen))
 	return sb.String()
 }
 
+func (bi *CompactedBEIndex) WildcardEntries() Entries {
+	return append(make(Entries, 0, len(bi.wildcardEntries.load())), bi.wildcardEntries.load()...)
+}
+
 func (bi *CompactedBEIndex) DumpEntries() string {
+	bi.postingList.compile()
 	sb := strings.Builder{}
 
 	sb.WriteString(fmt.Sprintf("Z:>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>\n"))
 	sb.WriteString(bi.StringKey(bi.wildcardKey))
 	sb.WriteString(":")
-	sb.WriteString(fmt.Sprintf("%v", bi.wildcardEntries.DocString()))
+	sb.WriteString(fmt.Sprintf("%v", bi.wildcardEntries.load().DocString()))
 	sb.WriteString("\n")
-	sb.WriteString(fmt.Sprintf("postingList avgLen:%d maxLen:%d >>>>>>\n",
-		bi.postingList.avgLen, bi.postingList.maxLen))
-	for k, entries := range bi.postingList.plEntries {
+	avgLen, maxLen := bi.postingList.lenStats()
+	sb.WriteString(fmt.Sprintf("postingList avgLen:%d maxLen:%d >>>>>>\n", avgLen, maxLen))
+	bi.postingList.eachList(func(k Key, entries Entries) {
 		sb.WriteString(bi.StringKey(k))
 		sb.WriteString(":")
 		sb.WriteString(fmt.Sprintf("%v", entries.DocString()))
 		sb.WriteString("\n")
-	}
+	})
 	return sb.String()
 }
@@ -13,7 +13,10 @@ type (
 		indexBase
 		sizeEntries     []*PostingEntries
 		wildcardKey     Key
-		wildcardEntries Entries
+		wildcardEntries atomicEntries
+
+		mergeThreshold int          // see WithKGroupMerging
+		mergedGroups   map[int]bool // K groups holding conjunctions of bigger size folded in
 	}
 )
 
@@ -23,10 +26,11 @@ func NewSizeGroupedBEIndex(idGen parser.IDAllocator) BEIndex {
 			idAllocator: idGen,
 			fieldDesc:   make(map[BEField]*FieldDesc),
 			idToField:   make(map[uint64]*FieldDesc),
+			docConjs:    make(map[DocID][]ConjID),
 		},
 		sizeEntries: make([]*PostingEntries, 0),
 	}
-	wildcardDesc := index.configureField("__wildcard__", FieldOption{
+	wildcardDesc := index.configureField(wildcardField, FieldOption{
 		Parser: parser.CommonParser,
 	})
 	index.wildcardKey = NewKey(wildcardDesc.ID, 0)
@@ -34,9 +38,7 @@ func NewSizeGroupedBEIndex(idGen parser.IDAllocator) BEIndex {
 }
 
 func (bi *SizeGroupedBEIndex) ConfigureIndexer(settings *IndexerSettings) {
-	for field, option := range settings.FieldConfig {
-		bi.configureField(field, option)
-	}
+	bi.configureFields(settings)
 }
 
 func (bi *SizeGroupedBEIndex) maxK() int {
@@ -45,27 +47,82 @@ func (bi *SizeGroupedBEIndex) maxK() int {
 
 //GetOrNewSizeEntries(k int) *PostingEntries
 func (bi *SizeGroupedBEIndex) appendWildcardEntryID(id EntryID) {
-	bi.wildcardEntries = append(bi.wildcardEntries, id)
+	bi.wildcardEntries.store(append(bi.wildcardEntries.load(), id))
 }
 
 //GetOrNewSizeEntries(k int) *PostingEntries
 func (bi *SizeGroupedBEIndex) newPostingEntriesIfNeeded(k int) *PostingEntries {
 	for k >= len(bi.sizeEntries) {
-		newSizeEntries := &PostingEntries{
-			plEntries: make(map[Key]Entries),
-		}
-		bi.sizeEntries = append(bi.sizeEntries, newSizeEntries)
+		bi.sizeEntries = append(bi.sizeEntries, newPostingEntries(make(map[Key]Entries)))
 	}
 	return bi.sizeEntries[k]
 }
 
 func (bi *SizeGroupedBEIndex) completeIndex() {
+	if bi.mergeThreshold > 0 {
+		bi.mergeSparseGroups()
+	}
+	lazy := bi.lazyCompile && !bi.sharePostingLists
 	for _, sizeEntries := range bi.sizeEntries {
+		if lazy {
+			sizeEntries.lazy = &lazyCompile{}
+			continue
+		}
 		sizeEntries.makeEntriesSorted()
+		if bi.sharePostingLists {
+			sizeEntries.shareEntries()
+		} else {
+			sizeEntries.inlineSmallLists()
+		}
+	}
+	if wildcards := bi.wildcardEntries.load(); wildcards.Len() > 0 {
+		sort.Sort(wildcards)
+	}
+	if lazy && bi.backgroundCompile {
+		go compileGroups(bi.sizeEntries...)
+	}
+}
+
+// mergeSparseGroups fold K(>1) groups with fewer than mergeThreshold conjunctions into the nearest
+// lower kept group, the folded conjunctions keep their size in ConjID, see retrieveMixedSize
+func (bi *SizeGroupedBEIndex) mergeSparseGroups() {
+	counts := make([]int, len(bi.sizeEntries))
+	for _, conjs := range bi.docConjs {
+		for _, conj := range conjs {
+			counts[conj.Size()]++
+		}
+	}
+	target := -1
+	for k := 1; k < len(bi.sizeEntries); k++ {
+		if counts[k] == 0 {
+			continue
+		}
+		if target < 0 || counts[k] >= bi.mergeThreshold {
+			target = k
+			continue
+		}
+		dst := bi.sizeEntries[target]
+		bi.sizeEntries[k].eachList(func(key Key, entries Entries) {
+			for _, id := range entries {
+				dst.AppendEntryID(key, id)
+			}
+		})
+		bi.sizeEntries[k] = newPostingEntries(make(map[Key]Entries))
+		if bi.mergedGroups == nil {
+			bi.mergedGroups = make(map[int]bool)
+		}
+		bi.mergedGroups[target] = true
+		Logger.Infof("fold K group:%d(%d conjunctions) into group:%d\n", k, counts[k], target)
 	}
-	if bi.wildcardEntries.Len() > 0 {
-		sort.Sort(bi.wildcardEntries)
+}
+
+func (bi *SizeGroupedBEIndex) Compact() (stats CompactStats, err error) {
+	compileGroups(bi.sizeEntries...)
+	bi.wildcardEntries.store(compactEntries(bi.wildcardEntries.load(), &stats))
+	for _, sizeEntries := range bi.sizeEntries {
+		sizeEntries.compact(&stats)
 	}
+	return stats, nil
 }
 
 func (bi *SizeGroupedBEIndex) getKSizeEntries(k int) *PostingEntries {
@@ -79,9 +136,9 @@ func (bi *SizeGroupedBEIndex) initPlEntriesScanners(ctx *RetrieveContext, k int)
 
 	fieldScanners := make(FieldScanners, 0, len(ctx.idAssigns))
 
-	if k == 0 && len(bi.wildcardEntries) > 0 {
-		pl := NewEntriesCursor(bi.wildcardKey, bi.wildcardEntries)
-		fieldScanners = append(fieldScanners, NewFieldScanner(pl))
+	if k == 0 && len(bi.wildcardEntries.load()) > 0 {
+		pl := ctx.newEntriesCursor(bi.wildcardKey, bi.wildcardEntries.load())
+		fieldScanners = append(fieldScanners, ctx.newFieldScanner(bi.fieldDesc[wildcardField], pl))
 	}
 
 	kSizeEntries := bi.getKSizeEntries(k)
@@ -91,22 +148,17 @@ func (bi *SizeGroupedBEIndex) initPlEntriesScanners(ctx *RetrieveContext, k int)
 
 		pls := make(CursorGroup, 0, len(ids))
 		for _, id := range ids {
-			key := NewKey(desc.ID, id)
-			if entries := kSizeEntries.getEntries(key); len(entries) > 0 {
-				pls = append(pls, NewEntriesCursor(key, entries))
-			}
+			pls = ctx.appendKeyCursors(pls, NewKey(desc.ID, id), kSizeEntries)
 		}
 		if len(pls) > 0 {
-			fieldScanners = append(fieldScanners, NewFieldScanner(pls...))
+			fieldScanners = append(fieldScanners, ctx.newFieldScanner(desc, pls...))
 		}
 	}
 	return fieldScanners
 }
 
 // retrieveK MOVE TO: FieldScanners ?
-func (bi *SizeGroupedBEIndex) retrieveK(fieldScanners FieldScanners, k int) (result []DocID) {
-	result = make([]DocID, 0, 256)
-
+func (bi *SizeGroupedBEIndex) retrieveK(ctx *RetrieveContext, fieldScanners FieldScanners, k int, collector ResultCollector) error {
 	//sort.Sort(fieldScanners)
 	fieldScanners.Sort()
 	for !fieldScanners[k-1].GetCurEntryID().IsNULLEntry() {
@@ -116,10 +168,16 @@ func (bi *SizeGroupedBEIndex) retrieveK(fieldScanners FieldScanners, k int) (res
 
 		nextID := NewEntryID(endEID.GetConjID(), false)
 
-		if eid.GetConjID() == endEID.GetConjID() {
+		if skip, next := ctx.filterCandidate(eid.GetConjID()); skip {
+			if skipID := NewEntryID(next, false); skipID > nextID {
+				nextID = skipID
+			}
+		} else if eid.GetConjID() == endEID.GetConjID() {
 			nextID = endEID + 1
 			if eid.IsInclude() {
-				result = append(result, eid.GetConjID().DocID())
+				if err := ctx.collect(collector, eid.GetConjID().DocID(), eid.GetConjID(), fieldScanners[:k]); err != nil {
+					return err
+				}
 			} else { //exclude
 
 				for i := k; i < fieldScanners.Len(); i++ {
@@ -127,6 +185,10 @@ func (bi *SizeGroupedBEIndex) retrieveK(fieldScanners FieldScanners, k int) (res
 						break
 					}
 					fieldScanners[i].Skip(nextID)
+					ctx.advancements++
+					if ctx.analysis != nil {
+						ctx.analyzeAdvance(fieldScanners[i])
+					}
 				}
 
 			}
@@ -135,26 +197,90 @@ func (bi *SizeGroupedBEIndex) retrieveK(fieldScanners FieldScanners, k int) (res
 		for i := 0; i < k; i++ {
 			fieldScanners[i].SkipTo(nextID)
 		}
+		ctx.advancements += int64(k)
+		if ctx.analysis != nil {
+			ctx.analyzeIteration(eid.GetConjID().Size(), fieldScanners[:k])
+		}
+		if ctx.budgetExceeded() {
+			return ErrBudgetExceeded
+		}
 		//sort.Sort(fieldScanners)
 		fieldScanners.Sort()
 	}
-	return result
+	return nil
 }
 
-func (bi *SizeGroupedBEIndex) Retrieve(queries Assignments) (result DocIDList, err error) {
-
-	idAssigns, err := bi.parseQueries(queries)
-	if err != nil {
-		Logger.Errorf("invalid query assigns:%s", err.Error())
+func (bi *SizeGroupedBEIndex) Retrieve(queries Assignments, opts ...IndexOpt) (result DocIDList, err error) {
+	ctx := newRetrieveContext(opts...)
+	collector := bi.newDefaultCollector(ctx)
+	if err = bi.retrieve(ctx, queries, collector); err != nil {
+		if ctx.partialResult(err) && !ctx.complement {
+			return ctx.arrangeResult(collector.GetDocIDs()), err
+		}
 		return nil, err
 	}
+	return bi.retrieveResult(ctx, collector.GetDocIDs()), nil
+}
 
-	ctx := &RetrieveContext{
-		idAssigns: idAssigns,
+func (bi *SizeGroupedBEIndex) RetrieveCount(queries Assignments, opts ...IndexOpt) (count int, err error) {
+	ctx := newRetrieveContext(opts...)
+	collector := &countCollector{}
+	if err = bi.retrieve(ctx, queries, collector); err != nil {
+		if ctx.partialResult(err) {
+			return ctx.arrangeCount(collector.Count()), err
+		}
+		return 0, err
 	}
+	return ctx.arrangeCount(collector.Count()), nil
+}
 
-	for k := util.MinInt(queries.Size(), bi.maxK()); k >= 0; k-- {
+func (bi *SizeGroupedBEIndex) RetrieveWithCollector(queries Assignments, collector ResultCollector, opts ...IndexOpt) (err error) {
+	return bi.retrieve(newRetrieveContext(opts...), queries, collector)
+}
 
+func (bi *SizeGroupedBEIndex) retrieve(ctx *RetrieveContext, queries Assignments, collector ResultCollector) (err error) {
+	if bi.noPanics {
+		ctx.recoverPanic = true
+	}
+	defer ctx.recoverFromPanic(&err)
+	defer ctx.finishTrace()
+	defer ctx.warnDroppedFields(&err)
+	defer ctx.flushMostSpecific(collector, &err)
+
+	if err = bi.checkSchema(ctx); err != nil {
+		return err
+	}
+	if ctx.twoPass {
+		if err = ctx.coarsePass(bi.retrieve); err != nil {
+			return err
+		}
+		if len(ctx.candidates) == 0 {
+			return nil
+		}
+	}
+
+	if ctx.idAssigns, err = bi.parseQueries(ctx, queries, bi.sizeEntries...); err != nil {
+		Logger.Errorf("invalid query assigns:%s", err.Error())
+		return err
+	}
+	ctx.sortKeys = bi.sortKeys
+	ctx.docNamespaces = bi.docNamespaces
+	ctx.chargeIDAssigns()
+	if !ctx.resolveLabelFilter(&bi.indexBase) {
+		return nil // some label carried by no doc, nothing can pass the filter
+	}
+	if ctx.requirePositive {
+		compileGroups(bi.sizeEntries...)
+		if !bi.hasPositivePredicate(ctx.idAssigns, bi.sizeEntries...) {
+			return ErrNoPositivePredicate
+		}
+	}
+
+	for k := util.MinInt(len(ctx.idAssigns), bi.maxK()); k >= 0; k-- {
+
+		if !bi.compileIfNeeded(ctx, k) {
+			continue
+		}
 		fieldScanners := bi.initPlEntriesScanners(ctx, k)
 
 		tempK := k
@@ -164,37 +290,55 @@ func (bi *SizeGroupedBEIndex) Retrieve(queries Assignments) (result DocIDList, e
 		if len(fieldScanners) < tempK {
 			continue
 		}
-		res := bi.retrieveK(fieldScanners, tempK)
-		result = append(result, res...)
+		if ctx.budgetExceeded() {
+			return ErrBudgetExceeded
+		}
+		ctx.traceGroupScanned()
+		if bi.mergedGroups[k] {
+			err = retrieveMixedSize(ctx, fieldScanners, collector)
+		} else {
+			err = bi.retrieveK(ctx, fieldScanners, tempK, collector)
+		}
+		if err != nil {
+			return err
+		}
 	}
-	return result, nil
+	return nil
+}
+
+func (bi *SizeGroupedBEIndex) WildcardEntries() Entries {
+	return append(make(Entries, 0, len(bi.wildcardEntries.load())), bi.wildcardEntries.load()...)
 }
 
 func (bi *SizeGroupedBEIndex) DumpEntries() string {
+	compileGroups(bi.sizeEntries...)
 	sb := strings.Builder{}
 	sb.WriteString(fmt.Sprintf("Z:>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>\n"))
 	sb.WriteString(bi.StringKey(bi.wildcardKey))
 	sb.WriteString(":")
-	sb.WriteString(fmt.Sprintf("%v", bi.wildcardEntries.DocString()))
+	sb.WriteString(fmt.Sprintf("%v", bi.wildcardEntries.load().DocString()))
 	sb.WriteString("\n")
 
 	for idx, ke := range bi.sizeEntries {
-		sb.WriteString(fmt.Sprintf("K:%d  avgLen:%d maxLen:%d >>>>>>\n", idx, ke.avgLen, ke.maxLen))
-		for k, entries := range ke.plEntries {
+		avgLen, maxLen := ke.lenStats()
+		sb.WriteString(fmt.Sprintf("K:%d  avgLen:%d maxLen:%d >>>>>>\n", idx, avgLen, maxLen))
+		ke.eachList(func(k Key, entries Entries) {
 			sb.WriteString(bi.StringKey(k))
 			sb.WriteString(":")
 			sb.WriteString(fmt.Sprintf("%v", entries.DocString()))
 			sb.WriteString("\n")
-		}
+		})
 	}
 	return sb.String()
 }
 
 func (bi *SizeGroupedBEIndex) DumpEntriesSummary() string {
+	compileGroups(bi.sizeEntries...)
 	sb := strings.Builder{}
-	sb.WriteString(fmt.Sprintf("wildcard entries length:%d >>>>>>\n", len(bi.wildcardEntries)))
+	sb.WriteString(fmt.Sprintf("wildcard entries length:%d >>>>>>\n", len(bi.wildcardEntries.load())))
 	for k, kse := range bi.sizeEntries {
-		sb.WriteString(fmt.Sprintf("SizeEntries k:%d avgLen:%d maxLen:%d >>>>>>\n", k, kse.avgLen, kse.maxLen))
+		avgLen, maxLen := kse.lenStats()
+		sb.WriteString(fmt.Sprintf("SizeEntries k:%d avgLen:%d maxLen:%d >>>>>>\n", k, avgLen, maxLen))
 	}
 	return sb.String()
 }
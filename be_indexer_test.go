@@ -2,12 +2,16 @@ package be_indexer
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"github.com/echoface/be_indexer/parser"
 	"github.com/echoface/be_indexer/util"
 	"github.com/smartystreets/goconvey/convey"
 	"io/ioutil"
 	"math/rand"
 	"sort"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -492,7 +496,9 @@ func TestBEIndex_Retrieve3(t *testing.T) {
 	}
 
 	index := &SizeGroupedBEIndex{}
-	fmt.Println(index.retrieveK(plgs, 2))
+	collector := NewDocIDCollector()
+	_ = index.retrieveK(newRetrieveContext(), plgs, 2, collector)
+	fmt.Println(collector.GetDocIDs())
 }
 
 func TestBEIndex_Retrieve4(t *testing.T) {
@@ -595,3 +601,812 @@ func TestBEIndex_Retrieve5(t *testing.T) {
 		convey.So(err, convey.ShouldBeNil)
 	})
 }
+
+func init() {
+	parser.RegisterBuilder("test_geo_region", parser.NewGeoRegionParserBuilder(map[string]string{
+		"San Francisco": "California",
+		"California":    "US",
+		"Seattle":       "Washington",
+		"Washington":    "US",
+	}))
+}
+
+func TestBEIndex_GeoRegionParser(t *testing.T) {
+	LogLevel = ErrorLevel
+
+	convey.Convey("test city query match state level rule", t, func() {
+		builder := NewIndexerBuilder()
+		builder.SetFieldParser("region", "test_geo_region")
+
+		doc := NewDocument(1)
+		doc.AddConjunction(NewConjunction().In("region", NewStrValues("California")))
+		builder.AddDocument(doc)
+
+		for _, index := range []BEIndex{builder.BuildIndex(), builder.BuildCompactedIndex()} {
+			ids, err := index.Retrieve(Assignments{"region": NewStrValues("San Francisco")})
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(ids, convey.ShouldResemble, DocIDList{1})
+
+			ids, err = index.Retrieve(Assignments{"region": NewStrValues("Seattle")})
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(len(ids), convey.ShouldEqual, 0)
+		}
+	})
+	convey.Convey("test levels param limit how far a query region resolved", t, func() {
+		builder := NewIndexerBuilder()
+		builder.ConfigField("region", FieldOption{Parser: "test_geo_region", ParserParams: map[string]string{"levels": "1"}})
+
+		doc := NewDocument(1)
+		doc.AddConjunction(NewConjunction().In("region", NewStrValues("US")))
+		builder.AddDocument(doc)
+		doc = NewDocument(2)
+		doc.AddConjunction(NewConjunction().In("region", NewStrValues("California")))
+		builder.AddDocument(doc)
+
+		for _, index := range []BEIndex{builder.BuildIndex(), builder.BuildCompactedIndex()} {
+			ids, err := index.Retrieve(Assignments{"region": NewStrValues("San Francisco")})
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(ids, convey.ShouldResemble, DocIDList{2})
+
+			ids, err = index.Retrieve(Assignments{"region": NewStrValues("Seattle")})
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(len(ids), convey.ShouldEqual, 0)
+
+			ids, err = index.Retrieve(Assignments{"region": NewStrValues("California")})
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(len(ids), convey.ShouldEqual, 2)
+		}
+
+		builder = NewIndexerBuilder()
+		builder.ConfigField("region", FieldOption{Parser: "test_geo_region", ParserParams: map[string]string{"depth": "1"}})
+		convey.So(func() { builder.BuildIndex() }, convey.ShouldPanic)
+	})
+}
+
+func TestBEIndex_Compact(t *testing.T) {
+	LogLevel = ErrorLevel
+
+	convey.Convey("test compact index keep retrieve result", t, func() {
+		docs, queries := BuildTestDocumentAndQueries(1000, 100, true)
+		b := NewIndexerBuilder()
+		for _, target := range docs {
+			doc := target.ToDocument()
+			for _, conj := range doc.Cons {
+				for _, expr := range conj.Expressions { // duplicated values produce duplicated entries
+					expr.Value = append(expr.Value, expr.Value...)
+				}
+			}
+			b.AddDocument(doc)
+		}
+
+		for _, index := range []BEIndex{b.BuildIndex(), b.BuildCompactedIndex()} {
+			stats, err := index.Compact()
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(stats.ListsRewritten, convey.ShouldBeGreaterThan, 0)
+			convey.So(stats.EntriesDropped, convey.ShouldBeGreaterThan, 0)
+			convey.So(stats.BytesReclaimed, convey.ShouldBeGreaterThan, 0)
+
+			for _, q := range queries {
+				var expect DocIDList
+				for id, target := range docs {
+					if target.Match(q.A, q.B, q.C, q.D) {
+						expect = append(expect, id)
+					}
+				}
+				ids, err := index.Retrieve(q.ToAssigns())
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(len(ids), convey.ShouldEqual, len(expect))
+				convey.So(ids.Sub(expect), convey.ShouldBeEmpty)
+			}
+
+			stats, err = index.Compact()
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(stats, convey.ShouldResemble, CompactStats{})
+		}
+	})
+}
+
+func TestBEIndex_CompactConcurrently(t *testing.T) {
+	LogLevel = ErrorLevel
+
+	convey.Convey("test compact interleaved with retrieve, results checked with brute force", t, func() {
+		docs, queries := BuildTestDocumentAndQueries(500, 50, true)
+		expects := make([]DocIDList, len(queries))
+		for i, q := range queries {
+			for id, target := range docs {
+				if target.Match(q.A, q.B, q.C, q.D) {
+					expects[i] = append(expects[i], id)
+				}
+			}
+			sort.Sort(expects[i])
+		}
+		b := NewIndexerBuilder()
+		for _, target := range docs {
+			doc := target.ToDocument()
+			for _, conj := range doc.Cons {
+				for _, expr := range conj.Expressions {
+					expr.Value = append(expr.Value, expr.Value...)
+				}
+			}
+			b.AddDocument(doc)
+		}
+
+		for round := 0; round < 4; round++ {
+			for _, index := range []BEIndex{b.BuildIndex(), b.BuildCompactedIndex()} {
+				var mismatches int32
+				var wg, started sync.WaitGroup
+				stop := make(chan struct{})
+				for r := 0; r < 4; r++ {
+					wg.Add(1)
+					started.Add(1)
+					go func(r int) {
+						defer wg.Done()
+						for i := r; ; i++ {
+							if i == r+1 {
+								started.Done()
+							}
+							select {
+							case <-stop:
+								return
+							default:
+							}
+							q := i % len(queries)
+							ids, err := index.Retrieve(queries[q].ToAssigns())
+							sort.Sort(ids)
+							if err != nil || len(ids) != len(expects[q]) || len(ids.Sub(expects[q])) > 0 {
+								atomic.AddInt32(&mismatches, 1)
+							}
+						}
+					}(r)
+				}
+				started.Wait() // compact while retrieving
+				stats, err := index.Compact()
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(stats.EntriesDropped, convey.ShouldBeGreaterThan, 0)
+				_, err = index.Compact()
+				convey.So(err, convey.ShouldBeNil)
+				close(stop)
+				wg.Wait()
+				convey.So(atomic.LoadInt32(&mismatches), convey.ShouldEqual, 0)
+			}
+		}
+	})
+}
+
+func TestBEIndex_DocConjunctions(t *testing.T) {
+	LogLevel = ErrorLevel
+
+	convey.Convey("test doc conjunctions lookup", t, func() {
+		b := NewIndexerBuilder(WithBadConjBehavior(SkipBadConj))
+		b.SetFieldParser("age", parser.NumRangeParser)
+
+		doc := NewDocument(1)
+		doc.AddConjunction(
+			NewConjunction().In("tag", NewIntValues(1)).In("age", NewStrValues("1:5")),
+			NewConjunction().NotIn("tag", NewIntValues(2)),
+			NewConjunction().In("age", NewStrValues("bad_range")))
+		b.AddDocument(doc)
+
+		doc = NewDocument(2)
+		doc.AddConjunction(NewConjunction().In("tag", NewIntValues(1)))
+		b.AddDocument(doc)
+
+		for _, index := range []BEIndex{b.BuildIndex(), b.BuildCompactedIndex()} {
+			conjs, ok := index.DocConjunctions(1)
+			convey.So(ok, convey.ShouldBeTrue)
+			// the bad conjunction skipped is not indexed
+			convey.So(conjs, convey.ShouldResemble, []ConjID{NewConjID(1, 0, 2), NewConjID(1, 1, 0)})
+
+			conjs, ok = index.DocConjunctions(2)
+			convey.So(ok, convey.ShouldBeTrue)
+			convey.So(conjs, convey.ShouldResemble, []ConjID{NewConjID(2, 0, 1)})
+
+			_, ok = index.DocConjunctions(3)
+			convey.So(ok, convey.ShouldBeFalse)
+		}
+	})
+}
+
+func TestBEIndex_VectorSimParser(t *testing.T) {
+	LogLevel = ErrorLevel
+
+	convey.Convey("test retrieve by vector similarity", t, func() {
+		builder := NewIndexerBuilder()
+		builder.ConfigField("embedding", FieldOption{
+			Parser:       parser.VectorSimParser,
+			ParserParams: map[string]string{"threshold": "0.9"},
+		})
+
+		doc := NewDocument(1)
+		doc.AddConjunction(NewConjunction().
+			In("embedding", Values{[]float32{1, 0, 0}}).
+			In("tag", NewIntValues(1)))
+		builder.AddDocument(doc)
+
+		doc = NewDocument(2)
+		doc.AddConjunction(NewConjunction().In("embedding", Values{[]float32{0, 1, 0}, []float32{0, 0, 1}}))
+		builder.AddDocument(doc)
+
+		for _, index := range []BEIndex{builder.BuildIndex(), builder.BuildCompactedIndex()} {
+			ids, err := index.Retrieve(Assignments{"embedding": Values{[]float32{0.98, 0.1, 0}}, "tag": NewIntValues(1)})
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(ids, convey.ShouldResemble, DocIDList{1})
+
+			ids, err = index.Retrieve(Assignments{"embedding": Values{[]float32{0.1, 0.05, 0.99}}})
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(ids, convey.ShouldResemble, DocIDList{2})
+
+			ids, err = index.Retrieve(Assignments{"embedding": Values{[]float32{1, 1, 1}}})
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(ids, convey.ShouldBeEmpty)
+		}
+
+		builder.ConfigField("tag", FieldOption{ParserParams: map[string]string{"threshold": "0.9"}})
+		convey.So(func() { builder.BuildIndex() }, convey.ShouldPanic)
+	})
+}
+
+func TestBEIndex_FuzzyMatchParser(t *testing.T) {
+	LogLevel = ErrorLevel
+
+	convey.Convey("test retrieve by fuzzy keyword", t, func() {
+		builder := NewIndexerBuilder()
+		builder.SetFieldParser("keyword", parser.FuzzyMatchParser)
+
+		doc := NewDocument(1)
+		doc.AddConjunction(NewConjunction().In("keyword", NewStrValues("sneaker", "shoes")))
+		builder.AddDocument(doc)
+
+		doc = NewDocument(2)
+		doc.AddConjunction(NewConjunction().NotIn("keyword", NewStrValues("laptop")))
+		builder.AddDocument(doc)
+
+		for _, index := range []BEIndex{builder.BuildIndex(), builder.BuildCompactedIndex()} {
+			ids, err := index.Retrieve(Assignments{"keyword": NewStrValues("sneakr")})
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(ids.Sub(DocIDList{1, 2}), convey.ShouldBeEmpty)
+			convey.So(len(ids), convey.ShouldEqual, 2)
+
+			ids, err = index.Retrieve(Assignments{"keyword": NewStrValues("laptob")})
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(ids, convey.ShouldBeEmpty)
+
+			ids, err = index.Retrieve(Assignments{"keyword": NewStrValues("snakr")}) // two edits off
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(ids, convey.ShouldResemble, DocIDList{2})
+		}
+	})
+}
+
+func TestBEIndex_RangeValue(t *testing.T) {
+	LogLevel = ErrorLevel
+
+	convey.Convey("test query mixing scalar and range values", t, func() {
+		builder := NewIndexerBuilder()
+		builder.SetFieldParser("age", parser.NumRangeParser)
+
+		doc := NewDocument(1)
+		doc.AddConjunction(NewConjunction().In("age", NewStrValues("18:25")).In("tag", NewStrValues("a")))
+		builder.AddDocument(doc)
+		doc = NewDocument(2)
+		doc.AddConjunction(NewConjunction().In("age", NewStrValues("40:50")))
+		builder.AddDocument(doc)
+		doc = NewDocument(3)
+		doc.AddConjunction(NewConjunction().NotIn("age", NewStrValues("60:70")))
+		builder.AddDocument(doc)
+
+		for _, index := range []BEIndex{builder.BuildIndex(), builder.BuildCompactedIndex()} {
+			ids, err := index.Retrieve(Assignments{"age": Values{NewRangeValue(20, 30)}, "tag": NewStrValues("a")})
+			convey.So(err, convey.ShouldBeNil)
+			sort.Sort(ids)
+			convey.So(ids, convey.ShouldResemble, DocIDList{1, 3})
+
+			ids, err = index.Retrieve(Assignments{"age": Values{NewRangeValue(26, 39), 45}})
+			convey.So(err, convey.ShouldBeNil)
+			sort.Sort(ids)
+			convey.So(ids, convey.ShouldResemble, DocIDList{2, 3})
+
+			ids, err = index.Retrieve(Assignments{"age": Values{NewRangeValue(65, 80)}})
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(ids, convey.ShouldBeEmpty)
+
+			_, err = index.Retrieve(Assignments{"tag": Values{NewRangeValue(1, 2)}})
+			convey.So(errors.Is(err, parser.ErrRangeNotSupported), convey.ShouldBeTrue)
+		}
+	})
+
+	convey.Convey("test scalar query against a excluded range", t, func() {
+		builder := NewIndexerBuilder()
+		builder.SetFieldParser("age", parser.NumRangeParser)
+
+		doc := NewDocument(1)
+		doc.AddConjunction(NewConjunction().NotIn("age", NewStrValues("13:17")))
+		builder.AddDocument(doc)
+		doc = NewDocument(2)
+		doc.AddConjunction(NewConjunction().In("age", NewStrValues("10:20")))
+		builder.AddDocument(doc)
+
+		for _, index := range []BEIndex{builder.BuildIndex(), builder.BuildCompactedIndex()} {
+			for _, age := range []int{13, 15, 17} { // inside, boundaries included
+				ids, err := index.Retrieve(Assignments{"age": NewIntValues(age)})
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(ids, convey.ShouldResemble, DocIDList{2})
+			}
+			for _, age := range []int{12, 18} { // just outside
+				ids, err := index.Retrieve(Assignments{"age": NewIntValues(age)})
+				convey.So(err, convey.ShouldBeNil)
+				sort.Sort(ids)
+				convey.So(ids, convey.ShouldResemble, DocIDList{1, 2})
+			}
+			ids, err := index.Retrieve(Assignments{"age": NewIntValues(30)}) // outside, value never indexed
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(ids, convey.ShouldResemble, DocIDList{1})
+
+			ids, err = index.Retrieve(Assignments{"age": NewIntValues(12, 15)}) // any value inside exclude
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(ids, convey.ShouldResemble, DocIDList{2})
+		}
+	})
+}
+
+type allOfTestExpr struct {
+	field  BEField
+	kind   int // 0: In, 1: NotIn, 2: InAll
+	values []int
+}
+
+func allOfExprMatch(expr allOfTestExpr, query map[BEField][]int) bool {
+	assigned := make(map[int]bool)
+	for _, v := range query[expr.field] {
+		assigned[v] = true
+	}
+	hit := 0
+	for _, v := range expr.values {
+		if assigned[v] {
+			hit++
+		}
+	}
+	switch expr.kind {
+	case 0:
+		return hit > 0
+	case 1:
+		return hit == 0
+	default:
+		return hit == len(expr.values)
+	}
+}
+
+func TestBEIndex_InAll(t *testing.T) {
+	LogLevel = ErrorLevel
+
+	convey.Convey("test InAll match only when all values assigned", t, func() {
+		builder := NewIndexerBuilder()
+		doc := NewDocument(1)
+		doc.AddConjunction(NewConjunction().InAll("apps", NewStrValues("com.a", "com.b")))
+		builder.AddDocument(doc)
+		doc = NewDocument(2)
+		doc.AddConjunction(NewConjunction().InAll("apps", NewStrValues("com.a")).In("os", NewStrValues("ios")))
+		builder.AddDocument(doc)
+
+		for _, index := range []BEIndex{builder.BuildIndex(), builder.BuildCompactedIndex()} {
+			ids, _ := index.Retrieve(Assignments{"apps": NewStrValues("com.a")})
+			convey.So(ids, convey.ShouldBeEmpty)
+			ids, _ = index.Retrieve(Assignments{"apps": NewStrValues("com.b", "com.c", "com.a")})
+			convey.So(ids, convey.ShouldResemble, DocIDList{1})
+			ids, _ = index.Retrieve(Assignments{"apps": NewStrValues("com.a", "com.b"), "os": NewStrValues("ios")})
+			sort.Sort(ids)
+			convey.So(ids, convey.ShouldResemble, DocIDList{1, 2})
+		}
+	})
+
+	convey.Convey("test InAll differential with brute force", t, func() {
+		rd := rand.New(rand.NewSource(7))
+		fields := []BEField{"f0", "f1", "f2", "f3"}
+		randValues := func(max int) []int {
+			values := make([]int, 1+rd.Intn(max))
+			for i := range values {
+				values[i] = rd.Intn(6)
+			}
+			return values
+		}
+
+		builder := NewIndexerBuilder()
+		docs := make(map[DocID][][]allOfTestExpr)
+		for id := DocID(1); id <= 300; id++ {
+			doc := NewDocument(id)
+			for c := 0; c < 1+rd.Intn(2); c++ {
+				conj := NewConjunction()
+				var exprs []allOfTestExpr
+				for _, f := range rd.Perm(len(fields))[:1+rd.Intn(3)] {
+					expr := allOfTestExpr{field: fields[f], kind: rd.Intn(3), values: randValues(3)}
+					switch expr.kind {
+					case 0:
+						conj.In(expr.field, NewIntValues(expr.values...))
+					case 1:
+						conj.NotIn(expr.field, NewIntValues(expr.values...))
+					default:
+						conj.InAll(expr.field, NewIntValues(expr.values...))
+					}
+					exprs = append(exprs, expr)
+				}
+				doc.AddConjunction(conj)
+				docs[id] = append(docs[id], exprs)
+			}
+			builder.AddDocument(doc)
+		}
+
+		indexes := []BEIndex{builder.BuildIndex(), builder.BuildCompactedIndex()}
+		matched := 0
+		for q := 0; q < 300; q++ {
+			query := map[BEField][]int{}
+			assigns := Assignments{}
+			for _, field := range fields {
+				if rd.Intn(4) > 0 {
+					query[field] = randValues(4)
+					assigns[field] = NewIntValues(query[field]...)
+				}
+			}
+
+			expect := DocIDList{}
+			for id, conjs := range docs {
+			CONJ:
+				for _, exprs := range conjs {
+					for _, expr := range exprs {
+						if !allOfExprMatch(expr, query) {
+							continue CONJ
+						}
+					}
+					expect = append(expect, id)
+					break
+				}
+			}
+			sort.Sort(expect)
+			matched += len(expect)
+
+			for _, index := range indexes {
+				ids, err := index.Retrieve(assigns)
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(ids.Sub(expect), convey.ShouldBeEmpty)
+				convey.So(expect.Sub(ids), convey.ShouldBeEmpty)
+			}
+		}
+		convey.So(matched, convey.ShouldBeGreaterThan, 0)
+	})
+}
+
+func TestBEIndex_Absent(t *testing.T) {
+	LogLevel = ErrorLevel
+
+	convey.Convey("test Absent match only when field not assigned", t, func() {
+		builder := NewIndexerBuilder()
+		doc := NewDocument(1)
+		doc.AddConjunction(NewConjunction().Absent("apps"))
+		builder.AddDocument(doc)
+		doc = NewDocument(2)
+		doc.AddConjunction(NewConjunction().Absent("apps").In("os", NewStrValues("ios")))
+		builder.AddDocument(doc)
+		doc = NewDocument(3)
+		doc.AddConjunction(NewConjunction().In("apps", NewStrValues("a")))
+		builder.AddDocument(doc)
+		doc = NewDocument(4)
+		doc.AddConjunction(NewConjunction().NotIn("apps", NewStrValues("a")))
+		builder.AddDocument(doc)
+
+		for _, index := range []BEIndex{builder.BuildIndex(), builder.BuildCompactedIndex()} {
+			retrieve := func(queries Assignments, opts ...IndexOpt) DocIDList {
+				ids, err := index.Retrieve(queries, opts...)
+				convey.So(err, convey.ShouldBeNil)
+				sort.Sort(ids)
+				return ids
+			}
+			convey.So(retrieve(Assignments{}), convey.ShouldResemble, DocIDList{1, 4})
+			convey.So(retrieve(Assignments{"apps": Values{}}), convey.ShouldResemble, DocIDList{1, 4})
+			convey.So(retrieve(Assignments{"os": NewStrValues("ios")}), convey.ShouldResemble, DocIDList{1, 2, 4})
+			convey.So(retrieve(Assignments{"apps": NewStrValues("a")}), convey.ShouldResemble, DocIDList{3})
+			convey.So(retrieve(Assignments{"apps": NewStrValues("b"), "os": NewStrValues("ios")}), convey.ShouldResemble, DocIDList{4})
+			convey.So(retrieve(Assignments{}, WithRawIDAssign("apps", []uint64{100})), convey.ShouldResemble, DocIDList{4})
+		}
+	})
+}
+
+// distinctDocs ids with the duplicates after the first removed, Retrieve list a doc per conjunction
+func distinctDocs(ids DocIDList) DocIDList {
+	seen := make(map[DocID]struct{}, len(ids))
+	result := make(DocIDList, 0, len(ids))
+	for _, id := range ids {
+		if _, ok := seen[id]; !ok {
+			seen[id] = struct{}{}
+			result = append(result, id)
+		}
+	}
+	return result
+}
+
+func TestBEIndex_RetrievePerConjunction(t *testing.T) {
+	LogLevel = ErrorLevel
+
+	convey.Convey("test doc listed once per conjunction matched", t, func() {
+		b := NewIndexerBuilder()
+		doc := NewDocument(1)
+		doc.AddConjunction(NewConjunction().In("tag", NewIntValues(1)))
+		doc.AddConjunction(NewConjunction().In("tag", NewIntValues(1, 2)))
+		doc.AddConjunction(NewConjunction().In("tag", NewIntValues(2)).In("age", NewIntValues(18)))
+		doc.AddConjunction(NewConjunction().NotIn("city", NewStrValues("sh")))
+		_ = b.AddDocument(doc)
+		doc = NewDocument(2)
+		doc.AddConjunction(NewConjunction().In("tag", NewIntValues(2)))
+		_ = b.AddDocument(doc)
+
+		for _, index := range []BEIndex{b.BuildIndex(), b.BuildCompactedIndex()} {
+			result, err := index.Retrieve(Assignments{"tag": NewIntValues(1, 2), "age": NewIntValues(18)})
+			convey.So(err, convey.ShouldBeNil)
+			sort.Sort(result)
+			convey.So(result, convey.ShouldResemble, DocIDList{1, 1, 1, 1, 2})
+		}
+	})
+}
+
+func TestBEIndex_RetrieveCount(t *testing.T) {
+	LogLevel = ErrorLevel
+
+	convey.Convey("test retrieve count equal to len of retrieve result", t, func() {
+		docs, queries := BuildTestDocumentAndQueries(2000, 100, true)
+		b := NewIndexerBuilder()
+		for _, doc := range docs {
+			doc := doc.ToDocument()
+			// multi conjunctions matched must count once
+			doc.AddConjunction(NewConjunction().In("A", NewIntValues(1)))
+			b.AddDocument(doc)
+		}
+		for _, index := range []BEIndex{b.BuildIndex(), b.BuildCompactedIndex()} {
+			for _, q := range queries {
+				result, err := index.Retrieve(q.ToAssigns())
+				convey.So(err, convey.ShouldBeNil)
+				count, err := index.RetrieveCount(q.ToAssigns())
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(count, convey.ShouldEqual, len(result))
+			}
+			count, err := index.RetrieveCount(Assignments{"A": NewIntValues(1)}, WithMaxResults(10))
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(count, convey.ShouldEqual, 10)
+		}
+	})
+}
+
+func TestBEIndex_WildcardEntries(t *testing.T) {
+	LogLevel = ErrorLevel
+
+	convey.Convey("test wildcard entries decoded to conjunctions", t, func() {
+		b := NewIndexerBuilder()
+		doc := NewDocument(1)
+		doc.AddConjunction(
+			NewConjunction().In("tag", NewIntValues(1)),
+			NewConjunction().NotIn("tag", NewIntValues(2)))
+		b.AddDocument(doc)
+		doc = NewDocument(2)
+		doc.AddConjunction(
+			NewConjunction().In("tag", NewIntValues(1)),
+			NewConjunction().In("age", NewIntValues(1)),
+			NewConjunction().NotIn("age", NewIntValues(2)).NotIn("tag", NewIntValues(3)))
+		b.AddDocument(doc)
+
+		for _, index := range []BEIndex{b.BuildIndex(), b.BuildCompactedIndex()} {
+			entries := index.WildcardEntries()
+			convey.So(len(entries), convey.ShouldEqual, 2)
+			convey.So(entries[0].GetConjID().DocID(), convey.ShouldEqual, 1)
+			convey.So(entries[0].GetConjID().Index(), convey.ShouldEqual, 1)
+			convey.So(entries[1].GetConjID().DocID(), convey.ShouldEqual, 2)
+			convey.So(entries[1].GetConjID().Index(), convey.ShouldEqual, 2)
+			for _, eid := range entries {
+				convey.So(eid.IsInclude(), convey.ShouldBeTrue)
+				convey.So(eid.GetConjID().Size(), convey.ShouldEqual, 0)
+			}
+
+			// a copy returned, index not affected
+			entries[0] = NULLENTRY
+			convey.So(index.WildcardEntries()[0].GetConjID().DocID(), convey.ShouldEqual, 1)
+		}
+	})
+}
+
+func TestBEIndex_WildcardValue(t *testing.T) {
+	LogLevel = ErrorLevel
+
+	b := NewIndexerBuilder()
+	for id := 1; id <= 10; id++ {
+		doc := NewDocument(DocID(id))
+		doc.AddConjunction(NewConjunction().In("tag", NewIntValues(id)))
+		b.AddDocument(doc)
+	}
+	doc := NewDocument(11)
+	doc.AddConjunction(NewConjunction().In("tag", NewIntValues(100)).In("city", NewStrValues("sh")))
+	b.AddDocument(doc)
+	doc = NewDocument(12)
+	doc.AddConjunction(NewConjunction().NotIn("tag", NewIntValues(3)))
+	b.AddDocument(doc)
+	doc = NewDocument(13)
+	doc.AddConjunction(NewConjunction().InAll("tag", NewIntValues(1, 2)))
+	b.AddDocument(doc)
+
+	convey.Convey("test wildcard value match any indexed value", t, func() {
+		for _, index := range []BEIndex{b.BuildIndex(), b.BuildCompactedIndex()} {
+			result, err := index.Retrieve(Assignments{"tag": NewWildcardValues()})
+			convey.So(err, convey.ShouldBeNil)
+			// not doc 11(city not assigned), not doc 12(tag 3 assigned)
+			convey.So(len(result), convey.ShouldEqual, 11)
+			convey.So(result.Contain(11) || result.Contain(12), convey.ShouldBeFalse)
+			convey.So(result.Contain(13), convey.ShouldBeTrue)
+
+			result, err = index.Retrieve(Assignments{"tag": NewWildcardValues(), "city": NewStrValues("sh")})
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(len(result), convey.ShouldEqual, 12)
+			convey.So(result.Contain(11), convey.ShouldBeTrue)
+
+			result, err = index.Retrieve(Assignments{"tag": NewWildcardValues(), "city": NewStrValues("bj")})
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(result.Contain(11), convey.ShouldBeFalse)
+
+			// mixed with specific values
+			result, err = index.Retrieve(Assignments{"tag": append(NewIntValues(1), WildcardValue)})
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(len(result), convey.ShouldEqual, 11)
+
+			result, err = index.Retrieve(Assignments{"city": NewWildcardValues()})
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(result, convey.ShouldResemble, DocIDList{12})
+
+			est, err := index.EstimateCost(Assignments{"tag": NewWildcardValues()})
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(est.Cursors, convey.ShouldBeGreaterThan, 10)
+		}
+	})
+
+	convey.Convey("test wildcard value rejected in conjunction", t, func() {
+		doc := NewDocument(1)
+		doc.AddConjunction(NewConjunction().In("tag", NewWildcardValues()))
+		convey.So(NewIndexerBuilder(WithNoPanics()).AddDocument(doc), convey.ShouldNotBeNil)
+	})
+}
+
+func TestBEIndex_RetrieveRich(t *testing.T) {
+	LogLevel = ErrorLevel
+
+	b := NewIndexerBuilder()
+	doc := NewDocument(1)
+	doc.AddConjunction(
+		NewConjunction().In("tag", NewIntValues(1)),
+		NewConjunction().In("tag", NewIntValues(1)).In("os", NewIntValues(2)).In("city", NewIntValues(3)))
+	b.AddDocument(doc)
+	doc = NewDocument(2)
+	doc.AddConjunction(NewConjunction().In("tag", NewIntValues(1)).In("os", NewIntValues(2)))
+	b.AddDocument(doc)
+	doc = NewDocument(3)
+	doc.AddConjunction(NewConjunction().NotIn("tag", NewIntValues(5)))
+	b.AddDocument(doc)
+
+	convey.Convey("test retrieve rich carry the max matched conjunction size", t, func() {
+		for _, index := range []BEIndex{b.BuildIndex(), b.BuildCompactedIndex(), NewMiddlewareBEIndex(b.BuildIndex())} {
+			query := Assignments{"tag": NewIntValues(1), "os": NewIntValues(2), "city": NewIntValues(3)}
+			rich, err := index.RetrieveRich(query, WithResultSort(func(a, b DocID) bool { return a < b }))
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(rich, convey.ShouldResemble, RichDocIDList{
+				{DocID: 1, MatchedConjSize: 3, MatchedConjIndex: 1},
+				{DocID: 2, MatchedConjSize: 2},
+				{DocID: 3, MatchedConjSize: 0},
+			})
+
+			result, err := index.Retrieve(query)
+			convey.So(err, convey.ShouldBeNil)
+			rich, err = index.RetrieveRich(query)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(rich.ToDocIDs(), convey.ShouldResemble, distinctDocs(result))
+
+			rich, err = index.RetrieveRich(Assignments{"tag": NewIntValues(1)}, WithMaxResults(1),
+				WithResultSort(func(a, b DocID) bool { return a < b }))
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(rich, convey.ShouldResemble, RichDocIDList{{DocID: 1, MatchedConjSize: 1}})
+
+			rich, err = index.RetrieveRich(Assignments{"tag": NewIntValues(5)})
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(rich, convey.ShouldBeEmpty)
+		}
+	})
+
+	convey.Convey("test retrieve rich select the most specific conjunction of a waterfall", t, func() {
+		b := NewIndexerBuilder()
+		doc := NewDocument(1)
+		doc.AddConjunction(
+			NewConjunction().In("city", NewStrValues("sh")),
+			NewConjunction().In("city", NewStrValues("sh")).In("os", NewStrValues("ios")).In("age", NewIntValues(20)),
+			NewConjunction().In("city", NewStrValues("sh")).In("os", NewStrValues("ios")),
+			NewConjunction().In("city", NewStrValues("sh")).In("age", NewIntValues(20)))
+		b.AddDocument(doc)
+
+		for _, index := range []BEIndex{b.BuildIndex(), b.BuildCompactedIndex()} {
+			query := Assignments{"city": NewStrValues("sh"), "os": NewStrValues("ios"), "age": NewIntValues(20)}
+			rich, err := index.RetrieveRich(query)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(rich, convey.ShouldResemble, RichDocIDList{{DocID: 1, MatchedConjSize: 3, MatchedConjIndex: 1}})
+
+			query = Assignments{"city": NewStrValues("sh"), "os": NewStrValues("ios")}
+			rich, err = index.RetrieveRich(query)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(rich, convey.ShouldResemble, RichDocIDList{{DocID: 1, MatchedConjSize: 2, MatchedConjIndex: 2}})
+
+			query = Assignments{"city": NewStrValues("sh"), "age": NewIntValues(20), "os": NewStrValues("android")}
+			rich, err = index.RetrieveRich(query)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(rich, convey.ShouldResemble, RichDocIDList{{DocID: 1, MatchedConjSize: 2, MatchedConjIndex: 3}})
+
+			rich, err = index.RetrieveRich(Assignments{"city": NewStrValues("sh")})
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(rich, convey.ShouldResemble, RichDocIDList{{DocID: 1, MatchedConjSize: 1, MatchedConjIndex: 0}})
+		}
+	})
+
+	convey.Convey("test retrieve rich select the first added of same size", t, func() {
+		b := NewIndexerBuilder()
+		doc := NewDocument(1)
+		doc.AddConjunction(
+			NewConjunction().In("os", NewStrValues("ios")).In("age", NewIntValues(20)),
+			NewConjunction().In("city", NewStrValues("sh")).In("os", NewStrValues("ios")))
+		b.AddDocument(doc)
+
+		for _, index := range []BEIndex{b.BuildIndex(), b.BuildCompactedIndex()} {
+			query := Assignments{"city": NewStrValues("sh"), "os": NewStrValues("ios"), "age": NewIntValues(20)}
+			rich, err := index.RetrieveRich(query)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(rich, convey.ShouldResemble, RichDocIDList{{DocID: 1, MatchedConjSize: 2, MatchedConjIndex: 0}})
+		}
+	})
+}
+
+func TestFieldOption_OrderWeight(t *testing.T) {
+	LogLevel = ErrorLevel
+
+	b := NewIndexerBuilder()
+	b.ConfigField("premium", FieldOption{OrderWeight: 10})
+	b.ConfigField("basic", FieldOption{OrderWeight: 1})
+	add := func(id DocID, conj *Conjunction) {
+		doc := NewDocument(id)
+		doc.AddConjunction(conj)
+		b.AddDocument(doc)
+	}
+	add(1, NewConjunction().In("basic", NewIntValues(1)))
+	add(3, NewConjunction().In("basic", NewIntValues(1)))
+	add(5, NewConjunction().In("premium", NewIntValues(1)))
+	add(9, NewConjunction().In("basic", NewIntValues(1)).In("other", NewIntValues(1)))
+	add(7, NewConjunction().In("premium", NewIntValues(1)).NotIn("other", NewIntValues(2)))
+	add(2, NewConjunction().NotIn("other", NewIntValues(2)))
+
+	query := Assignments{"basic": NewIntValues(1), "premium": NewIntValues(1), "other": NewIntValues(1)}
+
+	convey.Convey("test docs ordered by K then weight of matched fields", t, func() {
+		for _, index := range []BEIndex{b.BuildIndex(), b.BuildCompactedIndex()} {
+			result, err := index.Retrieve(query)
+			convey.So(err, convey.ShouldBeNil)
+			// K=2 first; premium matches ahead of basic ones; ties by DocID; wildcard weight 0 last
+			convey.So(result, convey.ShouldResemble, DocIDList{9, 5, 7, 1, 3, 2})
+
+			result, err = index.Retrieve(query, WithMaxResults(2))
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(result, convey.ShouldResemble, DocIDList{9, 5})
+
+			// explicit order override the weights
+			result, err = index.Retrieve(query, WithResultSort(func(a, b DocID) bool { return a > b }))
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(result, convey.ShouldResemble, DocIDList{9, 7, 5, 3, 2, 1})
+		}
+	})
+	convey.Convey("test weights attributed without allocating", t, func() {
+		collector := b.BuildIndex().(*SizeGroupedBEIndex).newScoredCollector()
+		fields := []BEField{"premium", "basic", "other"}
+		conj := NewConjID(1, 0, 3)
+		collector.Attribute(1, conj, fields)
+		allocs := testing.AllocsPerRun(100, func() {
+			collector.Attribute(1, conj, fields)
+		})
+		convey.So(allocs, convey.ShouldEqual, 0)
+		convey.So(collector.best[1], convey.ShouldResemble, matchWeight{size: 3, weight: 11})
+	})
+}
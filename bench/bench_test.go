@@ -0,0 +1,194 @@
+package bench
+
+import (
+	"flag"
+	"fmt"
+	"testing"
+
+	"github.com/echoface/be_indexer"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+const (
+	baselinePath = "testdata/baseline.json"
+)
+
+var (
+	docCount   = flag.Int("bench.docs", 10000, "documents count of each workload")
+	queryCount = flag.Int("bench.queries", 1000, "queries count of each workload")
+	seed       = flag.Int64("bench.seed", 1, "random seed of workload generators")
+
+	updateBaseline  = flag.Bool("bench.update", false, "measure and rewrite "+baselinePath)
+	compareBaseline = flag.Bool("bench.compare", false, "measure and report ratio against "+baselinePath)
+
+	indexKinds = []string{IndexSizeGrouped, IndexCompacted}
+)
+
+func mustWorkload(tb testing.TB, name string, docs, queries int, seed int64) (*Workload, *be_indexer.IndexerBuilder) {
+	be_indexer.LogLevel = be_indexer.ErrorLevel
+
+	w, err := NewWorkload(name, docs, queries, seed)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	builder, err := w.Builder()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return w, builder
+}
+
+func benchBuild(b *testing.B, builder *be_indexer.IndexerBuilder, kind string) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := BuildIndex(builder, kind); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func benchRetrieve(b *testing.B, w *Workload, index be_indexer.BEIndex) {
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := index.Retrieve(w.Queries[i%len(w.Queries)]); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkBuild(b *testing.B) {
+	for _, name := range WorkloadNames {
+		_, builder := mustWorkload(b, name, *docCount, *queryCount, *seed)
+		for _, kind := range indexKinds {
+			b.Run(fmt.Sprintf("%s/%s", name, kind), func(b *testing.B) {
+				benchBuild(b, builder, kind)
+			})
+		}
+	}
+}
+
+func BenchmarkRetrieve(b *testing.B) {
+	for _, name := range WorkloadNames {
+		w, builder := mustWorkload(b, name, *docCount, *queryCount, *seed)
+		for _, kind := range indexKinds {
+			index, err := BuildIndex(builder, kind)
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.Run(fmt.Sprintf("%s/%s", name, kind), func(b *testing.B) {
+				benchRetrieve(b, w, index)
+			})
+		}
+	}
+}
+
+func BenchmarkMemory(b *testing.B) {
+	for _, name := range WorkloadNames {
+		_, builder := mustWorkload(b, name, *docCount, *queryCount, *seed)
+		for _, kind := range indexKinds {
+			b.Run(fmt.Sprintf("%s/%s", name, kind), func(b *testing.B) {
+				var bytes int64
+				for i := 0; i < b.N; i++ {
+					bytes = MeasureIndexBytes(func() be_indexer.BEIndex {
+						index, _ := BuildIndex(builder, kind)
+						return index
+					})
+				}
+				b.ReportMetric(float64(bytes), "index-bytes")
+			})
+		}
+	}
+}
+
+func measure(t *testing.T, docs, queries int, seed int64) *Baseline {
+	current := &Baseline{Docs: docs, Queries: queries, Seed: seed}
+	for _, name := range WorkloadNames {
+		w, builder := mustWorkload(t, name, docs, queries, seed)
+		for _, kind := range indexKinds {
+			build := testing.Benchmark(func(b *testing.B) { benchBuild(b, builder, kind) })
+			index, err := BuildIndex(builder, kind)
+			if err != nil {
+				t.Fatal(err)
+			}
+			retrieve := testing.Benchmark(func(b *testing.B) { benchRetrieve(b, w, index) })
+			current.Results = append(current.Results, Result{
+				Workload:           name,
+				Index:              kind,
+				Docs:               docs,
+				BuildNsPerOp:       build.NsPerOp(),
+				RetrieveNsPerOp:    retrieve.NsPerOp(),
+				RetrieveAllocsOp:   retrieve.AllocsPerOp(),
+				RetrieveBytesPerOp: retrieve.AllocedBytesPerOp(),
+				IndexBytes: MeasureIndexBytes(func() be_indexer.BEIndex {
+					index, _ := BuildIndex(builder, kind)
+					return index
+				}),
+			})
+		}
+	}
+	return current
+}
+
+func TestWorkload(t *testing.T) {
+	convey.Convey("test workload generated deterministically", t, func() {
+		for _, name := range WorkloadNames {
+			w1, err := NewWorkload(name, 100, 10, 1)
+			convey.So(err, convey.ShouldBeNil)
+			w2, _ := NewWorkload(name, 100, 10, 1)
+			convey.So(len(w1.Docs), convey.ShouldEqual, 100)
+			convey.So(len(w1.Queries), convey.ShouldEqual, 10)
+			convey.So(w1.Docs, convey.ShouldResemble, w2.Docs)
+			convey.So(w1.Queries, convey.ShouldResemble, w2.Queries)
+
+			_, builder := mustWorkload(t, name, 100, 10, 1)
+			for _, kind := range indexKinds {
+				_, err := BuildIndex(builder, kind)
+				convey.So(err, convey.ShouldBeNil)
+			}
+		}
+		_, err := NewWorkload("unknown", 1, 1, 1)
+		convey.So(err, convey.ShouldNotBeNil)
+	})
+}
+
+// TestBaseline check the persisted baseline cover all workloads, and measure/compare with it on
+// -bench.update/-bench.compare, eg: go test ./bench -run TestBaseline -bench.compare -v
+func TestBaseline(t *testing.T) {
+	if *updateBaseline {
+		if err := measure(t, *docCount, *queryCount, *seed).Save(baselinePath); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	baseline, err := LoadBaseline(baselinePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range WorkloadNames {
+		for _, kind := range indexKinds {
+			if baseline.Find(name, kind) == nil {
+				t.Errorf("baseline of %s/%s missing, run with -bench.update", name, kind)
+			}
+		}
+	}
+
+	if !*compareBaseline {
+		return
+	}
+	current := measure(t, baseline.Docs, baseline.Queries, baseline.Seed)
+	ratio := func(cur, base int64) float64 {
+		if base == 0 {
+			return 0
+		}
+		return float64(cur) / float64(base)
+	}
+	for _, res := range current.Results {
+		base := baseline.Find(res.Workload, res.Index)
+		t.Logf("%s/%s build:%.2fx retrieve:%.2fx allocs:%.2fx memory:%.2fx", res.Workload, res.Index,
+			ratio(res.BuildNsPerOp, base.BuildNsPerOp),
+			ratio(res.RetrieveNsPerOp, base.RetrieveNsPerOp),
+			ratio(res.RetrieveAllocsOp, base.RetrieveAllocsOp),
+			ratio(res.IndexBytes, base.IndexBytes))
+	}
+}
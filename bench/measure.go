@@ -0,0 +1,96 @@
+package bench
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"runtime"
+	"sort"
+
+	"github.com/echoface/be_indexer"
+)
+
+const (
+	IndexSizeGrouped = "size_grouped"
+	IndexCompacted   = "compacted"
+)
+
+type (
+	// Result measurement of a workload on a index kind
+	Result struct {
+		Workload string `json:"workload"`
+		Index    string `json:"index"`
+		Docs     int    `json:"docs"`
+
+		BuildNsPerOp       int64 `json:"build_ns_per_op"`
+		RetrieveNsPerOp    int64 `json:"retrieve_ns_per_op"`
+		RetrieveAllocsOp   int64 `json:"retrieve_allocs_per_op"`
+		RetrieveBytesPerOp int64 `json:"retrieve_bytes_per_op"`
+		IndexBytes         int64 `json:"index_bytes"`
+	}
+
+	// Baseline results persisted in testdata, compared with the current measurement
+	Baseline struct {
+		Docs    int      `json:"docs"`
+		Queries int      `json:"queries"`
+		Seed    int64    `json:"seed"`
+		Results []Result `json:"results"`
+	}
+)
+
+// BuildIndex build the index kind from builder
+func BuildIndex(builder *be_indexer.IndexerBuilder, index string) (be_indexer.BEIndex, error) {
+	if index == IndexCompacted {
+		return builder.BuildCompactedIndexE()
+	}
+	return builder.BuildIndexE()
+}
+
+// MeasureIndexBytes return the heap bytes retained by the index built by fn, approximately
+func MeasureIndexBytes(fn func() be_indexer.BEIndex) int64 {
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	index := fn()
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+	runtime.KeepAlive(index)
+	return int64(after.HeapAlloc) - int64(before.HeapAlloc)
+}
+
+// LoadBaseline read baseline from json file
+func LoadBaseline(path string) (*Baseline, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	baseline := &Baseline{}
+	if err = json.Unmarshal(data, baseline); err != nil {
+		return nil, err
+	}
+	return baseline, nil
+}
+
+// Save write baseline as json file, results sorted by workload and index
+func (b *Baseline) Save(path string) error {
+	sort.Slice(b.Results, func(i, j int) bool {
+		if b.Results[i].Workload != b.Results[j].Workload {
+			return b.Results[i].Workload < b.Results[j].Workload
+		}
+		return b.Results[i].Index < b.Results[j].Index
+	})
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// Find return the result of workload on index, nil if not exist
+func (b *Baseline) Find(workload, index string) *Result {
+	for idx := range b.Results {
+		if b.Results[idx].Workload == workload && b.Results[idx].Index == index {
+			return &b.Results[idx]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+// Package bench provide reproducible workloads and measurement plumbing for benchmarking
+// be_indexer, run: go test ./bench -bench . -benchmem [-bench.docs=N -bench.queries=N -bench.seed=N]
+package bench
+
+import (
+	"fmt"
+	"math/rand"
+
+	"github.com/echoface/be_indexer"
+)
+
+const (
+	WorkloadSkewed         = "skewed"          // ad-targeting like, popular values dominate
+	WorkloadUniform        = "uniform"         // synthetic, values uniformly distributed
+	WorkloadExclusionHeavy = "exclusion_heavy" // most expressions are NotIn
+)
+
+type (
+	// Workload documents to index and queries to retrieve, generated deterministically from seed
+	Workload struct {
+		Name    string
+		Docs    []*be_indexer.Document
+		Queries []be_indexer.Assignments
+	}
+
+	generator func(rd *rand.Rand, docs, queries int) *Workload
+)
+
+var (
+	generators = map[string]generator{
+		WorkloadSkewed:         genSkewed,
+		WorkloadUniform:        genUniform,
+		WorkloadExclusionHeavy: genExclusionHeavy,
+	}
+
+	// WorkloadNames all canonical workloads, in report order
+	WorkloadNames = []string{WorkloadSkewed, WorkloadUniform, WorkloadExclusionHeavy}
+)
+
+// NewWorkload generate the named workload with docs documents and queries queries
+func NewWorkload(name string, docs, queries int, seed int64) (*Workload, error) {
+	gen, ok := generators[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown workload:%s", name)
+	}
+	w := gen(rand.New(rand.NewSource(seed)), docs, queries)
+	w.Name = name
+	return w, nil
+}
+
+// Builder return a IndexerBuilder with all docs of workload added
+func (w *Workload) Builder() (*be_indexer.IndexerBuilder, error) {
+	builder := be_indexer.NewIndexerBuilder()
+	for _, doc := range w.Docs {
+		if err := builder.AddDocument(doc); err != nil {
+			return nil, err
+		}
+	}
+	return builder, nil
+}
+
+func zipfValues(zipf *rand.Zipf, n int) []int {
+	values := make([]int, n)
+	for i := range values {
+		values[i] = int(zipf.Uint64())
+	}
+	return values
+}
+
+func uniformValues(rd *rand.Rand, max, n int) []int {
+	values := make([]int, n)
+	for i := range values {
+		values[i] = rd.Intn(max)
+	}
+	return values
+}
+
+// genSkewed: country(200, zipf), os(3), interest(5000, zipf), most docs target popular values
+func genSkewed(rd *rand.Rand, docs, queries int) *Workload {
+	country := rand.NewZipf(rd, 1.3, 1, 199)
+	interest := rand.NewZipf(rd, 1.1, 1, 4999)
+
+	w := &Workload{}
+	for i := 1; i <= docs; i++ {
+		doc := be_indexer.NewDocument(be_indexer.DocID(i))
+		for c := 0; c < 1+rd.Intn(2); c++ {
+			conj := be_indexer.NewConjunction()
+			conj.In("country", be_indexer.NewIntValues(zipfValues(country, 1+rd.Intn(3))...))
+			if rd.Intn(2) == 0 {
+				conj.In("os", be_indexer.NewIntValues(rd.Intn(3)))
+			}
+			if rd.Intn(3) > 0 {
+				conj.In("interest", be_indexer.NewIntValues(zipfValues(interest, 1+rd.Intn(8))...))
+			}
+			doc.AddConjunction(conj)
+		}
+		w.Docs = append(w.Docs, doc)
+	}
+	for i := 0; i < queries; i++ {
+		w.Queries = append(w.Queries, be_indexer.Assignments{
+			"country":  be_indexer.NewIntValues(zipfValues(country, 1)...),
+			"os":       be_indexer.NewIntValues(rd.Intn(3)),
+			"interest": be_indexer.NewIntValues(zipfValues(interest, 5)...),
+		})
+	}
+	return w
+}
+
+// genUniform: 10 fields with 100 values each, conjunction pick 3 random fields
+func genUniform(rd *rand.Rand, docs, queries int) *Workload {
+	const fields, cardinality = 10, 100
+	fieldName := func(i int) be_indexer.BEField {
+		return be_indexer.BEField(fmt.Sprintf("f%d", i))
+	}
+
+	w := &Workload{}
+	for i := 1; i <= docs; i++ {
+		doc := be_indexer.NewDocument(be_indexer.DocID(i))
+		conj := be_indexer.NewConjunction()
+		for _, f := range rd.Perm(fields)[:3] {
+			conj.In(fieldName(f), be_indexer.NewIntValues(uniformValues(rd, cardinality, 1+rd.Intn(3))...))
+		}
+		doc.AddConjunction(conj)
+		w.Docs = append(w.Docs, doc)
+	}
+	for i := 0; i < queries; i++ {
+		query := be_indexer.Assignments{}
+		for f := 0; f < fields; f++ {
+			query[fieldName(f)] = be_indexer.NewIntValues(uniformValues(rd, cardinality, 2)...)
+		}
+		w.Queries = append(w.Queries, query)
+	}
+	return w
+}
+
+// genExclusionHeavy: conjunction has at most one In expression and several NotIn expressions
+func genExclusionHeavy(rd *rand.Rand, docs, queries int) *Workload {
+	w := &Workload{}
+	for i := 1; i <= docs; i++ {
+		doc := be_indexer.NewDocument(be_indexer.DocID(i))
+		conj := be_indexer.NewConjunction()
+		if rd.Intn(2) == 0 {
+			conj.In("channel", be_indexer.NewIntValues(rd.Intn(20)))
+		}
+		conj.NotIn("blocked_site", be_indexer.NewIntValues(uniformValues(rd, 1000, 1+rd.Intn(10))...))
+		conj.NotIn("blocked_app", be_indexer.NewIntValues(uniformValues(rd, 500, 1+rd.Intn(5))...))
+		if rd.Intn(2) == 0 {
+			conj.NotIn("region", be_indexer.NewIntValues(uniformValues(rd, 50, 1+rd.Intn(3))...))
+		}
+		doc.AddConjunction(conj)
+		w.Docs = append(w.Docs, doc)
+	}
+	for i := 0; i < queries; i++ {
+		w.Queries = append(w.Queries, be_indexer.Assignments{
+			"channel":      be_indexer.NewIntValues(rd.Intn(20)),
+			"blocked_site": be_indexer.NewIntValues(rd.Intn(1000)),
+			"blocked_app":  be_indexer.NewIntValues(rd.Intn(500)),
+			"region":       be_indexer.NewIntValues(rd.Intn(50)),
+		})
+	}
+	return w
+}
@@ -14,6 +14,13 @@ type (
 	BoolValues struct {
 		Incl  bool   `json:"inc"`   // include: true exclude: false
 		Value Values `json:"value"` // values can be parser parse to id
+
+		// All the expression is true only when all values assigned(ALL-of), see Conjunction.InAll
+		All bool `json:"all,omitempty"`
+
+		// Absent the expression is true only when the field not assigned(no value) in query, Value
+		// is ignored, see Conjunction.Absent
+		Absent bool `json:"absent,omitempty"`
 	}
 
 	// BoolExprs expression a bool logic like: age (in) [15,16,17], city (not in) [shanghai,yz]
@@ -23,6 +30,13 @@ type (
 	}
 
 	Assignments map[BEField]Values
+
+	wildcardValue struct{}
+)
+
+var (
+	// WildcardValue a query value sentinel match any value of the field, see NewWildcardValues
+	WildcardValue interface{} = wildcardValue{}
 )
 
 func (ass Assignments) Size() (size int) {
@@ -73,6 +87,19 @@ func NewValues2(v interface{}, o ...interface{}) (res []interface{}) {
 	return
 }
 
+// NewWildcardValues query values of a field match any indexed value of the field, as if every value
+// indexed was assigned(catch-all queries for testing or admin); query only, conjunctions can't use it
+func NewWildcardValues() Values {
+	return Values{WildcardValue}
+}
+
+// NewRangeValue a query value match numbers within [lo, hi], can be mixed with scalar values, eg:
+// Assignments{"age": Values{NewRangeValue(18, 25), 30}}; only range aware parser(parser.NumRangeParser)
+// accept it, other parsers fail the query with parser.ErrRangeNotSupported
+func NewRangeValue(lo, hi int64) interface{} {
+	return parser.RangeValue{Lo: lo, Hi: hi}
+}
+
 func NewIntValues(o ...int) (res []interface{}) {
 	res = make([]interface{}, len(o))
 	for idx, optV := range o {
@@ -0,0 +1,85 @@
+package be_indexer
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+const (
+	EventDocIndexed    = "doc_indexed"    // a document's conjunctions indexed, attrs: doc_id, conjunctions
+	EventFieldCompiled = "field_compiled" // a field finished, attrs: field, field_id, parser
+	EventConjCached    = "conj_cached"    // reserved for conjunction cache, not emitted by this builder
+	EventConjCacheMiss = "conj_cache_miss"
+	EventBuildComplete = "build_complete" // attrs: documents, duration, and IndexStatistics counts
+	EventHotKey        = "hot_key"        // a posting list exceed FieldOption.MaxKeyEntries, attrs: field, key, entries, split
+)
+
+type (
+	// BuildEvent structured telemetry event of index building, see WithEventEmitter
+	BuildEvent struct {
+		Type       string
+		Timestamp  time.Time
+		Attributes map[string]interface{}
+	}
+
+	// BuildEventEmitter receive build events synchronously in the building goroutine, so it should not block
+	BuildEventEmitter interface {
+		Emit(event BuildEvent)
+	}
+
+	// ChannelEventEmitter emit events into a buffered channel, events are dropped(and counted)
+	// instead of blocking the build when the channel is full
+	ChannelEventEmitter struct {
+		ch      chan BuildEvent
+		dropped int64
+	}
+)
+
+// WithEventEmitter emit build events(EventDocIndexed...) to emitter
+func WithEventEmitter(emitter BuildEventEmitter) BuilderOpt {
+	return func(builder *IndexerBuilder) {
+		builder.emitter = emitter
+	}
+}
+
+func NewChannelEventEmitter(bufferSize int) *ChannelEventEmitter {
+	return &ChannelEventEmitter{
+		ch: make(chan BuildEvent, bufferSize),
+	}
+}
+
+func (e *ChannelEventEmitter) Emit(event BuildEvent) {
+	select {
+	case e.ch <- event:
+	default:
+		atomic.AddInt64(&e.dropped, 1)
+	}
+}
+
+// Events the channel to consume events from
+func (e *ChannelEventEmitter) Events() <-chan BuildEvent {
+	return e.ch
+}
+
+// Dropped count of events dropped because the channel was full
+func (e *ChannelEventEmitter) Dropped() int64 {
+	return atomic.LoadInt64(&e.dropped)
+}
+
+// Close close the events channel, Emit must not be called after Close
+func (e *ChannelEventEmitter) Close() {
+	close(e.ch)
+}
+
+func (b *IndexerBuilder) emit(eventType string, attrs map[string]interface{}) {
+	if b.emitter == nil {
+		return
+	}
+	b.inCallback = true
+	b.emitter.Emit(BuildEvent{
+		Type:       eventType,
+		Timestamp:  time.Now(),
+		Attributes: attrs,
+	})
+	b.inCallback = false
+}
@@ -0,0 +1,55 @@
+package be_indexer
+
+import (
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestWithEventEmitter(t *testing.T) {
+	LogLevel = ErrorLevel
+
+	convey.Convey("test build events emitted", t, func() {
+		emitter := NewChannelEventEmitter(16)
+		builder := NewIndexerBuilder(WithEventEmitter(emitter))
+		for i := 1; i <= 3; i++ {
+			doc := NewDocument(DocID(i))
+			doc.AddConjunction(NewConjunction().In("tag", NewIntValues(i)))
+			if i == 3 {
+				doc.AddConjunction(NewConjunction().In("age", NewIntValues(i)))
+			}
+			_ = builder.AddDocument(doc)
+		}
+		_ = builder.BuildIndex()
+		emitter.Close()
+
+		counts := map[string]int{}
+		var complete BuildEvent
+		for event := range emitter.Events() {
+			counts[event.Type]++
+			convey.So(event.Timestamp.IsZero(), convey.ShouldBeFalse)
+			if event.Type == EventDocIndexed && event.Attributes["doc_id"] == DocID(3) {
+				convey.So(event.Attributes["conjunctions"], convey.ShouldEqual, 2)
+			}
+			if event.Type == EventBuildComplete {
+				complete = event
+			}
+		}
+		convey.So(counts, convey.ShouldResemble, map[string]int{
+			EventDocIndexed:    3,
+			EventFieldCompiled: 2,
+			EventBuildComplete: 1,
+		})
+		convey.So(complete.Attributes["documents"], convey.ShouldEqual, 3)
+		convey.So(complete.Attributes["conjunctions"], convey.ShouldEqual, 4)
+		convey.So(emitter.Dropped(), convey.ShouldEqual, 0)
+	})
+
+	convey.Convey("test channel emitter drop events when full", t, func() {
+		emitter := NewChannelEventEmitter(1)
+		emitter.Emit(BuildEvent{Type: EventDocIndexed})
+		emitter.Emit(BuildEvent{Type: EventDocIndexed})
+		convey.So(emitter.Dropped(), convey.ShouldEqual, 1)
+		convey.So(len(emitter.Events()), convey.ShouldEqual, 1)
+	})
+}
@@ -0,0 +1,279 @@
+package be_indexer
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+type (
+	// ResultCollector receive the matched documents when retrieving,
+	// a document may be added more than once when multiple conjunctions of it matched
+	ResultCollector interface {
+		Add(id DocID, conj ConjID)
+		GetDocIDs() DocIDList
+		Reset()
+	}
+
+	// FallibleCollector collector can reject a hit with error, the error abort the retrieving and be
+	// returned by RetrieveWithCollector; Add is not called for a FallibleCollector
+	FallibleCollector interface {
+		ResultCollector
+		TryAdd(id DocID, conj ConjID) error
+	}
+
+	/*AttributionCollector a collector want to know which fields matched a hit, Attribute is called
+	before Add(or TryAdd) with the fields of the In expressions of conj matched by query(the wildcard
+	field for a size-0 conjunction); fields are ordered by the tie-break order(see
+	WithFieldTieBreakOrder), so the result is deterministic across retrievals
+	*/
+	AttributionCollector interface {
+		ResultCollector
+		Attribute(id DocID, conj ConjID, fields []BEField)
+	}
+
+	// ErrCollectorPanic a collector panic recovered in retrieving, Value is the panic value; the docs
+	// collected before the panic are kept in the collector, call Reset to reuse it
+	ErrCollectorPanic struct {
+		Value interface{}
+	}
+
+	// DocIDCollector the default collector, it list a doc once per conjunction matched it in the
+	// order of hits; the one of newDistinctCollector remove duplicated docs and keep the first hit
+	DocIDCollector struct {
+		docs DocIDList
+		hits map[DocID]struct{} // nil if not distinct
+	}
+
+	// countCollector count the hits without keeping them, see RetrieveCount
+	countCollector struct {
+		count int
+	}
+
+	// richCollector collect docs with the most specific conjunction matched them, see RetrieveRich
+	richCollector struct {
+		*DocIDCollector
+		best map[DocID]ConjID
+	}
+
+	/*WeightedPickCollector pick one of the matched docs with probability proportional to its weight,
+	by weighted reservoir sampling over the hits, so matched docs never collected into a list; a doc
+	matched by many conjunctions is sampled once, docs with weight <= 0(or NaN, +Inf) are never picked.
+	*/
+	WeightedPickCollector struct {
+		weight func(id DocID) float64
+		rnd    *rand.Rand
+		hits   map[DocID]struct{}
+		total  float64
+		picked DocID
+		found  bool
+	}
+
+	// CapProvider provide the frequency cap state of documents, eg: "show at most N times per user"
+	CapProvider interface {
+		// Remaining return how many times the document can still be served, doc with Remaining <= 0 will be dropped
+		Remaining(id DocID) int
+
+		// Consume be called once for each collected document after retrieving success, see CappedCollector.Commit
+		Consume(id DocID)
+	}
+
+	/*CappedCollector collect docs which still have remaining cap, it works in two phases:
+	phase 1: Add, docs be filtered by CapProvider.Remaining, nothing be consumed
+	phase 2: Commit, CapProvider.Consume be called for every collected doc
+	so caller should only Commit when the retrieving(and its own following logic) success,
+	a failed request will not burn the cap.
+	semantics:
+	- a doc is collected once however many conjunctions matched it, unlike DocIDCollector
+	- Remaining is queried once per doc within one retrieving, no matter how many conjunctions matched
+	- Consume is called once per collected doc, in the same order as GetDocIDs
+	- Commit more than once without Reset takes no effect
+	*/
+	CappedCollector struct {
+		DocIDCollector
+		provider  CapProvider
+		seen      map[DocID]bool // doc -> whether collected(false: capped)
+		committed bool
+	}
+)
+
+func (e *ErrCollectorPanic) Error() string {
+	return fmt.Sprintf("collector panic: %v", e.Value)
+}
+
+/*
+collect add a hit to collector, its error is returned to abort the retrieving; fields give the fields
+matched the hit, only called for AttributionCollector. a panic of collector is not recovered per hit:
+ctx.collecting mark it's collector's, recoverFromPanic deferred by retrieve turn it into ErrCollectorPanic
+*/
+func collect(ctx *RetrieveContext, collector ResultCollector, id DocID, conj ConjID, fields func() []BEField) error {
+	ctx.collecting = true
+	if attribution, ok := collector.(AttributionCollector); ok {
+		attribution.Attribute(id, conj, fields())
+	}
+	var err error
+	if fallible, ok := collector.(FallibleCollector); ok {
+		err = fallible.TryAdd(id, conj)
+	} else {
+		collector.Add(id, conj)
+	}
+	ctx.collecting = false
+	return err
+}
+
+func NewDocIDCollector() *DocIDCollector {
+	return &DocIDCollector{
+		docs: make(DocIDList, 0, 128),
+	}
+}
+
+// newDistinctCollector a DocIDCollector list every doc once, for results keyed by doc(eg: RetrieveRich)
+func newDistinctCollector() *DocIDCollector {
+	return &DocIDCollector{
+		docs: make(DocIDList, 0, 128),
+		hits: make(map[DocID]struct{}, 128),
+	}
+}
+
+func (c *DocIDCollector) Add(id DocID, _ ConjID) {
+	if c.hits != nil {
+		if _, ok := c.hits[id]; ok {
+			return
+		}
+		c.hits[id] = struct{}{}
+	}
+	c.docs = append(c.docs, id)
+}
+
+func (c *DocIDCollector) GetDocIDs() DocIDList {
+	return c.docs
+}
+
+func (c *DocIDCollector) Reset() {
+	c.docs = c.docs[:0]
+	if c.hits != nil {
+		c.hits = make(map[DocID]struct{}, len(c.hits))
+	}
+}
+
+func (c *countCollector) Add(_ DocID, _ ConjID) {
+	c.count++
+}
+
+// GetDocIDs countCollector keep no DocIDList, use Count instead
+func (c *countCollector) GetDocIDs() DocIDList {
+	return nil
+}
+
+func (c *countCollector) Count() int {
+	return c.count
+}
+
+func (c *countCollector) Reset() {
+	c.count = 0
+}
+
+func newRichCollector() *richCollector {
+	return &richCollector{
+		DocIDCollector: newDistinctCollector(),
+		best:           make(map[DocID]ConjID, 128),
+	}
+}
+
+func (c *richCollector) Add(id DocID, conj ConjID) {
+	c.DocIDCollector.Add(id, conj)
+	if best, ok := c.best[id]; !ok || moreSpecific(conj, best) {
+		c.best[id] = conj
+	}
+}
+
+// moreSpecific whether conjunction a selected over b of same doc: larger size(K) first, then the
+// one added to document first, so the selection not depend on the order of hits
+func moreSpecific(a, b ConjID) bool {
+	return a.Size() > b.Size() || (a.Size() == b.Size() && a.Index() < b.Index())
+}
+
+func (c *richCollector) Reset() {
+	c.DocIDCollector.Reset()
+	c.best = make(map[DocID]ConjID, len(c.best))
+}
+
+// NewWeightedPickCollector seed the random source of sampling, a fixed seed make the picks reproducible
+func NewWeightedPickCollector(weight func(id DocID) float64, seed int64) *WeightedPickCollector {
+	return &WeightedPickCollector{
+		weight: weight,
+		rnd:    rand.New(rand.NewSource(seed)),
+		hits:   make(map[DocID]struct{}, 128),
+	}
+}
+
+func (c *WeightedPickCollector) Add(id DocID, _ ConjID) {
+	if _, ok := c.hits[id]; ok {
+		return
+	}
+	c.hits[id] = struct{}{}
+	w := c.weight(id)
+	if !(w > 0) || math.IsInf(w, 1) {
+		return
+	}
+	// replacing with probability w/total keep every doc seen picked with probability weight/total
+	c.total += w
+	if c.rnd.Float64()*c.total < w {
+		c.picked, c.found = id, true
+	}
+}
+
+// Picked the doc picked, false if no doc with positive weight matched
+func (c *WeightedPickCollector) Picked() (DocID, bool) {
+	return c.picked, c.found
+}
+
+// GetDocIDs the doc picked as a list of at most one doc
+func (c *WeightedPickCollector) GetDocIDs() DocIDList {
+	if !c.found {
+		return DocIDList{}
+	}
+	return DocIDList{c.picked}
+}
+
+// Reset clear the picking, the random source continue(not reseeded)
+func (c *WeightedPickCollector) Reset() {
+	c.hits = make(map[DocID]struct{}, len(c.hits))
+	c.total, c.picked, c.found = 0, 0, false
+}
+
+func NewCappedCollector(provider CapProvider) *CappedCollector {
+	return &CappedCollector{
+		DocIDCollector: *NewDocIDCollector(),
+		provider:       provider,
+		seen:           make(map[DocID]bool),
+	}
+}
+
+func (c *CappedCollector) Add(id DocID, conj ConjID) {
+	if _, ok := c.seen[id]; ok {
+		return
+	}
+	collected := c.provider.Remaining(id) > 0
+	c.seen[id] = collected
+	if collected {
+		c.DocIDCollector.Add(id, conj)
+	}
+}
+
+// Commit consume the cap of all collected docs, call it only when retrieving success
+func (c *CappedCollector) Commit() {
+	if c.committed {
+		return
+	}
+	c.committed = true
+	for _, id := range c.docs {
+		c.provider.Consume(id)
+	}
+}
+
+func (c *CappedCollector) Reset() {
+	c.DocIDCollector.Reset()
+	c.seen = make(map[DocID]bool)
+	c.committed = false
+}
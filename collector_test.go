@@ -0,0 +1,284 @@
+package be_indexer
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+type mockCapProvider struct {
+	remaining map[DocID]int
+	queried   map[DocID]int
+}
+
+func (p *mockCapProvider) Remaining(id DocID) int {
+	p.queried[id]++
+	return p.remaining[id]
+}
+
+func (p *mockCapProvider) Consume(id DocID) {
+	p.remaining[id]--
+}
+
+func buildCappedTestIndex() *IndexerBuilder {
+	builder := NewIndexerBuilder()
+
+	// 1: (tag in 1) or (age in 10)
+	doc := NewDocument(1)
+	doc.AddConjunction(
+		NewConjunction().In("tag", NewIntValues(1)),
+		NewConjunction().In("age", NewIntValues(10)))
+	builder.AddDocument(doc)
+
+	// 2: (tag in 1, 2)
+	doc = NewDocument(2)
+	doc.AddConjunction(NewConjunction().In("tag", NewIntValues(1, 2)))
+	builder.AddDocument(doc)
+
+	// 3: (tag in 1 && age in 10)
+	doc = NewDocument(3)
+	doc.AddConjunction(NewConjunction().In("tag", NewIntValues(1)).In("age", NewIntValues(10)))
+	builder.AddDocument(doc)
+	return builder
+}
+
+func TestDocIDCollector(t *testing.T) {
+	convey.Convey("test doc id collector list doc per conjunction", t, func() {
+		collector := NewDocIDCollector()
+		collector.Add(3, NewConjID(3, 0, 1))
+		collector.Add(1, NewConjID(1, 0, 1))
+		collector.Add(3, NewConjID(3, 1, 2))
+		convey.So(collector.GetDocIDs(), convey.ShouldResemble, DocIDList{3, 1, 3})
+
+		collector.Reset()
+		convey.So(len(collector.GetDocIDs()), convey.ShouldEqual, 0)
+	})
+	convey.Convey("test distinct collector remove duplicated docs", t, func() {
+		collector := newDistinctCollector()
+		collector.Add(3, NewConjID(3, 0, 1))
+		collector.Add(1, NewConjID(1, 0, 1))
+		collector.Add(3, NewConjID(3, 1, 2))
+		convey.So(collector.GetDocIDs(), convey.ShouldResemble, DocIDList{3, 1})
+
+		collector.Reset()
+		collector.Add(3, NewConjID(3, 0, 1))
+		convey.So(collector.GetDocIDs(), convey.ShouldResemble, DocIDList{3})
+	})
+}
+
+func TestCappedCollector(t *testing.T) {
+	LogLevel = ErrorLevel
+
+	builder := buildCappedTestIndex()
+	query := Assignments{
+		"tag": NewIntValues(1),
+		"age": NewIntValues(10),
+	}
+
+	for _, index := range []BEIndex{builder.BuildIndex(), builder.BuildCompactedIndex()} {
+		convey.Convey("test capped collector filter and two phase consume", t, func() {
+			provider := &mockCapProvider{
+				remaining: map[DocID]int{1: 2, 2: 0, 3: 1},
+				queried:   map[DocID]int{},
+			}
+			collector := NewCappedCollector(provider)
+			convey.So(index.RetrieveWithCollector(query, collector), convey.ShouldBeNil)
+
+			ids := collector.GetDocIDs()
+			sort.Sort(ids)
+			convey.So(ids, convey.ShouldResemble, DocIDList{1, 3})
+			// doc 1 matched by two conjunctions, but only query its cap once
+			convey.So(provider.queried[1], convey.ShouldEqual, 1)
+			// nothing consumed before commit
+			convey.So(provider.remaining, convey.ShouldResemble, map[DocID]int{1: 2, 2: 0, 3: 1})
+
+			collector.Commit()
+			collector.Commit()
+			convey.So(provider.remaining, convey.ShouldResemble, map[DocID]int{1: 1, 2: 0, 3: 0})
+
+			collector.Reset()
+			convey.So(index.RetrieveWithCollector(query, collector), convey.ShouldBeNil)
+			convey.So(collector.GetDocIDs(), convey.ShouldResemble, DocIDList{1})
+
+			// a failed request don't commit, so the cap is not burned
+			collector.Reset()
+			convey.So(index.RetrieveWithCollector(query, collector), convey.ShouldBeNil)
+			convey.So(collector.GetDocIDs(), convey.ShouldResemble, DocIDList{1})
+			convey.So(provider.remaining[1], convey.ShouldEqual, 1)
+		})
+	}
+}
+
+// faultyCollector panic or fail when adding the failAt-th doc
+type faultyCollector struct {
+	DocIDCollector
+	failAt int
+	panics bool
+}
+
+func (c *faultyCollector) TryAdd(id DocID, conj ConjID) error {
+	if len(c.docs)+1 == c.failAt {
+		if c.panics {
+			panic(fmt.Sprintf("faulty collector, doc:%d", id))
+		}
+		return errors.New("collector quota exceeded")
+	}
+	c.DocIDCollector.Add(id, conj)
+	return nil
+}
+
+type panicCollector struct {
+	*DocIDCollector
+}
+
+func (c *panicCollector) Add(id DocID, conj ConjID) {
+	panic(fmt.Errorf("faulty collector, doc:%d", id))
+}
+
+func TestRetrieveWithCollector_Failure(t *testing.T) {
+	LogLevel = ErrorLevel
+
+	_, indexes := buildOptionTestIndexes(10)
+	query := Assignments{"tag": NewIntValues(10)}
+	for _, index := range indexes {
+		convey.Convey("test collector panic returned as ErrCollectorPanic", t, func() {
+			err := index.RetrieveWithCollector(query, &panicCollector{NewDocIDCollector()})
+			var panicErr *ErrCollectorPanic
+			convey.So(errors.As(err, &panicErr), convey.ShouldBeTrue)
+			convey.So(panicErr.Value.(error).Error(), convey.ShouldEqual, "faulty collector, doc:1")
+
+			collector := &faultyCollector{DocIDCollector: *NewDocIDCollector(), failAt: 4, panics: true}
+			err = index.RetrieveWithCollector(query, collector)
+			convey.So(errors.As(err, &panicErr), convey.ShouldBeTrue)
+			convey.So(len(collector.GetDocIDs()), convey.ShouldEqual, 3) // partial results kept
+
+			collector.Reset()
+			collector.failAt = 0
+			convey.So(index.RetrieveWithCollector(query, collector), convey.ShouldBeNil)
+			convey.So(len(collector.GetDocIDs()), convey.ShouldEqual, 10)
+		})
+
+		convey.Convey("test collector error abort retrieving", t, func() {
+			collector := &faultyCollector{DocIDCollector: *NewDocIDCollector(), failAt: 6}
+			err := index.RetrieveWithCollector(query, collector)
+			convey.So(err, convey.ShouldNotBeNil)
+			convey.So(err.Error(), convey.ShouldEqual, "collector quota exceeded")
+			convey.So(len(collector.GetDocIDs()), convey.ShouldEqual, 5)
+		})
+	}
+}
+
+type attributionCollector struct {
+	*DocIDCollector
+	attributes map[DocID][][]BEField
+}
+
+func (c *attributionCollector) Attribute(id DocID, conj ConjID, fields []BEField) {
+	c.attributes[id] = append(c.attributes[id], fields)
+}
+
+func TestAttributionCollector(t *testing.T) {
+	LogLevel = ErrorLevel
+
+	b := NewIndexerBuilder()
+	for id := DocID(1); id <= 20; id++ { // both fields share the EntryIDs of every doc
+		doc := NewDocument(id)
+		doc.AddConjunction(NewConjunction().
+			In("a", NewIntValues(1)).
+			In("b", NewIntValues(2)).
+			In("c", NewIntValues(3)))
+		b.AddDocument(doc)
+	}
+	doc := NewDocument(21)
+	doc.AddConjunction(NewConjunction().NotIn("a", NewIntValues(100)))
+	b.AddDocument(doc)
+
+	query := Assignments{"a": NewIntValues(1), "b": NewIntValues(2), "c": NewIntValues(3)}
+	convey.Convey("test attribution deterministic and ordered by tie-break order", t, func() {
+		for _, index := range []BEIndex{b.BuildIndex(), b.BuildCompactedIndex()} {
+			var byID []BEField // default order: field id
+			for _, desc := range index.FieldDescriptions() {
+				if _, ok := query[desc.Field]; ok {
+					byID = append(byID, desc.Field)
+				}
+			}
+
+			retrieve := func(opts ...IndexOpt) map[DocID][][]BEField {
+				collector := &attributionCollector{NewDocIDCollector(), make(map[DocID][][]BEField)}
+				convey.So(index.RetrieveWithCollector(query, collector, opts...), convey.ShouldBeNil)
+				convey.So(len(collector.GetDocIDs()), convey.ShouldEqual, 21)
+				return collector.attributes
+			}
+			first := retrieve()
+			convey.So(first[1], convey.ShouldResemble, [][]BEField{byID})
+			convey.So(first[21], convey.ShouldResemble, [][]BEField{{wildcardField}})
+			for i := 0; i < 100; i++ {
+				convey.So(retrieve(), convey.ShouldResemble, first)
+			}
+
+			ordered := retrieve(WithFieldTieBreakOrder("c", "a", "c"))
+			rest := byID[:0:0]
+			for _, field := range byID {
+				if field != "c" && field != "a" {
+					rest = append(rest, field)
+				}
+			}
+			convey.So(ordered[1], convey.ShouldResemble, [][]BEField{append([]BEField{"c", "a"}, rest...)})
+		}
+	})
+}
+
+func TestWeightedPickCollector(t *testing.T) {
+	LogLevel = ErrorLevel
+
+	b := NewIndexerBuilder()
+	for id := DocID(1); id <= 5; id++ {
+		doc := NewDocument(id)
+		doc.AddConjunction(NewConjunction().In("tag", NewIntValues(1)))
+		doc.AddConjunction(NewConjunction().In("tag", NewIntValues(1, 2))) // matched twice, sampled once
+		b.AddDocument(doc)
+	}
+	weights := map[DocID]float64{1: 1, 2: 2, 3: 3, 4: 4, 5: 0}
+	weight := func(id DocID) float64 {
+		return weights[id]
+	}
+
+	convey.Convey("test weighted pick frequencies approximate the weights", t, func() {
+		for _, index := range []BEIndex{b.BuildIndex(), b.BuildCompactedIndex()} {
+			collector := NewWeightedPickCollector(weight, 42)
+			counts := make(map[DocID]int)
+			runs := 20000
+			for i := 0; i < runs; i++ {
+				collector.Reset()
+				convey.So(index.RetrieveWithCollector(Assignments{"tag": NewIntValues(1)}, collector), convey.ShouldBeNil)
+				picked, ok := collector.Picked()
+				convey.So(ok, convey.ShouldBeTrue)
+				counts[picked]++
+			}
+			convey.So(counts[5], convey.ShouldEqual, 0)
+			for id := DocID(1); id <= 4; id++ {
+				convey.So(float64(counts[id])/float64(runs), convey.ShouldAlmostEqual, weights[id]/10, 0.02)
+			}
+			convey.So(len(collector.GetDocIDs()), convey.ShouldEqual, 1)
+
+			// reproducible with the same seed
+			first, second := NewWeightedPickCollector(weight, 7), NewWeightedPickCollector(weight, 7)
+			for i := 0; i < 10; i++ {
+				first.Reset()
+				second.Reset()
+				_ = index.RetrieveWithCollector(Assignments{"tag": NewIntValues(1)}, first)
+				_ = index.RetrieveWithCollector(Assignments{"tag": NewIntValues(1)}, second)
+				convey.So(first.GetDocIDs(), convey.ShouldResemble, second.GetDocIDs())
+			}
+
+			collector.Reset()
+			_ = index.RetrieveWithCollector(Assignments{"tag": NewIntValues(3)}, collector)
+			_, ok := collector.Picked()
+			convey.So(ok, convey.ShouldBeFalse)
+			convey.So(collector.GetDocIDs(), convey.ShouldBeEmpty)
+		}
+	})
+}
@@ -1,6 +1,14 @@
 package be_indexer
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	// MaxDocConjunctions conjunctions(OR branches) a document can have, limited by index field of ConjID
+	MaxDocConjunctions = 0xFFFF
+)
 
 type (
 	//ConjID max support 56bit len
@@ -13,18 +21,23 @@ type (
 	}
 )
 
-//NewConjID (reserved(16))| size(8bit) | index(8bit)  | docID(32bit)
+/*
+NewConjID reserved(8bit) | size(8bit) | index(16bit) | docID(32bit)
+the index field is 16bit for docs with many OR branches, it take 8 of the empty bits of EntryID
+(see NewEntryID), size and docID keep their width; size above index above docID, so entries are
+still ordered by size first and ConjIDs of a K group stay contiguous
+*/
 func NewConjID(docID DocID, index, size int) ConjID {
-	u := (uint64(size) << 40) | (uint64(index) << 32) | (uint64(docID))
+	u := (uint64(size) << 48) | (uint64(index) << 32) | (uint64(docID))
 	return ConjID(u)
 }
 
 func (id ConjID) Index() int {
-	return int((id >> 32) & 0xFF)
+	return int((id >> 32) & 0xFFFF)
 }
 
 func (id ConjID) Size() int {
-	return int((id >> 40) & 0xFF)
+	return int((id >> 48) & 0xFF)
 }
 
 func (id ConjID) DocID() DocID {
@@ -43,7 +56,41 @@ func (conj *Conjunction) In(field BEField, values Values) *Conjunction {
 	return conj
 }
 
-// any value in values is a **false** expression
+/*
+InAll all values must be assigned in query for a **true** expression(ALL-of), eg: installed_apps
+InAll [com.a, com.b] match a query assigning both. implemented by splitting the values into
+synthetic sub-fields(one value each, see allOfField) which count into the conjunction size, so
+the normal K matching require all of them hit; limits:
+  - every value add 1 to conjunction size, the size(with other In expressions) must be < 256
+  - every sub-field take a field id, the max value count of InAll on a field count into the 256 fields limit
+  - each query value of field is parsed once more for every sub-field, the cost grow with the
+    max value count of InAll on the field
+  - sub-fields only see values from Assignments, not WithBitsetAssign/WithRawIDAssign
+*/
+func (conj *Conjunction) InAll(field BEField, values Values) *Conjunction {
+	if len(values) == 0 {
+		panic(fmt.Errorf("InAll field:%s need at least one value", field))
+	}
+	conj.addExpression(field, true, values)
+	conj.Expressions[field].All = true
+	return conj
+}
+
+/*
+Absent the field must be absent(not assigned, or assigned with no value) in query for a **true**
+expression, eg: target users without any installed app; it's the opposite of a normal expression.
+it's indexed against a synthetic token(see absentField) which the query get when field absent, so
+it count 1 into conjunction size like a In expression; a field can't be both Absent and in other
+expression of one conjunction.
+*/
+func (conj *Conjunction) Absent(field BEField) *Conjunction {
+	conj.addExpression(field, true, nil)
+	conj.Expressions[field].Absent = true
+	return conj
+}
+
+// any value in values is a **false** expression; with parser.NumRangeParser a "lo:hi" value exclude
+// the whole closed interval, eg: NotIn("age", NewStrValues("13:17")) match any age outside [13, 17]
 func (conj *Conjunction) NotIn(field BEField, values Values) *Conjunction {
 	conj.addExpression(field, false, values)
 	return conj
@@ -79,10 +126,14 @@ func (conj *Conjunction) addExpression(field BEField, inc bool, values Values) {
 
 func (conj *Conjunction) CalcConjSize() (size int) {
 	for _, bv := range conj.Expressions {
-		if bv.Incl {
+		if bv.Incl && bv.All {
+			size += len(bv.Value)
+		} else if bv.Incl {
 			size++
 		}
 	}
-	conj.size = size
+	if conj.size != size { // computed conjunction only read, see Document.Prepare
+		conj.size = size
+	}
 	return
 }
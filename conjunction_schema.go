@@ -0,0 +1,76 @@
+package be_indexer
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/echoface/be_indexer/parser"
+)
+
+const (
+	jsonSchemaDraft = "http://json-schema.org/draft-07/schema#"
+)
+
+// valueJSONSchema schema of a value of the field parsed by parser, a parser registered by user
+// accept any value since its format unknown here
+func valueJSONSchema(parserName string) map[string]interface{} {
+	switch parserName {
+	case "", parser.CommonParser, parser.FuzzyMatchParser:
+		return map[string]interface{}{"type": []string{"string", "number"}}
+	case parser.NumRangeParser:
+		return map[string]interface{}{"type": "string", "pattern": "^[^:]+:[^:]+(:[^:]+)?$"} // start:end[:step]
+	case parser.VectorSimParser:
+		return map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "number"}, "minItems": 1}
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+/*
+GenerateConjunctionJSONSchema generate a JSON Schema(draft-07) describing the json form of a
+Conjunction(see BoolValues json tags) over fields, so rules written by hand can be validated
+before indexing; fields not listed are rejected, values are constrained by the parser of field:
+  - #common/#fuzzy(or no parser): string or number
+  - #num_range: "start:end[:step]" string
+  - #vector: non-empty array of number
+  - others(registered by user): any value
+*/
+func GenerateConjunctionJSONSchema(fields []FieldDesc) ([]byte, error) {
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("no field to generate schema")
+	}
+	exprs := make(map[string]interface{}, len(fields))
+	for _, desc := range fields {
+		if desc.Field == "" || desc.Field == wildcardField {
+			return nil, fmt.Errorf("invalid field:%s", desc.Field)
+		}
+		if _, ok := exprs[string(desc.Field)]; ok {
+			return nil, fmt.Errorf("duplicated field:%s", desc.Field)
+		}
+		exprs[string(desc.Field)] = map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"inc":    map[string]interface{}{"type": "boolean"},
+				"value":  map[string]interface{}{"type": "array", "items": valueJSONSchema(desc.ParserName)},
+				"all":    map[string]interface{}{"type": "boolean"},
+				"absent": map[string]interface{}{"type": "boolean"},
+			},
+			"required":             []string{"inc"},
+			"additionalProperties": false,
+		}
+	}
+	schema := map[string]interface{}{
+		"$schema": jsonSchemaDraft,
+		"title":   "Conjunction",
+		"type":    "object",
+		"properties": map[string]interface{}{
+			"exprs": map[string]interface{}{
+				"type":                 "object",
+				"properties":           exprs,
+				"additionalProperties": false,
+			},
+		},
+		"required": []string{"exprs"},
+	}
+	return json.MarshalIndent(schema, "", "  ")
+}
@@ -0,0 +1,67 @@
+package be_indexer
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/echoface/be_indexer/parser"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestGenerateConjunctionJSONSchema(t *testing.T) {
+	fields := []FieldDesc{
+		{Field: "city"},
+		{Field: "age", ParserName: parser.NumRangeParser},
+		{Field: "embedding", ParserName: parser.VectorSimParser},
+		{Field: "region", ParserName: "geo"},
+	}
+
+	convey.Convey("test generate conjunction json schema", t, func() {
+		data, err := GenerateConjunctionJSONSchema(fields)
+		convey.So(err, convey.ShouldBeNil)
+
+		schema := map[string]interface{}{}
+		convey.So(json.Unmarshal(data, &schema), convey.ShouldBeNil)
+		convey.So(schema["$schema"], convey.ShouldEqual, jsonSchemaDraft)
+		convey.So(schema["required"], convey.ShouldResemble, []interface{}{"exprs"})
+
+		exprs := schema["properties"].(map[string]interface{})["exprs"].(map[string]interface{})
+		convey.So(exprs["additionalProperties"], convey.ShouldBeFalse)
+		props := exprs["properties"].(map[string]interface{})
+		convey.So(len(props), convey.ShouldEqual, 4)
+
+		items := func(field string) map[string]interface{} {
+			expr := props[field].(map[string]interface{})
+			value := expr["properties"].(map[string]interface{})["value"].(map[string]interface{})
+			return value["items"].(map[string]interface{})
+		}
+		convey.So(items("city")["type"], convey.ShouldResemble, []interface{}{"string", "number"})
+		convey.So(items("age")["type"], convey.ShouldEqual, "string")
+		convey.So(items("embedding")["type"], convey.ShouldEqual, "array")
+		convey.So(items("region"), convey.ShouldBeEmpty)
+
+		// every property a conjunction serialized with must be described
+		conj := NewConjunction().
+			In("city", NewStrValues("sh")).
+			InAll("age", NewStrValues("1:5")).
+			Absent("region")
+		raw, _ := json.Marshal(conj)
+		decoded := map[string]map[string]map[string]interface{}{}
+		convey.So(json.Unmarshal(raw, &decoded), convey.ShouldBeNil)
+		for field, expr := range decoded["exprs"] {
+			exprProps := props[field].(map[string]interface{})["properties"].(map[string]interface{})
+			for name := range expr {
+				convey.So(exprProps, convey.ShouldContainKey, name)
+			}
+		}
+	})
+
+	convey.Convey("test generate schema with invalid fields", t, func() {
+		_, err := GenerateConjunctionJSONSchema(nil)
+		convey.So(err, convey.ShouldNotBeNil)
+		_, err = GenerateConjunctionJSONSchema([]FieldDesc{{Field: ""}})
+		convey.So(err, convey.ShouldNotBeNil)
+		_, err = GenerateConjunctionJSONSchema([]FieldDesc{{Field: "a"}, {Field: "a"}})
+		convey.So(err, convey.ShouldNotBeNil)
+	})
+}
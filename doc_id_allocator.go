@@ -0,0 +1,116 @@
+package be_indexer
+
+import (
+	"fmt"
+	"math"
+	"sync"
+)
+
+type (
+	/*DocIDAllocator map ids of an external system(eg: 64-bit campaign ids) to the DocIDs packed
+	into ConjID(32 bits), so callers add documents and read results by their own ids, see
+	IndexerBuilder.AddExternalDocument and ExternalIDs
+	*/
+	DocIDAllocator interface {
+		// AllocDocID return the DocID of external, allocate one if it's new
+		AllocDocID(external uint64) (DocID, error)
+		FindDocID(external uint64) (DocID, bool)
+		ExternalID(id DocID) (uint64, bool)
+	}
+
+	/*DenseDocIDAllocator allocate DocIDs densely from 1 in the order external ids first seen, an
+	allocated id is never reused(even the doc removed), so results of an index built before still
+	map back; it's safe for concurrent use, retrieving goroutines translate results while the
+	builder allocate ids for the next build
+	*/
+	DenseDocIDAllocator struct {
+		mtx      sync.RWMutex
+		docIDs   map[uint64]DocID
+		external []uint64 // DocID-1 -> external id
+	}
+)
+
+func NewDenseDocIDAllocator() *DenseDocIDAllocator {
+	return &DenseDocIDAllocator{
+		docIDs: make(map[uint64]DocID),
+	}
+}
+
+func (alloc *DenseDocIDAllocator) AllocDocID(external uint64) (DocID, error) {
+	if id, ok := alloc.FindDocID(external); ok {
+		return id, nil
+	}
+	alloc.mtx.Lock()
+	defer alloc.mtx.Unlock()
+	if id, ok := alloc.docIDs[external]; ok {
+		return id, nil
+	}
+	if uint64(len(alloc.external)) >= math.MaxUint32 {
+		return 0, fmt.Errorf("doc id exhausted, external id:%d", external)
+	}
+	alloc.external = append(alloc.external, external)
+	id := DocID(len(alloc.external))
+	alloc.docIDs[external] = id
+	return id, nil
+}
+
+func (alloc *DenseDocIDAllocator) FindDocID(external uint64) (DocID, bool) {
+	alloc.mtx.RLock()
+	defer alloc.mtx.RUnlock()
+	id, ok := alloc.docIDs[external]
+	return id, ok
+}
+
+func (alloc *DenseDocIDAllocator) ExternalID(id DocID) (uint64, bool) {
+	alloc.mtx.RLock()
+	defer alloc.mtx.RUnlock()
+	if id == 0 || int(id) > len(alloc.external) {
+		return 0, false
+	}
+	return alloc.external[id-1], true
+}
+
+// ExternalIDs translate ids(eg: a retrieving result) to the external ids, in order
+func ExternalIDs(alloc DocIDAllocator, ids DocIDList) ([]uint64, error) {
+	res := make([]uint64, 0, len(ids))
+	for _, id := range ids {
+		external, ok := alloc.ExternalID(id)
+		if !ok {
+			return nil, fmt.Errorf("doc:%d not allocated by the allocator", id)
+		}
+		res = append(res, external)
+	}
+	return res, nil
+}
+
+// WithDocIDAllocator the allocator AddExternalDocument/RemoveExternalDocument map external ids by
+func WithDocIDAllocator(alloc DocIDAllocator) BuilderOpt {
+	return func(builder *IndexerBuilder) {
+		builder.docIDAllocator = alloc
+	}
+}
+
+// AddExternalDocument add doc as the document of external id, doc.ID overwritten by the DocID
+// allocated(see WithDocIDAllocator), otherwise like AddDocument
+func (b *IndexerBuilder) AddExternalDocument(external uint64, doc *Document) error {
+	if b.docIDAllocator == nil {
+		return fmt.Errorf("no DocIDAllocator configured, see WithDocIDAllocator")
+	}
+	if doc != nil {
+		id, err := b.docIDAllocator.AllocDocID(external)
+		if err != nil {
+			return b.handleBadConj(err)
+		}
+		doc.ID = id
+	}
+	return b.AddDocument(doc)
+}
+
+// RemoveExternalDocument remove the document of external id, the DocID allocated is kept
+func (b *IndexerBuilder) RemoveExternalDocument(external uint64) bool {
+	if b.docIDAllocator == nil {
+		return false
+	}
+	id, ok := b.docIDAllocator.FindDocID(external)
+	return ok && b.RemoveDocument(id)
+}
@@ -0,0 +1,82 @@
+package be_indexer
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestDenseDocIDAllocator(t *testing.T) {
+	convey.Convey("test dense doc id allocation", t, func() {
+		alloc := NewDenseDocIDAllocator()
+		id, err := alloc.AllocDocID(1 << 40)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(id, convey.ShouldEqual, 1)
+		id, _ = alloc.AllocDocID(7)
+		convey.So(id, convey.ShouldEqual, 2)
+		id, _ = alloc.AllocDocID(1 << 40)
+		convey.So(id, convey.ShouldEqual, 1)
+
+		id, ok := alloc.FindDocID(7)
+		convey.So(ok, convey.ShouldBeTrue)
+		convey.So(id, convey.ShouldEqual, 2)
+		_, ok = alloc.FindDocID(8)
+		convey.So(ok, convey.ShouldBeFalse)
+
+		external, ok := alloc.ExternalID(1)
+		convey.So(ok, convey.ShouldBeTrue)
+		convey.So(external, convey.ShouldEqual, uint64(1<<40))
+		_, ok = alloc.ExternalID(0)
+		convey.So(ok, convey.ShouldBeFalse)
+		_, ok = alloc.ExternalID(3)
+		convey.So(ok, convey.ShouldBeFalse)
+
+		_, err = ExternalIDs(alloc, DocIDList{1, 3})
+		convey.So(err, convey.ShouldNotBeNil)
+	})
+}
+
+func TestIndexerBuilder_AddExternalDocument(t *testing.T) {
+	LogLevel = ErrorLevel
+
+	convey.Convey("test retrieve by external 64-bit ids", t, func() {
+		alloc := NewDenseDocIDAllocator()
+		b := NewIndexerBuilder(WithDocIDAllocator(alloc))
+		externals := []uint64{1<<63 + 5, 1 << 40, 9876543210123}
+		for i, external := range externals {
+			doc := NewDocument(0)
+			doc.AddConjunction(NewConjunction().In("tag", NewIntValues(i%2)))
+			convey.So(b.AddExternalDocument(external, doc), convey.ShouldBeNil)
+		}
+
+		for _, index := range []BEIndex{b.BuildIndex(), b.BuildCompactedIndex()} {
+			result, err := index.Retrieve(Assignments{"tag": NewIntValues(0)})
+			convey.So(err, convey.ShouldBeNil)
+			ids, err := ExternalIDs(alloc, result)
+			convey.So(err, convey.ShouldBeNil)
+			sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+			convey.So(ids, convey.ShouldResemble, []uint64{9876543210123, 1<<63 + 5})
+		}
+
+		convey.So(b.RemoveExternalDocument(externals[0]), convey.ShouldBeTrue)
+		convey.So(b.RemoveExternalDocument(42), convey.ShouldBeFalse)
+		doc := NewDocument(0)
+		doc.AddConjunction(NewConjunction().In("tag", NewIntValues(0)))
+		convey.So(b.AddExternalDocument(42, doc), convey.ShouldBeNil)
+		convey.So(doc.ID, convey.ShouldEqual, 4) // removed id not reused
+
+		result, err := b.BuildIndex().Retrieve(Assignments{"tag": NewIntValues(0)})
+		convey.So(err, convey.ShouldBeNil)
+		ids, _ := ExternalIDs(alloc, result)
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+		convey.So(ids, convey.ShouldResemble, []uint64{42, 9876543210123})
+	})
+
+	convey.Convey("test add external document without allocator", t, func() {
+		doc := NewDocument(0)
+		doc.AddConjunction(NewConjunction().In("tag", NewIntValues(1)))
+		convey.So(NewIndexerBuilder().AddExternalDocument(1, doc), convey.ShouldNotBeNil)
+		convey.So(NewIndexerBuilder().RemoveExternalDocument(1), convey.ShouldBeFalse)
+	})
+}
@@ -1,16 +1,33 @@
 package be_indexer
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"sort"
 )
 
 type (
 	DocID     uint32
 	DocIDList []DocID
 
+	// Document match a query when any of its conjunctions match(OR), so a size-0 conjunction(no In
+	// expression, eg: only NotIn) match every query except those hit its excluded values, no matter
+	// what the other conjunctions constrain; see WithRejectMixedWildcard to catch it as a data bug
 	Document struct {
 		ID   DocID          `json:"id"`   //只支持int32最大值个Doc
 		Cons []*Conjunction `json:"cons"` //conjunction之间的关系是或，具体描述可以看论文的表述
+
+		// AllowMixedWildcard mark mixing size-0 and constrained conjunctions is intended, skip WithRejectMixedWildcard
+		AllowMixedWildcard bool `json:"allow_mixed_wildcard,omitempty"`
+
+		// Labels stored in a side index of the built index, not a part of the boolean match(so they
+		// don't inflate conjunction size), matched docs can be narrowed by them, see WithLabelFilter
+		Labels []string `json:"labels,omitempty"`
+
+		// SortKey per doc attribute(eg: campaign start time) results can be ordered by without
+		// fetching payloads, nil means no key, see SetSortKey and WithOrderBySortKey
+		SortKey *int64 `json:"sort_key,omitempty"`
 	}
 )
 
@@ -21,6 +38,44 @@ func NewDocument(id DocID) *Document {
 	}
 }
 
+// SetSortKey set the sort key of doc, see WithOrderBySortKey
+func (doc *Document) SetSortKey(k int64) {
+	doc.SortKey = &k
+}
+
+/*
+Checksum sha256(hex) of the canonical form of doc: conjunctions in order(the index is a part of
+ConjID), expressions sorted by field, values formatted with their type(so 1 and "1" differ); labels
+and sort key included. it's stable across processes, see Manifest
+*/
+func (doc *Document) Checksum() string {
+	h := sha256.New()
+	fmt.Fprintf(h, "id:%d\n", doc.ID)
+	for idx, conj := range doc.Cons {
+		fields := make([]BEField, 0, len(conj.Expressions))
+		for field := range conj.Expressions {
+			fields = append(fields, field)
+		}
+		sort.Slice(fields, func(i, j int) bool {
+			return fields[i] < fields[j]
+		})
+		fmt.Fprintf(h, "conj:%d\n", idx)
+		for _, field := range fields {
+			expr := conj.Expressions[field]
+			fmt.Fprintf(h, "%s incl:%t all:%t absent:%t", field, expr.Incl, expr.All, expr.Absent)
+			for _, v := range expr.Value {
+				fmt.Fprintf(h, " %T:%v", v, v)
+			}
+			fmt.Fprintln(h)
+		}
+	}
+	fmt.Fprintf(h, "labels:%q\n", doc.Labels)
+	if doc.SortKey != nil {
+		fmt.Fprintf(h, "sort_key:%d\n", *doc.SortKey)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
 func (s DocIDList) Contain(id DocID) bool {
 	for _, v := range s {
 		if v == id {
@@ -58,13 +113,12 @@ func (doc *Document) AddConjunction(cons ...*Conjunction) {
 	}
 }
 
-//Prepare 计算生成doc内部的私有数据
+//Prepare 计算生成doc内部的私有数据, the count of conjunctions is validated when added(see WithMaxConjunctionsPerDocument)
 func (doc *Document) Prepare() {
-	if len(doc.Cons) >= 0xFF {
-		panic(fmt.Errorf("max 256 conjuctions per document limitation"))
-	}
 	for idx, conj := range doc.Cons {
 		size := conj.CalcConjSize()
-		conj.id = NewConjID(doc.ID, idx, size)
+		if id := NewConjID(doc.ID, idx, size); conj.id != id { // a prepared doc only read, see LiveIndex
+			conj.id = id
+		}
 	}
 }
@@ -0,0 +1,158 @@
+package be_indexer
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+type (
+	/*CSVMapping how a csv(with a header row) mapped to documents, every row become a document of
+	one conjunction: In expressions of the mapped fields with non-empty cells, other columns ignored.
+	values are strings as they are in csv(eg: "18"), query them with NewStrValues.
+	*/
+	CSVMapping struct {
+		IDColumn  string             // header of the DocID column
+		Fields    map[string]BEField // header of column -> field, a field mapped by one column at most
+		Delimiter string             // split a cell into multi values, "" means a cell is one value
+		Comma     rune               // column separator, 0 means ','
+	}
+
+	csvDocumentIterator struct {
+		reader  *csv.Reader
+		mapping CSVMapping
+		idIdx   int
+		fields  map[int]BEField // column index -> field
+		started bool
+		broken  error
+	}
+)
+
+// NewCSVDocumentIterator iterate documents of csv row by row, see CSVMapping
+func NewCSVDocumentIterator(r io.Reader, mapping CSVMapping) DocumentIterator {
+	reader := csv.NewReader(r)
+	if mapping.Comma != 0 {
+		reader.Comma = mapping.Comma
+	}
+	reader.ReuseRecord = true
+	return &csvDocumentIterator{reader: reader, mapping: mapping}
+}
+
+func (it *csvDocumentIterator) readHeader() error {
+	header, err := it.reader.Read()
+	if err != nil {
+		return err
+	}
+	columns := make(map[string]int, len(header))
+	for idx, column := range header {
+		columns[strings.TrimSpace(column)] = idx
+	}
+	var ok bool
+	if it.idIdx, ok = columns[it.mapping.IDColumn]; !ok {
+		return fmt.Errorf("id column:%s not found in header", it.mapping.IDColumn)
+	}
+	it.fields = make(map[int]BEField, len(it.mapping.Fields))
+	mapped := make(map[BEField]string, len(it.mapping.Fields))
+	for column, field := range it.mapping.Fields {
+		idx, ok := columns[column]
+		if !ok {
+			return fmt.Errorf("column:%s of field:%s not found in header", column, field)
+		}
+		if other, ok := mapped[field]; ok { // one expression a field in a conjunction
+			return fmt.Errorf("column:%s and column:%s mapped to same field:%s", other, column, field)
+		}
+		mapped[field] = column
+		it.fields[idx] = field
+	}
+	return nil
+}
+
+func (it *csvDocumentIterator) Next() (*Document, error) {
+	if it.broken != nil {
+		return nil, it.broken
+	}
+	if !it.started {
+		it.started = true
+		if err := it.readHeader(); err == io.EOF { // empty input, no document
+			it.broken = io.EOF
+			return nil, io.EOF
+		} else if err != nil {
+			return nil, it.markBroken(err)
+		}
+	}
+	record, err := it.reader.Read()
+	if err == io.EOF {
+		return nil, io.EOF
+	}
+	if err != nil {
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) && parseErr.Err == csv.ErrFieldCount { // the row consumed, can move on
+			return nil, err
+		}
+		return nil, it.markBroken(err)
+	}
+	id, err := strconv.ParseUint(strings.TrimSpace(record[it.idIdx]), 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid doc id:%s, e:%w", record[it.idIdx], err)
+	}
+	conj := NewConjunction()
+	for idx, field := range it.fields {
+		if values := it.splitCell(record[idx]); len(values) > 0 {
+			conj.In(field, values)
+		}
+	}
+	if len(conj.Expressions) == 0 {
+		return nil, fmt.Errorf("doc:%d has no value of mapped fields", id)
+	}
+	doc := NewDocument(DocID(id))
+	doc.AddConjunction(conj)
+	return doc, nil
+}
+
+func (it *csvDocumentIterator) splitCell(cell string) Values {
+	parts := []string{cell}
+	if it.mapping.Delimiter != "" {
+		parts = strings.Split(cell, it.mapping.Delimiter)
+	}
+	values := make(Values, 0, len(parts))
+	for _, part := range parts {
+		if part = strings.TrimSpace(part); part != "" {
+			values = append(values, part)
+		}
+	}
+	return values
+}
+
+func (it *csvDocumentIterator) markBroken(err error) error {
+	it.broken = fmt.Errorf("%w: %s", ErrIteratorBroken, err.Error())
+	return it.broken
+}
+
+// AddDocumentsFromCSV add documents of csv(see CSVMapping) and return the count of documents added,
+// bad rows handled according badConjBehavior like AddFromIterator
+func (b *IndexerBuilder) AddDocumentsFromCSV(r io.Reader, mapping CSVMapping) (added int, err error) {
+	it := NewCSVDocumentIterator(r, mapping)
+	for {
+		doc, err := it.Next()
+		if err == io.EOF {
+			return added, nil
+		}
+		if err == nil {
+			err = b.checkDocument(doc)
+		}
+		if err != nil {
+			if errors.Is(err, ErrIteratorBroken) {
+				return added, err
+			}
+			if err = b.handleBadConj(err); err != nil {
+				return added, err
+			}
+			continue
+		}
+		b.addDocument(doc)
+		added++
+	}
+}
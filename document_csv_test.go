@@ -0,0 +1,101 @@
+package be_indexer
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+const testCampaignCSV = `campaign_id,name,city,os,age
+1,spring sale,sh|bj,ios,18
+2,summer sale,gz,android|ios,
+3,autumn sale,,android,30
+4,winter sale,sh,,18|30
+`
+
+func TestIndexerBuilder_AddDocumentsFromCSV(t *testing.T) {
+	LogLevel = ErrorLevel
+
+	mapping := CSVMapping{
+		IDColumn:  "campaign_id",
+		Fields:    map[string]BEField{"city": "city", "os": "os", "age": "age"},
+		Delimiter: "|",
+	}
+
+	convey.Convey("test csv docs retrieve the same as hand built docs", t, func() {
+		b := NewIndexerBuilder()
+		added, err := b.AddDocumentsFromCSV(strings.NewReader(testCampaignCSV), mapping)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(added, convey.ShouldEqual, 4)
+
+		expect := NewIndexerBuilder()
+		conjs := []*Conjunction{
+			NewConjunction().In("city", NewStrValues("sh", "bj")).In("os", NewStrValues("ios")).In("age", NewStrValues("18")),
+			NewConjunction().In("city", NewStrValues("gz")).In("os", NewStrValues("android", "ios")),
+			NewConjunction().In("os", NewStrValues("android")).In("age", NewStrValues("30")),
+			NewConjunction().In("city", NewStrValues("sh")).In("age", NewStrValues("18", "30")),
+		}
+		for i, conj := range conjs {
+			doc := NewDocument(DocID(i + 1))
+			doc.AddConjunction(conj)
+			expect.AddDocument(doc)
+		}
+
+		queries := []Assignments{
+			{"city": NewStrValues("sh"), "os": NewStrValues("ios"), "age": NewStrValues("18")},
+			{"city": NewStrValues("gz"), "os": NewStrValues("ios")},
+			{"os": NewStrValues("android"), "age": NewStrValues("30")},
+			{"city": NewStrValues("sh", "bj"), "age": NewStrValues("30")},
+			{"city": NewStrValues("sh")},
+		}
+		indexes := []BEIndex{b.BuildIndex(), b.BuildCompactedIndex()}
+		expectIndexes := []BEIndex{expect.BuildIndex(), expect.BuildCompactedIndex()}
+		for i, index := range indexes {
+			for _, query := range queries {
+				result, err := index.Retrieve(query)
+				convey.So(err, convey.ShouldBeNil)
+				expected, err := expectIndexes[i].Retrieve(query)
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(len(result), convey.ShouldEqual, len(expected))
+				convey.So(result.Sub(expected), convey.ShouldBeEmpty)
+			}
+		}
+	})
+
+	convey.Convey("test csv bad rows", t, func() {
+		data := testCampaignCSV + "x,bad id,sh,ios,18\n5,too few columns\n6,no field,,,\n7,ok,sh,ios,18\n"
+		_, err := NewIndexerBuilder(WithBadConjBehavior(ErrorBadConj)).AddDocumentsFromCSV(strings.NewReader(data), mapping)
+		convey.So(err, convey.ShouldNotBeNil)
+
+		b := NewIndexerBuilder(WithBadConjBehavior(SkipBadConj))
+		added, err := b.AddDocumentsFromCSV(strings.NewReader(data), mapping)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(added, convey.ShouldEqual, 5)
+		convey.So(b.Documents, convey.ShouldContainKey, DocID(7))
+
+		// header not match the mapping, can't move on
+		_, err = b.AddDocumentsFromCSV(strings.NewReader("id,city\n1,sh\n"), mapping)
+		convey.So(errors.Is(err, ErrIteratorBroken), convey.ShouldBeTrue)
+
+		// two columns mapped to one field
+		dup := CSVMapping{IDColumn: "campaign_id", Fields: map[string]BEField{"city": "geo", "os": "os", "age": "geo"}}
+		_, err = b.AddDocumentsFromCSV(strings.NewReader(testCampaignCSV), dup)
+		convey.So(errors.Is(err, ErrIteratorBroken), convey.ShouldBeTrue)
+		convey.So(err.Error(), convey.ShouldContainSubstring, "same field:geo")
+	})
+
+	convey.Convey("test empty csv has no document", t, func() {
+		it := NewCSVDocumentIterator(strings.NewReader(""), mapping)
+		for i := 0; i < 2; i++ {
+			doc, err := it.Next()
+			convey.So(doc, convey.ShouldBeNil)
+			convey.So(err, convey.ShouldEqual, io.EOF)
+		}
+		added, err := NewIndexerBuilder().AddDocumentsFromCSV(strings.NewReader(""), mapping)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(added, convey.ShouldEqual, 0)
+	})
+}
@@ -0,0 +1,111 @@
+package be_indexer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+var (
+	// ErrIteratorBroken an iterator wrap its error with ErrIteratorBroken when it can't move on any more,
+	// AddFromIterator always abort on such error no matter what badConjBehavior is
+	ErrIteratorBroken = errors.New("document iterator broken")
+)
+
+type (
+	/*DocumentIterator produce documents one by one
+	Next return io.EOF when no more document; other error mean the current document is bad,
+	and the iterator can still move on unless the error wrapped ErrIteratorBroken
+	*/
+	DocumentIterator interface {
+		Next() (*Document, error)
+	}
+
+	chanDocumentIterator struct {
+		ch <-chan *Document
+	}
+
+	jsonDocumentIterator struct {
+		decoder *json.Decoder
+		started bool
+		broken  error
+	}
+)
+
+// NewChanDocumentIterator iterate documents received from ch until it closed
+func NewChanDocumentIterator(ch <-chan *Document) DocumentIterator {
+	return &chanDocumentIterator{ch: ch}
+}
+
+func (it *chanDocumentIterator) Next() (*Document, error) {
+	doc, ok := <-it.ch
+	if !ok {
+		return nil, io.EOF
+	}
+	return doc, nil
+}
+
+// NewJSONDocumentIterator iterate documents of a json array(the format of test_data/test_docs.json)
+// element by element, so the whole array never be loaded into memory
+func NewJSONDocumentIterator(r io.Reader) DocumentIterator {
+	return &jsonDocumentIterator{decoder: json.NewDecoder(r)}
+}
+
+func (it *jsonDocumentIterator) Next() (*Document, error) {
+	if it.broken != nil {
+		return nil, it.broken
+	}
+	if !it.started {
+		it.started = true
+		token, err := it.decoder.Token()
+		if err != nil {
+			return nil, it.markBroken(err)
+		}
+		if delim, ok := token.(json.Delim); !ok || delim != '[' {
+			return nil, it.markBroken(fmt.Errorf("json array expected, got:%v", token))
+		}
+	}
+	if !it.decoder.More() {
+		return nil, io.EOF
+	}
+	doc := &Document{}
+	if err := it.decoder.Decode(doc); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) { // the whole element has been consumed, can move on
+			return nil, err
+		}
+		return nil, it.markBroken(err)
+	}
+	return doc, nil
+}
+
+func (it *jsonDocumentIterator) markBroken(err error) error {
+	it.broken = fmt.Errorf("%w: %s", ErrIteratorBroken, err.Error())
+	return it.broken
+}
+
+// AddFromIterator add documents from iterator one by one until io.EOF, so caller needn't load all
+// documents into a slice first; both a bad document and a error from iterator be handled according
+// badConjBehavior, except the iterator is broken. note: the added documents are still held by the
+// builder until the index built, see Documents
+func (b *IndexerBuilder) AddFromIterator(it DocumentIterator) error {
+	for {
+		doc, err := it.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			if errors.Is(err, ErrIteratorBroken) {
+				return err
+			}
+			if err = b.handleBadConj(err); err != nil {
+				return err
+			}
+			continue
+		}
+		if err = b.AddDocument(doc); err != nil {
+			return err
+		}
+	}
+}
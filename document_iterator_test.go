@@ -0,0 +1,154 @@
+package be_indexer
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+type mockDocIterator struct {
+	idx   int
+	items []interface{} // *Document or error
+}
+
+func (it *mockDocIterator) Next() (*Document, error) {
+	if it.idx >= len(it.items) {
+		return nil, io.EOF
+	}
+	item := it.items[it.idx]
+	it.idx++
+	if err, ok := item.(error); ok {
+		return nil, err
+	}
+	return item.(*Document), nil
+}
+
+func newMockDocIterator(failAt int, failErr error) *mockDocIterator {
+	it := &mockDocIterator{}
+	for i := 1; i <= 5; i++ {
+		if i == failAt {
+			it.items = append(it.items, failErr)
+			continue
+		}
+		doc := NewDocument(DocID(i))
+		doc.AddConjunction(NewConjunction().In("tag", NewIntValues(i)))
+		it.items = append(it.items, doc)
+	}
+	return it
+}
+
+func builderDocIDs(b *IndexerBuilder) (ids DocIDList) {
+	for id := range b.Documents {
+		ids = append(ids, id)
+	}
+	sort.Sort(ids)
+	return ids
+}
+
+func TestIndexerBuilder_AddFromIterator(t *testing.T) {
+	LogLevel = ErrorLevel
+
+	convey.Convey("test iterator error mid-stream with ErrorBadConj", t, func() {
+		b := NewIndexerBuilder(WithBadConjBehavior(ErrorBadConj))
+		err := b.AddFromIterator(newMockDocIterator(3, errors.New("bad doc")))
+		convey.So(err, convey.ShouldNotBeNil)
+		convey.So(builderDocIDs(b), convey.ShouldResemble, DocIDList{1, 2})
+	})
+
+	convey.Convey("test iterator error mid-stream with SkipBadConj", t, func() {
+		b := NewIndexerBuilder(WithBadConjBehavior(SkipBadConj))
+		err := b.AddFromIterator(newMockDocIterator(3, errors.New("bad doc")))
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(builderDocIDs(b), convey.ShouldResemble, DocIDList{1, 2, 4, 5})
+
+		index := b.BuildIndex()
+		ids, err := index.Retrieve(Assignments{"tag": NewIntValues(3, 4)})
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(ids, convey.ShouldResemble, DocIDList{4})
+	})
+
+	convey.Convey("test broken iterator abort with SkipBadConj", t, func() {
+		b := NewIndexerBuilder(WithBadConjBehavior(SkipBadConj))
+		brokenErr := fmt.Errorf("%w: connection lost", ErrIteratorBroken)
+		err := b.AddFromIterator(newMockDocIterator(3, brokenErr))
+		convey.So(errors.Is(err, ErrIteratorBroken), convey.ShouldBeTrue)
+		convey.So(builderDocIDs(b), convey.ShouldResemble, DocIDList{1, 2})
+	})
+
+	convey.Convey("test invalid document from iterator", t, func() {
+		it := newMockDocIterator(3, nil)
+		it.items[2] = &Document{ID: 3, Cons: []*Conjunction{NewConjunction()}}
+
+		b := NewIndexerBuilder(WithBadConjBehavior(SkipBadConj))
+		convey.So(b.AddFromIterator(it), convey.ShouldBeNil)
+		convey.So(builderDocIDs(b), convey.ShouldResemble, DocIDList{1, 2, 4, 5})
+
+		it.idx = 0
+		b = NewIndexerBuilder(WithBadConjBehavior(ErrorBadConj))
+		convey.So(b.AddFromIterator(it), convey.ShouldNotBeNil)
+		convey.So(builderDocIDs(b), convey.ShouldResemble, DocIDList{1, 2})
+
+		it.idx = 0
+		b = NewIndexerBuilder()
+		convey.So(func() { _ = b.AddFromIterator(it) }, convey.ShouldPanic)
+	})
+}
+
+func TestNewChanDocumentIterator(t *testing.T) {
+	convey.Convey("test channel document iterator", t, func() {
+		ch := make(chan *Document)
+		go func() {
+			for _, item := range newMockDocIterator(0, nil).items {
+				ch <- item.(*Document)
+			}
+			close(ch)
+		}()
+		b := NewIndexerBuilder()
+		convey.So(b.AddFromIterator(NewChanDocumentIterator(ch)), convey.ShouldBeNil)
+		convey.So(builderDocIDs(b), convey.ShouldResemble, DocIDList{1, 2, 3, 4, 5})
+	})
+}
+
+func TestNewJSONDocumentIterator(t *testing.T) {
+	LogLevel = ErrorLevel
+
+	convey.Convey("test json document iterator", t, func() {
+		f, err := os.Open("./test_data/test_docs.json")
+		convey.So(err, convey.ShouldBeNil)
+		defer f.Close()
+
+		b := NewIndexerBuilder()
+		convey.So(b.AddFromIterator(NewJSONDocumentIterator(f)), convey.ShouldBeNil)
+
+		var expect DocIDList
+		for _, doc := range buildTestDoc() {
+			expect = append(expect, doc.ID)
+		}
+		sort.Sort(expect)
+		convey.So(builderDocIDs(b), convey.ShouldResemble, expect)
+	})
+
+	convey.Convey("test json document iterator with bad element", t, func() {
+		content := `[{"id": 1, "cons": [{"exprs": {"tag": {"inc": true, "value": [1]}}}]},
+			{"id": "bad_id", "cons": []},
+			{"id": 3, "cons": [{"exprs": {"tag": {"inc": true, "value": [3]}}}]}]`
+
+		b := NewIndexerBuilder(WithBadConjBehavior(SkipBadConj))
+		convey.So(b.AddFromIterator(NewJSONDocumentIterator(strings.NewReader(content))), convey.ShouldBeNil)
+		convey.So(builderDocIDs(b), convey.ShouldResemble, DocIDList{1, 3})
+
+		b = NewIndexerBuilder(WithBadConjBehavior(ErrorBadConj))
+		convey.So(b.AddFromIterator(NewJSONDocumentIterator(strings.NewReader(content))), convey.ShouldNotBeNil)
+		convey.So(builderDocIDs(b), convey.ShouldResemble, DocIDList{1})
+
+		b = NewIndexerBuilder(WithBadConjBehavior(SkipBadConj))
+		err := b.AddFromIterator(NewJSONDocumentIterator(strings.NewReader(`[{"id": 1}, {"id": `)))
+		convey.So(errors.Is(err, ErrIteratorBroken), convey.ShouldBeTrue)
+	})
+}
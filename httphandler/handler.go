@@ -0,0 +1,118 @@
+// Package httphandler expose health, metrics and inspection endpoints of a BEIndex
+package httphandler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/echoface/be_indexer"
+)
+
+const (
+	contentTypeJSON       = "application/json; charset=utf-8"
+	contentTypePrometheus = "text/plain; version=0.0.4; charset=utf-8"
+)
+
+type (
+	handler struct {
+		index be_indexer.BEIndex
+		mux   *http.ServeMux
+	}
+
+	healthResponse struct {
+		Status   string `json:"status"`
+		DocCount int    `json:"doc_count"`
+	}
+)
+
+// NewHTTPHandler create a http.Handler serving:
+// GET /health: {"status":"ok","doc_count":N}
+// GET /metrics: index statistics in prometheus text exposition format
+// GET /stats: json encoded be_indexer.IndexStatistics
+// GET /fields: json encoded be_indexer.FieldDescriptions
+func NewHTTPHandler(idx be_indexer.BEIndex) http.Handler {
+	h := &handler{
+		index: idx,
+		mux:   http.NewServeMux(),
+	}
+	h.mux.HandleFunc("/health", h.onlyGet(h.health))
+	h.mux.HandleFunc("/metrics", h.onlyGet(h.metrics))
+	h.mux.HandleFunc("/stats", h.onlyGet(h.stats))
+	h.mux.HandleFunc("/fields", h.onlyGet(h.fields))
+	return h
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *handler) onlyGet(fn http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		fn(w, r)
+	}
+}
+
+func (h *handler) health(w http.ResponseWriter, _ *http.Request) {
+	if h.index == nil {
+		writeJSON(w, http.StatusServiceUnavailable, &healthResponse{Status: "unavailable"})
+		return
+	}
+	writeJSON(w, http.StatusOK, &healthResponse{Status: "ok", DocCount: h.index.Stats().DocCount})
+}
+
+func (h *handler) metrics(w http.ResponseWriter, _ *http.Request) {
+	if h.index == nil {
+		http.Error(w, "index unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	stats := h.index.Stats()
+	w.Header().Set("Content-Type", contentTypePrometheus)
+	w.WriteHeader(http.StatusOK)
+	for _, m := range []struct {
+		name  string
+		help  string
+		value int
+	}{
+		{"be_indexer_docs", "Number of documents indexed.", stats.DocCount},
+		{"be_indexer_conjunctions", "Number of conjunctions indexed.", stats.ConjunctionCount},
+		{"be_indexer_fields", "Number of fields configured.", stats.FieldCount},
+		{"be_indexer_posting_lists", "Number of posting lists.", stats.PostingListCount},
+		{"be_indexer_entries", "Number of entries of all posting lists.", stats.EntryCount},
+		{"be_indexer_wildcard_entries", "Number of entries of wildcard posting list.", stats.WildcardEntryCount},
+	} {
+		_, _ = fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n%s %d\n", m.name, m.help, m.name, m.name, m.value)
+	}
+}
+
+func (h *handler) stats(w http.ResponseWriter, _ *http.Request) {
+	if h.index == nil {
+		http.Error(w, "index unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	writeJSON(w, http.StatusOK, h.index.Stats())
+}
+
+func (h *handler) fields(w http.ResponseWriter, _ *http.Request) {
+	if h.index == nil {
+		http.Error(w, "index unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	writeJSON(w, http.StatusOK, h.index.FieldDescriptions())
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", contentTypeJSON)
+	w.WriteHeader(code)
+	_, _ = w.Write(data)
+}
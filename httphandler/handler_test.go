@@ -0,0 +1,82 @@
+package httphandler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/echoface/be_indexer"
+	"github.com/echoface/be_indexer/parser"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func newTestIndex() be_indexer.BEIndex {
+	be_indexer.LogLevel = be_indexer.ErrorLevel
+
+	b := be_indexer.NewIndexerBuilder()
+	b.SetFieldParser("age", parser.NumRangeParser)
+
+	doc := be_indexer.NewDocument(1)
+	doc.AddConjunction(be_indexer.NewConjunction().In("age", be_indexer.NewStrValues("10:20")))
+	_ = b.AddDocument(doc)
+
+	doc = be_indexer.NewDocument(2)
+	doc.AddConjunction(
+		be_indexer.NewConjunction().In("tag", be_indexer.NewStrValues("a")),
+		be_indexer.NewConjunction().NotIn("tag", be_indexer.NewStrValues("b")))
+	_ = b.AddDocument(doc)
+	return b.BuildIndex()
+}
+
+func serve(h http.Handler, method, path string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+	return rec
+}
+
+func TestNewHTTPHandler(t *testing.T) {
+	convey.Convey("test http handler endpoints", t, func() {
+		index := newTestIndex()
+		h := NewHTTPHandler(index)
+
+		rec := serve(h, http.MethodGet, "/health")
+		convey.So(rec.Code, convey.ShouldEqual, http.StatusOK)
+		convey.So(rec.Header().Get("Content-Type"), convey.ShouldStartWith, "application/json")
+		convey.So(rec.Body.String(), convey.ShouldEqual, `{"status":"ok","doc_count":2}`)
+
+		rec = serve(h, http.MethodGet, "/stats")
+		convey.So(rec.Code, convey.ShouldEqual, http.StatusOK)
+		stats := be_indexer.IndexStatistics{}
+		convey.So(json.Unmarshal(rec.Body.Bytes(), &stats), convey.ShouldBeNil)
+		convey.So(stats, convey.ShouldResemble, index.Stats())
+		convey.So(stats.ConjunctionCount, convey.ShouldEqual, 3)
+		convey.So(stats.FieldCount, convey.ShouldEqual, 2)
+
+		rec = serve(h, http.MethodGet, "/fields")
+		convey.So(rec.Code, convey.ShouldEqual, http.StatusOK)
+		fields := be_indexer.FieldDescriptions{}
+		convey.So(json.Unmarshal(rec.Body.Bytes(), &fields), convey.ShouldBeNil)
+		convey.So(fields, convey.ShouldResemble, index.FieldDescriptions())
+		convey.So(len(fields), convey.ShouldEqual, 2)
+		convey.So(fields[0].Field, convey.ShouldEqual, "age")
+		convey.So(fields[0].Parser, convey.ShouldEqual, parser.NumRangeParser)
+
+		rec = serve(h, http.MethodGet, "/metrics")
+		convey.So(rec.Code, convey.ShouldEqual, http.StatusOK)
+		convey.So(rec.Header().Get("Content-Type"), convey.ShouldStartWith, "text/plain")
+		convey.So(rec.Body.String(), convey.ShouldContainSubstring, "# TYPE be_indexer_docs gauge\nbe_indexer_docs 2\n")
+
+		convey.So(serve(h, http.MethodPost, "/stats").Code, convey.ShouldEqual, http.StatusMethodNotAllowed)
+		convey.So(serve(h, http.MethodGet, "/unknown").Code, convey.ShouldEqual, http.StatusNotFound)
+	})
+
+	convey.Convey("test http handler without index", t, func() {
+		h := NewHTTPHandler(nil)
+		rec := serve(h, http.MethodGet, "/health")
+		convey.So(rec.Code, convey.ShouldEqual, http.StatusServiceUnavailable)
+		convey.So(strings.Contains(rec.Body.String(), "unavailable"), convey.ShouldBeTrue)
+		convey.So(serve(h, http.MethodGet, "/metrics").Code, convey.ShouldEqual, http.StatusServiceUnavailable)
+	})
+}
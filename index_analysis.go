@@ -0,0 +1,64 @@
+package be_indexer
+
+import (
+	"time"
+)
+
+type (
+	/*RetrievalAnalysis the actual work of a retrieving broken down, see RetrieveWithAnalysis; unlike
+	CostEstimate it's measured, for comparing query plans and detecting regressions automatically.
+	fields are the indexed ones: the wildcard field for size-0 conjunctions, the sub-fields of InAll
+	and NamespacedField as they are. the coarse pass of two pass retrieving is not included.
+	*/
+	RetrievalAnalysis struct {
+		// PerFieldCursorAdvancements cursor advancements of the scanners of every field, they sum
+		// to RetrieveTrace.CursorAdvancements
+		PerFieldCursorAdvancements map[BEField]int64 `json:"per_field_cursor_advancements"`
+
+		// PerKGroupIterations scanning steps taken on conjunctions of every size(K)
+		PerKGroupIterations map[int]int64 `json:"per_k_group_iterations"`
+
+		TotalDuration time.Duration `json:"total_duration"`
+
+		// WildcardMatchCount hits of size-0 conjunctions collected, a doc counted once per conjunction
+		WildcardMatchCount int `json:"wildcard_match_count"`
+	}
+)
+
+// retrieveWithAnalysis run retrieve with the analysis of ctx enabled
+func retrieveWithAnalysis(retrieve func(Assignments, ...IndexOpt) (DocIDList, error),
+	queries Assignments, opts ...IndexOpt) (DocIDList, RetrievalAnalysis, error) {
+
+	analysis := RetrievalAnalysis{
+		PerFieldCursorAdvancements: make(map[BEField]int64),
+		PerKGroupIterations:        make(map[int]int64),
+	}
+	opts = append(opts[:len(opts):len(opts)], func(ctx *RetrieveContext) {
+		ctx.analysis = &analysis
+	})
+	start := time.Now()
+	result, err := retrieve(queries, opts...)
+	analysis.TotalDuration = time.Since(start)
+	return result, analysis, err
+}
+
+func (bi *SizeGroupedBEIndex) RetrieveWithAnalysis(queries Assignments, opts ...IndexOpt) (DocIDList, RetrievalAnalysis, error) {
+	return retrieveWithAnalysis(bi.Retrieve, queries, opts...)
+}
+
+func (bi *CompactedBEIndex) RetrieveWithAnalysis(queries Assignments, opts ...IndexOpt) (DocIDList, RetrievalAnalysis, error) {
+	return retrieveWithAnalysis(bi.Retrieve, queries, opts...)
+}
+
+// analyzeIteration record a scanning step on conjunctions of size k advancing the cursors of advanced
+func (ctx *RetrieveContext) analyzeIteration(k int, advanced FieldScanners) {
+	ctx.analysis.PerKGroupIterations[k]++
+	for _, scanner := range advanced {
+		ctx.analysis.PerFieldCursorAdvancements[scanner.field]++
+	}
+}
+
+// analyzeAdvance record a advancement of the cursor of scanner
+func (ctx *RetrieveContext) analyzeAdvance(scanner *FieldScanner) {
+	ctx.analysis.PerFieldCursorAdvancements[scanner.field]++
+}
@@ -0,0 +1,61 @@
+package be_indexer
+
+import (
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestBEIndex_RetrieveWithAnalysis(t *testing.T) {
+	LogLevel = ErrorLevel
+
+	b := NewIndexerBuilder()
+	for id := 1; id <= 30; id++ {
+		conj := NewConjunction()
+		switch id % 3 {
+		case 0:
+			conj.In("tag", NewIntValues(id%2))
+		case 1:
+			conj.In("tag", NewIntValues(id%2)).In("city", NewStrValues("sh"))
+		default:
+			conj.NotIn("city", NewStrValues("bj"))
+		}
+		doc := NewDocument(DocID(id))
+		doc.AddConjunction(conj)
+		_ = b.AddDocument(doc)
+	}
+
+	convey.Convey("test analysis break down the work of retrieving", t, func() {
+		index := b.BuildIndex()
+		for _, index := range []BEIndex{index, b.BuildCompactedIndex(), NewAtomicIndexHandle(index)} {
+			query := Assignments{"tag": NewIntValues(1), "city": NewStrValues("sh")}
+			expect, _ := index.Retrieve(query)
+
+			trace := RetrieveTrace{}
+			result, analysis, err := index.RetrieveWithAnalysis(query, WithRetrieveTrace(&trace))
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(len(result), convey.ShouldEqual, len(expect))
+			convey.So(result.Sub(expect), convey.ShouldBeEmpty)
+			convey.So(len(result), convey.ShouldEqual, 20) // 5 + 5 + 10 wildcard
+
+			var sum int64
+			for _, cnt := range analysis.PerFieldCursorAdvancements {
+				sum += cnt
+			}
+			convey.So(sum, convey.ShouldEqual, trace.CursorAdvancements)
+			convey.So(analysis.PerFieldCursorAdvancements["tag"], convey.ShouldBeGreaterThan, 0)
+			convey.So(analysis.PerFieldCursorAdvancements[wildcardField], convey.ShouldBeGreaterThan, 0)
+			for _, k := range []int{0, 1, 2} {
+				convey.So(analysis.PerKGroupIterations[k], convey.ShouldBeGreaterThan, 0)
+			}
+			convey.So(analysis.WildcardMatchCount, convey.ShouldEqual, 10)
+			convey.So(analysis.TotalDuration, convey.ShouldBeGreaterThan, 0)
+
+			result, analysis, err = index.RetrieveWithAnalysis(Assignments{"city": NewStrValues("bj")})
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(result, convey.ShouldBeEmpty)
+			convey.So(analysis.WildcardMatchCount, convey.ShouldEqual, 0)
+			convey.So(analysis.PerFieldCursorAdvancements["city"], convey.ShouldBeGreaterThan, 0)
+		}
+	})
+}
@@ -0,0 +1,153 @@
+package be_indexer
+
+type (
+	// StoreOpt option of VersionedIndexStore
+	StoreOpt func(s *VersionedIndexStore)
+)
+
+// WithAutoCompact compact the index committed when its fragmentation(duplicated entries and empty
+// keys / all entries) exceed threshold(< 0 taken as 0); a committed index never change, so it's
+// checked once by Commit. compaction run on a copy-on-write clone, the index given to Commit is untouched
+func WithAutoCompact(threshold float64) StoreOpt {
+	return func(s *VersionedIndexStore) {
+		if threshold < 0 {
+			threshold = 0
+		}
+		s.autoCompact = true
+		s.compactThreshold = threshold
+	}
+}
+
+// clone a PostingEntries sharing the lists with kse, compact on either swap in its own lists only
+func (kse *PostingEntries) clone() *PostingEntries {
+	cloned := &PostingEntries{lazy: kse.lazy}
+	cloned.lists.Store(kse.load())
+	return cloned
+}
+
+// waste count duplicated entries and empty keys of posting lists, see compact
+func (kse *PostingEntries) waste() (wasted, total int) {
+	kse.eachList(func(_ Key, entries Entries) {
+		if len(entries) == 0 {
+			wasted++
+			return
+		}
+		wasted += entriesWaste(entries)
+		total += len(entries)
+	})
+	return wasted, total
+}
+
+func entriesWaste(entries Entries) (wasted int) {
+	for i := 1; i < len(entries); i++ {
+		if entries[i] == entries[i-1] {
+			wasted++
+		}
+	}
+	return wasted
+}
+
+func fragmentationRatio(wasted, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(wasted) / float64(total)
+}
+
+// cloneTo copy bi into clone field by field sharing the maps(never written once built), the atomic
+// holders get fresh ones loaded with the values of bi, sync/atomic forbid copying them after use
+func (bi *indexBase) cloneTo(clone *indexBase) {
+	clone.idAllocator = bi.idAllocator
+	clone.fieldDesc = bi.fieldDesc
+	clone.idToField = bi.idToField
+	clone.docConjs = bi.docConjs
+	clone.allOfFields = bi.allOfFields
+	clone.absentFields = bi.absentFields
+	clone.requiredFields = bi.requiredFields
+	clone.defaultOption = bi.defaultOption
+	clone.sharePostingLists = bi.sharePostingLists
+	clone.noPanics = bi.noPanics
+	clone.lazyCompile = bi.lazyCompile
+	clone.backgroundCompile = bi.backgroundCompile
+	clone.orderWeighted = bi.orderWeighted
+	clone.labelDocs = bi.labelDocs
+	clone.docNamespaces = bi.docNamespaces
+	clone.sortKeys = bi.sortKeys
+	clone.conjFields = bi.conjFields
+	clone.nearMissAnalysis = bi.nearMissAnalysis
+	clone.conjHashes = bi.conjHashes
+	clone.mutationSeq = bi.mutationSeq
+	clone.schemaVersion = bi.schemaVersion
+	clone.fingerprint = bi.fingerprint
+	clone.reverseLookup = bi.reverseLookup
+	if docs, ok := bi.wildcardDocs.Load().(DocIDList); ok {
+		clone.wildcardDocs.Store(docs) // Compact only drop duplicates, the matches stay
+	}
+}
+
+// cloneIndex a shallow copy sharing posting lists with bi, Compact on the clone never write the
+// shared lists(compactEntries/compact always allocate new ones), so bi can serve Retrieve meanwhile
+func (bi *SizeGroupedBEIndex) cloneIndex() BEIndex {
+	compileGroups(bi.sizeEntries...)
+	clone := &SizeGroupedBEIndex{
+		sizeEntries:    make([]*PostingEntries, len(bi.sizeEntries)),
+		wildcardKey:    bi.wildcardKey,
+		mergeThreshold: bi.mergeThreshold,
+		mergedGroups:   bi.mergedGroups,
+	}
+	bi.indexBase.cloneTo(&clone.indexBase)
+	clone.wildcardEntries.store(bi.wildcardEntries.load())
+	for k, kse := range bi.sizeEntries {
+		clone.sizeEntries[k] = kse.clone()
+	}
+	return clone
+}
+
+func (bi *SizeGroupedBEIndex) fragmentation() float64 {
+	wasted, total := entriesWaste(bi.wildcardEntries.load()), len(bi.wildcardEntries.load())
+	for _, kse := range bi.sizeEntries {
+		w, t := kse.waste()
+		wasted, total = wasted+w, total+t
+	}
+	return fragmentationRatio(wasted, total)
+}
+
+func (bi *CompactedBEIndex) cloneIndex() BEIndex {
+	bi.postingList.compile()
+	clone := &CompactedBEIndex{
+		wildcardKey: bi.wildcardKey,
+		postingList: bi.postingList.clone(),
+	}
+	bi.indexBase.cloneTo(&clone.indexBase)
+	clone.wildcardEntries.store(bi.wildcardEntries.load())
+	return clone
+}
+
+func (bi *CompactedBEIndex) fragmentation() float64 {
+	wasted, total := bi.postingList.waste()
+	wasted += entriesWaste(bi.wildcardEntries.load())
+	total += len(bi.wildcardEntries.load())
+	return fragmentationRatio(wasted, total)
+}
+
+// compactFragmented a compacted clone of idx if its fragmentation exceed the threshold, otherwise
+// idx and nil stats
+func (s *VersionedIndexStore) compactFragmented(idx BEIndex) (BEIndex, *CompactStats) {
+	if !s.autoCompact || idx.fragmentation() <= s.compactThreshold {
+		return idx, nil
+	}
+	clone := idx.cloneIndex()
+	stats, err := clone.Compact()
+	if err != nil {
+		Logger.Errorf("auto compact fail:%s, commit it as it is\n", err.Error())
+		return idx, nil
+	}
+	return clone, &stats
+}
+
+// AutoCompactStats return how many committed indexes auto compaction compacted and the summed stats
+func (s *VersionedIndexStore) AutoCompactStats() (runs int, total CompactStats) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	return s.compactRuns, s.compactStats
+}
@@ -0,0 +1,77 @@
+package be_indexer
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type (
+	// ErrFieldsDropped a non-fatal warning returned with the result of a best effort retrieving,
+	// Fields the fields dropped from query and their parse errors, see WithBestEffortRetrieval
+	ErrFieldsDropped struct {
+		Fields map[BEField]error
+	}
+)
+
+func (e *ErrFieldsDropped) Error() string {
+	fields := make([]string, 0, len(e.Fields))
+	for field := range e.Fields {
+		fields = append(fields, string(field))
+	}
+	sort.Strings(fields)
+	return fmt.Sprintf("fields dropped from query:[%s]", strings.Join(fields, ","))
+}
+
+/*
+WithBestEffortRetrieval a field whose query values fail parsing is dropped from the query(no cursor
+created for it) instead of failing the retrieving, the others are retrieved as usual; the result is
+returned along with a *ErrFieldsDropped warning, the dropped fields also recorded in RetrieveTrace.
+
+note: the result is a guess of the real one, conjunctions with an In expression of a dropped field
+can't reach their K and never match, conjunctions with a NotIn expression of it are never excluded
+by it, and Absent expressions of it never match(the field was assigned); the coarse pass of two pass
+retrieving stays strict. strict(fail the whole query) is the default.
+*/
+func WithBestEffortRetrieval() IndexOpt {
+	return func(ctx *RetrieveContext) {
+		ctx.bestEffort = true
+	}
+}
+
+// IsFieldsDropped whether err is a best effort retrieving warning, the result along with it is valid
+func IsFieldsDropped(err error) bool {
+	var dropped *ErrFieldsDropped
+	return errors.As(err, &dropped)
+}
+
+// dropField drop field from query because of err if best effort retrieving, return false if strict
+func (ctx *RetrieveContext) dropField(field BEField, err error) bool {
+	if !ctx.bestEffort {
+		return false
+	}
+	if ctx.droppedFields == nil {
+		ctx.droppedFields = make(map[BEField]error)
+	}
+	if _, ok := ctx.droppedFields[field]; ok {
+		return true
+	}
+	ctx.droppedFields[field] = err
+	if ctx.trace != nil {
+		ctx.trace.DroppedFields = append(ctx.trace.DroppedFields, field)
+	}
+	return true
+}
+
+// warnDroppedFields must be deferred, turn a succeed retrieving with fields dropped to a warning
+func (ctx *RetrieveContext) warnDroppedFields(err *error) {
+	if *err == nil && len(ctx.droppedFields) > 0 {
+		*err = &ErrFieldsDropped{Fields: ctx.droppedFields}
+	}
+}
+
+// partialResult whether the result collected is returned along with err
+func (ctx *RetrieveContext) partialResult(err error) bool {
+	return (ctx.partialOnBudgetExceed && err == ErrBudgetExceeded) || IsFieldsDropped(err)
+}
@@ -0,0 +1,104 @@
+package be_indexer
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/echoface/be_indexer/parser"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+const (
+	failingParser = "test_failing_parser"
+)
+
+// failingAssignParser fail parsing query value "bad", simulate a broken field at query time
+type failingAssignParser struct {
+	parser.FieldValueParser
+}
+
+func (p *failingAssignParser) ParseAssign(v interface{}) ([]uint64, error) {
+	if v == "bad" {
+		return nil, fmt.Errorf("faulty parser, value:%v", v)
+	}
+	return p.FieldValueParser.ParseAssign(v)
+}
+
+func init() {
+	parser.RegisterBuilder(failingParser, func(alloc parser.IDAllocator) parser.FieldValueParser {
+		return &failingAssignParser{parser.NewCommonStrParser(alloc)}
+	})
+}
+
+func TestWithBestEffortRetrieval(t *testing.T) {
+	LogLevel = ErrorLevel + 1 // parse failures logged at error level
+
+	b := NewIndexerBuilder(WithNearMissAnalysis())
+	b.ConfigField("city", FieldOption{Parser: failingParser})
+	doc := NewDocument(1)
+	doc.AddConjunction(NewConjunction().In("tag", NewIntValues(1)))
+	b.AddDocument(doc)
+	doc = NewDocument(2)
+	doc.AddConjunction(NewConjunction().In("tag", NewIntValues(1)).In("city", NewStrValues("sh")))
+	b.AddDocument(doc)
+	doc = NewDocument(3)
+	doc.AddConjunction(NewConjunction().In("tag", NewIntValues(1)).NotIn("city", NewStrValues("sh")))
+	b.AddDocument(doc)
+	doc = NewDocument(4)
+	doc.AddConjunction(NewConjunction().In("tag", NewIntValues(1)).Absent("city"))
+	b.AddDocument(doc)
+
+	query := Assignments{"tag": NewIntValues(1), "city": NewStrValues("sh", "bad")}
+
+	convey.Convey("test strict retrieving fail the whole query", t, func() {
+		for _, index := range []BEIndex{b.BuildIndex(), b.BuildCompactedIndex()} {
+			result, err := index.Retrieve(query)
+			convey.So(err, convey.ShouldNotBeNil)
+			convey.So(IsFieldsDropped(err), convey.ShouldBeFalse)
+			convey.So(result, convey.ShouldBeNil)
+		}
+	})
+
+	convey.Convey("test best effort retrieving drop the failing field", t, func() {
+		for _, index := range []BEIndex{b.BuildIndex(), b.BuildCompactedIndex()} {
+			trace := &RetrieveTrace{}
+			result, err := index.Retrieve(query, WithBestEffortRetrieval(), WithRetrieveTrace(trace))
+			convey.So(IsFieldsDropped(err), convey.ShouldBeTrue)
+			var dropped *ErrFieldsDropped
+			convey.So(errors.As(err, &dropped), convey.ShouldBeTrue)
+			convey.So(dropped.Fields, convey.ShouldContainKey, BEField("city"))
+			convey.So(len(dropped.Fields), convey.ShouldEqual, 1)
+			convey.So(trace.DroppedFields, convey.ShouldResemble, []BEField{"city"})
+
+			// doc 2 can't reach its K, doc 3 never excluded, doc 4 city assigned(not absent)
+			convey.So(len(result), convey.ShouldEqual, 2)
+			convey.So(result.Contain(1) && result.Contain(3), convey.ShouldBeTrue)
+
+			count, err := index.RetrieveCount(query, WithBestEffortRetrieval())
+			convey.So(IsFieldsDropped(err), convey.ShouldBeTrue)
+			convey.So(count, convey.ShouldEqual, 2)
+
+			collector := NewDocIDCollector()
+			err = index.RetrieveWithCollector(query, collector, WithBestEffortRetrieval())
+			convey.So(IsFieldsDropped(err), convey.ShouldBeTrue)
+			convey.So(len(collector.GetDocIDs()), convey.ShouldEqual, 2)
+
+			result, _, err = index.RetrieveWithNearMiss(query, WithBestEffortRetrieval())
+			convey.So(IsFieldsDropped(err), convey.ShouldBeTrue)
+			convey.So(len(result), convey.ShouldEqual, 2)
+		}
+	})
+
+	convey.Convey("test best effort retrieving without failure", t, func() {
+		for _, index := range []BEIndex{b.BuildIndex(), b.BuildCompactedIndex()} {
+			trace := &RetrieveTrace{}
+			result, err := index.Retrieve(Assignments{"tag": NewIntValues(1), "city": NewStrValues("sh")},
+				WithBestEffortRetrieval(), WithRetrieveTrace(trace))
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(trace.DroppedFields, convey.ShouldBeEmpty)
+			convey.So(len(result), convey.ShouldEqual, 2)
+			convey.So(result.Contain(1) && result.Contain(2), convey.ShouldBeTrue)
+		}
+	})
+}
@@ -0,0 +1,137 @@
+package be_indexer
+
+import (
+	"fmt"
+	"sort"
+)
+
+type (
+	// FieldBuildReport posting lists of a field, a key's list in every K group counted as one
+	FieldBuildReport struct {
+		Field      BEField `json:"field"`
+		KeyCount   int     `json:"key_count"`
+		EntryCount int     `json:"entry_count"`
+
+		MinPostingLength int     `json:"min_posting_length"`
+		MaxPostingLength int     `json:"max_posting_length"`
+		AvgPostingLength float64 `json:"avg_posting_length"`
+	}
+
+	/*BuildReport machine readable summary of the last index built by a IndexerBuilder, see
+	IndexerBuilder.BuildReport; for CI and tests to assert invariants of the data(eg: no field's
+	max posting length exceed X, doc count as expected) instead of parsing the human readable dumps.
+	*/
+	BuildReport struct {
+		DocCount           int `json:"doc_count"`
+		ConjunctionCount   int `json:"conjunction_count"` // conjunctions indexed
+		WildcardEntryCount int `json:"wildcard_entry_count"`
+		PostingListCount   int `json:"posting_list_count"`
+		EntryCount         int `json:"entry_count"`
+
+		// SkippedConjunctions conjunctions of documents not indexed, see SkipBadConj
+		SkippedConjunctions int `json:"skipped_conjunctions"`
+
+		// Fields every field of index sorted by name, the wildcard field and internal fields(eg:
+		// sub-fields of InAll) included as they are indexed
+		Fields []FieldBuildReport `json:"fields"`
+
+		// Warnings things worth a look: skipped conjunctions, auto created fields, fields without
+		// any posting list, hot keys(see FieldOption.MaxKeyEntries) and options disabled by others
+		Warnings []string `json:"warnings,omitempty"`
+	}
+)
+
+/*
+BuildReport the report of the index last built(by any Build* method), zero BuildReport if nothing
+built yet; computed when the index built, so it reflect the index as built(eg: not a Compact after),
+and the builder hold no reference to the index.
+*/
+func (b *IndexerBuilder) BuildReport() BuildReport {
+	return b.lastReport
+}
+
+// newBuildReport the report of indexer built from documents of conjCount conjunctions
+func (b *IndexerBuilder) newBuildReport(indexer BEIndex, conjCount int) BuildReport {
+	stats := indexer.Stats()
+	report := BuildReport{
+		DocCount:            stats.DocCount,
+		ConjunctionCount:    stats.ConjunctionCount,
+		WildcardEntryCount:  stats.WildcardEntryCount,
+		PostingListCount:    stats.PostingListCount,
+		EntryCount:          stats.EntryCount,
+		SkippedConjunctions: conjCount - stats.ConjunctionCount,
+		Fields:              indexer.fieldBuildReports(),
+	}
+	if report.SkippedConjunctions > 0 {
+		report.Warnings = append(report.Warnings, fmt.Sprintf("%d conjunctions skipped", report.SkippedConjunctions))
+	}
+	if b.settings.LazyCompile && b.settings.SharePostingLists {
+		report.Warnings = append(report.Warnings, "lazy compile ignored, shared posting lists compiled when built")
+	}
+	for _, field := range stats.AutoCreatedFields {
+		report.Warnings = append(report.Warnings, fmt.Sprintf("field:%s auto created, not configured", field))
+	}
+	for _, field := range report.Fields {
+		if field.KeyCount == 0 && field.Field != wildcardField {
+			report.Warnings = append(report.Warnings, fmt.Sprintf("field:%s has no posting list", field.Field))
+		}
+	}
+	for _, hot := range stats.HotKeys {
+		report.Warnings = append(report.Warnings, fmt.Sprintf("field:%s key:%d hot key of %d entries", hot.Field, hot.Key, hot.Entries))
+	}
+	return report
+}
+
+// fieldReports report of fields by lengths of posting lists in lists, wildcard field by wildcards
+func (bi *indexBase) fieldReports(wildcards int, lists ...*PostingEntries) []FieldBuildReport {
+	lengths := make(map[Key]int)
+	for _, pl := range lists {
+		pl.eachList(func(key Key, entries Entries) {
+			if len(entries) > 0 {
+				lengths[key] += len(entries)
+			}
+		})
+	}
+	reports := make(map[uint64]*FieldBuildReport, len(bi.fieldDesc))
+	for field, desc := range bi.fieldDesc {
+		reports[desc.ID] = &FieldBuildReport{Field: field}
+	}
+	add := func(report *FieldBuildReport, length int) {
+		if report.KeyCount == 0 || length < report.MinPostingLength {
+			report.MinPostingLength = length
+		}
+		if length > report.MaxPostingLength {
+			report.MaxPostingLength = length
+		}
+		report.KeyCount++
+		report.EntryCount += length
+	}
+	for key, length := range lengths {
+		if report, ok := reports[key.GetFieldID()]; ok {
+			add(report, length)
+		}
+	}
+	if desc, ok := bi.fieldDesc[wildcardField]; ok && wildcards > 0 {
+		add(reports[desc.ID], wildcards)
+	}
+
+	res := make([]FieldBuildReport, 0, len(reports))
+	for _, report := range reports {
+		if report.KeyCount > 0 {
+			report.AvgPostingLength = float64(report.EntryCount) / float64(report.KeyCount)
+		}
+		res = append(res, *report)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Field < res[j].Field
+	})
+	return res
+}
+
+func (bi *SizeGroupedBEIndex) fieldBuildReports() []FieldBuildReport {
+	return bi.fieldReports(len(bi.wildcardEntries.load()), bi.sizeEntries...)
+}
+
+func (bi *CompactedBEIndex) fieldBuildReports() []FieldBuildReport {
+	return bi.fieldReports(len(bi.wildcardEntries.load()), bi.postingList)
+}
@@ -0,0 +1,75 @@
+package be_indexer
+
+import (
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/echoface/be_indexer/parser"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestIndexerBuilder_BuildReport(t *testing.T) {
+	LogLevel = ErrorLevel
+
+	b := NewIndexerBuilder(WithBadConjBehavior(SkipBadConj))
+	b.ConfigField("tag", FieldOption{MaxKeyEntries: 3})
+	b.ConfigField("unused", FieldOption{})
+	b.SetFieldParser("age", parser.NumRangeParser)
+	for id := 1; id <= 4; id++ {
+		doc := NewDocument(DocID(id))
+		doc.AddConjunction(NewConjunction().In("tag", NewIntValues(1)))
+		_ = b.AddDocument(doc)
+	}
+	doc := NewDocument(5)
+	doc.AddConjunction(NewConjunction().In("tag", NewIntValues(2)).In("city", NewStrValues("sh")))
+	_ = b.AddDocument(doc)
+	doc = NewDocument(6)
+	doc.AddConjunction(NewConjunction().NotIn("city", NewStrValues("bj")))
+	_ = b.AddDocument(doc)
+	doc = NewDocument(7)
+	doc.AddConjunction(NewConjunction().In("age", NewStrValues("bad")))
+	_ = b.AddDocument(doc)
+
+	convey.Convey("test report match the dataset", t, func() {
+		convey.So(NewIndexerBuilder().BuildReport(), convey.ShouldResemble, BuildReport{})
+
+		for _, build := range []func() BEIndex{b.BuildIndex, b.BuildCompactedIndex} {
+			build()
+			report := b.BuildReport()
+			convey.So(report.DocCount, convey.ShouldEqual, 6)
+			convey.So(report.ConjunctionCount, convey.ShouldEqual, 6)
+			convey.So(report.SkippedConjunctions, convey.ShouldEqual, 1)
+			convey.So(report.WildcardEntryCount, convey.ShouldEqual, 1)
+			convey.So(report.PostingListCount, convey.ShouldEqual, 4)
+			convey.So(report.EntryCount, convey.ShouldEqual, 7)
+
+			convey.So(report.Fields, convey.ShouldResemble, []FieldBuildReport{
+				{Field: wildcardField, KeyCount: 1, EntryCount: 1, MinPostingLength: 1, MaxPostingLength: 1, AvgPostingLength: 1},
+				{Field: "age"},
+				{Field: "city", KeyCount: 2, EntryCount: 2, MinPostingLength: 1, MaxPostingLength: 1, AvgPostingLength: 1},
+				{Field: "tag", KeyCount: 2, EntryCount: 5, MinPostingLength: 1, MaxPostingLength: 4, AvgPostingLength: 2.5},
+				{Field: "unused"},
+			})
+
+			convey.So(len(report.Warnings), convey.ShouldEqual, 5)
+			convey.So(report.Warnings, convey.ShouldContain, "1 conjunctions skipped")
+			convey.So(report.Warnings, convey.ShouldContain, "field:city auto created, not configured")
+			convey.So(report.Warnings, convey.ShouldContain, "field:age has no posting list")
+			convey.So(report.Warnings, convey.ShouldContain, "field:unused has no posting list")
+		}
+	})
+	convey.Convey("test builder not retain the index it built", t, func() {
+		var released int32
+		index := b.BuildIndex()
+		runtime.SetFinalizer(index, func(BEIndex) { atomic.StoreInt32(&released, 1) })
+		index = nil
+		for i := 0; i < 10 && atomic.LoadInt32(&released) == 0; i++ {
+			runtime.GC()
+			time.Sleep(time.Millisecond)
+		}
+		convey.So(atomic.LoadInt32(&released), convey.ShouldEqual, 1)
+		convey.So(b.BuildReport().DocCount, convey.ShouldEqual, 6)
+	})
+}
@@ -1,24 +1,310 @@
 package be_indexer
 
 import (
+	"errors"
 	"fmt"
 	"github.com/echoface/be_indexer/parser"
+	"reflect"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// PanicBadConj panic when meet a bad document/conjunction, the default behavior
+	PanicBadConj BadConjBehavior = iota
+	// ErrorBadConj return the error to caller
+	ErrorBadConj
+	// SkipBadConj skip the bad document/conjunction with a error log
+	SkipBadConj
+)
+
+var (
+	// ErrMemoryBudgetExceeded building abort because of the posting lists memory exceed the budget
+	ErrMemoryBudgetExceeded = errors.New("index memory budget exceeded")
+
+	// ErrBuildPanic a parser or callback panic when building and recovered, see WithNoPanics
+	ErrBuildPanic = errors.New("build index panic")
+
+	// ErrTooManyFields documents have more fields than field id can encode, see MaxBEFieldID
+	ErrTooManyFields = errors.New("too many fields")
+
+	// ErrTooManyConjunctions a document has more conjunctions than allowed, see WithMaxConjunctionsPerDocument
+	ErrTooManyConjunctions = errors.New("too many conjunctions")
 )
 
 type (
+	// BadConjBehavior control how builder deal with a invalid document or a conjunction can't be parsed
+	BadConjBehavior int
+
+	BuilderOpt func(builder *IndexerBuilder)
+
 	IndexerBuilder struct {
-		Documents map[DocID]*Document
-		settings  IndexerSettings
+		Documents       map[DocID]*Document
+		settings        IndexerSettings
+		badConjBehavior BadConjBehavior
+
+		fieldExprCounts map[BEField]int // how many times field show up in conjunctions of added documents
+
+		memoryBudget int64 // see WithMemoryBudget
+		memoryUsage  int64 // approximate posting lists memory of the building index
+
+		verifySamples Values // see WithParserVerification
+
+		preloadValues []string // see WithPreloadValues
+
+		rejectMixedWildcard bool // see WithRejectMixedWildcard
+
+		emitter BuildEventEmitter // see WithEventEmitter
+
+		kGroupMergeThreshold int // see WithKGroupMerging
+
+		noPanics   bool // see WithNoPanics
+		inCallback bool // a parser or user callback running, its panic is a ErrBuildPanic, see recoverBuildPanic
+
+		maxConjunctions int // see WithMaxConjunctionsPerDocument
+
+		valueWhitelists map[BEField]map[interface{}]struct{} // see WithFieldValueWhitelist
+		whitelistErrors map[BEField]error                    // approved values not comparable, see checkWhitelists
+
+		internCaches map[BEField]*sync.Map // string value -> the interned Values element, see WithStringInterning
+
+		txInterceptor func(tx *IndexingBETx) error // see WithTxInterceptor
+
+		docIDAllocator DocIDAllocator // see WithDocIDAllocator
+
+		namespace     string           // namespace of documents adding, see WithFieldNamespace
+		docNamespaces map[DocID]string // namespace each document added in
+
+		mutationLog MutationLog // see WithMutationLog
+		mutationSeq uint64      // sequence number of the last mutation, see MutationLog
+
+		lastReport BuildReport // see BuildReport
 	}
 )
 
-func NewIndexerBuilder() *IndexerBuilder {
-	return &IndexerBuilder{
+func WithBadConjBehavior(behavior BadConjBehavior) BuilderOpt {
+	return func(builder *IndexerBuilder) {
+		builder.badConjBehavior = behavior
+	}
+}
+
+// WithMemoryBudget limit the approximate memory of posting lists when building, BuildIndexE
+// return ErrMemoryBudgetExceeded(BuildIndex panic) once exceeded instead of OOMing the process;
+// the accounting counts 8 bytes per entry and a fixed overhead per key, bytes <= 0 means no limit
+func WithMemoryBudget(bytes int64) BuilderOpt {
+	return func(builder *IndexerBuilder) {
+		builder.memoryBudget = bytes
+	}
+}
+
+// WithParserVerification verify parser of every configured field and of the default option with
+// samples(see parser.VerifyParserConsistency) before building, the parsers created and configured
+// (ParserParams) as the index does; BuildIndexE return error if any parser inconsistent,
+// verification use throwaway IDAllocator, so the index is not affected
+func WithParserVerification(samples Values) BuilderOpt {
+	return func(builder *IndexerBuilder) {
+		builder.verifySamples = samples
+	}
+}
+
+// WithPreloadValues pre-seed the IDAllocator of index with ordered values, the i-th distinct
+// value always get string id i, so indexes built from different shards(or ingest order) are compatible
+func WithPreloadValues(values []string) BuilderOpt {
+	return func(builder *IndexerBuilder) {
+		builder.preloadValues = values
+	}
+}
+
+// WithRejectMixedWildcard treat document containing both size-0 conjunction and constrained conjunction
+// as bad document(handled by BadConjBehavior), bz the size-0 one make the others meaningless(see Document);
+// size counted by CalcConjSize, so exclude-only conjunction is size-0, Document.AllowMixedWildcard skip it
+func WithRejectMixedWildcard() BuilderOpt {
+	return func(builder *IndexerBuilder) {
+		builder.rejectMixedWildcard = true
+	}
+}
+
+// WithDefaultFieldOption set the base option of fields, explicit option(ConfigField/SetFieldParser)
+// override it(see mergeFieldOption), fields not configured but used by documents take it as is
+func WithDefaultFieldOption(option FieldOption) BuilderOpt {
+	return func(builder *IndexerBuilder) {
+		builder.settings.DefaultFieldOption = option
+	}
+}
+
+/*
+WithKGroupMerging fold the K groups(K > 1) of SizeGroupedBEIndex having fewer than threshold
+conjunctions into the nearest lower group, it saves the container and per-query setup cost of
+tiny groups made by a few outlier conjunctions(eg: K=9..14 while most are K=1..4); the folded
+conjunctions keep their true size, a merged group verify the exact K of every conjunction like
+CompactedBEIndex does. threshold <= 0 means no merging, CompactedBEIndex is not affected.
+*/
+func WithKGroupMerging(threshold int) BuilderOpt {
+	return func(builder *IndexerBuilder) {
+		builder.kGroupMergeThreshold = threshold
+	}
+}
+
+/*
+WithNoPanics library mode for embedding in a service can't afford a crash by bad data:
+  - a bad document/conjunction is returned as error even under PanicBadConj(the default)
+  - BuildIndexE/BuildCompactedIndexE recover panics of parsers(eg: bad parser params) and user
+    callbacks(eg: TxInterceptor, BuildEventEmitter) and return them as ErrBuildPanic, BuildIndex
+    still panic on any error as it always do
+  - the index built recover panics of retrieving as WithRecover do for every query
+
+programming errors(eg: configure a field twice) and bugs of building itself still panic.
+*/
+func WithNoPanics() BuilderOpt {
+	return func(builder *IndexerBuilder) {
+		builder.noPanics = true
+		builder.settings.NoPanics = true
+	}
+}
+
+/*
+WithSharedPostingLists make keys with identical posting list share one Entries when index completed
+(see shareEntries), it saves memory of fields whose values mostly listed together by conjunctions,
+see IndexStatistics.SharedPostingListBytes, at the cost of hashing every posting list once when
+building; retrieving is not affected. sharing need every list sorted when index completed and
+frozen as a whole slice, so it disable WithLazyCompile(every group compiled by the build) and the
+inlining of short lists(see inlineSmallLists), the BuildReport warn about the lazy compile ignored.
+*/
+func WithSharedPostingLists() BuilderOpt {
+	return func(builder *IndexerBuilder) {
+		builder.settings.SharePostingLists = true
+	}
+}
+
+/*
+WithLazyCompile build return right after the lightweight bookkeeping, the sorting of posting lists
+of every K group(the single list of CompactedBEIndex) deferred to the first retrieving need the
+group, guarded by a sync.Once per group, so concurrent first queries sort a group once and a query
+only block on the groups it need. apis inspecting the whole index(Dump*, Export*, Compact...) and
+WithRequirePositive compile all groups first; IndexStatistics.CompiledGroups report the progress.
+it's ignored with WithSharedPostingLists, sharing need every list sorted when index completed.
+*/
+func WithLazyCompile() BuilderOpt {
+	return func(builder *IndexerBuilder) {
+		builder.settings.LazyCompile = true
+	}
+}
+
+// WithBackgroundCompile WithLazyCompile along with a goroutine compiling the groups(in K order)
+// proactively after build, a group a query compiled first isn't sorted twice
+func WithBackgroundCompile() BuilderOpt {
+	return func(builder *IndexerBuilder) {
+		builder.settings.LazyCompile = true
+		builder.settings.BackgroundCompile = true
+	}
+}
+
+// WithPostingListInterning an alias of WithSharedPostingLists: identical posting lists interned when
+// index completed, the memory saved reported by IndexStatistics.SharedPostingListBytes
+func WithPostingListInterning() BuilderOpt {
+	return WithSharedPostingLists()
+}
+
+/*
+WithStringInterning intern string values of field's expressions when documents added: identical
+strings reuse the same string(and the same boxed interface{} in Values), so the millions of
+expressions of a low cardinality field(eg: country, browser) retained by builder don't allocate a
+string each, less GC pressure when building. the Values of added documents are rewritten in place,
+with equal strings, so the documents mean the same; non-string values are left as they are.
+*/
+func WithStringInterning(field BEField) BuilderOpt {
+	return func(builder *IndexerBuilder) {
+		if builder.internCaches == nil {
+			builder.internCaches = make(map[BEField]*sync.Map)
+		}
+		builder.internCaches[field] = &sync.Map{}
+	}
+}
+
+// WithMaxConjunctionsPerDocument limit the conjunctions a document can have to n, a document exceed
+// it is a bad document(handled by BadConjBehavior); n <= 0 means MaxDocConjunctions, the limit of
+// ConjID encoding, which can't be exceeded, a larger n is clamped to it
+func WithMaxConjunctionsPerDocument(n int) BuilderOpt {
+	return func(builder *IndexerBuilder) {
+		if n > MaxDocConjunctions {
+			n = MaxDocConjunctions
+		}
+		builder.maxConjunctions = n
+	}
+}
+
+/*
+WithFieldValueWhitelist only values in approved can be indexed on field(eg: pre-approved audience
+segment ids), a conjunction with any expression of field holding a value not approved is a bad
+conjunction(handled by BadConjBehavior) when building. values are compared as they are in documents
+(before FieldOption.Canonicalizer) by value and type, so 1 and int64(1) differ; approved values must
+be comparable, a value not is a build error handled by BadConjBehavior(skipped: never approved).
+calling it again for a field replace the whitelist.
+*/
+func WithFieldValueWhitelist(field BEField, approved Values) BuilderOpt {
+	whitelist := make(map[interface{}]struct{}, len(approved))
+	var err error
+	for _, v := range approved {
+		if v == nil || !reflect.TypeOf(v).Comparable() {
+			err = fmt.Errorf("field:%s whitelist value:%+v(%T) not comparable", field, v, v)
+			continue
+		}
+		whitelist[v] = struct{}{}
+	}
+	return func(builder *IndexerBuilder) {
+		if builder.valueWhitelists == nil {
+			builder.valueWhitelists = make(map[BEField]map[interface{}]struct{})
+			builder.whitelistErrors = make(map[BEField]error)
+		}
+		builder.valueWhitelists[field] = whitelist
+		delete(builder.whitelistErrors, field)
+		if err != nil {
+			builder.whitelistErrors[field] = err
+		}
+	}
+}
+
+// checkWhitelists report the whitelists with values not comparable, see WithFieldValueWhitelist
+func (b *IndexerBuilder) checkWhitelists() error {
+	for _, err := range b.whitelistErrors {
+		if err = b.handleBadConj(err); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkWhitelist the error of the first value of expr not approved for field, see WithFieldValueWhitelist
+func (b *IndexerBuilder) checkWhitelist(field BEField, expr *BoolValues) error {
+	whitelist, ok := b.valueWhitelists[field]
+	if !ok {
+		return nil
+	}
+	for _, v := range expr.Value {
+		if v != nil && reflect.TypeOf(v).Comparable() {
+			if _, approved := whitelist[v]; approved {
+				continue
+			}
+		}
+		return fmt.Errorf("value:%+v(%T) not in whitelist", v, v)
+	}
+	return nil
+}
+
+func NewIndexerBuilder(opts ...BuilderOpt) *IndexerBuilder {
+	builder := &IndexerBuilder{
 		Documents: make(map[DocID]*Document),
 		settings: IndexerSettings{
 			FieldConfig: make(map[BEField]FieldOption),
 		},
+		fieldExprCounts: make(map[BEField]int),
+	}
+	for _, fn := range opts {
+		fn(builder)
 	}
+	return builder
 }
 
 func (b *IndexerBuilder) SetFieldParser(field BEField, parserName string) {
@@ -27,33 +313,215 @@ func (b *IndexerBuilder) SetFieldParser(field BEField, parserName string) {
 	}
 }
 
-func (b *IndexerBuilder) AddDocument(doc *Document) {
+// ConfigField set the full option of field, override SetFieldParser
+func (b *IndexerBuilder) ConfigField(field BEField, option FieldOption) {
+	b.settings.FieldConfig[field] = option
+}
+
+// handleBadConj deal with error according badConjBehavior, nil returned means the error can be skipped
+func (b *IndexerBuilder) handleBadConj(err error) error {
+	switch b.badConjBehavior {
+	case SkipBadConj:
+		Logger.Errorf("skip bad document/conjunction, err:%s\n", err.Error())
+		return nil
+	case ErrorBadConj:
+		return err
+	default:
+		if b.noPanics {
+			return err
+		}
+		panic(err)
+	}
+}
+
+// validDocument check doc is indexable with at most maxConjunctions conjunctions(<= 0 means MaxDocConjunctions)
+func validDocument(doc *Document, maxConjunctions int) error {
 	if doc == nil {
-		panic(fmt.Errorf("nil doc not allow"))
+		return fmt.Errorf("nil doc not allow")
+	}
+	if maxConjunctions <= 0 {
+		maxConjunctions = MaxDocConjunctions
+	}
+	if len(doc.Cons) > maxConjunctions {
+		return fmt.Errorf("%w, doc:%d has %d conjunctions, max %d per document",
+			ErrTooManyConjunctions, doc.ID, len(doc.Cons), maxConjunctions)
+	}
+	for idx, conj := range doc.Cons {
+		if conj == nil || len(conj.Expressions) == 0 {
+			return fmt.Errorf("doc:%d has invalid conjunction at index:%d", doc.ID, idx)
+		}
+		for field, expr := range conj.Expressions {
+			if expr == nil {
+				return fmt.Errorf("doc:%d conjunction at index:%d has nil expression of field:%s", doc.ID, idx, field)
+			}
+			for _, value := range expr.Value {
+				if value == WildcardValue {
+					return fmt.Errorf("doc:%d conjunction at index:%d field:%s WildcardValue is query only", doc.ID, idx, field)
+				}
+			}
+		}
+		if size := conj.CalcConjSize(); size > 0xFF {
+			return fmt.Errorf("doc:%d conjunction at index:%d size:%d exceed 255", doc.ID, idx, size)
+		}
+	}
+	return nil
+}
+
+func checkMixedWildcard(doc *Document) error {
+	wildcard, constrained := -1, -1
+	for idx, conj := range doc.Cons {
+		if conj.CalcConjSize() == 0 {
+			wildcard = idx
+		} else {
+			constrained = idx
+		}
+	}
+	if wildcard >= 0 && constrained >= 0 {
+		return fmt.Errorf("doc:%d mix size-0 conjunction(index:%d) with constrained conjunction(index:%d)",
+			doc.ID, wildcard, constrained)
+	}
+	return nil
+}
+
+// checkDocument validate doc without touching builder state, so it can run without holding lock
+func (b *IndexerBuilder) checkDocument(doc *Document) error {
+	if err := validDocument(doc, b.maxConjunctions); err != nil {
+		return err
+	}
+	if b.rejectMixedWildcard && !doc.AllowMixedWildcard {
+		return checkMixedWildcard(doc)
+	}
+	return nil
+}
+
+// AddDocument add doc to builder, a invalid doc will be handled according badConjBehavior
+func (b *IndexerBuilder) AddDocument(doc *Document) error {
+	if err := b.checkDocument(doc); err != nil {
+		return b.handleBadConj(err)
+	}
+	b.addDocument(doc)
+	return nil
+}
+
+func (b *IndexerBuilder) addDocument(doc *Document) {
+	b.internStrings(doc)
+	old, updated := b.Documents[doc.ID]
+	if updated {
+		b.countFieldExpressions(old, -1)
 	}
 	b.Documents[doc.ID] = doc
+	b.recordNamespace(doc.ID)
+	b.countFieldExpressions(doc, 1)
+	b.logAdd(doc, updated)
+}
+
+// internStrings replace string values of interned fields with the interned ones, see WithStringInterning
+func (b *IndexerBuilder) internStrings(doc *Document) {
+	if len(b.internCaches) == 0 {
+		return
+	}
+	for _, conj := range doc.Cons {
+		for field, expr := range conj.Expressions {
+			cache, ok := b.internCaches[field]
+			if !ok {
+				continue
+			}
+			for i, v := range expr.Value {
+				if s, ok := v.(string); ok {
+					interned, _ := cache.LoadOrStore(s, v)
+					expr.Value[i] = interned
+				}
+			}
+		}
+	}
 }
 
 func (b *IndexerBuilder) RemoveDocument(doc DocID) bool {
-	_, hit := b.Documents[doc]
+	old, hit := b.Documents[doc]
 	if hit {
 		delete(b.Documents, doc)
+		delete(b.docNamespaces, doc)
+		b.countFieldExpressions(old, -1)
+		b.logRemove(doc)
 	}
 	return hit
 }
 
-func (b *IndexerBuilder) buildDocEntries(indexer BEIndex, doc *Document) {
+// Reset remove all added documents and statistics, field settings are kept; every document
+// removed is logged as a MutationRemove(in DocID order)
+func (b *IndexerBuilder) Reset() {
+	removed := make([]DocID, 0, len(b.Documents))
+	for id := range b.Documents {
+		removed = append(removed, id)
+	}
+	b.logRemove(removed...)
+	b.Documents = make(map[DocID]*Document)
+	b.docNamespaces = nil
+	b.fieldExprCounts = make(map[BEField]int)
+}
+
+func (b *IndexerBuilder) countFieldExpressions(doc *Document, delta int) {
+	if b.fieldExprCounts == nil {
+		b.fieldExprCounts = make(map[BEField]int)
+	}
+	for _, conj := range doc.Cons {
+		for field := range conj.Expressions {
+			if b.fieldExprCounts[field] += delta; b.fieldExprCounts[field] <= 0 {
+				delete(b.fieldExprCounts, field)
+			}
+		}
+	}
+}
+
+// FieldExpressionCounts return how many times each field show up across all conjunctions added so far
+func (b *IndexerBuilder) FieldExpressionCounts() map[BEField]int {
+	res := make(map[BEField]int, len(b.fieldExprCounts))
+	for field, cnt := range b.fieldExprCounts {
+		res[field] = cnt
+	}
+	return res
+}
+
+// TopNFields return the n most common fields sorted descending by count, fields with same count sorted by name
+func (b *IndexerBuilder) TopNFields(n int) []BEField {
+	fields := make([]BEField, 0, len(b.fieldExprCounts))
+	for field := range b.fieldExprCounts {
+		fields = append(fields, field)
+	}
+	sort.Slice(fields, func(i, j int) bool {
+		ci, cj := b.fieldExprCounts[fields[i]], b.fieldExprCounts[fields[j]]
+		if ci != cj {
+			return ci > cj
+		}
+		return fields[i] < fields[j]
+	})
+	if n >= 0 && n < len(fields) {
+		fields = fields[:n]
+	}
+	return fields
+}
+
+// ConjunctionSizeHistogram return how many conjunctions of added documents there are for each
+// size(k), wildcard conjunctions counted as size 0; it's computed from documents without building,
+// helps to choose between BuildCompactedIndex(sizes spread thin) and BuildIndex
+func (b *IndexerBuilder) ConjunctionSizeHistogram() map[int]int {
+	histogram := make(map[int]int)
+	for _, doc := range b.Documents {
+		for _, conj := range doc.Cons {
+			histogram[conj.CalcConjSize()]++
+		}
+	}
+	return histogram
+}
+
+func (b *IndexerBuilder) buildDocEntries(indexer docIndexer, doc *Document) error {
 
 	doc.Prepare()
+	ns := b.docNamespaces[doc.ID]
 
 FORCONJ:
 	for _, conj := range doc.Cons {
 
-		if conj.size == 0 {
-			indexer.appendWildcardEntryID(NewEntryID(conj.id, true))
-		}
-
-		kSizeEntries := indexer.newPostingEntriesIfNeeded(conj.size)
 		type pair struct {
 			key   Key
 			entry EntryID
@@ -61,59 +529,298 @@ FORCONJ:
 		var pairs []*pair
 
 		for field, expr := range conj.Expressions {
-			desc := indexer.newFieldDescIfNeeded(field)
-			entryID := NewEntryID(conj.id, expr.Incl)
+			if e := b.checkWhitelist(field, expr); e != nil {
+				e = fmt.Errorf("doc:%d, field:%s %s", conj.id.DocID(), field, e.Error())
+				if e = b.handleBadConj(e); e != nil {
+					return e
+				}
+				continue FORCONJ
+			}
+			field = NamespacedField(field, ns)
+			tx := &IndexingBETx{Field: field, EID: NewEntryID(conj.id, expr.Incl)}
+			if expr.Absent {
+				b.inCallback = true // parser of field created
+				desc := indexer.newAbsentFieldDescIfNeeded(field)
+				b.inCallback = false
+				if desc.ID > MaxBEFieldID {
+					return fmt.Errorf("%w, field:%s id:%d exceed:%d", ErrTooManyFields, field, desc.ID, MaxBEFieldID)
+				}
+				tx.Keys = append(tx.Keys, NewKey(desc.ID, 0))
+			} else {
+				b.inCallback = true
+				desc := indexer.newFieldDescIfNeeded(field)
+				b.inCallback = false
 
-			for _, value := range expr.Value {
-				res, e := desc.Parser.ParseValue(value)
+				for idx, value := range expr.Value {
+					b.inCallback = true
+					if expr.All {
+						desc = indexer.newAllOfFieldDescIfNeeded(field, idx)
+					}
+					res, e := desc.parseValue(value)
+					b.inCallback = false
+					if desc.ID > MaxBEFieldID {
+						return fmt.Errorf("%w, field:%s id:%d exceed:%d", ErrTooManyFields, field, desc.ID, MaxBEFieldID)
+					}
+					if e == nil {
+						e = checkValueIDs(res)
+					}
+					if e != nil {
+						e = fmt.Errorf("doc:%d, field:%s value:%+v parse fail, err:%s", conj.id.DocID(), field, value, e.Error())
+						if e = b.handleBadConj(e); e != nil {
+							return e
+						}
+						continue FORCONJ //conjunction as logic unit, just skip this conj if any error occur
+					}
+					for _, id := range res {
+						tx.Keys = append(tx.Keys, NewKey(desc.ID, id))
+					}
+				}
+			}
+			if b.txInterceptor != nil {
+				b.inCallback = true
+				e := b.txInterceptor(tx)
+				b.inCallback = false
 				if e != nil {
-					panic(e)
-					Logger.Errorf("field %s parse failed\n", field)
-					Logger.Errorf("doc:%d, value %+v parse fail err detail:%+v\n", conj.id.DocID(), value, e)
-					continue FORCONJ //break FORCONJ, conjunction as logic unit, just skip this conj if any error occur
+					e = fmt.Errorf("doc:%d, field:%s intercepted, err:%s", conj.id.DocID(), field, e.Error())
+					if e = b.handleBadConj(e); e != nil {
+						return e
+					}
+					continue FORCONJ
 				}
-				for _, id := range res {
-					pairs = append(pairs, &pair{
-						key:   NewKey(desc.ID, id),
-						entry: entryID,
-					})
+			}
+			for _, key := range tx.Keys {
+				pairs = append(pairs, &pair{key: key, entry: tx.EID})
+			}
+		}
+
+		indexer.appendDocConjunction(conj.id)
+		indexer.appendConjHash(conj.id, conj.Hash())
+		if b.settings.NearMissAnalysis {
+			var inclFields []uint64
+			for _, v := range pairs {
+				if v.entry.IsInclude() {
+					inclFields = appendFieldOnce(inclFields, v.key.GetFieldID())
 				}
 			}
+			if len(inclFields) > 0 {
+				indexer.appendConjFields(conj.id, inclFields)
+			}
+		}
+		if conj.size == 0 {
+			indexer.appendWildcardEntryID(NewEntryID(conj.id, true))
 		}
+
+		kSizeEntries := indexer.newPostingEntriesIfNeeded(conj.size)
 		for _, v := range pairs {
+			if err := b.chargeMemory(kSizeEntries, v.key); err != nil {
+				return fmt.Errorf("%w, doc:%d, budget:%d, used:%d", err, doc.ID, b.memoryBudget, b.memoryUsage)
+			}
 			kSizeEntries.AppendEntryID(v.key, v.entry)
 		}
 	}
+	if len(doc.Labels) > 0 {
+		indexer.appendDocLabels(doc.ID, doc.Labels)
+	}
+	if ns != "" {
+		indexer.appendDocNamespace(doc.ID, ns)
+	}
+	if doc.SortKey != nil {
+		indexer.appendDocSortKey(doc.ID, *doc.SortKey)
+	}
+	return nil
 }
 
-func (b *IndexerBuilder) BuildIndex() BEIndex {
+// checkValueIDs check value ids a parser returned can be encoded into Key
+func checkValueIDs(ids []uint64) error {
+	for _, id := range ids {
+		if id > MaxBEValueID {
+			return fmt.Errorf("value id:%d out of range:%d", id, MaxBEValueID)
+		}
+	}
+	return nil
+}
 
-	idGen := parser.NewIDAllocatorImpl()
+func (b *IndexerBuilder) chargeMemory(entries *PostingEntries, key Key) error {
+	if b.memoryBudget <= 0 {
+		return nil
+	}
+	b.memoryUsage += entryIDBytes
+	if entries.getEntries(key) == nil {
+		b.memoryUsage += postingKeyBytes
+	}
+	if b.memoryUsage > b.memoryBudget {
+		return ErrMemoryBudgetExceeded
+	}
+	return nil
+}
 
-	indexer := NewSizeGroupedBEIndex(idGen)
+func (b *IndexerBuilder) verifyParsers() error {
+	return b.eachFieldParser(func(field BEField, p parser.FieldValueParser, parserName string) error {
+		if err := parser.VerifyParserConsistency(p, b.verifySamples); err != nil {
+			return fmt.Errorf("field:%s parser:%s verify fail, %s", field, parserName, err.Error())
+		}
+		return nil
+	})
+}
 
-	indexer.ConfigureIndexer(&b.settings)
+// checkFieldOptions check the parsers of options can be created and provide the capabilities the
+// options required, so a misconfiguration fail BuildIndexE with error instead of a panic of
+// configuring index
+func (b *IndexerBuilder) checkFieldOptions() error {
+	return b.eachFieldParser(func(BEField, parser.FieldValueParser, string) error {
+		return nil
+	})
+}
 
+// eachFieldParser call fn with a parser(on a throwaway IDAllocator) of every configured field and
+// of the default option(for fields not configured, named "#default"), created as the index does
+func (b *IndexerBuilder) eachFieldParser(fn func(field BEField, p parser.FieldValueParser, parserName string) error) error {
+	options := map[BEField]FieldOption{"#default": b.settings.DefaultFieldOption}
+	for field, option := range b.settings.FieldConfig {
+		options[field] = mergeFieldOption(b.settings.DefaultFieldOption, option)
+	}
+	for field, option := range options {
+		p, parserName, err := newFieldParser(field, option, parser.NewIDAllocatorImpl())
+		if err != nil {
+			return err
+		}
+		if err := fn(field, p, parserName); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *IndexerBuilder) buildIndex(indexer BEIndex) (BEIndex, error) {
+	return b.buildIndexFrom(indexer, b.eachDocument)
+}
+
+// eachDocument call fn with every document added, stop at the first error
+func (b *IndexerBuilder) eachDocument(fn func(doc *Document) error) error {
 	for _, doc := range b.Documents {
-		b.buildDocEntries(indexer, doc)
+		if err := fn(doc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// buildIndexFrom build indexer with the documents given by each, see StreamingIndexerBuilder
+func (b *IndexerBuilder) buildIndexFrom(indexer BEIndex, each func(fn func(doc *Document) error) error) (_ BEIndex, err error) {
+	b.inCallback = false
+	defer b.recoverBuildPanic(&err)
+	if err := b.checkFieldOptions(); err != nil {
+		return nil, err
+	}
+	if err := b.checkWhitelists(); err != nil {
+		return nil, err
+	}
+	if len(b.verifySamples) > 0 {
+		b.inCallback = true
+		err := b.verifyParsers()
+		b.inCallback = false
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	start := time.Now()
+	b.inCallback = true // parsers of fields configured created
+	indexer.ConfigureIndexer(&b.settings)
+	b.inCallback = false
+
+	b.memoryUsage = 0
+	docCount, conjCount := 0, 0
+	err = each(func(doc *Document) error {
+		if err := b.buildDocEntries(indexer, doc); err != nil {
+			return err
+		}
+		docCount, conjCount = docCount+1, conjCount+len(doc.Cons)
+		if b.emitter != nil {
+			conjs, _ := indexer.DocConjunctions(doc.ID)
+			b.emit(EventDocIndexed, map[string]interface{}{"doc_id": doc.ID, "conjunctions": len(conjs)})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 	indexer.completeIndex()
+	indexer.setMutationSeq(b.mutationSeq)
+	b.lastReport = b.newBuildReport(indexer, conjCount)
+	b.reportHotKeys(indexer)
+	b.flushMutationLog()
 
-	return indexer
+	if b.emitter != nil {
+		for _, desc := range indexer.FieldDescriptions() {
+			b.emit(EventFieldCompiled, map[string]interface{}{"field": desc.Field, "field_id": desc.ID, "parser": desc.Parser})
+		}
+		stats := indexer.Stats()
+		b.emit(EventBuildComplete, map[string]interface{}{
+			"documents":     docCount,
+			"duration":      time.Since(start),
+			"conjunctions":  stats.ConjunctionCount,
+			"posting_lists": stats.PostingListCount,
+			"entries":       stats.EntryCount,
+		})
+	}
+	return indexer, nil
 }
 
-func (b *IndexerBuilder) BuildCompactedIndex() BEIndex {
+// recoverBuildPanic must be deferred directly by buildIndexFrom, under WithNoPanics it turn a panic
+// of parsers and user callbacks(see inCallback) into ErrBuildPanic, other panics are not recovered
+func (b *IndexerBuilder) recoverBuildPanic(err *error) {
+	if !b.noPanics || !b.inCallback {
+		return
+	}
+	if r := recover(); r != nil {
+		b.inCallback = false
+		Logger.Errorf("build index panic recovered:%v, stack:\n%s\n", r, debug.Stack())
+		*err = fmt.Errorf("%w: %v", ErrBuildPanic, r)
+	}
+}
 
+func (b *IndexerBuilder) newIDAllocator() parser.IDAllocator {
 	idGen := parser.NewIDAllocatorImpl()
+	if b.settings.ValueReverseLookup {
+		idGen = parser.NewReversibleIDAllocator()
+	}
+	if preloader, ok := idGen.(parser.Preloader); ok {
+		preloader.Preload(b.preloadValues)
+	}
+	return idGen
+}
 
-	indexer := NewCompactedBEIndex(idGen)
+// BuildIndexE build a SizeGroupedBEIndex, error returned when meet a bad conjunction under ErrorBadConj
+func (b *IndexerBuilder) BuildIndexE() (BEIndex, error) {
+	return b.buildIndex(b.newSizeGroupedIndex())
+}
 
-	indexer.ConfigureIndexer(&b.settings)
+func (b *IndexerBuilder) newSizeGroupedIndex() BEIndex {
+	indexer := NewSizeGroupedBEIndex(b.newIDAllocator())
+	indexer.(*SizeGroupedBEIndex).mergeThreshold = b.kGroupMergeThreshold
+	return indexer
+}
 
-	for _, doc := range b.Documents {
-		b.buildDocEntries(indexer, doc)
+// BuildCompactedIndexE build a CompactedBEIndex, error returned when meet a bad conjunction under ErrorBadConj
+func (b *IndexerBuilder) BuildCompactedIndexE() (BEIndex, error) {
+	return b.buildIndex(NewCompactedBEIndex(b.newIDAllocator()))
+}
+
+// BuildIndex panic if any error, use BuildIndexE instead for ErrorBadConj
+func (b *IndexerBuilder) BuildIndex() BEIndex {
+	indexer, err := b.BuildIndexE()
+	if err != nil {
+		panic(err)
 	}
-	indexer.completeIndex()
+	return indexer
+}
 
+// BuildCompactedIndex panic if any error, use BuildCompactedIndexE instead for ErrorBadConj
+func (b *IndexerBuilder) BuildCompactedIndex() BEIndex {
+	indexer, err := b.BuildCompactedIndexE()
+	if err != nil {
+		panic(err)
+	}
 	return indexer
 }
@@ -0,0 +1,165 @@
+package be_indexer
+
+import (
+	"sort"
+	"sync"
+)
+
+type (
+	/*ConcurrentIndexerBuilder a IndexerBuilder safe for concurrent use, documents can be added
+	from multiple goroutines while(or after) others build the index. validation of documents
+	run outside the lock, only the bookkeeping is serialized; use AddDocumentBatch to take the
+	lock once for many documents when contention matters.
+	*/
+	ConcurrentIndexerBuilder struct {
+		mtx     sync.Mutex
+		builder *IndexerBuilder
+	}
+)
+
+func NewConcurrentIndexerBuilder(opts ...BuilderOpt) *ConcurrentIndexerBuilder {
+	return &ConcurrentIndexerBuilder{
+		builder: NewIndexerBuilder(opts...),
+	}
+}
+
+func (b *ConcurrentIndexerBuilder) SetFieldParser(field BEField, parserName string) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	b.builder.SetFieldParser(field, parserName)
+}
+
+func (b *ConcurrentIndexerBuilder) ConfigField(field BEField, option FieldOption) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	b.builder.ConfigField(field, option)
+}
+
+// AddDocument see IndexerBuilder.AddDocument
+func (b *ConcurrentIndexerBuilder) AddDocument(doc *Document) error {
+	if err := b.builder.checkDocument(doc); err != nil {
+		return b.builder.handleBadConj(err)
+	}
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	b.builder.addDocument(doc)
+	return nil
+}
+
+/*
+AddDocumentBatch add docs under one lock, docs are validated before locking and added in
+order of DocID(the later one win for duplicated DocID in docs, same as calling AddDocument
+one by one); a invalid doc handled according BadConjBehavior, under ErrorBadConj the error
+returned and nothing of the batch added.
+*/
+func (b *ConcurrentIndexerBuilder) AddDocumentBatch(docs []*Document) error {
+	valid := make([]*Document, 0, len(docs))
+	for _, doc := range docs {
+		if err := b.builder.checkDocument(doc); err != nil {
+			if err = b.builder.handleBadConj(err); err != nil {
+				return err
+			}
+			continue
+		}
+		valid = append(valid, doc)
+	}
+	sort.SliceStable(valid, func(i, j int) bool {
+		return valid[i].ID < valid[j].ID
+	})
+
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	for _, doc := range valid {
+		b.builder.addDocument(doc)
+	}
+	return nil
+}
+
+func (b *ConcurrentIndexerBuilder) RemoveDocument(doc DocID) bool {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	return b.builder.RemoveDocument(doc)
+}
+
+// AddExternalDocument see IndexerBuilder.AddExternalDocument
+func (b *ConcurrentIndexerBuilder) AddExternalDocument(external uint64, doc *Document) error {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	return b.builder.AddExternalDocument(external, doc)
+}
+
+func (b *ConcurrentIndexerBuilder) RemoveExternalDocument(external uint64) bool {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	return b.builder.RemoveExternalDocument(external)
+}
+
+// WithFieldNamespace see IndexerBuilder.WithFieldNamespace, it applies to documents added by any goroutine
+func (b *ConcurrentIndexerBuilder) WithFieldNamespace(ns string) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	b.builder.WithFieldNamespace(ns)
+}
+
+// Reset see IndexerBuilder.Reset
+func (b *ConcurrentIndexerBuilder) Reset() {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	b.builder.Reset()
+}
+
+// DocumentCount return how many documents added
+func (b *ConcurrentIndexerBuilder) DocumentCount() int {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	return len(b.builder.Documents)
+}
+
+func (b *ConcurrentIndexerBuilder) FieldExpressionCounts() map[BEField]int {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	return b.builder.FieldExpressionCounts()
+}
+
+func (b *ConcurrentIndexerBuilder) TopNFields(n int) []BEField {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	return b.builder.TopNFields(n)
+}
+
+func (b *ConcurrentIndexerBuilder) ConjunctionSizeHistogram() map[int]int {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	return b.builder.ConjunctionSizeHistogram()
+}
+
+// BuildIndexE build index with documents added so far, documents added meanwhile wait until it's done
+func (b *ConcurrentIndexerBuilder) BuildIndexE() (BEIndex, error) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	return b.builder.BuildIndexE()
+}
+
+func (b *ConcurrentIndexerBuilder) BuildCompactedIndexE() (BEIndex, error) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	return b.builder.BuildCompactedIndexE()
+}
+
+func (b *ConcurrentIndexerBuilder) BuildIndex() BEIndex {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	return b.builder.BuildIndex()
+}
+
+func (b *ConcurrentIndexerBuilder) BuildCompactedIndex() BEIndex {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	return b.builder.BuildCompactedIndex()
+}
+
+func (b *ConcurrentIndexerBuilder) BuildReport() BuildReport {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	return b.builder.BuildReport()
+}
@@ -0,0 +1,79 @@
+package be_indexer
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestConcurrentIndexerBuilder(t *testing.T) {
+	LogLevel = ErrorLevel
+
+	convey.Convey("test add documents from multiple goroutines", t, func() {
+		builder := NewConcurrentIndexerBuilder()
+
+		var failures int64
+		wg := sync.WaitGroup{}
+		for g := 0; g < 8; g++ {
+			wg.Add(1)
+			go func(g int) {
+				defer wg.Done()
+				var batch []*Document
+				for i := 0; i < 100; i++ {
+					doc := NewDocument(DocID(g*1000 + i + 1))
+					doc.AddConjunction(NewConjunction().In("tag", NewIntValues(g%2)))
+					if i%2 != 0 {
+						batch = append(batch, doc)
+					} else if builder.AddDocument(doc) != nil {
+						atomic.AddInt64(&failures, 1)
+					}
+				}
+				if builder.AddDocumentBatch(batch) != nil {
+					atomic.AddInt64(&failures, 1)
+				}
+				builder.BuildIndex() // building concurrently with adding
+			}(g)
+		}
+		wg.Wait()
+
+		convey.So(failures, convey.ShouldEqual, 0)
+		convey.So(builder.DocumentCount(), convey.ShouldEqual, 800)
+		convey.So(builder.FieldExpressionCounts(), convey.ShouldResemble, map[BEField]int{"tag": 800})
+		for _, index := range []BEIndex{builder.BuildIndex(), builder.BuildCompactedIndex()} {
+			ids, err := index.Retrieve(Assignments{"tag": NewIntValues(1)})
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(len(ids), convey.ShouldEqual, 400)
+		}
+	})
+
+	convey.Convey("test add document batch", t, func() {
+		builder := NewConcurrentIndexerBuilder(WithBadConjBehavior(ErrorBadConj))
+
+		first, second := NewDocument(1), NewDocument(1)
+		first.AddConjunction(NewConjunction().In("tag", NewIntValues(1)))
+		second.AddConjunction(NewConjunction().In("tag", NewIntValues(2)))
+		other := NewDocument(2)
+		other.AddConjunction(NewConjunction().In("tag", NewIntValues(1)))
+
+		// bad doc fail the whole batch
+		bad := NewDocument(3)
+		bad.Cons = append(bad.Cons, nil)
+		convey.So(builder.AddDocumentBatch([]*Document{other, bad}), convey.ShouldNotBeNil)
+		convey.So(builder.DocumentCount(), convey.ShouldEqual, 0)
+
+		// the later one win for duplicated DocID
+		convey.So(builder.AddDocumentBatch([]*Document{other, first, second}), convey.ShouldBeNil)
+		convey.So(builder.DocumentCount(), convey.ShouldEqual, 2)
+		ids, err := builder.BuildIndex().Retrieve(Assignments{"tag": NewIntValues(2)})
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(ids, convey.ShouldResemble, DocIDList{1})
+
+		builder = NewConcurrentIndexerBuilder(WithBadConjBehavior(SkipBadConj))
+		convey.So(builder.AddDocumentBatch([]*Document{other, nil}), convey.ShouldBeNil)
+		convey.So(builder.DocumentCount(), convey.ShouldEqual, 1)
+		convey.So(builder.RemoveDocument(2), convey.ShouldBeTrue)
+		convey.So(builder.DocumentCount(), convey.ShouldEqual, 0)
+	})
+}
@@ -0,0 +1,84 @@
+package be_indexer
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+)
+
+type (
+	// ShardRouter assign documents to shards by hash of DocID, the same DocID always route to
+	// the same shard for a given shard count, across processes and runs
+	ShardRouter struct {
+		shards int
+	}
+
+	/*ShardedIndexerBuilder a IndexerBuilder per shard, documents routed by ShardRouter, so shards
+	can be built(BuildIndex of Shard(i)) in parallel and a doc updated later land on its shard again;
+	like IndexerBuilder it's not safe for concurrent use.
+	*/
+	ShardedIndexerBuilder struct {
+		router   *ShardRouter
+		builders []*IndexerBuilder
+	}
+)
+
+func NewShardRouter(shards int) *ShardRouter {
+	if shards <= 0 {
+		panic(fmt.Errorf("invalid shard count:%d", shards))
+	}
+	return &ShardRouter{shards: shards}
+}
+
+// ShardFor the shard of doc, fnv32a hash of DocID(little endian) mod shard count
+func (r *ShardRouter) ShardFor(id DocID) int {
+	buf := make([]byte, 4)
+	binary.LittleEndian.PutUint32(buf, uint32(id))
+	h := fnv.New32a()
+	_, _ = h.Write(buf)
+	return int(h.Sum32() % uint32(r.shards))
+}
+
+func (r *ShardRouter) Shards() int {
+	return r.shards
+}
+
+// NewShardedIndexerBuilder create shards builders with the same opts
+func NewShardedIndexerBuilder(shards int, opts ...BuilderOpt) *ShardedIndexerBuilder {
+	b := &ShardedIndexerBuilder{
+		router:   NewShardRouter(shards),
+		builders: make([]*IndexerBuilder, shards),
+	}
+	for i := range b.builders {
+		b.builders[i] = NewIndexerBuilder(opts...)
+	}
+	return b
+}
+
+func (b *ShardedIndexerBuilder) Router() *ShardRouter {
+	return b.router
+}
+
+// Shard the builder of i-th shard, panic if i out of range
+func (b *ShardedIndexerBuilder) Shard(i int) *IndexerBuilder {
+	if i < 0 || i >= len(b.builders) {
+		panic(fmt.Errorf("shard:%d out of range [0, %d)", i, len(b.builders)))
+	}
+	return b.builders[i]
+}
+
+// ConfigField config field of every shard, see IndexerBuilder.ConfigField
+func (b *ShardedIndexerBuilder) ConfigField(field BEField, option FieldOption) {
+	for _, builder := range b.builders {
+		builder.ConfigField(field, option)
+	}
+}
+
+// ShardedAddDocument add doc to the shard it routed to, see IndexerBuilder.AddDocument
+func (b *ShardedIndexerBuilder) ShardedAddDocument(doc *Document) (shardIdx int, err error) {
+	if doc == nil { // no shard to route, validated(and handled) as any builder do
+		return -1, b.builders[0].AddDocument(doc)
+	}
+	shardIdx = b.router.ShardFor(doc.ID)
+	return shardIdx, b.builders[shardIdx].AddDocument(doc)
+}
@@ -0,0 +1,64 @@
+package be_indexer
+
+import (
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestShardRouter(t *testing.T) {
+	convey.Convey("test shard router deterministic and balanced", t, func() {
+		router, other := NewShardRouter(8), NewShardRouter(8)
+		counts := make([]int, 8)
+		for id := DocID(0); id < 8000; id++ {
+			shard := router.ShardFor(id)
+			convey.So(shard, convey.ShouldEqual, other.ShardFor(id))
+			counts[shard]++
+		}
+		for _, cnt := range counts {
+			convey.So(cnt, convey.ShouldBeBetween, 800, 1200)
+		}
+		// fnv32a of little endian DocID, fixed across runs
+		convey.So(NewShardRouter(1000).ShardFor(1), convey.ShouldEqual, 0xfb69b604%1000)
+
+		convey.So(func() { NewShardRouter(0) }, convey.ShouldPanic)
+	})
+}
+
+func TestShardedIndexerBuilder(t *testing.T) {
+	LogLevel = ErrorLevel
+
+	convey.Convey("test sharded builder route docs to shards", t, func() {
+		b := NewShardedIndexerBuilder(4, WithBadConjBehavior(ErrorBadConj))
+		b.ConfigField("tag", FieldOption{})
+		for id := 1; id <= 100; id++ {
+			doc := NewDocument(DocID(id))
+			doc.AddConjunction(NewConjunction().In("tag", NewIntValues(1)))
+			shard, err := b.ShardedAddDocument(doc)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(shard, convey.ShouldEqual, b.Router().ShardFor(doc.ID))
+			convey.So(b.Shard(shard).Documents, convey.ShouldContainKey, doc.ID)
+		}
+		// update land on the same shard
+		doc := NewDocument(1)
+		doc.AddConjunction(NewConjunction().In("tag", NewIntValues(2)))
+		shard, err := b.ShardedAddDocument(doc)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(shard, convey.ShouldEqual, b.Router().ShardFor(1))
+
+		var all DocIDList
+		for i := 0; i < b.Router().Shards(); i++ {
+			result, err := b.Shard(i).BuildIndex().Retrieve(Assignments{"tag": NewIntValues(1)})
+			convey.So(err, convey.ShouldBeNil)
+			for _, id := range result {
+				convey.So(b.Router().ShardFor(id), convey.ShouldEqual, i)
+			}
+			all = append(all, result...)
+		}
+		convey.So(len(all), convey.ShouldEqual, 99)
+
+		_, err = b.ShardedAddDocument(nil)
+		convey.So(err, convey.ShouldNotBeNil)
+		convey.So(func() { b.Shard(4) }, convey.ShouldPanic)
+	})
+}
@@ -0,0 +1,179 @@
+package be_indexer
+
+import (
+	"bufio"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+type (
+	/*StreamingIndexerBuilder build index from documents more than memory can hold at once: every
+	document added is encoded(encoding/gob) into a temp file instead of being kept, and building
+	make a single pass over the file, a document decoded, indexed and dropped one by one; only the
+	index and the DocID -> latest version map stay in memory. adding a DocID again replace it as
+	IndexerBuilder does. value types other than the builtin ones must be gob.Register-ed. it's not
+	safe for concurrent use, call Close to remove the temp file.
+	*/
+	StreamingIndexerBuilder struct {
+		builder *IndexerBuilder // settings and building, its Documents are never used
+		file    *os.File
+		writer  *bufio.Writer
+		encoder *gob.Encoder
+		latest  map[DocID]int // doc -> seq of its latest version in file, other versions skipped
+		seq     int           // records written
+	}
+
+	// streamedDoc a record of the temp file, gob drop pointers to zero value, so SortKey carried aside
+	streamedDoc struct {
+		Doc        *Document
+		SortKey    int64
+		HasSortKey bool
+	}
+)
+
+var (
+	// ErrStreamingBuilderClosed use a StreamingIndexerBuilder after Close
+	ErrStreamingBuilderClosed = errors.New("streaming builder closed")
+)
+
+// NewStreamingIndexerBuilder create the temp file in dir(os.TempDir() if ""), opts same as NewIndexerBuilder
+func NewStreamingIndexerBuilder(dir string, opts ...BuilderOpt) (*StreamingIndexerBuilder, error) {
+	file, err := ioutil.TempFile(dir, "be_indexer_stream_*.gob")
+	if err != nil {
+		return nil, err
+	}
+	writer := bufio.NewWriter(file)
+	return &StreamingIndexerBuilder{
+		builder: NewIndexerBuilder(opts...),
+		file:    file,
+		writer:  writer,
+		encoder: gob.NewEncoder(writer),
+		latest:  make(map[DocID]int),
+	}, nil
+}
+
+func (b *StreamingIndexerBuilder) SetFieldParser(field BEField, parserName string) {
+	b.builder.SetFieldParser(field, parserName)
+}
+
+func (b *StreamingIndexerBuilder) ConfigField(field BEField, option FieldOption) {
+	b.builder.ConfigField(field, option)
+}
+
+// AddDocument validate doc and append it to the temp file, a invalid doc handled according BadConjBehavior
+func (b *StreamingIndexerBuilder) AddDocument(doc *Document) error {
+	if b.file == nil {
+		return ErrStreamingBuilderClosed
+	}
+	if err := b.builder.checkDocument(doc); err != nil {
+		return b.builder.handleBadConj(err)
+	}
+	record := streamedDoc{Doc: doc}
+	if doc.SortKey != nil {
+		record.SortKey, record.HasSortKey = *doc.SortKey, true
+	}
+	if err := b.encoder.Encode(&record); err != nil {
+		return fmt.Errorf("doc:%d stream fail, %w", doc.ID, err)
+	}
+	b.latest[doc.ID] = b.seq
+	b.seq++
+	return nil
+}
+
+// RemoveDocument drop doc from the index built later, its records stay in file but skipped
+func (b *StreamingIndexerBuilder) RemoveDocument(id DocID) bool {
+	_, hit := b.latest[id]
+	delete(b.latest, id)
+	return hit
+}
+
+// DocumentCount documents to be indexed, replaced and removed ones not counted
+func (b *StreamingIndexerBuilder) DocumentCount() int {
+	return len(b.latest)
+}
+
+// eachDocument decode the temp file from the start, call fn with the latest version of every doc
+func (b *StreamingIndexerBuilder) eachDocument(fn func(doc *Document) error) error {
+	if err := b.writer.Flush(); err != nil {
+		return err
+	}
+	file, err := os.Open(b.file.Name())
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	decoder := gob.NewDecoder(bufio.NewReader(file))
+	for seq := 0; seq < b.seq; seq++ {
+		record := streamedDoc{}
+		if err = decoder.Decode(&record); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return fmt.Errorf("decode streamed doc fail, %w", err)
+		}
+		doc := record.Doc
+		if latest, ok := b.latest[doc.ID]; !ok || latest != seq {
+			continue
+		}
+		if record.HasSortKey {
+			doc.SetSortKey(record.SortKey)
+		}
+		if err = fn(doc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// BuildIndexE build a SizeGroupedBEIndex in one pass over the documents streamed, see IndexerBuilder.BuildIndexE
+func (b *StreamingIndexerBuilder) BuildIndexE() (BEIndex, error) {
+	if b.file == nil {
+		return nil, ErrStreamingBuilderClosed
+	}
+	return b.builder.buildIndexFrom(b.builder.newSizeGroupedIndex(), b.eachDocument)
+}
+
+// BuildCompactedIndexE build a CompactedBEIndex in one pass over the documents streamed
+func (b *StreamingIndexerBuilder) BuildCompactedIndexE() (BEIndex, error) {
+	if b.file == nil {
+		return nil, ErrStreamingBuilderClosed
+	}
+	return b.builder.buildIndexFrom(NewCompactedBEIndex(b.builder.newIDAllocator()), b.eachDocument)
+}
+
+// BuildIndex panic if any error, use BuildIndexE instead
+func (b *StreamingIndexerBuilder) BuildIndex() BEIndex {
+	indexer, err := b.BuildIndexE()
+	if err != nil {
+		panic(err)
+	}
+	return indexer
+}
+
+// BuildCompactedIndex panic if any error, use BuildCompactedIndexE instead
+func (b *StreamingIndexerBuilder) BuildCompactedIndex() BEIndex {
+	indexer, err := b.BuildCompactedIndexE()
+	if err != nil {
+		panic(err)
+	}
+	return indexer
+}
+
+// Close remove the temp file, the indexes built are not affected; it's safe to call more than once
+func (b *StreamingIndexerBuilder) Close() error {
+	if b.file == nil {
+		return nil
+	}
+	file := b.file
+	b.file = nil
+	err := file.Close()
+	if e := os.Remove(file.Name()); err == nil {
+		err = e
+	}
+	return err
+}
@@ -0,0 +1,93 @@
+package be_indexer
+
+import (
+	"os"
+	"sort"
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestStreamingIndexerBuilder(t *testing.T) {
+	LogLevel = ErrorLevel
+
+	newDoc := func(id, version int) *Document {
+		doc := NewDocument(DocID(id))
+		conj := NewConjunction().In("tag", NewIntValues(id%5+version)).NotIn("city", NewStrValues("bj"))
+		if id%3 == 0 {
+			conj.In("age", NewInt32Values(int32(id%7)))
+		}
+		doc.AddConjunction(conj)
+		if id%4 == 0 {
+			doc.AddConjunction(NewConjunction().NotIn("city", NewStrValues("sh")))
+		}
+		doc.Labels = []string{"l" + string(rune('a'+id%2))}
+		doc.SetSortKey(int64(id % 3)) // zero included
+		return doc
+	}
+
+	convey.Convey("test index built by streaming same as built in memory", t, func() {
+		streaming, err := NewStreamingIndexerBuilder("", WithBadConjBehavior(ErrorBadConj))
+		convey.So(err, convey.ShouldBeNil)
+		defer streaming.Close()
+		b := NewIndexerBuilder(WithBadConjBehavior(ErrorBadConj))
+		add := func(doc *Document) {
+			convey.So(streaming.AddDocument(doc), convey.ShouldBeNil)
+			convey.So(b.AddDocument(doc), convey.ShouldBeNil)
+		}
+		for id := 1; id <= 300; id++ {
+			add(newDoc(id, 0))
+		}
+		for id := 1; id <= 300; id += 10 {
+			add(newDoc(id, 1)) // replaced
+		}
+		for id := 2; id <= 300; id += 50 {
+			convey.So(streaming.RemoveDocument(DocID(id)), convey.ShouldBeTrue)
+			convey.So(b.RemoveDocument(DocID(id)), convey.ShouldBeTrue)
+		}
+		convey.So(streaming.RemoveDocument(1000), convey.ShouldBeFalse)
+		convey.So(streaming.DocumentCount(), convey.ShouldEqual, len(b.Documents))
+		convey.So(streaming.AddDocument(nil), convey.ShouldNotBeNil)
+
+		queries := []Assignments{
+			{"tag": NewIntValues(1, 2)},
+			{"tag": NewIntValues(3), "age": NewInt32Values(3)},
+			{"tag": NewIntValues(5), "city": NewStrValues("bj")},
+			{"city": NewStrValues("sh")},
+		}
+		opts := []IndexOpt{WithLabelFilter("la"), WithOrderBySortKey(false)}
+		for _, pair := range [][2]BEIndex{
+			{streaming.BuildIndex(), b.BuildIndex()},
+			{streaming.BuildCompactedIndex(), b.BuildCompactedIndex()},
+		} {
+			convey.So(pair[0].Stats().DocCount, convey.ShouldEqual, pair[1].Stats().DocCount)
+			for _, query := range queries {
+				expect, err := pair[1].Retrieve(query)
+				convey.So(err, convey.ShouldBeNil)
+				result, err := pair[0].Retrieve(query)
+				convey.So(err, convey.ShouldBeNil)
+				sort.Sort(expect)
+				sort.Sort(result)
+				convey.So(result, convey.ShouldResemble, expect)
+
+				expect, _ = pair[1].Retrieve(query, opts...)
+				result, _ = pair[0].Retrieve(query, opts...)
+				convey.So(result, convey.ShouldResemble, expect)
+			}
+		}
+
+		add(newDoc(2, 0)) // keep adding after built
+		result, err := streaming.BuildIndex().Retrieve(Assignments{"tag": NewIntValues(2)})
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(result, convey.ShouldContain, DocID(2))
+
+		name := streaming.file.Name()
+		convey.So(streaming.Close(), convey.ShouldBeNil)
+		_, err = os.Stat(name)
+		convey.So(os.IsNotExist(err), convey.ShouldBeTrue)
+		convey.So(streaming.Close(), convey.ShouldBeNil)
+		convey.So(streaming.AddDocument(newDoc(1, 0)), convey.ShouldEqual, ErrStreamingBuilderClosed)
+		_, err = streaming.BuildIndexE()
+		convey.So(err, convey.ShouldEqual, ErrStreamingBuilderClosed)
+	})
+}
@@ -0,0 +1,985 @@
+package be_indexer
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"unsafe"
+
+	"github.com/echoface/be_indexer/parser"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func newBadConjTestBuilder(opts ...BuilderOpt) *IndexerBuilder {
+	b := NewIndexerBuilder(opts...)
+	b.SetFieldParser("age", parser.NumRangeParser)
+
+	doc := NewDocument(1)
+	doc.AddConjunction(
+		NewConjunction().In("age", NewStrValues("bad_range")),
+		NewConjunction().In("tag", NewIntValues(1)))
+	_ = b.AddDocument(doc)
+
+	doc = NewDocument(2)
+	doc.AddConjunction(NewConjunction().In("age", NewStrValues("1:10")))
+	_ = b.AddDocument(doc)
+	return b
+}
+
+func TestIndexerBuilder_BadConjBehavior(t *testing.T) {
+	LogLevel = ErrorLevel
+
+	convey.Convey("test bad conjunction behavior when building", t, func() {
+		convey.So(func() { newBadConjTestBuilder().BuildIndex() }, convey.ShouldPanic)
+
+		_, err := newBadConjTestBuilder(WithBadConjBehavior(ErrorBadConj)).BuildIndexE()
+		convey.So(err, convey.ShouldNotBeNil)
+		_, err = newBadConjTestBuilder(WithBadConjBehavior(ErrorBadConj)).BuildCompactedIndexE()
+		convey.So(err, convey.ShouldNotBeNil)
+
+		index, err := newBadConjTestBuilder(WithBadConjBehavior(SkipBadConj)).BuildIndexE()
+		convey.So(err, convey.ShouldBeNil)
+		ids, err := index.Retrieve(Assignments{"age": NewIntValues(5), "tag": NewIntValues(1)})
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(len(ids), convey.ShouldEqual, 2)
+	})
+
+	convey.Convey("test bad document behavior when adding", t, func() {
+		convey.So(func() { _ = NewIndexerBuilder().AddDocument(nil) }, convey.ShouldPanic)
+		convey.So(NewIndexerBuilder(WithBadConjBehavior(ErrorBadConj)).AddDocument(nil), convey.ShouldNotBeNil)
+
+		b := NewIndexerBuilder(WithBadConjBehavior(SkipBadConj))
+		convey.So(b.AddDocument(nil), convey.ShouldBeNil)
+		convey.So(len(b.Documents), convey.ShouldEqual, 0)
+	})
+}
+
+func TestIndexerBuilder_FieldExpressionCounts(t *testing.T) {
+	convey.Convey("test field expression counts", t, func() {
+		b := NewIndexerBuilder()
+
+		doc := NewDocument(1)
+		doc.AddConjunction(
+			NewConjunction().In("age", NewIntValues(1)).In("tag", NewIntValues(1)),
+			NewConjunction().In("tag", NewIntValues(2)))
+		_ = b.AddDocument(doc)
+
+		doc = NewDocument(2)
+		doc.AddConjunction(NewConjunction().NotIn("tag", NewIntValues(1)).In("city", NewStrValues("bj")))
+		_ = b.AddDocument(doc)
+
+		convey.So(b.FieldExpressionCounts(), convey.ShouldResemble, map[BEField]int{"age": 1, "tag": 3, "city": 1})
+		convey.So(b.TopNFields(2), convey.ShouldResemble, []BEField{"tag", "age"})
+		convey.So(b.TopNFields(10), convey.ShouldResemble, []BEField{"tag", "age", "city"})
+
+		// replace doc 2
+		doc = NewDocument(2)
+		doc.AddConjunction(NewConjunction().In("city", NewStrValues("sh")))
+		_ = b.AddDocument(doc)
+		convey.So(b.FieldExpressionCounts(), convey.ShouldResemble, map[BEField]int{"age": 1, "tag": 2, "city": 1})
+
+		b.RemoveDocument(1)
+		convey.So(b.FieldExpressionCounts(), convey.ShouldResemble, map[BEField]int{"city": 1})
+
+		b.Reset()
+		convey.So(len(b.Documents), convey.ShouldEqual, 0)
+		convey.So(b.FieldExpressionCounts(), convey.ShouldBeEmpty)
+		convey.So(b.TopNFields(3), convey.ShouldBeEmpty)
+	})
+}
+
+func TestIndexerBuilder_ConjunctionSizeHistogram(t *testing.T) {
+	convey.Convey("test conjunction size histogram", t, func() {
+		b := NewIndexerBuilder()
+		convey.So(b.ConjunctionSizeHistogram(), convey.ShouldBeEmpty)
+
+		doc := NewDocument(1)
+		doc.AddConjunction(
+			NewConjunction().In("age", NewIntValues(1)).In("tag", NewIntValues(1)),
+			NewConjunction().In("tag", NewIntValues(2)),
+			NewConjunction().NotIn("tag", NewIntValues(3)))
+		_ = b.AddDocument(doc)
+
+		doc = NewDocument(2)
+		doc.AddConjunction(
+			NewConjunction().In("city", NewStrValues("bj")).NotIn("tag", NewIntValues(1)),
+			NewConjunction().InAll("tag", NewIntValues(1, 2, 3)))
+		_ = b.AddDocument(doc)
+		convey.So(b.ConjunctionSizeHistogram(), convey.ShouldResemble, map[int]int{0: 1, 1: 2, 2: 1, 3: 1})
+
+		b.RemoveDocument(1)
+		convey.So(b.ConjunctionSizeHistogram(), convey.ShouldResemble, map[int]int{1: 1, 3: 1})
+	})
+}
+
+func TestIndexerBuilder_WithMemoryBudget(t *testing.T) {
+	LogLevel = ErrorLevel
+
+	buildDocs := func(b *IndexerBuilder) {
+		for i := 1; i <= 10; i++ {
+			doc := NewDocument(DocID(i))
+			doc.AddConjunction(NewConjunction().In("tag", NewIntValues(1, 2, i)))
+			_ = b.AddDocument(doc)
+		}
+	}
+
+	convey.Convey("test build with memory budget", t, func() {
+		// 10 keys, 30 entries(doc 1 and 2 hold duplicated values)
+		b := NewIndexerBuilder(WithMemoryBudget(10*postingKeyBytes + 30*entryIDBytes))
+		buildDocs(b)
+		index, err := b.BuildIndexE()
+		convey.So(err, convey.ShouldBeNil)
+		ids, _ := index.Retrieve(Assignments{"tag": NewIntValues(2)})
+		convey.So(len(ids), convey.ShouldEqual, 10)
+
+		_, err = b.BuildCompactedIndexE()
+		convey.So(err, convey.ShouldBeNil)
+
+		b = NewIndexerBuilder(WithMemoryBudget(10*postingKeyBytes + 30*entryIDBytes - 1))
+		buildDocs(b)
+		_, err = b.BuildIndexE()
+		convey.So(errors.Is(err, ErrMemoryBudgetExceeded), convey.ShouldBeTrue)
+		convey.So(func() { b.BuildCompactedIndex() }, convey.ShouldPanic)
+
+		b = NewIndexerBuilder(WithMemoryBudget(100))
+		buildDocs(b)
+		_, err = b.BuildIndexE()
+		convey.So(errors.Is(err, ErrMemoryBudgetExceeded), convey.ShouldBeTrue)
+	})
+}
+
+type trimOnBuildParser struct {
+	idAlloc parser.IDAllocator
+}
+
+func (p *trimOnBuildParser) ParseAssign(v interface{}) ([]uint64, error) {
+	s := v.(string)
+	if id, ok := p.idAlloc.FindStringID(&s); ok {
+		return []uint64{id}, nil
+	}
+	return nil, nil
+}
+
+func (p *trimOnBuildParser) ParseValue(v interface{}) ([]uint64, error) {
+	return []uint64{p.idAlloc.AllocStringID(strings.TrimSpace(v.(string)))}, nil
+}
+
+// trimByParamParser consistent unless configured with trim=build, then trim on build only
+type trimByParamParser struct {
+	trimOnBuildParser
+	trim bool
+}
+
+func (p *trimByParamParser) Configure(params map[string]string) error {
+	p.trim = params["trim"] == "build"
+	return nil
+}
+
+func (p *trimByParamParser) ParseValue(v interface{}) ([]uint64, error) {
+	if p.trim {
+		return p.trimOnBuildParser.ParseValue(v)
+	}
+	return []uint64{p.idAlloc.AllocStringID(v.(string))}, nil
+}
+
+func init() {
+	parser.RegisterBuilder("test_trim_on_build", func(alloc parser.IDAllocator) parser.FieldValueParser {
+		return &trimOnBuildParser{idAlloc: alloc}
+	})
+	parser.RegisterBuilder("test_trim_by_param", func(alloc parser.IDAllocator) parser.FieldValueParser {
+		return &trimByParamParser{trimOnBuildParser: trimOnBuildParser{idAlloc: alloc}}
+	})
+	parser.RegisterBuilder("test_panic_on_assign", func(alloc parser.IDAllocator) parser.FieldValueParser {
+		return &trimOnBuildParser{idAlloc: alloc} // panic for a non-string query value
+	})
+}
+
+func TestIndexerBuilder_WithParserVerification(t *testing.T) {
+	LogLevel = ErrorLevel
+
+	convey.Convey("test parser verification when building", t, func() {
+		samples := NewStrValues("a", " b")
+
+		b := NewIndexerBuilder(WithParserVerification(samples), WithBadConjBehavior(ErrorBadConj))
+		b.SetFieldParser("tag", parser.CommonParser)
+		b.SetFieldParser("age", parser.NumRangeParser)
+		_, err := b.BuildIndexE()
+		convey.So(err, convey.ShouldBeNil)
+
+		b.SetFieldParser("keyword", "test_trim_on_build")
+		_, err = b.BuildIndexE()
+		convey.So(err, convey.ShouldNotBeNil)
+		convey.So(err.Error(), convey.ShouldContainSubstring, "keyword")
+
+		// verified as configured: params turn a consistent parser inconsistent
+		b = NewIndexerBuilder(WithParserVerification(samples))
+		b.SetFieldParser("keyword", "test_trim_by_param")
+		_, err = b.BuildIndexE()
+		convey.So(err, convey.ShouldBeNil)
+		b.ConfigField("keyword", FieldOption{Parser: "test_trim_by_param", ParserParams: map[string]string{"trim": "build"}})
+		_, err = b.BuildIndexE()
+		convey.So(err, convey.ShouldNotBeNil)
+		convey.So(err.Error(), convey.ShouldContainSubstring, "keyword")
+
+		// fields not configured parsed by the default option
+		b = NewIndexerBuilder(WithParserVerification(samples), WithDefaultFieldOption(FieldOption{Parser: "test_trim_on_build"}))
+		_, err = b.BuildIndexE()
+		convey.So(err, convey.ShouldNotBeNil)
+		convey.So(err.Error(), convey.ShouldContainSubstring, "#default")
+
+		// without verification, index built but never match
+		b = NewIndexerBuilder()
+		b.SetFieldParser("keyword", "test_trim_on_build")
+		doc := NewDocument(1)
+		doc.AddConjunction(NewConjunction().In("keyword", NewStrValues(" b")))
+		_ = b.AddDocument(doc)
+		index, err := b.BuildIndexE()
+		convey.So(err, convey.ShouldBeNil)
+		ids, _ := index.Retrieve(Assignments{"keyword": NewStrValues(" b")})
+		convey.So(ids, convey.ShouldBeEmpty)
+	})
+}
+
+func TestIndexerBuilder_WithPreloadValues(t *testing.T) {
+	LogLevel = ErrorLevel
+
+	convey.Convey("test preloaded value ids stable regardless of ingest order", t, func() {
+		preload := []string{"us", "cn", "jp"}
+		newDoc := func(id DocID, country string) *Document {
+			doc := NewDocument(id)
+			doc.AddConjunction(NewConjunction().In("country", NewStrValues(country)))
+			return doc
+		}
+
+		b1 := NewIndexerBuilder(WithPreloadValues(preload))
+		_ = b1.AddDocument(newDoc(1, "jp"))
+		b2 := NewIndexerBuilder(WithPreloadValues(preload))
+		_ = b2.AddDocument(newDoc(1, "us"))
+		_ = b2.AddDocument(newDoc(2, "jp"))
+
+		for _, index := range []BEIndex{b1.BuildIndex(), b2.BuildIndex(), b2.BuildCompactedIndex()} {
+			var alloc parser.IDAllocator
+			switch bi := index.(type) {
+			case *SizeGroupedBEIndex:
+				alloc = bi.idAllocator
+			case *CompactedBEIndex:
+				alloc = bi.idAllocator
+			}
+			for idx, v := range preload {
+				id, found := alloc.FindStringID(&v)
+				convey.So(found, convey.ShouldBeTrue)
+				convey.So(id, convey.ShouldEqual, uint64(idx))
+			}
+		}
+	})
+}
+
+func TestIndexerBuilder_WithRejectMixedWildcard(t *testing.T) {
+	LogLevel = ErrorLevel
+
+	newMixedDoc := func(id DocID) *Document {
+		doc := NewDocument(id)
+		doc.AddConjunction(
+			NewConjunction().In("tag", NewIntValues(1)).In("age", NewIntValues(18)),
+			NewConjunction().NotIn("city", NewStrValues("sh"))) // exclude-only, size 0
+		return doc
+	}
+
+	convey.Convey("test mixed wildcard document semantics", t, func() {
+		builder := NewIndexerBuilder()
+		convey.So(builder.AddDocument(newMixedDoc(1)), convey.ShouldBeNil)
+		for _, index := range []BEIndex{builder.BuildIndex(), builder.BuildCompactedIndex()} {
+			// size-2 conjunction not satisfied, but the size-0 one match
+			ids, err := index.Retrieve(Assignments{"tag": NewIntValues(2)})
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(ids, convey.ShouldResemble, DocIDList{1})
+
+			ids, err = index.Retrieve(Assignments{"city": NewStrValues("sh")})
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(ids, convey.ShouldBeEmpty)
+		}
+	})
+
+	convey.Convey("test reject mixed wildcard document", t, func() {
+		builder := NewIndexerBuilder(WithRejectMixedWildcard(), WithBadConjBehavior(ErrorBadConj))
+		err := builder.AddDocument(newMixedDoc(1))
+		convey.So(err, convey.ShouldNotBeNil)
+		convey.So(err.Error(), convey.ShouldContainSubstring, "size-0 conjunction(index:1)")
+
+		doc := newMixedDoc(2)
+		doc.AllowMixedWildcard = true
+		convey.So(builder.AddDocument(doc), convey.ShouldBeNil)
+
+		doc = NewDocument(3)
+		doc.AddConjunction(NewConjunction().NotIn("city", NewStrValues("sh")))
+		doc.AddConjunction(NewConjunction().NotIn("city", NewStrValues("bj")))
+		convey.So(builder.AddDocument(doc), convey.ShouldBeNil)
+
+		doc = NewDocument(4)
+		doc.AddConjunction(NewConjunction().In("tag", NewIntValues(1)))
+		doc.AddConjunction(NewConjunction().In("age", NewIntValues(1)).NotIn("city", NewStrValues("sh")))
+		convey.So(builder.AddDocument(doc), convey.ShouldBeNil)
+		convey.So(len(builder.Documents), convey.ShouldEqual, 3)
+
+		builder = NewIndexerBuilder(WithRejectMixedWildcard())
+		convey.So(func() { _ = builder.AddDocument(newMixedDoc(1)) }, convey.ShouldPanic)
+	})
+}
+
+func TestIndexerBuilder_WithDefaultFieldOption(t *testing.T) {
+	LogLevel = ErrorLevel
+
+	convey.Convey("test merge field option", t, func() {
+		base := FieldOption{Parser: parser.VectorSimParser, ParserParams: map[string]string{"threshold": "0.8", "bands": "4"}}
+		convey.So(mergeFieldOption(base, FieldOption{}), convey.ShouldResemble, base)
+		convey.So(mergeFieldOption(base, FieldOption{ParserParams: map[string]string{"bands": "2"}}), convey.ShouldResemble,
+			FieldOption{Parser: parser.VectorSimParser, ParserParams: map[string]string{"threshold": "0.8", "bands": "2"}})
+		convey.So(mergeFieldOption(base, FieldOption{Parser: parser.NumRangeParser}), convey.ShouldResemble,
+			FieldOption{Parser: parser.NumRangeParser})
+		convey.So(base.ParserParams, convey.ShouldResemble, map[string]string{"threshold": "0.8", "bands": "4"})
+	})
+
+	convey.Convey("test precedence explicit > builder default > package default", t, func() {
+		newDoc := func() *Document {
+			doc := NewDocument(1)
+			doc.AddConjunction(NewConjunction().
+				In("explicit", NewStrValues("10:20")).
+				In("inherit", NewStrValues("abc")).
+				In("auto", NewStrValues("abc")))
+			return doc
+		}
+
+		builder := NewIndexerBuilder(WithDefaultFieldOption(FieldOption{
+			Parser:       parser.FuzzyMatchParser,
+			ParserParams: map[string]string{"distance": "1"},
+		}))
+		builder.SetFieldParser("explicit", parser.NumRangeParser) // fuzzy params not inherited
+		builder.ConfigField("inherit", FieldOption{ParserParams: map[string]string{"distance": "0"}})
+		builder.AddDocument(newDoc())
+
+		for _, index := range []BEIndex{builder.BuildIndex(), builder.BuildCompactedIndex()} {
+			parsers := map[BEField]string{}
+			for _, desc := range index.FieldDescriptions() {
+				parsers[desc.Field] = desc.Parser
+				convey.So(desc.AutoCreated, convey.ShouldEqual, desc.Field == "auto")
+			}
+			convey.So(parsers, convey.ShouldResemble, map[BEField]string{
+				"explicit": parser.NumRangeParser,
+				"inherit":  parser.FuzzyMatchParser,
+				"auto":     parser.FuzzyMatchParser,
+			})
+			convey.So(index.Stats().AutoCreatedFields, convey.ShouldResemble, []BEField{"auto"})
+
+			query := Assignments{"explicit": NewIntValues(15), "inherit": NewStrValues("abc"), "auto": NewStrValues("abd")}
+			ids, err := index.Retrieve(query)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(ids, convey.ShouldResemble, DocIDList{1})
+
+			query["inherit"] = NewStrValues("abd") // distance 0 explicitly configured
+			ids, err = index.Retrieve(query)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(ids, convey.ShouldBeEmpty)
+		}
+
+		builder = NewIndexerBuilder()
+		builder.AddDocument(newDoc())
+		index := builder.BuildIndex()
+		convey.So(index.Stats().AutoCreatedFields, convey.ShouldResemble, []BEField{"auto", "explicit", "inherit"})
+		for _, desc := range index.FieldDescriptions() {
+			convey.So(desc.Parser, convey.ShouldEqual, parser.CommonParser)
+		}
+	})
+}
+
+func TestIndexerBuilder_WithKGroupMerging(t *testing.T) {
+	LogLevel = ErrorLevel
+
+	rd := rand.New(rand.NewSource(11))
+	fields := []BEField{"f0", "f1", "f2", "f3", "f4", "f5", "f6", "f7", "f8"}
+	randValues := func() []int {
+		values := make([]int, 1+rd.Intn(2))
+		for i := range values {
+			values[i] = rd.Intn(3)
+		}
+		return values
+	}
+
+	docs := make(map[DocID][][]allOfTestExpr)
+	newBuilder := func(opts ...BuilderOpt) *IndexerBuilder {
+		builder := NewIndexerBuilder(opts...)
+		for id := range docs {
+			doc := NewDocument(id)
+			for _, exprs := range docs[id] {
+				conj := NewConjunction()
+				for _, expr := range exprs {
+					if expr.kind == 0 {
+						conj.In(expr.field, NewIntValues(expr.values...))
+					} else {
+						conj.NotIn(expr.field, NewIntValues(expr.values...))
+					}
+				}
+				doc.AddConjunction(conj)
+			}
+			builder.AddDocument(doc)
+		}
+		return builder
+	}
+	for id := DocID(1); id <= 500; id++ {
+		exprCnt := 1 + rd.Intn(3)
+		if id%25 == 0 { // outliers
+			exprCnt = 6 + rd.Intn(4)
+		}
+		var exprs []allOfTestExpr
+		for _, f := range rd.Perm(len(fields))[:exprCnt] {
+			kind := 0
+			if rd.Intn(5) == 0 {
+				kind = 1
+			}
+			exprs = append(exprs, allOfTestExpr{field: fields[f], kind: kind, values: randValues()})
+		}
+		docs[id] = append(docs[id], exprs)
+	}
+
+	convey.Convey("test K group merging differential with brute force", t, func() {
+		indexes := []BEIndex{
+			newBuilder().BuildIndex(),
+			newBuilder(WithKGroupMerging(100)).BuildIndex(),
+			newBuilder(WithKGroupMerging(100)).BuildCompactedIndex(),
+		}
+		convey.So(indexes[1].(*SizeGroupedBEIndex).mergedGroups, convey.ShouldNotBeEmpty)
+		convey.So(indexes[0].(*SizeGroupedBEIndex).mergedGroups, convey.ShouldBeEmpty)
+
+		matched, outliers := 0, 0
+		for q := 0; q < 500; q++ {
+			query := map[BEField][]int{}
+			assigns := Assignments{}
+			for _, field := range fields {
+				if rd.Intn(10) > 0 {
+					query[field] = randValues()
+					assigns[field] = NewIntValues(query[field]...)
+				}
+			}
+			expect := DocIDList{}
+			for id, conjs := range docs {
+			CONJ:
+				for _, exprs := range conjs {
+					for _, expr := range exprs {
+						if !allOfExprMatch(expr, query) {
+							continue CONJ
+						}
+					}
+					expect = append(expect, id)
+					if id%25 == 0 {
+						outliers++
+					}
+					break
+				}
+			}
+			matched += len(expect)
+
+			for _, index := range indexes {
+				ids, err := index.Retrieve(assigns)
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(len(ids), convey.ShouldEqual, len(expect))
+				convey.So(ids.Sub(expect), convey.ShouldBeEmpty)
+			}
+		}
+		convey.So(matched, convey.ShouldBeGreaterThan, 0)
+		convey.So(outliers, convey.ShouldBeGreaterThan, 0)
+	})
+
+	convey.Convey("test merged groups reduce group scanning", t, func() {
+		assigns := Assignments{}
+		for _, field := range fields {
+			assigns[field] = NewIntValues(0, 1, 2)
+		}
+		var plain, merged RetrieveTrace
+		ids, err := newBuilder().BuildIndex().Retrieve(assigns, WithRetrieveTrace(&plain))
+		convey.So(err, convey.ShouldBeNil)
+		mergedIDs, err := newBuilder(WithKGroupMerging(100)).BuildIndex().Retrieve(assigns, WithRetrieveTrace(&merged))
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(len(mergedIDs), convey.ShouldEqual, len(ids))
+		convey.So(merged.GroupsScanned, convey.ShouldBeLessThan, plain.GroupsScanned)
+		convey.So(merged.CursorAdvancements, convey.ShouldBeGreaterThan, 0)
+	})
+}
+
+func TestIndexerBuilder_RangeQueryCapability(t *testing.T) {
+	LogLevel = ErrorLevel
+
+	convey.Convey("test range query field require range aware parser", t, func() {
+		doc := NewDocument(1)
+		doc.AddConjunction(NewConjunction().In("age", NewStrValues("18:25")))
+
+		b := NewIndexerBuilder()
+		b.ConfigField("age", FieldOption{Parser: parser.CommonParser, RangeQuery: true})
+		_ = b.AddDocument(doc)
+		_, err := b.BuildIndexE()
+		convey.So(err, convey.ShouldNotBeNil)
+		convey.So(err.Error(), convey.ShouldContainSubstring, "field:age")
+		_, err = b.BuildCompactedIndexE()
+		convey.So(err, convey.ShouldNotBeNil)
+
+		b.ConfigField("age", FieldOption{Parser: "not_registered", RangeQuery: true}) // fallback to common parser
+		_, err = b.BuildIndexE()
+		convey.So(err, convey.ShouldNotBeNil)
+
+		b.ConfigField("age", FieldOption{Parser: parser.NumRangeParser, RangeQuery: true})
+		index, err := b.BuildIndexE()
+		convey.So(err, convey.ShouldBeNil)
+		ids, err := index.Retrieve(Assignments{"age": Values{NewRangeValue(20, 30)}})
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(ids, convey.ShouldResemble, DocIDList{1})
+
+		// index configured directly panic
+		convey.So(func() {
+			NewSizeGroupedBEIndex(parser.NewIDAllocatorImpl()).ConfigureIndexer(&IndexerSettings{
+				FieldConfig: map[BEField]FieldOption{"age": {RangeQuery: true}},
+			})
+		}, convey.ShouldPanic)
+	})
+}
+
+func TestIndexerBuilder_WithSharedPostingLists(t *testing.T) {
+	LogLevel = ErrorLevel
+
+	convey.Convey("test index with shared posting lists retrieve the same result", t, func() {
+		docs, queries := BuildTestDocumentAndQueries(1000, 100, true)
+		b := NewIndexerBuilder()
+		shared := NewIndexerBuilder(WithSharedPostingLists())
+		for _, target := range docs {
+			doc := target.ToDocument()
+			doc.AddConjunction(NewConjunction().In("city", NewStrValues("sh", "bj", "gz")))
+			b.AddDocument(doc)
+			shared.AddDocument(doc)
+		}
+		indexes := []BEIndex{b.BuildIndex(), b.BuildCompactedIndex()}
+		sharedIndexes := []BEIndex{shared.BuildIndex(), shared.BuildCompactedIndex()}
+		queries = append(queries, &Q{A: []int{1}})
+		for i, index := range indexes {
+			convey.So(index.Stats().SharedPostingListCount, convey.ShouldEqual, 0)
+			convey.So(sharedIndexes[i].Stats().SharedPostingListCount, convey.ShouldBeGreaterThanOrEqualTo, 2)
+			convey.So(sharedIndexes[i].Stats().EntryCount, convey.ShouldEqual, index.Stats().EntryCount)
+
+			for _, q := range queries {
+				assigns := q.ToAssigns()
+				assigns["city"] = NewStrValues("bj")
+				expect, err := index.Retrieve(assigns)
+				convey.So(err, convey.ShouldBeNil)
+				result, err := sharedIndexes[i].Retrieve(assigns)
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(len(result), convey.ShouldEqual, len(expect))
+				convey.So(result.Sub(expect), convey.ShouldBeEmpty)
+			}
+		}
+	})
+
+	convey.Convey("test mirror values share one posting list", t, func() {
+		b := NewIndexerBuilder()
+		shared := NewIndexerBuilder(WithSharedPostingLists())
+		interned := NewIndexerBuilder(WithPostingListInterning())
+		for id := 1; id <= 500; id++ {
+			doc := NewDocument(DocID(id))
+			gender := NewStrValues("m", "male")
+			if id%2 == 0 {
+				gender = NewStrValues("f", "female")
+			}
+			doc.AddConjunction(NewConjunction().In("gender", gender).In("age", NewIntValues(id%10)))
+			b.AddDocument(doc)
+			shared.AddDocument(doc)
+			interned.AddDocument(doc)
+		}
+		convey.So(interned.BuildIndex().Stats(), convey.ShouldResemble, shared.BuildIndex().Stats())
+
+		indexes := []BEIndex{b.BuildIndex(), b.BuildCompactedIndex()}
+		sharedIndexes := []BEIndex{shared.BuildIndex(), shared.BuildCompactedIndex()}
+		for i, index := range indexes {
+			stats, sharedStats := index.Stats(), sharedIndexes[i].Stats()
+			convey.So(stats.SharedPostingListCount, convey.ShouldEqual, 0)
+			convey.So(stats.SharedPostingListBytes, convey.ShouldEqual, 0)
+			convey.So(sharedStats.SharedPostingListCount, convey.ShouldEqual, 2) // "male" and "female"
+			convey.So(sharedStats.SharedPostingListBytes, convey.ShouldEqual, 500*entryIDBytes)
+
+			for _, gender := range []string{"m", "male", "f", "female"} {
+				male := gender[0] == 'm'
+				for age := 0; age < 10; age++ {
+					assigns := Assignments{"gender": NewStrValues(gender), "age": NewIntValues(age)}
+					expect, err := index.Retrieve(assigns)
+					convey.So(err, convey.ShouldBeNil)
+					if male == (age%2 == 1) {
+						convey.So(len(expect), convey.ShouldEqual, 50)
+					} else {
+						convey.So(expect, convey.ShouldBeEmpty)
+					}
+					result, err := sharedIndexes[i].Retrieve(assigns)
+					convey.So(err, convey.ShouldBeNil)
+					convey.So(len(result), convey.ShouldEqual, len(expect))
+					convey.So(result.Sub(expect), convey.ShouldBeEmpty)
+				}
+			}
+
+			// shared lists stay shared and untouched by Compact
+			_, err := sharedIndexes[i].Compact()
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(sharedIndexes[i].Stats().SharedPostingListCount, convey.ShouldEqual, 2)
+		}
+	})
+
+	convey.Convey("test lazy compile disabled by shared posting lists", t, func() {
+		b := NewIndexerBuilder(WithSharedPostingLists(), WithLazyCompile())
+		for id := 1; id <= 10; id++ {
+			doc := NewDocument(DocID(id))
+			doc.AddConjunction(NewConjunction().In("tag", NewIntValues(id%2)).In("age", NewIntValues(id%3)))
+			b.AddDocument(doc)
+		}
+		for _, index := range []BEIndex{b.BuildIndex(), b.BuildCompactedIndex()} {
+			stats := index.Stats()
+			convey.So(stats.CompiledGroups, convey.ShouldEqual, stats.PostingGroups)
+			convey.So(b.BuildReport().Warnings, convey.ShouldContain, "lazy compile ignored, shared posting lists compiled when built")
+		}
+	})
+}
+
+// hostileValue values no parser accept, or accept only by luck
+func hostileValue(rd *rand.Rand) interface{} {
+	switch rd.Intn(8) {
+	case 0:
+		return nil
+	case 1:
+		return struct{}{}
+	case 2:
+		return []int{rd.Int()}
+	case 3:
+		return NewRangeValue(rd.Int63(), rd.Int63())
+	case 4:
+		return "1:" + strings.Repeat("9", 1+rd.Intn(2)*24) // overflow int64 sometimes
+	case 5:
+		return rd.Float64() * 1e300
+	case 6:
+		return map[string]int{}
+	default:
+		return rd.Int63()
+	}
+}
+
+func hostileDocument(rd *rand.Rand) *Document {
+	if rd.Intn(20) == 0 {
+		return nil
+	}
+	doc := NewDocument(DocID(rd.Uint32()))
+	for i := rd.Intn(5); i >= 0; i-- {
+		switch rd.Intn(10) {
+		case 0:
+			doc.Cons = append(doc.Cons, nil)
+		case 1:
+			doc.Cons = append(doc.Cons, NewConjunction())
+		case 2:
+			conj := NewConjunction()
+			conj.Expressions["tag"] = nil
+			doc.Cons = append(doc.Cons, conj)
+		default:
+			conj := NewConjunction()
+			for j := rd.Intn(4); j >= 0; j-- {
+				conj.Expressions[BEField(fmt.Sprintf("f%d", rd.Intn(6)))] = &BoolValues{
+					Incl:   rd.Intn(2) == 0,
+					Value:  Values{hostileValue(rd)},
+					All:    rd.Intn(5) == 0,
+					Absent: rd.Intn(10) == 0,
+				}
+			}
+			doc.Cons = append(doc.Cons, conj)
+		}
+	}
+	if rd.Intn(50) == 0 {
+		for len(doc.Cons) < 300 {
+			doc.Cons = append(doc.Cons, NewConjunction().In("tag", NewIntValues(1)))
+		}
+	}
+	return doc
+}
+
+func TestIndexerBuilder_WithNoPanics(t *testing.T) {
+	LogLevel = ErrorLevel
+
+	noPanic := func(fn func()) (panicked bool) {
+		defer func() {
+			if r := recover(); r != nil {
+				panicked = true
+			}
+		}()
+		fn()
+		return false
+	}
+
+	convey.Convey("test hostile documents never panic under WithNoPanics", t, func() {
+		rd := rand.New(rand.NewSource(11))
+		panics := 0
+		for round := 0; round < 50; round++ {
+			b := NewIndexerBuilder(WithNoPanics())
+			b.SetFieldParser("f0", parser.NumRangeParser)
+			b.SetFieldParser("f1", parser.NumRangeParser)
+			b.SetFieldParser("f2", parser.FuzzyMatchParser)
+			for i := 0; i < 20; i++ {
+				if noPanic(func() { _ = b.AddDocument(hostileDocument(rd)) }) {
+					panics++
+				}
+			}
+			if noPanic(func() { _, _ = b.BuildIndexE() }) || noPanic(func() { _, _ = b.BuildCompactedIndexE() }) {
+				panics++
+			}
+		}
+		convey.So(panics, convey.ShouldEqual, 0)
+
+		convey.So(NewIndexerBuilder(WithNoPanics()).AddDocument(nil), convey.ShouldNotBeNil)
+	})
+
+	convey.Convey("test panics of building returned as ErrBuildPanic", t, func() {
+		b := NewIndexerBuilder(WithNoPanics())
+		conj := NewConjunction()
+		for i := 0; i < 300; i++ { // more fields than field id can encode, decoded from json eg
+			conj.Expressions[BEField(fmt.Sprintf("field_%d", i))] = &BoolValues{Incl: true, Value: NewIntValues(1)}
+		}
+		doc := NewDocument(1)
+		doc.Cons = append(doc.Cons, conj)
+		convey.So(b.AddDocument(doc), convey.ShouldNotBeNil) // size exceed 255
+		for _, expr := range conj.Expressions {
+			expr.Incl = false
+		}
+		convey.So(b.AddDocument(doc), convey.ShouldBeNil)
+		_, err := b.BuildIndexE()
+		convey.So(errors.Is(err, ErrTooManyFields), convey.ShouldBeTrue) // a error, not a panic recovered
+
+		b = NewIndexerBuilder(WithNoPanics())
+		b.ConfigField("tag", FieldOption{Parser: parser.CommonParser, ParserParams: map[string]string{"k": "v"}})
+		_, err = b.BuildCompactedIndexE()
+		convey.So(err.Error(), convey.ShouldContainSubstring, "not accept params") // checked before configuring
+		convey.So(errors.Is(err, ErrBuildPanic), convey.ShouldBeFalse)
+
+		b = NewIndexerBuilder(WithNoPanics(), WithTxInterceptor(func(tx *IndexingBETx) error {
+			panic("faulty interceptor")
+		}))
+		doc = NewDocument(1)
+		doc.AddConjunction(NewConjunction().In("tag", NewIntValues(1)))
+		convey.So(b.AddDocument(doc), convey.ShouldBeNil)
+		_, err = b.BuildIndexE()
+		convey.So(errors.Is(err, ErrBuildPanic), convey.ShouldBeTrue)
+		convey.So(b.inCallback, convey.ShouldBeFalse)
+	})
+
+	convey.Convey("test hostile queries never panic under WithNoPanics", t, func() {
+		b := NewIndexerBuilder(WithNoPanics(), WithBadConjBehavior(SkipBadConj))
+		b.SetFieldParser("f0", parser.NumRangeParser)
+		b.SetFieldParser("keyword", "test_panic_on_assign")
+		rd := rand.New(rand.NewSource(13))
+		for i := 0; i < 200; i++ {
+			_ = b.AddDocument(hostileDocument(rd))
+		}
+		doc := NewDocument(1)
+		doc.AddConjunction(NewConjunction().In("keyword", NewStrValues("a")))
+		_ = b.AddDocument(doc)
+
+		for _, index := range []BEIndex{b.BuildIndex(), b.BuildCompactedIndex()} {
+			panics := 0
+			for i := 0; i < 500; i++ {
+				queries := Assignments{}
+				for j := rd.Intn(4); j >= 0; j-- {
+					queries[BEField(fmt.Sprintf("f%d", rd.Intn(8)))] = Values{hostileValue(rd), hostileValue(rd)}
+				}
+				opts := []IndexOpt{WithRawIDAssign("f1", []uint64{rd.Uint64()})}
+				if noPanic(func() { _, _ = index.Retrieve(queries, opts...) }) {
+					panics++
+				}
+			}
+			convey.So(panics, convey.ShouldEqual, 0)
+
+			_, err := index.Retrieve(Assignments{"keyword": Values{1}})
+			convey.So(errors.Is(err, ErrRetrievePanic), convey.ShouldBeTrue)
+		}
+	})
+}
+
+func TestIndexerBuilder_ManyConjunctions(t *testing.T) {
+	LogLevel = ErrorLevel
+
+	convey.Convey("test doc with more than 255 conjunctions", t, func() {
+		b := NewIndexerBuilder()
+		doc := NewDocument(1)
+		for i := 0; i < 300; i++ {
+			doc.AddConjunction(NewConjunction().In("tag", NewIntValues(i)))
+		}
+		doc.AddConjunction(NewConjunction().NotIn("tag", NewIntValues(1000)))
+		convey.So(b.AddDocument(doc), convey.ShouldBeNil)
+
+		other := NewDocument(2)
+		other.AddConjunction(NewConjunction().In("tag", NewIntValues(299)).In("age", NewIntValues(1)))
+		convey.So(b.AddDocument(other), convey.ShouldBeNil)
+
+		for _, index := range []BEIndex{b.BuildIndex(), b.BuildCompactedIndex()} {
+			conjs, ok := index.DocConjunctions(1)
+			convey.So(ok, convey.ShouldBeTrue)
+			convey.So(len(conjs), convey.ShouldEqual, 301)
+			for idx, conj := range conjs {
+				convey.So(conj.Index(), convey.ShouldEqual, idx)
+				convey.So(conj.DocID(), convey.ShouldEqual, 1)
+			}
+			convey.So(conjs[299].Size(), convey.ShouldEqual, 1)
+			convey.So(conjs[300].Size(), convey.ShouldEqual, 0)
+
+			for i := 0; i < 300; i++ {
+				result, err := index.Retrieve(Assignments{"tag": NewIntValues(i), "age": NewIntValues(1)})
+				convey.So(err, convey.ShouldBeNil)
+				if i == 299 {
+					convey.So(len(result), convey.ShouldEqual, 3)
+					convey.So(result.Contain(1) && result.Contain(2), convey.ShouldBeTrue)
+				} else {
+					convey.So(result, convey.ShouldResemble, DocIDList{1, 1}) // the In and the NotIn conjunction
+				}
+			}
+			// only the NotIn conjunction
+			result, err := index.Retrieve(Assignments{"tag": NewIntValues(500)})
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(result, convey.ShouldResemble, DocIDList{1})
+			result, err = index.Retrieve(Assignments{"tag": NewIntValues(1000)})
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(result, convey.ShouldBeEmpty)
+		}
+	})
+
+	convey.Convey("test conjunctions exceed MaxDocConjunctions", t, func() {
+		doc := NewDocument(1)
+		conj := NewConjunction().In("tag", NewIntValues(1))
+		for i := 0; i <= MaxDocConjunctions; i++ {
+			doc.Cons = append(doc.Cons, conj)
+		}
+		convey.So(validDocument(doc, 0), convey.ShouldNotBeNil)
+		doc.Cons = doc.Cons[:MaxDocConjunctions]
+		convey.So(validDocument(doc, 0), convey.ShouldBeNil)
+	})
+
+	convey.Convey("test per builder max conjunctions", t, func() {
+		b := NewIndexerBuilder(WithBadConjBehavior(ErrorBadConj), WithMaxConjunctionsPerDocument(2))
+		doc := NewDocument(1)
+		doc.AddConjunction(NewConjunction().In("tag", NewIntValues(1)), NewConjunction().In("tag", NewIntValues(2)))
+		convey.So(b.AddDocument(doc), convey.ShouldBeNil)
+
+		doc = NewDocument(2)
+		for i := 0; i < 3; i++ {
+			doc.AddConjunction(NewConjunction().In("tag", NewIntValues(i)))
+		}
+		err := b.AddDocument(doc)
+		convey.So(errors.Is(err, ErrTooManyConjunctions), convey.ShouldBeTrue)
+
+		result, err := b.BuildIndex().Retrieve(Assignments{"tag": NewIntValues(0, 1, 2)})
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(result, convey.ShouldResemble, DocIDList{1, 1})
+
+		clamped := NewIndexerBuilder(WithBadConjBehavior(ErrorBadConj), WithMaxConjunctionsPerDocument(MaxDocConjunctions+1))
+		convey.So(clamped.maxConjunctions, convey.ShouldEqual, MaxDocConjunctions)
+		doc = NewDocument(3)
+		for i := 0; i <= MaxDocConjunctions; i++ {
+			doc.AddConjunction(NewConjunction().In("tag", NewIntValues(i)))
+		}
+		convey.So(errors.Is(clamped.AddDocument(doc), ErrTooManyConjunctions), convey.ShouldBeTrue)
+	})
+}
+
+func TestIndexerBuilder_WithStringInterning(t *testing.T) {
+	LogLevel = ErrorLevel
+
+	// the string data pointer, equal for strings sharing memory
+	dataOf := func(v interface{}) uintptr {
+		s := v.(string)
+		return (*reflect.StringHeader)(unsafe.Pointer(&s)).Data
+	}
+
+	b := NewIndexerBuilder(WithStringInterning("country"))
+	for id := 1; id <= 10; id++ {
+		doc := NewDocument(DocID(id))
+		country := strings.Repeat("c", 2) + fmt.Sprint(id%2) // a fresh string every doc
+		doc.AddConjunction(NewConjunction().
+			In("country", NewStrValues(country)).
+			In("browser", NewStrValues(fmt.Sprintf("chrome_%d", id%2))))
+		b.AddDocument(doc)
+	}
+
+	convey.Convey("test identical strings share memory", t, func() {
+		values := func(id DocID, field BEField) interface{} {
+			return b.Documents[id].Cons[0].Expressions[field].Value[0]
+		}
+		convey.So(values(1, "country"), convey.ShouldEqual, "cc1")
+		convey.So(dataOf(values(1, "country")), convey.ShouldEqual, dataOf(values(3, "country")))
+		convey.So(dataOf(values(2, "country")), convey.ShouldEqual, dataOf(values(10, "country")))
+		convey.So(dataOf(values(1, "country")), convey.ShouldNotEqual, dataOf(values(2, "country")))
+
+		// field not interned keep its own strings
+		convey.So(dataOf(values(1, "browser")), convey.ShouldNotEqual, dataOf(values(3, "browser")))
+	})
+
+	convey.Convey("test interned values retrieve as usual", t, func() {
+		for _, index := range []BEIndex{b.BuildIndex(), b.BuildCompactedIndex()} {
+			result, err := index.Retrieve(Assignments{"country": NewStrValues("cc1"), "browser": NewStrValues("chrome_1")})
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(len(result), convey.ShouldEqual, 5)
+		}
+	})
+}
+
+func TestIndexerBuilder_WithFieldValueWhitelist(t *testing.T) {
+	LogLevel = ErrorLevel
+
+	addDocs := func(b *IndexerBuilder) {
+		doc := NewDocument(1)
+		doc.AddConjunction(NewConjunction().In("segment", NewIntValues(1, 2)).In("tag", NewIntValues(9)))
+		_ = b.AddDocument(doc)
+		doc = NewDocument(2)
+		doc.AddConjunction(NewConjunction().In("segment", NewIntValues(2, 3)))   // 3 not approved
+		doc.AddConjunction(NewConjunction().NotIn("segment", NewInt64Values(1))) // int64(1) not approved
+		doc.AddConjunction(NewConjunction().In("tag", NewIntValues(9)))
+		_ = b.AddDocument(doc)
+	}
+
+	convey.Convey("test conjunction with value not approved is a bad conjunction", t, func() {
+		b := NewIndexerBuilder(WithBadConjBehavior(SkipBadConj), WithFieldValueWhitelist("segment", NewIntValues(1, 2)))
+		addDocs(b)
+		for _, index := range []BEIndex{b.BuildIndex(), b.BuildCompactedIndex()} {
+			result, err := index.Retrieve(Assignments{"segment": NewIntValues(2, 3)})
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(result, convey.ShouldBeEmpty)
+
+			result, err = index.Retrieve(Assignments{"segment": NewIntValues(1), "tag": NewIntValues(9)})
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(len(result), convey.ShouldEqual, 2)
+			convey.So(result, convey.ShouldContain, DocID(2))
+		}
+		convey.So(b.BuildReport().SkippedConjunctions, convey.ShouldEqual, 2)
+
+		b = NewIndexerBuilder(WithBadConjBehavior(ErrorBadConj), WithFieldValueWhitelist("segment", NewIntValues(1, 2)))
+		addDocs(b)
+		_, err := b.BuildIndexE()
+		convey.So(err, convey.ShouldNotBeNil)
+
+		b = NewIndexerBuilder(WithBadConjBehavior(ErrorBadConj), WithFieldValueWhitelist("segment", NewIntValues(1, 2, 3)))
+		doc := NewDocument(1)
+		doc.AddConjunction(NewConjunction().In("segment", NewIntValues(3)))
+		_ = b.AddDocument(doc)
+		_, err = b.BuildIndexE()
+		convey.So(err, convey.ShouldBeNil)
+
+		// values not comparable is a build error, never a panic of option
+		approved := Values{3, []int{1}}
+		b = NewIndexerBuilder(WithBadConjBehavior(ErrorBadConj), WithFieldValueWhitelist("segment", approved))
+		_ = b.AddDocument(doc)
+		_, err = b.BuildIndexE()
+		convey.So(err, convey.ShouldNotBeNil)
+		convey.So(err.Error(), convey.ShouldContainSubstring, "not comparable")
+
+		b = NewIndexerBuilder(WithBadConjBehavior(SkipBadConj), WithFieldValueWhitelist("segment", approved))
+		_ = b.AddDocument(doc)
+		result, err := b.BuildIndex().Retrieve(Assignments{"segment": NewIntValues(3)})
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(result, convey.ShouldResemble, DocIDList{1})
+	})
+}
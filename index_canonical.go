@@ -0,0 +1,68 @@
+package be_indexer
+
+import (
+	"fmt"
+	"reflect"
+)
+
+type (
+	/*CanonicalizeFunc normalize a value of field before it's parsed(eg: strip "www." of domains, map
+	country aliases to one code), see FieldOption.Canonicalizer; it's called with every value of
+	documents and queries(parser.RangeValue included, WildcardValue excluded) one by one, values it
+	doesn't care should be returned as they are.
+	*/
+	CanonicalizeFunc func(value interface{}) (interface{}, error)
+)
+
+// canonicalize value by the canonicalizer of field if any, the only way values reach the parser,
+// so a document value and a query value are always normalized by the same function
+func (desc *FieldDesc) canonicalize(value interface{}) (interface{}, error) {
+	if desc.option.Canonicalizer == nil {
+		return value, nil
+	}
+	canonical, err := desc.option.Canonicalizer(value)
+	if err != nil {
+		return nil, fmt.Errorf("canonicalize value:%+v fail, %w", value, err)
+	}
+	return canonical, nil
+}
+
+// parseValue parse a value of document, see canonicalize
+func (desc *FieldDesc) parseValue(value interface{}) ([]uint64, error) {
+	canonical, err := desc.canonicalize(value)
+	if err != nil {
+		return nil, err
+	}
+	return desc.Parser.ParseValue(canonical)
+}
+
+// parseAssign parse a value of query, see canonicalize
+func (desc *FieldDesc) parseAssign(value interface{}) ([]uint64, error) {
+	canonical, err := desc.canonicalize(value)
+	if err != nil {
+		return nil, err
+	}
+	return desc.Parser.ParseAssign(canonical)
+}
+
+/*
+VerifyCanonicalizer check fn with samples for what sharing it by build and query rely on: it's
+deterministic(same result for same value) and idempotent(a canonical value is canonical already,
+so a value normalized by upstream, eg: taken from ExportDictionary, still match); samples fn fail
+on are skipped, a helper for tests of canonicalizers.
+*/
+func VerifyCanonicalizer(fn CanonicalizeFunc, samples Values) error {
+	for _, sample := range samples {
+		canonical, err := fn(sample)
+		if err != nil {
+			continue
+		}
+		if again, err := fn(sample); err != nil || !reflect.DeepEqual(again, canonical) {
+			return fmt.Errorf("canonicalizer not deterministic, value:%+v got:%+v then:%+v(err:%v)", sample, canonical, again, err)
+		}
+		if twice, err := fn(canonical); err != nil || !reflect.DeepEqual(twice, canonical) {
+			return fmt.Errorf("canonicalizer not idempotent, value:%+v canonical:%+v again:%+v(err:%v)", sample, canonical, twice, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package be_indexer
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestFieldOption_Canonicalizer(t *testing.T) {
+	LogLevel = ErrorLevel
+
+	calls := 0
+	domain := func(v interface{}) (interface{}, error) {
+		calls++
+		s, ok := v.(string)
+		if !ok {
+			return nil, errors.New("not a domain")
+		}
+		return strings.TrimPrefix(strings.ToLower(s), "www."), nil
+	}
+
+	convey.Convey("test values of document and query canonicalized alike", t, func() {
+		b := NewIndexerBuilder()
+		b.ConfigField("domain", FieldOption{Canonicalizer: domain})
+		doc := NewDocument(1)
+		doc.AddConjunction(NewConjunction().In("domain", NewStrValues("www.Example.com")))
+		_ = b.AddDocument(doc)
+		doc = NewDocument(2)
+		doc.AddConjunction(NewConjunction().InAll("domain", NewStrValues("a.com", "WWW.b.com")).In("tag", NewIntValues(1)))
+		_ = b.AddDocument(doc)
+		doc = NewDocument(3)
+		doc.AddConjunction(NewConjunction().In("tag", NewIntValues(1)))
+		_ = b.AddDocument(doc)
+
+		for _, build := range []func() BEIndex{b.BuildIndex, b.BuildCompactedIndex} {
+			calls = 0
+			index := build()
+			convey.So(calls, convey.ShouldEqual, 3) // every document value
+
+			result, err := index.Retrieve(Assignments{"domain": NewStrValues("EXAMPLE.com")})
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(result, convey.ShouldResemble, DocIDList{1})
+			convey.So(calls, convey.ShouldEqual, 6) // the field and its 2 InAll sub-fields
+
+			result, err = index.Retrieve(Assignments{"domain": NewStrValues("www.a.com", "b.com"), "tag": NewIntValues(1)})
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(len(result), convey.ShouldEqual, 2)
+			convey.So(result, convey.ShouldContain, DocID(2))
+			convey.So(result, convey.ShouldContain, DocID(3))
+
+			query := Assignments{"domain": NewIntValues(1), "tag": NewIntValues(1)}
+			_, err = index.Retrieve(query)
+			convey.So(err, convey.ShouldNotBeNil)
+
+			result, err = index.Retrieve(query, WithBestEffortRetrieval())
+			convey.So(IsFieldsDropped(err), convey.ShouldBeTrue)
+			convey.So(result, convey.ShouldResemble, DocIDList{3})
+		}
+	})
+
+	convey.Convey("test document value fail to canonicalize is a bad conjunction", t, func() {
+		b := NewIndexerBuilder(WithBadConjBehavior(ErrorBadConj), WithDefaultFieldOption(FieldOption{Canonicalizer: domain}))
+		doc := NewDocument(1)
+		doc.AddConjunction(NewConjunction().In("domain", NewIntValues(1)))
+		_ = b.AddDocument(doc)
+		_, err := b.BuildIndexE()
+		convey.So(err, convey.ShouldNotBeNil)
+	})
+
+	convey.Convey("test verify canonicalizer", t, func() {
+		convey.So(VerifyCanonicalizer(domain, Values{"www.a.com", "WWW.b.com", "c.com", 1}), convey.ShouldBeNil)
+		convey.So(VerifyCanonicalizer(domain, Values{"www.www.b.com"}), convey.ShouldNotBeNil) // strip once only
+
+		suffix := func(v interface{}) (interface{}, error) {
+			return v.(string) + ".", nil
+		}
+		convey.So(VerifyCanonicalizer(suffix, Values{"a.com"}), convey.ShouldNotBeNil)
+	})
+}
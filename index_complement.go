@@ -0,0 +1,51 @@
+package be_indexer
+
+import (
+	"sort"
+)
+
+/*
+WithComplementResults Retrieve return the indexed docs NOT matched instead(eg: the rules an event
+didn't trigger), sorted by DocID before WithResultSort/WithMaxResults applied. the complement is
+taken by walking the docs of index and skipping the matched ones, no complement posting list is
+built; docs are still narrowed by WithLabelFilter. a partial match set(see WithBestEffortRetrieval,
+WithPartialResultsOnBudgetExceed) has no meaningful complement, the error returned with nil result.
+other retrieving apis ignore it.
+*/
+func WithComplementResults() IndexOpt {
+	return func(ctx *RetrieveContext) {
+		ctx.complement = true
+	}
+}
+
+// complementResult the docs of index not in matched, carrying the labels of WithLabelFilter and
+// in the namespace of WithQueryNamespace
+func (bi *indexBase) complementResult(ctx *RetrieveContext, matched DocIDList) DocIDList {
+	hit := make(map[DocID]struct{}, len(matched))
+	for _, id := range matched {
+		hit[id] = struct{}{}
+	}
+	res := make(DocIDList, 0, len(bi.docConjs)-len(hit))
+DOCS:
+	for id := range bi.docConjs {
+		if _, ok := hit[id]; ok || bi.docNamespaces[id] != ctx.namespace {
+			continue
+		}
+		for _, label := range ctx.labelFilter {
+			if _, ok := bi.labelDocs[label][id]; !ok {
+				continue DOCS
+			}
+		}
+		res = append(res, id)
+	}
+	sort.Sort(res)
+	return res
+}
+
+// retrieveResult the DocIDList Retrieve return for the docs collected, see WithComplementResults
+func (bi *indexBase) retrieveResult(ctx *RetrieveContext, collected DocIDList) DocIDList {
+	if ctx.complement {
+		collected = bi.complementResult(ctx, collected)
+	}
+	return ctx.arrangeResult(collected)
+}
@@ -0,0 +1,69 @@
+package be_indexer
+
+import (
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestBEIndex_WithComplementResults(t *testing.T) {
+	LogLevel = ErrorLevel
+
+	b := NewIndexerBuilder()
+	for id := 1; id <= 10; id++ {
+		doc := NewDocument(DocID(id))
+		doc.AddConjunction(NewConjunction().In("tag", NewIntValues(id%3)))
+		if id%2 == 0 {
+			doc.Labels = []string{"even"}
+		}
+		_ = b.AddDocument(doc)
+	}
+	b.ConfigField("weighted", FieldOption{OrderWeight: 1})
+
+	convey.Convey("test retrieve the docs not matched", t, func() {
+		for _, index := range []BEIndex{b.BuildIndex(), b.BuildCompactedIndex()} {
+			query := Assignments{"tag": NewIntValues(1)}
+			matched, err := index.Retrieve(query)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(len(matched), convey.ShouldEqual, 4)
+
+			result, err := index.Retrieve(query, WithComplementResults())
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(result, convey.ShouldResemble, DocIDList{2, 3, 5, 6, 8, 9})
+
+			result, err = index.Retrieve(Assignments{"tag": NewIntValues(1, 2, 0)}, WithComplementResults())
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(result, convey.ShouldBeEmpty)
+
+			result, err = index.Retrieve(Assignments{"tag": NewIntValues(7)}, WithComplementResults())
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(len(result), convey.ShouldEqual, 10)
+
+			result, err = index.Retrieve(query, WithComplementResults(), WithLabelFilter("even"))
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(result, convey.ShouldResemble, DocIDList{2, 6, 8})
+
+			result, err = index.Retrieve(query, WithComplementResults(), WithLabelFilter("odd"))
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(result, convey.ShouldBeEmpty)
+
+			result, err = index.Retrieve(query, WithComplementResults(), WithMaxResults(2),
+				WithResultSort(func(a, b DocID) bool { return a > b }))
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(result, convey.ShouldResemble, DocIDList{9, 8})
+		}
+	})
+
+	convey.Convey("test no complement of partial result", t, func() {
+		for _, index := range []BEIndex{b.BuildIndex(), b.BuildCompactedIndex()} {
+			query := Assignments{"tag": NewIntValues(1, 2)}
+			result, err := index.Retrieve(query, WithMaxCursorAdvancements(1), WithPartialResultsOnBudgetExceed())
+			convey.So(err, convey.ShouldEqual, ErrBudgetExceeded)
+			convey.So(result, convey.ShouldNotBeNil)
+
+			result, err = index.Retrieve(query, WithMaxCursorAdvancements(1), WithPartialResultsOnBudgetExceed(), WithComplementResults())
+			convey.So(err, convey.ShouldEqual, ErrBudgetExceeded)
+			convey.So(result, convey.ShouldBeNil)
+		}
+	})
+}
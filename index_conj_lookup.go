@@ -0,0 +1,62 @@
+package be_indexer
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+)
+
+/*
+Hash fnv64a of the normalized form of conj: expressions sorted by field, values formatted with
+their type(so 1 and "1" differ) and sorted, duplicated values of a In/NotIn expression dropped,
+so conjunctions with the same structure hash the same no matter the order they were built in;
+it's stable across processes, see BEIndex.FindByConjunction
+*/
+func (conj *Conjunction) Hash() uint64 {
+	fields := make([]BEField, 0, len(conj.Expressions))
+	for field := range conj.Expressions {
+		fields = append(fields, field)
+	}
+	sort.Slice(fields, func(i, j int) bool {
+		return fields[i] < fields[j]
+	})
+	h := fnv.New64a()
+	for _, field := range fields {
+		expr := conj.Expressions[field]
+		values := make([]string, 0, len(expr.Value))
+		for _, v := range expr.Value {
+			values = append(values, fmt.Sprintf("%T:%v", v, v))
+		}
+		sort.Strings(values)
+		fmt.Fprintf(h, "%s incl:%t all:%t absent:%t", field, expr.Incl, expr.All, expr.Absent)
+		for i, v := range values {
+			if i > 0 && !expr.All && v == values[i-1] { // InAll count every value in conjunction size
+				continue
+			}
+			fmt.Fprintf(h, " %q", v)
+		}
+		fmt.Fprintln(h)
+	}
+	return h.Sum64()
+}
+
+func (bi *indexBase) appendConjHash(id ConjID, hash uint64) {
+	if bi.conjHashes == nil {
+		bi.conjHashes = make(map[uint64][]ConjID)
+	}
+	bi.conjHashes[hash] = append(bi.conjHashes[hash], id)
+}
+
+// FindByConjunction return docs(sorted) having a indexed conjunction identical to conj in structure,
+// matched by Conjunction.Hash; conjunctions skipped as bad when building are not included
+func (bi *indexBase) FindByConjunction(conj *Conjunction) DocIDList {
+	conjs := bi.conjHashes[conj.Hash()]
+	res := make(DocIDList, 0, len(conjs))
+	for _, id := range conjs {
+		if len(res) == 0 || res[len(res)-1] != id.DocID() {
+			res = append(res, id.DocID())
+		}
+	}
+	sort.Sort(res)
+	return res
+}
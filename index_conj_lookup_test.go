@@ -0,0 +1,54 @@
+package be_indexer
+
+import (
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestConjunction_Hash(t *testing.T) {
+	convey.Convey("test hash of normalized conjunction", t, func() {
+		conj := NewConjunction().In("tag", NewIntValues(1, 2)).NotIn("city", NewStrValues("bj"))
+		same := NewConjunction().NotIn("city", NewStrValues("bj")).In("tag", NewIntValues(2, 1, 2))
+		convey.So(same.Hash(), convey.ShouldEqual, conj.Hash())
+
+		convey.So(NewConjunction().In("tag", NewStrValues("1", "2")).NotIn("city", NewStrValues("bj")).Hash(),
+			convey.ShouldNotEqual, conj.Hash())
+		convey.So(NewConjunction().In("tag", NewIntValues(1, 2)).In("city", NewStrValues("bj")).Hash(),
+			convey.ShouldNotEqual, conj.Hash())
+		convey.So(NewConjunction().InAll("tag", NewIntValues(1, 2)).NotIn("city", NewStrValues("bj")).Hash(),
+			convey.ShouldNotEqual, conj.Hash())
+		convey.So(NewConjunction().InAll("tag", NewIntValues(1, 1)).Hash(),
+			convey.ShouldNotEqual, NewConjunction().InAll("tag", NewIntValues(1)).Hash())
+	})
+}
+
+func TestBEIndex_FindByConjunction(t *testing.T) {
+	LogLevel = ErrorLevel
+
+	convey.Convey("test find docs by conjunction", t, func() {
+		b := NewIndexerBuilder()
+		for id := 1; id <= 6; id++ {
+			doc := NewDocument(DocID(id))
+			doc.AddConjunction(NewConjunction().In("tag", NewIntValues(id)))
+			if id%2 == 0 {
+				doc.AddConjunction(NewConjunction().In("city", NewStrValues("sh", "bj")).NotIn("age", NewIntValues(18)))
+			}
+			if id == 4 { // identical conjunctions in one doc
+				doc.AddConjunction(NewConjunction().NotIn("age", NewIntValues(18)).In("city", NewStrValues("bj", "sh")))
+			}
+			_ = b.AddDocument(doc)
+		}
+
+		for _, index := range []BEIndex{b.BuildIndex(), b.BuildCompactedIndex()} {
+			conj := NewConjunction().NotIn("age", NewIntValues(18)).In("city", NewStrValues("bj", "sh"))
+			convey.So(index.FindByConjunction(conj), convey.ShouldResemble, DocIDList{2, 4, 6})
+
+			convey.So(index.FindByConjunction(NewConjunction().In("tag", NewIntValues(3))), convey.ShouldResemble, DocIDList{3})
+			convey.So(index.FindByConjunction(NewConjunction().In("city", NewStrValues("sh", "bj"))), convey.ShouldBeEmpty)
+
+			handle := NewAtomicIndexHandle(index)
+			convey.So(handle.FindByConjunction(conj), convey.ShouldResemble, DocIDList{2, 4, 6})
+		}
+	})
+}
@@ -0,0 +1,79 @@
+package be_indexer
+
+import (
+	"fmt"
+)
+
+type (
+	// QKey a field/value pair assigned by query, Value is the query value formatted by fmt.Sprint(bitset
+	// and raw id assigns report the value id), see WithValueContribution
+	QKey struct {
+		Field BEField
+		Value string
+	}
+
+	// qkeyDoc a doc attributed to a query value, keep a doc counted once for every value
+	qkeyDoc struct {
+		qkey QKey
+		doc  DocID
+	}
+)
+
+/*
+WithValueContribution fill contribution with the count of matched docs attributed to every query
+value, for query tuning(eg: which values of a huge IN list lead to most matches). a matched doc is
+attributed to the values whose cursors sit on the matched conjunction, so a doc matched by a
+conjunction of K In expressions count to one value(at least) of each of the K fields; docs matched by
+wildcard(size-0) conjunctions only count to no value, synthetic sub-fields(InAll) count to the field.
+*/
+func WithValueContribution(contribution map[QKey]int) IndexOpt {
+	return func(ctx *RetrieveContext) {
+		ctx.valueContribution = contribution
+		ctx.keyQValues = make(map[Key][]QKey)
+		ctx.contributed = make(map[qkeyDoc]struct{})
+	}
+}
+
+// recordQValue remember the query value the posting list keys of ids parsed from, no-op if not needed
+func (ctx *RetrieveContext) recordQValue(desc *FieldDesc, field BEField, value interface{}, ids []uint64) {
+	if ctx.valueContribution == nil {
+		return
+	}
+	qkey := QKey{Field: field, Value: fmt.Sprint(value)}
+	for _, id := range ids {
+		key := NewKey(desc.ID, id)
+		ctx.keyQValues[key] = append(ctx.keyQValues[key], qkey)
+	}
+}
+
+// attributeValues attribute doc matched by conj to the query values of matched cursors
+func (ctx *RetrieveContext) attributeValues(id DocID, conj ConjID, matched FieldScanners) {
+	ctx.contributeValues(id, ctx.matchedQValues(conj, matched))
+}
+
+// matchedQValues the query values of matched cursors sitting on conj, nil if not needed
+func (ctx *RetrieveContext) matchedQValues(conj ConjID, matched FieldScanners) (qkeys []QKey) {
+	if ctx.valueContribution == nil {
+		return nil
+	}
+	for _, scanner := range matched {
+		for _, cursor := range scanner.cursorGroup {
+			if cursor.GetCurEntryID().GetConjID() == conj {
+				qkeys = append(qkeys, ctx.keyQValues[cursor.key]...)
+			}
+		}
+	}
+	return qkeys
+}
+
+// contributeValues attribute doc to qkeys, a doc counted once for every value
+func (ctx *RetrieveContext) contributeValues(id DocID, qkeys []QKey) {
+	for _, qkey := range qkeys {
+		seen := qkeyDoc{qkey: qkey, doc: id}
+		if _, ok := ctx.contributed[seen]; ok {
+			continue
+		}
+		ctx.contributed[seen] = struct{}{}
+		ctx.valueContribution[qkey]++
+	}
+}
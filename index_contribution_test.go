@@ -0,0 +1,65 @@
+package be_indexer
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestWithValueContribution(t *testing.T) {
+	LogLevel = ErrorLevel
+
+	b := NewIndexerBuilder()
+	for id := 1; id <= 200; id++ {
+		doc := NewDocument(DocID(id))
+		doc.AddConjunction(NewConjunction().
+			In("tag", NewIntValues(id%5)).
+			In("city", NewStrValues(fmt.Sprintf("c%d", id%3))))
+		if id%10 == 0 {
+			doc.AddConjunction(NewConjunction().In("os", NewIntValues(1)))
+		}
+		b.AddDocument(doc)
+	}
+	queries := Assignments{
+		"tag":  NewIntValues(0, 1, 2, 3, 4, 9),
+		"city": NewStrValues("c0", "c1"),
+	}
+
+	convey.Convey("test value contribution sum consistently with matches", t, func() {
+		for _, index := range []BEIndex{b.BuildIndex(), b.BuildCompactedIndex()} {
+			contribution := map[QKey]int{}
+			result, err := index.Retrieve(queries, WithValueContribution(contribution))
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(len(result), convey.ShouldBeGreaterThan, 0)
+
+			// every matched doc attributed to exactly one value of each field
+			sums := map[BEField]int{}
+			for qkey, cnt := range contribution {
+				sums[qkey.Field] += cnt
+			}
+			convey.So(sums, convey.ShouldResemble, map[BEField]int{"tag": len(result), "city": len(result)})
+
+			for v := 0; v < 5; v++ {
+				expect := 0
+				for _, id := range result {
+					if int(id)%5 == v {
+						expect++
+					}
+				}
+				convey.So(contribution[QKey{Field: "tag", Value: fmt.Sprint(v)}], convey.ShouldEqual, expect)
+			}
+			convey.So(contribution, convey.ShouldNotContainKey, QKey{Field: "tag", Value: "9"})
+
+			// doc matched by two conjunctions counted once for a value
+			contribution = map[QKey]int{}
+			result, err = index.Retrieve(Assignments{"tag": NewIntValues(0), "city": NewStrValues("c2"), "os": NewIntValues(1)},
+				WithValueContribution(contribution))
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(len(distinctDocs(result)), convey.ShouldEqual, 27) // id%15==5(14 docs) or id%10==0(20 docs), 7 docs both
+			convey.So(contribution[QKey{Field: "os", Value: "1"}], convey.ShouldEqual, 20)
+			convey.So(contribution[QKey{Field: "tag", Value: "0"}], convey.ShouldEqual, 14)
+			convey.So(contribution[QKey{Field: "city", Value: "c2"}], convey.ShouldEqual, 14)
+		}
+	})
+}
@@ -0,0 +1,75 @@
+package be_indexer
+
+import (
+	"math/bits"
+
+	"github.com/echoface/be_indexer/util"
+)
+
+type (
+	/*CostEstimate a dry-run estimate of retrieving cost, see BEIndex.EstimateCost
+	Entries/Cursors: the posting entries/cursors a retrieving would create, equal to
+	RetrieveTrace.EntriesCursored/CursorsCreated of a actual run(without two pass and label filter)
+	MaxListLen: length of the largest single posting list
+	ScanCost: rough predicted scan cost, entries weighted by log2 of cursors merged, a cursor can
+	advance at most len(list) times and every advancement pay for keeping cursors ordered
+	*/
+	CostEstimate struct {
+		Entries    int   `json:"entries"`
+		Cursors    int   `json:"cursors"`
+		MaxListLen int   `json:"max_list_len"`
+		ScanCost   int64 `json:"scan_cost"`
+	}
+)
+
+func (est *CostEstimate) addList(entries Entries) {
+	if len(entries) == 0 {
+		return
+	}
+	est.Cursors++
+	est.Entries += len(entries)
+	if len(entries) > est.MaxListLen {
+		est.MaxListLen = len(entries)
+	}
+}
+
+func (est *CostEstimate) addLists(bi *indexBase, idAssigns map[BEField][]uint64, pl *PostingEntries) {
+	for field, ids := range idAssigns {
+		desc := bi.fieldDesc[field]
+		for _, id := range ids {
+			est.addList(pl.getEntries(NewKey(desc.ID, id)))
+		}
+	}
+}
+
+func (est *CostEstimate) predictScanCost() {
+	est.ScanCost = int64(est.Entries) * int64(bits.Len(uint(est.Cursors)))
+}
+
+// EstimateCost estimate the cost of retrieving queries by posting list lengths, nothing scanned
+func (bi *SizeGroupedBEIndex) EstimateCost(queries Assignments) (est CostEstimate, err error) {
+	idAssigns, err := bi.parseQueries(newRetrieveContext(), queries, bi.sizeEntries...)
+	if err != nil {
+		return est, err
+	}
+	for k := util.MinInt(len(idAssigns), bi.maxK()); k >= 0; k-- {
+		if k == 0 {
+			est.addList(bi.wildcardEntries.load())
+		}
+		est.addLists(&bi.indexBase, idAssigns, bi.getKSizeEntries(k))
+	}
+	est.predictScanCost()
+	return est, nil
+}
+
+// EstimateCost estimate the cost of retrieving queries by posting list lengths, nothing scanned
+func (bi *CompactedBEIndex) EstimateCost(queries Assignments) (est CostEstimate, err error) {
+	idAssigns, err := bi.parseQueries(newRetrieveContext(), queries, bi.postingList)
+	if err != nil {
+		return est, err
+	}
+	est.addList(bi.wildcardEntries.load())
+	est.addLists(&bi.indexBase, idAssigns, bi.postingList)
+	est.predictScanCost()
+	return est, nil
+}
@@ -0,0 +1,71 @@
+package be_indexer
+
+import (
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestBEIndex_EstimateCost(t *testing.T) {
+	LogLevel = ErrorLevel
+
+	b := NewIndexerBuilder()
+	for id := DocID(1); id <= 100; id++ {
+		doc := NewDocument(id)
+		doc.AddConjunction(NewConjunction().In("tag", NewIntValues(int(id%5))))
+		doc.AddConjunction(NewConjunction().
+			In("tag", NewIntValues(int(id%7))).
+			In("city", NewStrValues("sh", "bj")))
+		if id%3 == 0 {
+			doc.AddConjunction(NewConjunction().NotIn("city", NewStrValues("gz")))
+		}
+		if id%10 == 0 {
+			doc.AddConjunction(NewConjunction().In("age", NewIntValues(int(id))))
+		}
+		b.AddDocument(doc)
+	}
+
+	convey.Convey("test estimate cost equal to an actual run", t, func() {
+		queries := []Assignments{
+			{"tag": NewIntValues(1, 2, 3)},
+			{"tag": NewIntValues(0, 1, 2, 3, 4, 5, 6), "city": NewStrValues("sh", "gz")},
+			{"city": NewStrValues("sh", "bj", "gz"), "age": NewIntValues(10, 20, 30)},
+			{"unknown": NewStrValues("x")},
+			{},
+		}
+		for _, index := range []BEIndex{b.BuildIndex(), b.BuildCompactedIndex()} {
+			for _, query := range queries {
+				est, err := index.EstimateCost(query)
+				convey.So(err, convey.ShouldBeNil)
+
+				trace := &RetrieveTrace{}
+				_, err = index.Retrieve(query, WithRetrieveTrace(trace))
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(est.Entries, convey.ShouldEqual, trace.EntriesCursored)
+				convey.So(est.Cursors, convey.ShouldEqual, trace.CursorsCreated)
+				convey.So(est.MaxListLen, convey.ShouldBeLessThanOrEqualTo, est.Entries)
+				convey.So(est.ScanCost, convey.ShouldBeGreaterThanOrEqualTo, int64(est.Entries))
+			}
+		}
+	})
+
+	convey.Convey("test estimate cost grow with value list", t, func() {
+		for _, index := range []BEIndex{b.BuildIndex(), b.BuildCompactedIndex()} {
+			small, _ := index.EstimateCost(Assignments{"tag": NewIntValues(1)})
+			large, _ := index.EstimateCost(Assignments{"tag": NewIntValues(0, 1, 2, 3, 4, 5, 6)})
+			convey.So(large.Entries, convey.ShouldBeGreaterThan, small.Entries)
+			convey.So(large.Cursors, convey.ShouldBeGreaterThan, small.Cursors)
+			convey.So(large.ScanCost, convey.ShouldBeGreaterThan, small.ScanCost)
+
+			// wildcard list hold 33 docs with a NotIn only conjunction
+			convey.So(small.MaxListLen, convey.ShouldBeGreaterThanOrEqualTo, 33)
+		}
+	})
+
+	convey.Convey("test estimate cost of invalid query", t, func() {
+		for _, index := range []BEIndex{b.BuildIndex(), b.BuildCompactedIndex()} {
+			_, err := index.EstimateCost(Assignments{"tag": Values{struct{}{}}})
+			convey.So(err, convey.ShouldNotBeNil)
+		}
+	})
+}
@@ -0,0 +1,158 @@
+package be_indexer
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+const (
+	defaultDiagramWidth  = 80
+	defaultDiagramHeight = 6
+	diagramAxisWidth     = 8 // "  12345|"
+	diagramBar           = "█"
+)
+
+type (
+	diagramOption struct {
+		width  int
+		height int
+	}
+
+	// DiagramOpt option of SizeGroupedBEIndex.DumpKGroupDiagram
+	DiagramOpt func(opt *diagramOption)
+
+	diagramColumn struct {
+		field   BEField
+		entries int
+	}
+)
+
+// WithDiagramWidth limit diagram lines within w columns(terminal width), default 80
+func WithDiagramWidth(w int) DiagramOpt {
+	return func(opt *diagramOption) {
+		opt.width = w
+	}
+}
+
+// DumpKGroupDiagram write a ascii diagram of the K groups: each K group a horizontal band, each field
+// a column whose bar height show the total posting list length of the field in the group(scaled to
+// the longest column of the band), fields don't fit in the width are summarized at the band end, eg:
+//
+//	K:2 lists:8 entries:10 >>>>>>
+//	      5| █   █
+//	       | █   █
+//	       +--------
+//	         age tag
+func (bi *SizeGroupedBEIndex) DumpKGroupDiagram(w io.Writer, opts ...DiagramOpt) error {
+	opt := &diagramOption{width: defaultDiagramWidth, height: defaultDiagramHeight}
+	for _, fn := range opts {
+		fn(opt)
+	}
+	if opt.width < diagramAxisWidth+2 {
+		return fmt.Errorf("diagram width:%d too small, min:%d", opt.width, diagramAxisWidth+2)
+	}
+
+	compileGroups(bi.sizeEntries...)
+	sb := &strings.Builder{}
+	sb.WriteString(fitWidth(fmt.Sprintf("Z: wildcard entries:%d", len(bi.wildcardEntries.load())), opt.width))
+	for k, kse := range bi.sizeEntries {
+		bi.writeKGroupBand(sb, k, kse, opt)
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// fitWidth truncate ascii line to width and end it with newline
+func fitWidth(line string, width int) string {
+	if len(line) > width {
+		line = line[:width]
+	}
+	return line + "\n"
+}
+
+func summaryWidth(hidden int) int {
+	if hidden == 0 {
+		return 0
+	}
+	return len(fmt.Sprintf(" +%d", hidden))
+}
+
+func (bi *SizeGroupedBEIndex) writeKGroupBand(sb *strings.Builder, k int, kse *PostingEntries, opt *diagramOption) {
+	fieldEntries := make(map[uint64]int)
+	total := 0
+	kse.eachList(func(key Key, entries Entries) {
+		fieldEntries[key.GetFieldID()] += len(entries)
+		total += len(entries)
+	})
+	sb.WriteString(fitWidth(fmt.Sprintf("K:%d lists:%d entries:%d >>>>>>", k, kse.keyCount(), total), opt.width))
+	if len(fieldEntries) == 0 {
+		return
+	}
+
+	columns := make([]diagramColumn, 0, len(fieldEntries))
+	for fieldID, cnt := range fieldEntries {
+		columns = append(columns, diagramColumn{field: bi.getFieldFromID(fieldID), entries: cnt})
+	}
+	sort.Slice(columns, func(i, j int) bool {
+		if columns[i].entries != columns[j].entries {
+			return columns[i].entries > columns[j].entries
+		}
+		return columns[i].field < columns[j].field
+	})
+
+	// column width: longest field name + 1 space, shrink when not fit
+	available := opt.width - diagramAxisWidth
+	colWidth := 2
+	for _, col := range columns {
+		if l := len(col.field) + 1; l > colWidth {
+			colWidth = l
+		}
+	}
+	if colWidth*len(columns) > available {
+		colWidth = available / len(columns)
+		if colWidth < 2 {
+			colWidth = 2
+		}
+	}
+	shown := len(columns)
+	for shown > 1 && shown*colWidth+summaryWidth(len(columns)-shown) > available {
+		shown-- // leave room for the "+N" summary of hidden columns
+	}
+
+	maxEntries := columns[0].entries
+	for row := opt.height; row >= 1; row-- {
+		if row == opt.height {
+			sb.WriteString(fmt.Sprintf("%*d|", diagramAxisWidth-1, maxEntries))
+		} else {
+			sb.WriteString(fmt.Sprintf("%*s|", diagramAxisWidth-1, ""))
+		}
+		line := &strings.Builder{}
+		for _, col := range columns[:shown] {
+			// bar height ceil(entries/maxEntries*height), a non-empty column at least 1
+			height := (col.entries*opt.height + maxEntries - 1) / maxEntries
+			cell := strings.Repeat(" ", colWidth)
+			if height >= row {
+				cell = " " + diagramBar + strings.Repeat(" ", colWidth-2)
+			}
+			line.WriteString(cell)
+		}
+		sb.WriteString(strings.TrimRight(line.String(), " "))
+		sb.WriteString("\n")
+	}
+
+	sb.WriteString(fmt.Sprintf("%*s+%s\n", diagramAxisWidth-1, "", strings.Repeat("-", shown*colWidth)))
+	labels := &strings.Builder{}
+	for _, col := range columns[:shown] {
+		name := string(col.field)
+		if len(name) > colWidth-1 {
+			name = name[:colWidth-1]
+		}
+		labels.WriteString(" " + name + strings.Repeat(" ", colWidth-1-len(name)))
+	}
+	if shown < len(columns) {
+		labels.WriteString(fmt.Sprintf(" +%d", len(columns)-shown))
+	}
+	sb.WriteString(fmt.Sprintf("%*s%s\n", diagramAxisWidth, "", strings.TrimRight(labels.String(), " ")))
+}
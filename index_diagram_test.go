@@ -0,0 +1,66 @@
+package be_indexer
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestSizeGroupedBEIndex_DumpKGroupDiagram(t *testing.T) {
+	LogLevel = ErrorLevel
+
+	convey.Convey("test k group diagram", t, func() {
+		builder := NewIndexerBuilder()
+		for i := 1; i <= 20; i++ {
+			doc := NewDocument(DocID(i))
+			conj := NewConjunction().In("tag", NewIntValues(i%3))
+			if i%4 == 0 {
+				conj.In("age", NewIntValues(i))
+			}
+			doc.AddConjunction(conj)
+			if i%5 == 0 {
+				doc.AddConjunction(NewConjunction().NotIn("city", NewIntValues(1)))
+			}
+			builder.AddDocument(doc)
+		}
+		index := builder.BuildIndex().(*SizeGroupedBEIndex)
+
+		sb := &strings.Builder{}
+		convey.So(index.DumpKGroupDiagram(sb), convey.ShouldBeNil)
+		diagram := sb.String()
+		convey.So(diagram, convey.ShouldContainSubstring, "Z: wildcard entries:4\n")
+		convey.So(diagram, convey.ShouldContainSubstring, "K:0 lists:1 entries:4 >>>>>>\n")
+		convey.So(diagram, convey.ShouldContainSubstring, "K:1 lists:3 entries:15 >>>>>>\n")
+		convey.So(diagram, convey.ShouldContainSubstring, "K:2 lists:8 entries:10 >>>>>>\n")
+		// K:2 tag and age both have 5 entries, same height
+		convey.So(diagram, convey.ShouldContainSubstring, "      5| █   █\n")
+		convey.So(diagram, convey.ShouldContainSubstring, "        age tag\n")
+		for _, line := range strings.Split(diagram, "\n") {
+			convey.So(utf8.RuneCountInString(line), convey.ShouldBeLessThanOrEqualTo, 80)
+		}
+
+		convey.So(index.DumpKGroupDiagram(sb, WithDiagramWidth(5)), convey.ShouldNotBeNil)
+	})
+
+	convey.Convey("test k group diagram fit in width", t, func() {
+		builder := NewIndexerBuilder()
+		for i := 1; i <= 30; i++ {
+			doc := NewDocument(DocID(i))
+			doc.AddConjunction(NewConjunction().In(BEField(fmt.Sprintf("long_field_name_%d", i)), NewIntValues(i)))
+			builder.AddDocument(doc)
+		}
+		index := builder.BuildIndex().(*SizeGroupedBEIndex)
+
+		for _, width := range []int{20, 40, 80} {
+			sb := &strings.Builder{}
+			convey.So(index.DumpKGroupDiagram(sb, WithDiagramWidth(width)), convey.ShouldBeNil)
+			for _, line := range strings.Split(sb.String(), "\n") {
+				convey.So(utf8.RuneCountInString(line), convey.ShouldBeLessThanOrEqualTo, width)
+			}
+			convey.So(sb.String(), convey.ShouldContainSubstring, " +")
+		}
+	})
+}
@@ -0,0 +1,136 @@
+package be_indexer
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/echoface/be_indexer/parser"
+)
+
+// exportDictionary write value->id dictionary of field(see parser.WriteDictionary for the format),
+// only values indexed in postings are exported
+func (bi *indexBase) exportDictionary(field BEField, w io.Writer, postings ...*PostingEntries) error {
+	desc, ok := bi.fieldDesc[field]
+	if !ok || field == wildcardField {
+		return fmt.Errorf("field:%s not configured", field)
+	}
+	header := parser.DictionaryHeader{
+		Field:  string(field),
+		Parser: desc.ParserName,
+	}
+
+	if tokenizer, ok := desc.Parser.(parser.HashTokenizer); ok {
+		header.Params = tokenizer.HashParams()
+		return parser.WriteDictionary(w, header, bi.idAllocator, false, func(uint64) bool {
+			return false
+		})
+	}
+
+	valueIDs := make(map[uint64]struct{})
+	for _, pl := range postings {
+		pl.eachList(func(key Key, _ Entries) {
+			if key.GetFieldID() == desc.ID {
+				valueIDs[key.GetValueID()] = struct{}{}
+			}
+		})
+	}
+	_, numeric := desc.Parser.(*parser.NumberRangeParser)
+	return parser.WriteDictionary(w, header, bi.idAllocator, numeric, func(id uint64) bool {
+		_, hit := valueIDs[id]
+		return hit
+	})
+}
+
+func (bi *SizeGroupedBEIndex) ExportDictionary(field BEField, w io.Writer) error {
+	compileGroups(bi.sizeEntries...)
+	return bi.exportDictionary(field, w, bi.sizeEntries...)
+}
+
+func (bi *CompactedBEIndex) ExportDictionary(field BEField, w io.Writer) error {
+	bi.postingList.compile()
+	return bi.exportDictionary(field, w, bi.postingList)
+}
+
+/*
+WithValueReverseLookup keep the value->id maps reversed(see parser.NewReversibleIDAllocator), so
+values of index can be decoded from their ids: it's required by ExportInverted and Manifest(see
+BuildIndexWithManifest), and fill the decoded value of DumpField; it cost a map entry more per
+distinct value, the serving path never decode.
+*/
+func WithValueReverseLookup() BuilderOpt {
+	return func(builder *IndexerBuilder) {
+		builder.settings.ValueReverseLookup = true
+	}
+}
+
+func (bi *indexBase) valueReverseLookup() bool {
+	return bi.reverseLookup
+}
+
+// exportInverted decode the include posting lists of field in postings into value->docs mapping
+func (bi *indexBase) exportInverted(field BEField, postings ...*PostingEntries) (map[string]DocIDList, error) {
+	desc, ok := bi.fieldDesc[field]
+	if !ok || field == wildcardField {
+		return nil, fmt.Errorf("field:%s not configured", field)
+	}
+	if !bi.reverseLookup {
+		return nil, fmt.Errorf("field:%s values can't be decoded, index built without WithValueReverseLookup", field)
+	}
+	reversible, ok := desc.Parser.(parser.Reversible)
+	if !ok {
+		return nil, fmt.Errorf("field:%s parser:%s not support reverse lookup", field, desc.ParserName)
+	}
+
+	var err error
+	docSets := make(map[string]map[DocID]struct{})
+	for _, pl := range postings {
+		pl.eachList(func(key Key, entries Entries) {
+			if err != nil || key.GetFieldID() != desc.ID {
+				return
+			}
+			value, found := reversible.ValueOf(key.GetValueID())
+			if !found {
+				err = fmt.Errorf("field:%s value id:%d can't be reversed", field, key.GetValueID())
+				return
+			}
+			docs, ok := docSets[value]
+			if !ok {
+				docs = make(map[DocID]struct{})
+				docSets[value] = docs
+			}
+			for _, eid := range entries {
+				if eid.IsInclude() {
+					docs[eid.GetConjID().DocID()] = struct{}{}
+				}
+			}
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]DocIDList, len(docSets))
+	for value, docs := range docSets {
+		if len(docs) == 0 { // only excluded
+			continue
+		}
+		list := make(DocIDList, 0, len(docs))
+		for doc := range docs {
+			list = append(list, doc)
+		}
+		sort.Sort(list)
+		result[value] = list
+	}
+	return result, nil
+}
+
+func (bi *SizeGroupedBEIndex) ExportInverted(field BEField) (map[string]DocIDList, error) {
+	compileGroups(bi.sizeEntries...)
+	return bi.exportInverted(field, bi.sizeEntries...)
+}
+
+func (bi *CompactedBEIndex) ExportInverted(field BEField) (map[string]DocIDList, error) {
+	bi.postingList.compile()
+	return bi.exportInverted(field, bi.postingList)
+}
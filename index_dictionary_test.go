@@ -0,0 +1,108 @@
+package be_indexer
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/echoface/be_indexer/parser"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestBEIndex_ExportDictionary(t *testing.T) {
+	LogLevel = ErrorLevel
+
+	convey.Convey("test query tokenized by exported dictionary", t, func() {
+		builder := NewIndexerBuilder()
+		builder.SetFieldParser("age", parser.NumRangeParser)
+		builder.ConfigField("embedding", FieldOption{
+			Parser:       parser.VectorSimParser,
+			ParserParams: map[string]string{"threshold": "0.8"},
+		})
+
+		doc := NewDocument(1)
+		doc.AddConjunction(NewConjunction().In("tag", NewStrValues("a", "b")).In("age", NewStrValues("10:12")))
+		_ = builder.AddDocument(doc)
+		doc = NewDocument(2)
+		doc.AddConjunction(NewConjunction().In("tag", NewStrValues("c")).In("embedding", Values{[]float32{1, 0}}))
+		_ = builder.AddDocument(doc)
+
+		for _, index := range []BEIndex{builder.BuildIndex(), builder.BuildCompactedIndex()} {
+			tagDict, ageDict := &bytes.Buffer{}, &bytes.Buffer{}
+			convey.So(index.ExportDictionary("tag", tagDict), convey.ShouldBeNil)
+			convey.So(index.ExportDictionary("age", ageDict), convey.ShouldBeNil)
+			convey.So(index.ExportDictionary("unknown", &bytes.Buffer{}), convey.ShouldNotBeNil)
+
+			tagAlloc, err := parser.LoadDictionary(tagDict)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(tagAlloc.TotalIDCount(), convey.ShouldEqual, 3)
+			ageAlloc, err := parser.LoadDictionary(ageDict)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(ageAlloc.TotalIDCount(), convey.ShouldEqual, 3)
+
+			expect, err := index.Retrieve(Assignments{"tag": NewStrValues("b"), "age": NewIntValues(11)})
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(expect, convey.ShouldResemble, DocIDList{1})
+
+			tag := "b"
+			tagID, _ := tagAlloc.FindStringID(&tag)
+			ageID, _ := ageAlloc.FindNumID(11)
+			ids, err := index.Retrieve(nil, WithRawIDAssign("tag", []uint64{tagID}), WithRawIDAssign("age", []uint64{ageID}))
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(ids, convey.ShouldResemble, expect)
+
+			vectorDict := &bytes.Buffer{}
+			convey.So(index.ExportDictionary("embedding", vectorDict), convey.ShouldBeNil)
+			header := parser.DictionaryHeader{}
+			convey.So(json.NewDecoder(vectorDict).Decode(&header), convey.ShouldBeNil)
+			convey.So(header.Parser, convey.ShouldEqual, parser.VectorSimParser)
+			convey.So(header.Params, convey.ShouldResemble, map[string]string{"threshold": "0.8", "bands": "8", "seed": "1"})
+		}
+	})
+}
+
+func TestBEIndex_ExportInverted(t *testing.T) {
+	LogLevel = ErrorLevel
+
+	convey.Convey("test export inverted lists of field", t, func() {
+		builder := NewIndexerBuilder(WithValueReverseLookup())
+		builder.SetFieldParser("age", parser.NumRangeParser)
+
+		doc := NewDocument(1)
+		doc.AddConjunction(NewConjunction().In("tag", NewStrValues("a", "b")).In("age", NewStrValues("10:12")))
+		doc.AddConjunction(NewConjunction().In("tag", NewStrValues("c")))
+		_ = builder.AddDocument(doc)
+		doc = NewDocument(2)
+		doc.AddConjunction(NewConjunction().In("tag", NewStrValues("b")).NotIn("age", NewStrValues("11:12")))
+		_ = builder.AddDocument(doc)
+		doc = NewDocument(3)
+		doc.AddConjunction(NewConjunction().NotIn("tag", NewStrValues("d")))
+		_ = builder.AddDocument(doc)
+
+		for _, index := range []BEIndex{builder.BuildIndex(), builder.BuildCompactedIndex()} {
+			inverted, err := index.ExportInverted("tag")
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(inverted, convey.ShouldResemble, map[string]DocIDList{
+				"a": {1},
+				"b": {1, 2},
+				"c": {1},
+			})
+
+			inverted, err = index.ExportInverted("age")
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(inverted, convey.ShouldResemble, map[string]DocIDList{
+				"10": {1},
+				"11": {1},
+				"12": {1},
+			})
+
+			_, err = index.ExportInverted("unknown")
+			convey.So(err, convey.ShouldNotBeNil)
+		}
+
+		plain := NewIndexerBuilder()
+		_ = plain.AddDocument(doc)
+		_, err := plain.BuildIndex().ExportInverted("tag")
+		convey.So(err.Error(), convey.ShouldContainSubstring, "WithValueReverseLookup")
+	})
+}
@@ -0,0 +1,238 @@
+package be_indexer
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/echoface/be_indexer/parser"
+)
+
+type (
+	// PostingListDump the posting list of a key, entries of all K merged and sorted
+	PostingListDump struct {
+		Key     Key       `json:"key"`
+		Value   string    `json:"value,omitempty"` // decoded value, see parser.Reversible and WithValueReverseLookup
+		Entries []EntryID `json:"entries"`
+	}
+
+	// FieldDumpPage a page of DumpField, NextToken is empty when the last page reached
+	FieldDumpPage struct {
+		Field     BEField           `json:"field"`
+		Lists     []PostingListDump `json:"lists"`
+		NextToken string            `json:"next_token,omitempty"`
+	}
+
+	// EntryDump a entry of DumpFieldEntries, the EntryID decoded
+	EntryDump struct {
+		EntryID   EntryID `json:"entry_id"`
+		DocID     DocID   `json:"doc_id"`
+		ConjIdx   int     `json:"conj_idx"`
+		IsInclude bool    `json:"is_include"`
+	}
+
+	// KeyEntriesDump the posting list of a key dumped by DumpFieldEntries
+	KeyEntriesDump struct {
+		Key     Key         `json:"key"`
+		Entries []EntryDump `json:"entries"`
+	}
+)
+
+func encodeDumpToken(key Key) string {
+	return strconv.FormatUint(uint64(key), 36)
+}
+
+func decodeDumpToken(token string, fieldID uint64) (Key, error) {
+	v, err := strconv.ParseUint(token, 36, 64)
+	if err != nil || Key(v).GetFieldID() != fieldID {
+		return 0, fmt.Errorf("invalid continuation token:%s", token)
+	}
+	return Key(v), nil
+}
+
+/*
+dumpField dump posting lists of field in key order, starting after the key encoded in token(""
+means from the first key); lists are added until their json size exceed maxBytes(<= 0 means no
+limit), but at least one list per page to guarantee progress, so a page may exceed maxBytes when
+a single list does. the index is immutable after built, so continuation is stable across calls.
+*/
+func (bi *indexBase) dumpField(field BEField, token string, maxBytes int, postings ...*PostingEntries) (*FieldDumpPage, error) {
+	desc, ok := bi.fieldDesc[field]
+	if !ok || field == wildcardField {
+		return nil, fmt.Errorf("field:%s not configured", field)
+	}
+	var after Key
+	var err error
+	if token != "" {
+		if after, err = decodeDumpToken(token, desc.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	keySet := make(map[Key]struct{})
+	for _, pl := range postings {
+		pl.eachList(func(key Key, _ Entries) {
+			if key.GetFieldID() == desc.ID && (token == "" || key > after) {
+				keySet[key] = struct{}{}
+			}
+		})
+	}
+	keys := make([]Key, 0, len(keySet))
+	for key := range keySet {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i] < keys[j]
+	})
+
+	reversible, _ := desc.Parser.(parser.Reversible)
+	page := &FieldDumpPage{Field: field, Lists: make([]PostingListDump, 0)}
+	size := 0
+	for idx, key := range keys {
+		list := PostingListDump{Key: key, Entries: make([]EntryID, 0)}
+		for _, pl := range postings {
+			list.Entries = append(list.Entries, pl.getEntries(key)...)
+		}
+		sort.Slice(list.Entries, func(i, j int) bool {
+			return list.Entries[i] < list.Entries[j]
+		})
+		if reversible != nil {
+			list.Value, _ = reversible.ValueOf(key.GetValueID())
+		}
+
+		data, err := json.Marshal(list)
+		if err != nil {
+			return nil, err
+		}
+		if maxBytes > 0 && len(page.Lists) > 0 && size+len(data) > maxBytes {
+			page.NextToken = encodeDumpToken(keys[idx-1])
+			break
+		}
+		size += len(data)
+		page.Lists = append(page.Lists, list)
+	}
+	return page, nil
+}
+
+func (bi *SizeGroupedBEIndex) DumpField(field BEField, token string, maxBytes int) (*FieldDumpPage, error) {
+	compileGroups(bi.sizeEntries...)
+	return bi.dumpField(field, token, maxBytes, bi.sizeEntries...)
+}
+
+func (bi *CompactedBEIndex) DumpField(field BEField, token string, maxBytes int) (*FieldDumpPage, error) {
+	bi.postingList.compile()
+	return bi.dumpField(field, token, maxBytes, bi.postingList)
+}
+
+// dumpFieldEntries json array of all posting lists of field in key order, entries decoded, see KeyEntriesDump
+func (bi *indexBase) dumpFieldEntries(field BEField, postings ...*PostingEntries) ([]byte, error) {
+	page, err := bi.dumpField(field, "", 0, postings...)
+	if err != nil {
+		return nil, err
+	}
+	lists := make([]KeyEntriesDump, 0, len(page.Lists))
+	for _, pl := range page.Lists {
+		list := KeyEntriesDump{Key: pl.Key, Entries: make([]EntryDump, 0, len(pl.Entries))}
+		for _, eid := range pl.Entries {
+			list.Entries = append(list.Entries, EntryDump{
+				EntryID:   eid,
+				DocID:     eid.GetConjID().DocID(),
+				ConjIdx:   eid.GetConjID().Index(),
+				IsInclude: eid.IsInclude(),
+			})
+		}
+		lists = append(lists, list)
+	}
+	return json.Marshal(lists)
+}
+
+func (bi *SizeGroupedBEIndex) DumpFieldEntries(field BEField) ([]byte, error) {
+	compileGroups(bi.sizeEntries...)
+	return bi.dumpFieldEntries(field, bi.sizeEntries...)
+}
+
+func (bi *CompactedBEIndex) DumpFieldEntries(field BEField) ([]byte, error) {
+	bi.postingList.compile()
+	return bi.dumpFieldEntries(field, bi.postingList)
+}
+
+func writeQueryPlanKey(sb *strings.Builder, bi *indexBase, key Key, postings []*PostingEntries, listName func(i int) string) {
+	for i, pl := range postings {
+		if entries := pl.getEntries(key); len(entries) > 0 {
+			sb.WriteString(fmt.Sprintf("  %s %s:%v\n", listName(i), bi.StringKey(key), entries.DocString()))
+		}
+	}
+}
+
+/*
+dumpQueryPlan show the posting lists every field/value of queries resolved to, listName name the
+i-th of postings(eg: "K:1"); synthetic sub-fields(see allOfField, absentField) the query assigned
+are listed after the fields, and the wildcard list(matched by any query) first.
+*/
+func (bi *indexBase) dumpQueryPlan(queries Assignments, wildcard Entries, postings []*PostingEntries, listName func(i int) string) string {
+	sb := &strings.Builder{}
+	sb.WriteString(fmt.Sprintf("wildcard %s:%v\n", bi.StringKey(NewKey(bi.fieldDesc[wildcardField].ID, 0)), wildcard.DocString()))
+
+	fields := make([]BEField, 0, len(queries))
+	for field := range queries {
+		fields = append(fields, field)
+	}
+	sort.Slice(fields, func(i, j int) bool {
+		return fields[i] < fields[j]
+	})
+	for _, field := range fields {
+		desc, ok := bi.fieldDesc[field]
+		if !ok {
+			sb.WriteString(fmt.Sprintf("field:%s not configured, ignored\n", field))
+			continue
+		}
+		for _, value := range queries[field] {
+			ids, err := bi.parseAssign(desc, value, postings...)
+			if err != nil {
+				sb.WriteString(fmt.Sprintf("field:%s value:%+v parse fail:%s\n", field, value, err.Error()))
+				continue
+			}
+			sb.WriteString(fmt.Sprintf("field:%s value:%+v ids:%v\n", field, value, ids))
+			for _, id := range ids {
+				writeQueryPlanKey(sb, bi, NewKey(desc.ID, id), postings, listName)
+			}
+		}
+	}
+
+	idAssigns, err := bi.parseQueries(&RetrieveContext{}, queries, postings...)
+	if err != nil {
+		return sb.String()
+	}
+	var synthetic []BEField
+	for field := range idAssigns {
+		if _, ok := queries[field]; !ok {
+			synthetic = append(synthetic, field)
+		}
+	}
+	sort.Slice(synthetic, func(i, j int) bool {
+		return synthetic[i] < synthetic[j]
+	})
+	for _, field := range synthetic {
+		sb.WriteString(fmt.Sprintf("synthetic field:%s ids:%v\n", field, idAssigns[field]))
+		for _, id := range idAssigns[field] {
+			writeQueryPlanKey(sb, bi, NewKey(bi.fieldDesc[field].ID, id), postings, listName)
+		}
+	}
+	return sb.String()
+}
+
+func (bi *SizeGroupedBEIndex) DumpQueryPlan(queries Assignments) string {
+	compileGroups(bi.sizeEntries...)
+	return bi.dumpQueryPlan(queries, bi.wildcardEntries.load(), bi.sizeEntries, func(i int) string {
+		return fmt.Sprintf("K:%d", i)
+	})
+}
+
+func (bi *CompactedBEIndex) DumpQueryPlan(queries Assignments) string {
+	bi.postingList.compile()
+	return bi.dumpQueryPlan(queries, bi.wildcardEntries.load(), []*PostingEntries{bi.postingList}, func(int) string {
+		return "postingList"
+	})
+}
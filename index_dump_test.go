@@ -0,0 +1,155 @@
+package be_indexer
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestBEIndex_DumpField(t *testing.T) {
+	LogLevel = ErrorLevel
+
+	b := NewIndexerBuilder(WithValueReverseLookup())
+	for i := 1; i <= 200; i++ {
+		doc := NewDocument(DocID(i))
+		conj := NewConjunction().In("tag", NewIntValues(i%50, i%7))
+		if i%3 == 0 {
+			conj.NotIn("city", NewStrValues("sh"))
+		} else {
+			conj.In("city", NewStrValues("bj", "sz"))
+		}
+		doc.AddConjunction(conj)
+		b.AddDocument(doc)
+	}
+
+	convey.Convey("test paginated dump stitch back to unpaginated dump", t, func() {
+		for _, index := range []BEIndex{b.BuildIndex(), b.BuildCompactedIndex()} {
+			for _, field := range []BEField{"tag", "city"} {
+				full, err := index.DumpField(field, "", 0)
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(full.NextToken, convey.ShouldBeEmpty)
+				convey.So(len(full.Lists), convey.ShouldBeGreaterThan, 0)
+
+				for _, maxBytes := range []int{1, 300, 2000} {
+					var stitched []PostingListDump
+					token, pages := "", 0
+					for {
+						page, err := index.DumpField(field, token, maxBytes)
+						convey.So(err, convey.ShouldBeNil)
+						convey.So(page.Field, convey.ShouldEqual, field)
+						if len(page.Lists) > 1 {
+							data, _ := json.Marshal(page.Lists)
+							convey.So(len(data), convey.ShouldBeLessThanOrEqualTo, maxBytes+len(page.Lists)+1)
+						}
+						stitched = append(stitched, page.Lists...)
+						pages++
+						if token = page.NextToken; token == "" {
+							break
+						}
+					}
+					convey.So(stitched, convey.ShouldResemble, full.Lists)
+					if maxBytes == 1 {
+						convey.So(pages, convey.ShouldEqual, len(full.Lists))
+					}
+				}
+			}
+
+			page, err := index.DumpField("tag", "", 0)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(page.Lists[0].Value, convey.ShouldNotBeEmpty)
+
+			_, err = index.DumpField("unknown", "", 0)
+			convey.So(err, convey.ShouldNotBeNil)
+			_, err = index.DumpField("tag", "not a token!", 0)
+			convey.So(err, convey.ShouldNotBeNil)
+			cityPage, _ := index.DumpField("city", "", 1)
+			_, err = index.DumpField("tag", cityPage.NextToken, 0)
+			convey.So(err, convey.ShouldNotBeNil)
+		}
+	})
+}
+
+func TestBEIndex_DumpQueryPlan(t *testing.T) {
+	LogLevel = ErrorLevel
+
+	b := NewIndexerBuilder()
+	doc := NewDocument(1)
+	doc.AddConjunction(NewConjunction().In("tag", NewIntValues(1)).Absent("apps"))
+	b.AddDocument(doc)
+	doc = NewDocument(2)
+	doc.AddConjunction(NewConjunction().NotIn("tag", NewIntValues(1)))
+	b.AddDocument(doc)
+
+	convey.Convey("test dump query plan", t, func() {
+		for _, index := range []BEIndex{b.BuildIndex(), b.BuildCompactedIndex()} {
+			plan := index.DumpQueryPlan(Assignments{"tag": NewIntValues(1, 2), "unknown": NewIntValues(1)})
+			convey.So(plan, convey.ShouldContainSubstring, "wildcard <__wildcard__,0>:[<2,true>]")
+			convey.So(plan, convey.ShouldContainSubstring, "field:tag value:1 ids:")
+			convey.So(plan, convey.ShouldContainSubstring, "field:tag value:2 ids:")
+			convey.So(plan, convey.ShouldContainSubstring, "<2,false>")
+			convey.So(plan, convey.ShouldContainSubstring, "field:tag value:2 ids:[]") // value not indexed
+			convey.So(plan, convey.ShouldContainSubstring, "field:unknown not configured, ignored")
+			convey.So(plan, convey.ShouldContainSubstring, "synthetic field:apps#absent ids:[0]")
+			convey.So(plan, convey.ShouldContainSubstring, "<apps#absent,0>:[<1,true>]")
+
+			plan = index.DumpQueryPlan(Assignments{"apps": NewStrValues("x")})
+			convey.So(plan, convey.ShouldNotContainSubstring, "synthetic field")
+		}
+	})
+}
+
+func TestBEIndex_DumpFieldEntries(t *testing.T) {
+	LogLevel = ErrorLevel
+
+	b := NewIndexerBuilder()
+	doc := NewDocument(1)
+	doc.AddConjunction(NewConjunction().In("tag", NewIntValues(1)))
+	doc.AddConjunction(NewConjunction().In("tag", NewIntValues(2)).NotIn("city", NewStrValues("sh")))
+	b.AddDocument(doc)
+	doc = NewDocument(2)
+	doc.AddConjunction(NewConjunction().In("tag", NewIntValues(1, 2)))
+	b.AddDocument(doc)
+
+	convey.Convey("test dump entries of a field as json", t, func() {
+		for _, index := range []BEIndex{b.BuildIndex(), b.BuildCompactedIndex()} {
+			data, err := index.DumpFieldEntries("tag")
+			convey.So(err, convey.ShouldBeNil)
+
+			var lists []map[string]interface{}
+			convey.So(json.Unmarshal(data, &lists), convey.ShouldBeNil)
+			convey.So(len(lists), convey.ShouldEqual, 2)
+			for _, list := range lists {
+				convey.So(list["key"], convey.ShouldNotBeNil)
+				convey.So(len(list["entries"].([]interface{})), convey.ShouldEqual, 2)
+			}
+
+			var dumps []KeyEntriesDump
+			convey.So(json.Unmarshal(data, &dumps), convey.ShouldBeNil)
+			page, _ := index.DumpField("tag", "", 0)
+			for i, list := range dumps {
+				convey.So(list.Key, convey.ShouldEqual, page.Lists[i].Key)
+				for j, entry := range list.Entries {
+					convey.So(entry.EntryID, convey.ShouldEqual, page.Lists[i].Entries[j])
+					convey.So(entry.IsInclude, convey.ShouldBeTrue)
+				}
+			}
+
+			data, err = index.DumpFieldEntries("city")
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(json.Unmarshal(data, &dumps), convey.ShouldBeNil)
+			convey.So(dumps, convey.ShouldResemble, []KeyEntriesDump{{
+				Key: dumps[0].Key,
+				Entries: []EntryDump{{
+					EntryID:   dumps[0].Entries[0].EntryID,
+					DocID:     1,
+					ConjIdx:   1,
+					IsInclude: false,
+				}},
+			}})
+
+			_, err = index.DumpFieldEntries("unknown")
+			convey.So(err, convey.ShouldNotBeNil)
+		}
+	})
+}
@@ -0,0 +1,89 @@
+package be_indexer
+
+import (
+	"fmt"
+
+	"github.com/echoface/be_indexer/parser"
+)
+
+type (
+	// estimateKey a posting list of size grouped index, (k, field, value id)
+	estimateKey struct {
+		k     int
+		field BEField
+		id    uint64
+	}
+)
+
+/*
+EstimateIndexMemory predict IndexStatistics.MemoryFootprint of the index BuildIndex build from docs
+with settings, for capacity planning(eg: sharding decisions) before committing to the build. it's a
+static analysis: values parsed by throwaway parsers to count the distinct posting list keys of every
+K group and the entries, nothing indexed. the compacted index(BuildCompactedIndex) share keys across
+K, so its footprint is no more than the estimation; shared posting lists(see WithSharedPostingLists)
+are not predicted. a value can't be parsed fail the estimation.
+note: it's the cost model of posting lists(see WithMemoryBudget), not the heap the index take, which
+is several times of it with dictionaries and per doc/conjunction maps; compare shards by it, but
+measure the heap for a absolute number.
+*/
+func EstimateIndexMemory(docs []*Document, settings IndexerSettings) (int64, error) {
+	alloc := parser.NewIDAllocatorImpl()
+	parsers := make(map[BEField]*FieldDesc)
+	parserOf := func(field BEField) (*FieldDesc, error) {
+		if p, ok := parsers[field]; ok {
+			return p, nil
+		}
+		option := settings.DefaultFieldOption
+		if fieldOption, ok := settings.FieldConfig[field]; ok {
+			option = mergeFieldOption(settings.DefaultFieldOption, fieldOption)
+		}
+		p := parser.NewParser(option.Parser, alloc)
+		if p == nil {
+			p = parser.NewParser(parser.CommonParser, alloc)
+		}
+		if configurable, ok := p.(parser.Configurable); ok && len(option.ParserParams) > 0 {
+			if err := configurable.Configure(option.ParserParams); err != nil {
+				return nil, fmt.Errorf("field:%s configure parser fail:%s", field, err.Error())
+			}
+		}
+		parsers[field] = &FieldDesc{Field: field, Parser: p, option: option}
+		return parsers[field], nil
+	}
+
+	keys := make(map[estimateKey]struct{})
+	var entries, wildcardEntries int64
+	for _, doc := range docs {
+		for _, conj := range doc.Cons {
+			k := conj.CalcConjSize()
+			if k == 0 {
+				wildcardEntries++
+			}
+			for field, expr := range conj.Expressions {
+				if expr.Absent {
+					keys[estimateKey{k: k, field: absentField(field)}] = struct{}{}
+					entries++
+					continue
+				}
+				p, err := parserOf(field)
+				if err != nil {
+					return 0, err
+				}
+				for idx, value := range expr.Value {
+					ids, err := p.parseValue(value)
+					if err != nil {
+						return 0, fmt.Errorf("doc:%d, field:%s value:%+v parse fail, err:%s", doc.ID, field, value, err.Error())
+					}
+					keyField := field
+					if expr.All {
+						keyField = allOfField(field, idx)
+					}
+					for _, id := range ids {
+						keys[estimateKey{k: k, field: keyField, id: id}] = struct{}{}
+					}
+					entries += int64(len(ids))
+				}
+			}
+		}
+	}
+	return int64(len(keys))*postingKeyBytes + (entries+wildcardEntries)*entryIDBytes, nil
+}
@@ -0,0 +1,91 @@
+package be_indexer
+
+import (
+	"fmt"
+	"math"
+	"runtime"
+	"testing"
+
+	"github.com/echoface/be_indexer/parser"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestEstimateIndexMemory(t *testing.T) {
+	LogLevel = ErrorLevel
+
+	settings := IndexerSettings{
+		FieldConfig: map[BEField]FieldOption{
+			"age": {Parser: parser.CommonParser},
+		},
+	}
+	var docs []*Document
+	for id := 1; id <= 1000; id++ {
+		doc := NewDocument(DocID(id))
+		conj := NewConjunction().In("tag", NewIntValues(id%100, id%13))
+		switch id % 4 {
+		case 0:
+			conj.NotIn("city", NewStrValues(fmt.Sprintf("city_%d", id%20)))
+		case 1:
+			conj.InAll("label", NewStrValues("a", fmt.Sprintf("l_%d", id%5)))
+		case 2:
+			conj.Absent("region")
+		}
+		doc.AddConjunction(conj)
+		if id%10 == 0 {
+			doc.AddConjunction(NewConjunction().In("age", NewIntValues(id%60)).NotIn("tag", NewIntValues(1)))
+		}
+		if id%50 == 0 {
+			doc.AddConjunction(NewConjunction().NotIn("city", NewStrValues("sh")))
+		}
+		docs = append(docs, doc)
+	}
+
+	newBuilder := func() *IndexerBuilder {
+		b := NewIndexerBuilder()
+		for field, option := range settings.FieldConfig {
+			b.ConfigField(field, option)
+		}
+		for _, doc := range docs {
+			b.AddDocument(doc)
+		}
+		return b
+	}
+
+	convey.Convey("test estimation match the footprint of built index", t, func() {
+		estimation, err := EstimateIndexMemory(docs, settings)
+		convey.So(err, convey.ShouldBeNil)
+
+		b := newBuilder()
+		footprint := b.BuildIndex().Stats().MemoryFootprint
+		convey.So(footprint, convey.ShouldBeGreaterThan, 0)
+		convey.So(math.Abs(float64(estimation-footprint))/float64(footprint), convey.ShouldBeLessThan, 0.1)
+
+		compacted := b.BuildCompactedIndex().Stats().MemoryFootprint
+		convey.So(compacted, convey.ShouldBeLessThanOrEqualTo, footprint)
+	})
+
+	convey.Convey("test estimation a lower bound of the heap index take", t, func() {
+		estimation, _ := EstimateIndexMemory(docs, settings)
+		for _, doc := range docs {
+			doc.Prepare() // caches of docs are not the index's
+		}
+		var before, after runtime.MemStats
+		runtime.GC()
+		runtime.ReadMemStats(&before)
+		index := newBuilder().BuildIndex() // the builder is garbage, only the index retained
+		runtime.GC()
+		runtime.ReadMemStats(&after)
+		heap := int64(after.HeapAlloc) - int64(before.HeapAlloc)
+		runtime.KeepAlive(index)
+
+		convey.So(heap, convey.ShouldBeGreaterThan, estimation)
+		convey.So(heap, convey.ShouldBeLessThan, 20*estimation) // the model cover a meaningful part
+	})
+
+	convey.Convey("test estimation fail on value can't be parsed", t, func() {
+		doc := NewDocument(1)
+		doc.AddConjunction(NewConjunction().In("age", Values{struct{}{}}))
+		_, err := EstimateIndexMemory([]*Document{doc}, settings)
+		convey.So(err, convey.ShouldNotBeNil)
+	})
+}
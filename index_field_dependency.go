@@ -0,0 +1,49 @@
+package be_indexer
+
+import (
+	"fmt"
+)
+
+// configureRequiredField remember the prerequisite of field, see FieldOption.RequiresField
+func (bi *indexBase) configureRequiredField(field, required BEField) {
+	if required == "" {
+		return
+	}
+	if required == field {
+		panic(fmt.Errorf("field:%s can't require itself", field))
+	}
+	if bi.requiredFields == nil {
+		bi.requiredFields = make(map[BEField]BEField)
+	}
+	bi.requiredFields[field] = required
+}
+
+// assigned whether query assign field, by queries(namespaced), WithBitsetAssign or WithRawIDAssign
+func (ctx *RetrieveContext) assigned(queries Assignments, field BEField) bool {
+	return len(queries[field]) > 0 || ctx.bitsetAssigns[field] != nil || len(ctx.rawIDAssigns[field]) > 0
+}
+
+// removeAssign no cursor created for field(and its InAll sub-fields)
+func (bi *indexBase) removeAssign(idAssigns map[BEField][]uint64, field BEField) {
+	delete(idAssigns, field)
+	for i := 0; i < bi.allOfFields[field]; i++ {
+		delete(idAssigns, allOfField(field, i))
+	}
+}
+
+/*
+skipDependentFields remove the assigns of fields whose prerequisite not assigned by query, conjunctions
+on them include the prerequisite(see FieldOption.RequiresField) so can't be matched anyway; like
+dropped fields they are still assigned(not absent). skipped fields are reported by RetrieveTrace.
+*/
+func (bi *indexBase) skipDependentFields(ctx *RetrieveContext, queries Assignments, idAssigns map[BEField][]uint64) {
+	for field, required := range bi.requiredFields {
+		if !ctx.assigned(queries, field) || ctx.assigned(queries, required) {
+			continue
+		}
+		bi.removeAssign(idAssigns, field)
+		if ctx.trace != nil {
+			ctx.trace.SkippedFields = append(ctx.trace.SkippedFields, field)
+		}
+	}
+}
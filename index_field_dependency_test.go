@@ -0,0 +1,79 @@
+package be_indexer
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestFieldOption_RequiresField(t *testing.T) {
+	LogLevel = ErrorLevel
+
+	build := func(hint bool) *IndexerBuilder {
+		b := NewIndexerBuilder()
+		if hint {
+			b.ConfigField("os_version", FieldOption{RequiresField: "os"})
+			b.ConfigField("app_version", FieldOption{RequiresField: "app"})
+		}
+		for id := 1; id <= 100; id++ {
+			doc := NewDocument(DocID(id))
+			switch id % 4 {
+			case 0:
+				doc.AddConjunction(NewConjunction().In("os", NewStrValues("ios")).In("os_version", NewIntValues(id%3)))
+			case 1:
+				doc.AddConjunction(NewConjunction().In("os", NewStrValues("android")).NotIn("os_version", NewIntValues(id%3)))
+			case 2:
+				doc.AddConjunction(NewConjunction().In("app", NewStrValues("a")).InAll("app_version", NewIntValues(1, id%3)))
+			default:
+				doc.AddConjunction(NewConjunction().In("tag", NewIntValues(id%5)))
+			}
+			_ = b.AddDocument(doc)
+		}
+		return b
+	}
+	b, hinted := build(false), build(true)
+
+	queries := []Assignments{
+		{"os_version": NewIntValues(0, 1, 2), "app_version": NewIntValues(1, 2), "tag": NewIntValues(1, 2)},
+		{"os": NewStrValues("ios", "android"), "os_version": NewIntValues(1), "tag": NewIntValues(3)},
+		{"app": NewStrValues("a"), "app_version": NewIntValues(1), "os_version": NewIntValues(2)},
+		{"os": NewStrValues("android"), "app": NewStrValues("a"), "app_version": NewIntValues(1, 2)},
+	}
+	convey.Convey("test dependent field skipped when prerequisite absent, results unchanged", t, func() {
+		indexes := []BEIndex{b.BuildIndex(), b.BuildCompactedIndex()}
+		for i, index := range []BEIndex{hinted.BuildIndex(), hinted.BuildCompactedIndex()} {
+			for j, query := range queries {
+				expectTrace, trace := &RetrieveTrace{}, &RetrieveTrace{}
+				expect, err := indexes[i].Retrieve(query, WithRetrieveTrace(expectTrace))
+				convey.So(err, convey.ShouldBeNil)
+				result, err := index.Retrieve(query, WithRetrieveTrace(trace))
+				convey.So(err, convey.ShouldBeNil)
+				sort.Sort(expect)
+				sort.Sort(result)
+				convey.So(result, convey.ShouldResemble, expect)
+				convey.So(len(expectTrace.SkippedFields), convey.ShouldEqual, 0)
+
+				sort.Slice(trace.SkippedFields, func(i, j int) bool {
+					return trace.SkippedFields[i] < trace.SkippedFields[j]
+				})
+				switch j {
+				case 0:
+					convey.So(len(result), convey.ShouldBeGreaterThan, 0)
+					convey.So(trace.SkippedFields, convey.ShouldResemble, []BEField{"app_version", "os_version"})
+					convey.So(trace.CursorsCreated, convey.ShouldBeLessThan, expectTrace.CursorsCreated)
+				case 2:
+					convey.So(trace.SkippedFields, convey.ShouldResemble, []BEField{"os_version"})
+				default:
+					convey.So(len(trace.SkippedFields), convey.ShouldEqual, 0)
+				}
+			}
+		}
+	})
+
+	convey.Convey("test field require itself", t, func() {
+		b := NewIndexerBuilder()
+		b.ConfigField("os", FieldOption{RequiresField: "os"})
+		convey.So(func() { b.BuildIndex() }, convey.ShouldPanic)
+	})
+}
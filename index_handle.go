@@ -0,0 +1,252 @@
+package be_indexer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type (
+	/*AtomicIndexHandle a BEIndex serving whichever index installed currently, it's used to rotate
+	a rebuilt index without downtime:
+
+		handle := NewAtomicIndexHandle(builder.BuildIndex())
+		...
+		old := handle.Swap(newBuilder.BuildIndex())
+
+	every call is satisfied by the index loaded when the call started, a Swap never affect the calls
+	in flight, so the returned old index can be drained(or released) after them.
+	the handle can't be used by IndexerBuilder, the builder only work on index created by itself.
+	*/
+	AtomicIndexHandle struct {
+		mtx     sync.Mutex   // serialize writers(Swap, Compact)
+		current atomic.Value // indexHolder
+	}
+
+	// indexHolder atomic.Value require a consistent concrete type, but BEIndex implementations differ
+	indexHolder struct {
+		index BEIndex
+	}
+)
+
+func NewAtomicIndexHandle(initial BEIndex) *AtomicIndexHandle {
+	if initial == nil {
+		panic(fmt.Errorf("nil index not allow"))
+	}
+	h := &AtomicIndexHandle{}
+	h.current.Store(indexHolder{index: initial})
+	return h
+}
+
+// Load return the current index
+func (h *AtomicIndexHandle) Load() BEIndex {
+	return h.current.Load().(indexHolder).index
+}
+
+// Swap install newIdx as current index and return the old one
+func (h *AtomicIndexHandle) Swap(newIdx BEIndex) BEIndex {
+	if newIdx == nil {
+		panic(fmt.Errorf("nil index not allow"))
+	}
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	old := h.Load()
+	h.current.Store(indexHolder{index: newIdx})
+	return old
+}
+
+func (h *AtomicIndexHandle) appendWildcardEntryID(id EntryID) {
+	panic(fmt.Errorf("AtomicIndexHandle can't be used to build index"))
+}
+
+func (h *AtomicIndexHandle) appendDocConjunction(id ConjID) {
+	panic(fmt.Errorf("AtomicIndexHandle can't be used to build index"))
+}
+
+func (h *AtomicIndexHandle) appendDocLabels(id DocID, labels []string) {
+	panic(fmt.Errorf("AtomicIndexHandle can't be used to build index"))
+}
+
+func (h *AtomicIndexHandle) appendDocNamespace(id DocID, ns string) {
+	panic(fmt.Errorf("AtomicIndexHandle can't be used to build index"))
+}
+
+func (h *AtomicIndexHandle) appendDocSortKey(id DocID, key int64) {
+	panic(fmt.Errorf("AtomicIndexHandle can't be used to build index"))
+}
+
+func (h *AtomicIndexHandle) appendConjFields(id ConjID, fields []uint64) {
+	panic(fmt.Errorf("AtomicIndexHandle can't be used to build index"))
+}
+
+func (h *AtomicIndexHandle) appendConjHash(id ConjID, hash uint64) {
+	panic(fmt.Errorf("AtomicIndexHandle can't be used to build index"))
+}
+
+func (h *AtomicIndexHandle) setMutationSeq(seq uint64) {
+	panic(fmt.Errorf("AtomicIndexHandle can't be used to build index"))
+}
+
+func (h *AtomicIndexHandle) newFieldDescIfNeeded(field BEField) *FieldDesc {
+	panic(fmt.Errorf("AtomicIndexHandle can't be used to build index"))
+}
+
+func (h *AtomicIndexHandle) newAllOfFieldDescIfNeeded(field BEField, i int) *FieldDesc {
+	panic(fmt.Errorf("AtomicIndexHandle can't be used to build index"))
+}
+
+func (h *AtomicIndexHandle) newAbsentFieldDescIfNeeded(field BEField) *FieldDesc {
+	panic(fmt.Errorf("AtomicIndexHandle can't be used to build index"))
+}
+
+func (h *AtomicIndexHandle) newPostingEntriesIfNeeded(k int) *PostingEntries {
+	panic(fmt.Errorf("AtomicIndexHandle can't be used to build index"))
+}
+
+func (h *AtomicIndexHandle) completeIndex() {
+	panic(fmt.Errorf("AtomicIndexHandle can't be used to build index"))
+}
+
+// cloneIndex a new handle serving the clone of current index
+func (h *AtomicIndexHandle) cloneIndex() BEIndex {
+	return NewAtomicIndexHandle(h.Load().cloneIndex())
+}
+
+func (h *AtomicIndexHandle) fragmentation() float64 {
+	return h.Load().fragmentation()
+}
+
+func (h *AtomicIndexHandle) fieldBuildReports() []FieldBuildReport {
+	return h.Load().fieldBuildReports()
+}
+
+func (h *AtomicIndexHandle) valueReverseLookup() bool {
+	return h.Load().valueReverseLookup()
+}
+
+func (h *AtomicIndexHandle) ConfigureIndexer(settings *IndexerSettings) {
+	h.Load().ConfigureIndexer(settings)
+}
+
+func (h *AtomicIndexHandle) Retrieve(queries Assignments, opts ...IndexOpt) (DocIDList, error) {
+	return h.Load().Retrieve(queries, opts...)
+}
+
+func (h *AtomicIndexHandle) RetrieveWithCollector(queries Assignments, collector ResultCollector, opts ...IndexOpt) error {
+	return h.Load().RetrieveWithCollector(queries, collector, opts...)
+}
+
+func (h *AtomicIndexHandle) RetrieveWithAnalysis(queries Assignments, opts ...IndexOpt) (DocIDList, RetrievalAnalysis, error) {
+	return h.Load().RetrieveWithAnalysis(queries, opts...)
+}
+
+func (h *AtomicIndexHandle) RetrieveRich(queries Assignments, opts ...IndexOpt) (RichDocIDList, error) {
+	return h.Load().RetrieveRich(queries, opts...)
+}
+
+// RetrieveScoredPage the pages of a Swap-ed index may overlap or miss docs, like DumpField
+func (h *AtomicIndexHandle) RetrieveScoredPage(queries Assignments, pageSize int, token string, opts ...IndexOpt) (*ScoredPage, error) {
+	return h.Load().RetrieveScoredPage(queries, pageSize, token, opts...)
+}
+
+func (h *AtomicIndexHandle) RetrieveCount(queries Assignments, opts ...IndexOpt) (int, error) {
+	return h.Load().RetrieveCount(queries, opts...)
+}
+
+func (h *AtomicIndexHandle) RetrieveWithNearMiss(queries Assignments, opts ...IndexOpt) (DocIDList, []NearMiss, error) {
+	return h.Load().RetrieveWithNearMiss(queries, opts...)
+}
+
+func (h *AtomicIndexHandle) EstimateCost(queries Assignments) (CostEstimate, error) {
+	return h.Load().EstimateCost(queries)
+}
+
+func (h *AtomicIndexHandle) DocConjunctions(id DocID) ([]ConjID, bool) {
+	return h.Load().DocConjunctions(id)
+}
+
+func (h *AtomicIndexHandle) FindByConjunction(conj *Conjunction) DocIDList {
+	return h.Load().FindByConjunction(conj)
+}
+
+func (h *AtomicIndexHandle) WildcardEntries() Entries {
+	return h.Load().WildcardEntries()
+}
+
+func (h *AtomicIndexHandle) WildcardDocs(offset, limit int) (DocIDList, int, error) {
+	return h.Load().WildcardDocs(offset, limit)
+}
+
+func (h *AtomicIndexHandle) FindOrphanEntries() []OrphanEntry {
+	return h.Load().FindOrphanEntries()
+}
+
+// Compact compact a copy-on-write clone of current index then swap it in, the index in use is left
+// untouched; calls are serialized with each other and Swap
+func (h *AtomicIndexHandle) Compact() (CompactStats, error) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	clone := h.Load().cloneIndex()
+	stats, err := clone.Compact()
+	if err != nil {
+		return stats, err
+	}
+	h.current.Store(indexHolder{index: clone})
+	return stats, nil
+}
+
+func (h *AtomicIndexHandle) Stats() IndexStatistics {
+	return h.Load().Stats()
+}
+
+func (h *AtomicIndexHandle) SchemaVersion() string {
+	return h.Load().SchemaVersion()
+}
+
+func (h *AtomicIndexHandle) FieldFingerprint() string {
+	return h.Load().FieldFingerprint()
+}
+
+// StreamStats every snapshot is taken from the index current at the time, so a Swap show up in the stream
+func (h *AtomicIndexHandle) StreamStats(ctx context.Context, interval time.Duration) <-chan IndexStatistics {
+	return streamStats(ctx, interval, h.Stats)
+}
+
+func (h *AtomicIndexHandle) FieldDescriptions() FieldDescriptions {
+	return h.Load().FieldDescriptions()
+}
+
+func (h *AtomicIndexHandle) ExportDictionary(field BEField, w io.Writer) error {
+	return h.Load().ExportDictionary(field, w)
+}
+
+func (h *AtomicIndexHandle) ExportInverted(field BEField) (map[string]DocIDList, error) {
+	return h.Load().ExportInverted(field)
+}
+
+// DumpField a continuation token is only valid for the index it came from, a Swap between pages
+// make the continuation unstable
+func (h *AtomicIndexHandle) DumpField(field BEField, token string, maxBytes int) (*FieldDumpPage, error) {
+	return h.Load().DumpField(field, token, maxBytes)
+}
+
+func (h *AtomicIndexHandle) DumpFieldEntries(field BEField) ([]byte, error) {
+	return h.Load().DumpFieldEntries(field)
+}
+
+func (h *AtomicIndexHandle) DumpEntries() string {
+	return h.Load().DumpEntries()
+}
+
+func (h *AtomicIndexHandle) DumpQueryPlan(queries Assignments) string {
+	return h.Load().DumpQueryPlan(queries)
+}
+
+func (h *AtomicIndexHandle) DumpEntriesSummary() string {
+	return h.Load().DumpEntriesSummary()
+}
@@ -0,0 +1,151 @@
+package be_indexer
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func buildHandleTestIndex(docCnt int, compacted bool) BEIndex {
+	builder := NewIndexerBuilder()
+	for i := 1; i <= docCnt; i++ {
+		doc := NewDocument(DocID(i))
+		doc.AddConjunction(NewConjunction().In("tag", NewIntValues(1)))
+		builder.AddDocument(doc)
+	}
+	if compacted {
+		return builder.BuildCompactedIndex()
+	}
+	return builder.BuildIndex()
+}
+
+func TestAtomicIndexHandle(t *testing.T) {
+	LogLevel = ErrorLevel
+
+	convey.Convey("test swap index", t, func() {
+		convey.So(func() { NewAtomicIndexHandle(nil) }, convey.ShouldPanic)
+
+		first, second := buildHandleTestIndex(1, false), buildHandleTestIndex(2, true)
+		handle := NewAtomicIndexHandle(first)
+		convey.So(handle.Load(), convey.ShouldEqual, first)
+		ids, err := handle.Retrieve(Assignments{"tag": NewIntValues(1)})
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(ids, convey.ShouldResemble, DocIDList{1})
+
+		convey.So(handle.Swap(second), convey.ShouldEqual, first)
+		convey.So(func() { handle.Swap(nil) }, convey.ShouldPanic)
+		convey.So(handle.Load(), convey.ShouldEqual, second)
+		ids, err = handle.Retrieve(Assignments{"tag": NewIntValues(1)})
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(len(ids), convey.ShouldEqual, 2)
+		convey.So(handle.Stats().DocCount, convey.ShouldEqual, 2)
+
+		// old index still usable for draining calls
+		ids, err = first.Retrieve(Assignments{"tag": NewIntValues(1)})
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(ids, convey.ShouldResemble, DocIDList{1})
+	})
+
+	convey.Convey("test compact on clone then swap in", t, func() {
+		index := buildFragmentedIndex(10, false)
+		handle := NewAtomicIndexHandle(index)
+		stats, err := handle.Compact()
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(stats.EntriesDropped, convey.ShouldEqual, 30)
+		convey.So(handle.Load(), convey.ShouldNotEqual, index)
+		convey.So(handle.fragmentation(), convey.ShouldEqual, 0)
+		convey.So(index.fragmentation(), convey.ShouldAlmostEqual, 0.75)
+	})
+
+	convey.Convey("test concurrent retrieve while swapping", t, func() {
+		indexes := []BEIndex{buildHandleTestIndex(10, false), buildHandleTestIndex(20, true)}
+		handle := NewAtomicIndexHandle(indexes[0])
+
+		var stop, reads, failures int64
+		wg := sync.WaitGroup{}
+		for r := 0; r < 4; r++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for atomic.LoadInt64(&stop) == 0 {
+					ids, err := handle.Retrieve(Assignments{"tag": NewIntValues(1)})
+					if err != nil || (len(ids) != 10 && len(ids) != 20) {
+						atomic.AddInt64(&failures, 1)
+					}
+					atomic.AddInt64(&reads, 1)
+				}
+			}()
+		}
+		for i := 0; i < 1000 || atomic.LoadInt64(&reads) < 1000; i++ {
+			handle.Swap(indexes[i%2])
+		}
+		atomic.StoreInt64(&stop, 1)
+		wg.Wait()
+
+		convey.So(atomic.LoadInt64(&failures), convey.ShouldEqual, 0)
+		convey.So(atomic.LoadInt64(&reads), convey.ShouldBeGreaterThan, 0)
+	})
+}
+
+func TestBEIndex_StreamStats(t *testing.T) {
+	LogLevel = ErrorLevel
+
+	convey.Convey("test first snapshot sent immediately and stream stop on cancel", t, func() {
+		for _, index := range []BEIndex{buildHandleTestIndex(10, false), buildHandleTestIndex(10, true)} {
+			ctx, cancel := context.WithCancel(context.Background())
+			start := time.Now()
+			ch := index.StreamStats(ctx, time.Hour)
+			stats := <-ch
+			convey.So(time.Since(start), convey.ShouldBeLessThan, time.Second)
+			convey.So(stats.DocCount, convey.ShouldEqual, 10)
+
+			cancel()
+			_, ok := <-ch
+			convey.So(ok, convey.ShouldBeFalse)
+		}
+	})
+
+	convey.Convey("test slow consumer receive the latest snapshot", t, func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		var taken uint64
+		ch := streamStats(ctx, time.Millisecond, func() IndexStatistics {
+			return IndexStatistics{MutationSeq: atomic.AddUint64(&taken, 1)}
+		})
+		for atomic.LoadUint64(&taken) < 20 {
+			time.Sleep(time.Millisecond)
+		}
+		stats := <-ch
+		convey.So(stats.MutationSeq, convey.ShouldBeGreaterThanOrEqualTo, 19)
+
+		_, ok := <-streamStats(ctx, 0, func() IndexStatistics { return IndexStatistics{} })
+		convey.So(ok, convey.ShouldBeFalse)
+	})
+
+	convey.Convey("test handle stream the current index on every tick", t, func() {
+		handle := NewAtomicIndexHandle(buildHandleTestIndex(10, false))
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		ch := handle.StreamStats(ctx, time.Millisecond)
+		convey.So((<-ch).DocCount, convey.ShouldEqual, 10)
+		handle.Swap(buildHandleTestIndex(20, true))
+
+		deadline := time.After(5 * time.Second)
+		for swapped := false; !swapped; {
+			select {
+			case stats := <-ch:
+				swapped = stats.DocCount == 20
+			case <-deadline:
+				t.Fatal("swapped index never streamed")
+			}
+		}
+		cancel()
+		for range ch { // drained until closed
+		}
+	})
+}
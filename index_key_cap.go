@@ -0,0 +1,57 @@
+package be_indexer
+
+import (
+	"sort"
+)
+
+type (
+	// HotKey a posting list exceed FieldOption.MaxKeyEntries, a key of SizeGroupedBEIndex is
+	// reported once per K group it's hot in
+	HotKey struct {
+		Field   BEField `json:"field"`
+		Key     Key     `json:"key"`
+		Entries int     `json:"entries"`
+	}
+)
+
+// hotKeys posting lists of lists longer than MaxKeyEntries of their field, sorted by field and key
+func (bi *indexBase) hotKeys(lists ...*PostingEntries) (hot []HotKey) {
+	for _, pl := range lists {
+		pl.eachList(func(key Key, entries Entries) {
+			desc, ok := bi.idToField[key.GetFieldID()]
+			if !ok || desc.option.MaxKeyEntries <= 0 || len(entries) <= desc.option.MaxKeyEntries {
+				return
+			}
+			hot = append(hot, HotKey{Field: desc.Field, Key: key, Entries: len(entries)})
+		})
+	}
+	sort.Slice(hot, func(i, j int) bool {
+		if hot[i].Field != hot[j].Field {
+			return hot[i].Field < hot[j].Field
+		}
+		if hot[i].Key != hot[j].Key {
+			return hot[i].Key < hot[j].Key
+		}
+		return hot[i].Entries < hot[j].Entries
+	})
+	return hot
+}
+
+// reportHotKeys warn and emit EventHotKey for the hot keys of indexer, only if any field capped
+func (b *IndexerBuilder) reportHotKeys(indexer BEIndex) {
+	capped := b.settings.DefaultFieldOption.MaxKeyEntries > 0
+	for _, option := range b.settings.FieldConfig {
+		capped = capped || option.MaxKeyEntries > 0
+	}
+	if !capped {
+		return
+	}
+	for _, hot := range indexer.Stats().HotKeys {
+		Logger.Infof("hot key field:%s key:%d entries:%d\n", hot.Field, hot.Key, hot.Entries)
+		b.emit(EventHotKey, map[string]interface{}{
+			"field":   hot.Field,
+			"key":     hot.Key,
+			"entries": hot.Entries,
+		})
+	}
+}
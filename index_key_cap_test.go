@@ -0,0 +1,62 @@
+package be_indexer
+
+import (
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestFieldOption_MaxKeyEntries(t *testing.T) {
+	LogLevel = ErrorLevel
+
+	newBuilder := func(option FieldOption, opts ...BuilderOpt) *IndexerBuilder {
+		b := NewIndexerBuilder(opts...)
+		b.ConfigField("device", option)
+		for id := 1; id <= 520; id++ {
+			device := "android"
+			if id > 500 {
+				device = "ios"
+			}
+			doc := NewDocument(DocID(id))
+			conj := NewConjunction().In("device", NewStrValues(device))
+			if id%3 == 0 {
+				conj.NotIn("city", NewStrValues("sh"))
+			}
+			doc.AddConjunction(conj)
+			b.AddDocument(doc)
+		}
+		return b
+	}
+	query := Assignments{"device": NewStrValues("android")}
+
+	convey.Convey("test no hot key reported without cap", t, func() {
+		b := newBuilder(FieldOption{})
+		convey.So(b.BuildIndex().Stats().HotKeys, convey.ShouldBeEmpty)
+	})
+
+	convey.Convey("test skewed value warned", t, func() {
+		emitter := NewChannelEventEmitter(1024)
+		b := newBuilder(FieldOption{MaxKeyEntries: 100}, WithEventEmitter(emitter))
+		index := b.BuildCompactedIndex()
+
+		hot := index.Stats().HotKeys
+		convey.So(len(hot), convey.ShouldEqual, 1)
+		convey.So(hot[0].Field, convey.ShouldEqual, BEField("device"))
+		convey.So(hot[0].Entries, convey.ShouldEqual, 500)
+
+		var events []BuildEvent
+		for len(emitter.Events()) > 0 {
+			if event := <-emitter.Events(); event.Type == EventHotKey {
+				events = append(events, event)
+			}
+		}
+		convey.So(len(events), convey.ShouldEqual, 1)
+		convey.So(events[0].Attributes["entries"], convey.ShouldEqual, 500)
+
+		trace := &RetrieveTrace{}
+		result, err := index.Retrieve(query, WithRetrieveTrace(trace))
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(len(result), convey.ShouldEqual, 500)
+		convey.So(trace.CursorsCreated, convey.ShouldEqual, 1)
+	})
+}
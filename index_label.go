@@ -0,0 +1,64 @@
+package be_indexer
+
+// WithLabelFilter only collect matched docs carrying all the labels(see Document.Labels),
+// it's applied when collecting, the boolean match is not affected
+func WithLabelFilter(labels ...string) IndexOpt {
+	return func(ctx *RetrieveContext) {
+		ctx.labelFilter = append(ctx.labelFilter, labels...)
+	}
+}
+
+func (bi *indexBase) appendDocLabels(id DocID, labels []string) {
+	if bi.labelDocs == nil {
+		bi.labelDocs = make(map[string]map[DocID]struct{})
+	}
+	for _, label := range labels {
+		docs, ok := bi.labelDocs[label]
+		if !ok {
+			docs = make(map[DocID]struct{})
+			bi.labelDocs[label] = docs
+		}
+		docs[id] = struct{}{}
+	}
+}
+
+// resolveLabelFilter lookup docs of labels in filter, false returned if any label carried by no doc
+func (ctx *RetrieveContext) resolveLabelFilter(bi *indexBase) bool {
+	ctx.labelDocs = ctx.labelDocs[:0]
+	for _, label := range ctx.labelFilter {
+		docs, ok := bi.labelDocs[label]
+		if !ok {
+			return false
+		}
+		ctx.labelDocs = append(ctx.labelDocs, docs)
+	}
+	return true
+}
+
+// collect add the hit to collector if doc pass the label filter, see collect
+func (ctx *RetrieveContext) collect(collector ResultCollector, id DocID, conj ConjID, matched FieldScanners) error {
+	for _, docs := range ctx.labelDocs {
+		if _, ok := docs[id]; !ok {
+			return nil
+		}
+	}
+	if !ctx.inNamespace(id) {
+		return nil
+	}
+	if !ctx.verifyApproximate(conj, matched) {
+		return nil
+	}
+	if ctx.specificHits != nil {
+		ctx.holdMostSpecific(id, conj, matched)
+		return nil
+	}
+	if err := collect(ctx, collector, id, conj, matched.fields); err != nil {
+		return err
+	}
+	if ctx.analysis != nil && conj.Size() == 0 {
+		ctx.analysis.WildcardMatchCount++
+	}
+	ctx.attributeValues(id, conj, matched)
+	ctx.chargeCollector(collector)
+	return nil
+}
@@ -0,0 +1,50 @@
+package be_indexer
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestWithLabelFilter(t *testing.T) {
+	LogLevel = ErrorLevel
+
+	b := NewIndexerBuilder()
+	for id, labels := range map[DocID][]string{1: {"video"}, 2: {"video", "hd"}, 3: {"image"}, 4: nil} {
+		doc := NewDocument(id)
+		doc.Labels = labels
+		doc.AddConjunction(NewConjunction().In("tag", NewIntValues(1)))
+		b.AddDocument(doc)
+	}
+	doc := NewDocument(5) // wildcard doc
+	doc.Labels = []string{"video"}
+	doc.AddConjunction(NewConjunction().NotIn("tag", NewIntValues(2)))
+	b.AddDocument(doc)
+
+	convey.Convey("test label filter narrow matched docs", t, func() {
+		for _, index := range []BEIndex{b.BuildIndex(), b.BuildCompactedIndex()} {
+			retrieve := func(opts ...IndexOpt) DocIDList {
+				ids, err := index.Retrieve(Assignments{"tag": NewIntValues(1)}, opts...)
+				convey.So(err, convey.ShouldBeNil)
+				sort.Sort(ids)
+				return ids
+			}
+			convey.So(retrieve(), convey.ShouldResemble, DocIDList{1, 2, 3, 4, 5})
+			convey.So(retrieve(WithLabelFilter("video")), convey.ShouldResemble, DocIDList{1, 2, 5})
+			convey.So(retrieve(WithLabelFilter("video", "hd")), convey.ShouldResemble, DocIDList{2})
+			convey.So(retrieve(WithLabelFilter("video"), WithLabelFilter("image")), convey.ShouldBeEmpty)
+			convey.So(retrieve(WithLabelFilter("unknown")), convey.ShouldBeEmpty)
+
+			// labels are not a part of boolean match
+			ids, err := index.Retrieve(Assignments{"tag": NewIntValues(2)}, WithLabelFilter("video"))
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(ids, convey.ShouldBeEmpty)
+
+			collector := NewDocIDCollector()
+			err = index.RetrieveWithCollector(Assignments{"tag": NewIntValues(1)}, collector, WithLabelFilter("image"))
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(collector.GetDocIDs(), convey.ShouldResemble, DocIDList{3})
+		}
+	})
+}
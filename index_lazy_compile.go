@@ -0,0 +1,81 @@
+package be_indexer
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+type (
+	// lazyCompile compile state of a posting list group, see WithLazyCompile
+	lazyCompile struct {
+		once  sync.Once
+		done  int32
+		sorts int32 // times the group sorted, never more than 1
+	}
+)
+
+// compile sort the group if it's lazy and not compiled yet, concurrent callers wait for the one sorting
+func (kse *PostingEntries) compile() {
+	if kse.lazy == nil {
+		return
+	}
+	kse.lazy.once.Do(func() {
+		atomic.AddInt32(&kse.lazy.sorts, 1)
+		kse.makeEntriesSorted()
+		atomic.StoreInt32(&kse.lazy.done, 1)
+	})
+}
+
+// compiled whether the lists of group sorted, elements of a group not compiled can't be read
+func (kse *PostingEntries) compiled() bool {
+	return kse.lazy == nil || atomic.LoadInt32(&kse.lazy.done) == 1
+}
+
+func compileGroups(lists ...*PostingEntries) {
+	for _, pl := range lists {
+		pl.compile()
+	}
+}
+
+// compileIfNeeded compile group k if the query can match conjunctions in it: K fields(1 for k 0,
+// wildcard list count) of query have posting lists in the group; false if group k can't match
+func (bi *SizeGroupedBEIndex) compileIfNeeded(ctx *RetrieveContext, k int) bool {
+	kse := bi.sizeEntries[k]
+	if kse.compiled() {
+		return true
+	}
+	need := k
+	if need == 0 {
+		need = 1
+		if len(bi.wildcardEntries.load()) > 0 {
+			need--
+		}
+	}
+	for field, ids := range ctx.idAssigns {
+		if need <= 0 {
+			break
+		}
+		desc := bi.fieldDesc[field]
+		for _, id := range ids {
+			if len(kse.getEntries(NewKey(desc.ID, id))) > 0 {
+				need--
+				break
+			}
+		}
+	}
+	if need > 0 {
+		return false
+	}
+	kse.compile()
+	return true
+}
+
+// compileProgress count posting list groups and the compiled ones
+func compileProgress(stats *IndexStatistics, lists ...*PostingEntries) {
+	for _, pl := range lists {
+		stats.PostingGroups++
+		if pl.compiled() {
+			stats.CompiledGroups++
+		}
+	}
+}
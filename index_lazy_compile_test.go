@@ -0,0 +1,120 @@
+package be_indexer
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func newLazyCompileTestBuilder(opts ...BuilderOpt) *IndexerBuilder {
+	b := NewIndexerBuilder(opts...)
+	for id := 1; id <= 300; id++ {
+		conj := NewConjunction().In("tag", NewIntValues(id%5))
+		if id%3 > 0 {
+			conj.In("city", NewStrValues("sh", "bj"))
+		}
+		if id%3 > 1 {
+			conj.NotIn("age", NewIntValues(id%4)).In("os", NewStrValues("ios"))
+		}
+		doc := NewDocument(DocID(id))
+		doc.AddConjunction(conj)
+		b.AddDocument(doc)
+	}
+	return b
+}
+
+func groupSorts(index BEIndex) (sorts []int32) {
+	var lists []*PostingEntries
+	switch idx := index.(type) {
+	case *SizeGroupedBEIndex:
+		lists = idx.sizeEntries
+	case *CompactedBEIndex:
+		lists = []*PostingEntries{idx.postingList}
+	}
+	for _, pl := range lists {
+		sorts = append(sorts, atomic.LoadInt32(&pl.lazy.sorts))
+	}
+	return sorts
+}
+
+func TestIndexerBuilder_WithLazyCompile(t *testing.T) {
+	LogLevel = ErrorLevel
+
+	queries := []Assignments{
+		{"tag": NewIntValues(1)},
+		{"tag": NewIntValues(1, 2), "city": NewStrValues("sh")},
+		{"tag": NewIntValues(2), "city": NewStrValues("bj"), "os": NewStrValues("ios"), "age": NewIntValues(1)},
+	}
+
+	convey.Convey("test first query compile only the groups it need", t, func() {
+		b := newLazyCompileTestBuilder(WithLazyCompile())
+		index := b.BuildIndex()
+		stats := index.Stats()
+		convey.So(stats.PostingGroups, convey.ShouldEqual, 4)
+		convey.So(stats.CompiledGroups, convey.ShouldEqual, 0)
+
+		result, err := index.Retrieve(queries[0])
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(len(result), convey.ShouldEqual, 20)
+		convey.So(groupSorts(index), convey.ShouldResemble, []int32{0, 1, 0, 0})
+		convey.So(index.Stats().CompiledGroups, convey.ShouldEqual, 1)
+
+		eager := newLazyCompileTestBuilder().BuildIndex()
+		convey.So(eager.Stats().CompiledGroups, convey.ShouldEqual, 4)
+		for _, query := range queries {
+			expect, _ := eager.Retrieve(query)
+			result, err := index.Retrieve(query)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(len(result), convey.ShouldEqual, len(expect))
+			convey.So(result.Sub(expect), convey.ShouldBeEmpty)
+		}
+
+		index.DumpEntries()
+		convey.So(index.Stats().CompiledGroups, convey.ShouldEqual, 4)
+		convey.So(groupSorts(index), convey.ShouldResemble, []int32{1, 1, 1, 1})
+	})
+
+	convey.Convey("test concurrent first queries don't double sort", t, func() {
+		b := newLazyCompileTestBuilder(WithLazyCompile())
+		for _, index := range []BEIndex{b.BuildIndex(), b.BuildCompactedIndex()} {
+			wg := sync.WaitGroup{}
+			counts := make([]int, 16)
+			for i := range counts {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					result, _ := index.Retrieve(queries[2])
+					counts[i] = len(result)
+				}(i)
+			}
+			wg.Wait()
+			for _, cnt := range counts {
+				convey.So(cnt, convey.ShouldEqual, counts[0])
+			}
+			convey.So(counts[0], convey.ShouldBeGreaterThan, 0)
+			for _, sorts := range groupSorts(index) {
+				convey.So(sorts, convey.ShouldBeLessThanOrEqualTo, 1)
+			}
+		}
+	})
+
+	convey.Convey("test background compile all groups", t, func() {
+		b := newLazyCompileTestBuilder(WithBackgroundCompile())
+		for _, index := range []BEIndex{b.BuildIndex(), b.BuildCompactedIndex()} {
+			_, err := index.Retrieve(queries[1])
+			convey.So(err, convey.ShouldBeNil)
+
+			deadline := time.Now().Add(5 * time.Second)
+			for stats := index.Stats(); stats.CompiledGroups < stats.PostingGroups; stats = index.Stats() {
+				convey.So(time.Now().Before(deadline), convey.ShouldBeTrue)
+				time.Sleep(time.Millisecond)
+			}
+			for _, sorts := range groupSorts(index) {
+				convey.So(sorts, convey.ShouldEqual, 1)
+			}
+		}
+	})
+}
@@ -0,0 +1,495 @@
+package be_indexer
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type (
+	/*LiveIndex a index whose documents are queryable right after added, for rule engines can't wait
+	a rebuild. the main index is built from all documents, documents mutated since then(the delta)
+	are appended to a liveDelta, indexed by the parsers and expressions semantics of the main index
+	but neither sorted nor rebuilt, Retrieve scan it linearly along with querying the main index and
+	union the results, the stale versions in main index hidden. the delta is merged(main index
+	rebuilt in background, mutations not blocked meanwhile) every merge interval or by FlushDelta,
+	keep the delta small enough by them. it's safe for concurrent use.
+	*/
+	LiveIndex struct {
+		mtx      sync.Mutex // serialize mutations and publishing
+		mergeMtx sync.Mutex // one merge at a time
+		builder  *IndexerBuilder
+		dirty    map[DocID]uint64 // docs mutated since main index built -> mutation seq
+		delta    *liveDelta       // records of dirty docs
+		state    atomic.Value     // *liveState
+
+		stopCh   chan struct{}
+		stopOnce sync.Once
+		mergeWG  sync.WaitGroup
+	}
+
+	// liveState what Retrieve see, replaced as a whole by mutations and merges
+	liveState struct {
+		main    BEIndex
+		delta   *liveDelta
+		records []*liveRecord // the records of delta published, a prefix of delta.records
+		size    int           // count of dirty docs
+		seq     uint64        // mutation seq of builder when published
+		stats   *LiveStats    // of main, computed once per main index
+	}
+
+	/*LiveStats statistics of the main index of a LiveIndex, they're computed when the main index
+	built(RefreshedAt) and don't reflect the delta; PendingMutations tell how stale they are, they're
+	refreshed by every merge, RefreshStats merge right away.
+	*/
+	LiveStats struct {
+		IndexStatistics
+		RefreshedAt      time.Time `json:"refreshed_at"`
+		PendingMutations uint64    `json:"pending_mutations"` // mutations since RefreshedAt
+	}
+
+	/*liveDelta records of the documents mutated since main index built, a mutation append a record
+	of the doc(indexed by buildDocEntries with a dictionary of delta's own) or a tombstone, nothing
+	sorted or rebuilt; the latest record of a doc win. it implement docIndexer to capture the doc
+	being indexed into pending, replaced by a fresh one when merged*/
+	liveDelta struct {
+		indexBase
+
+		mtx     sync.RWMutex    // the parsers and dictionary, written by indexing, read by parsing queries
+		records []*liveRecord   // append only, readers hold a prefix of it
+		values  *PostingEntries // keys of all records, to resolve WildcardValue
+
+		pending        *liveRecord
+		pendingEntries *PostingEntries
+	}
+
+	// liveRecord a version of doc in delta, the (key, entry) pairs of its conjunctions; removed if tombstone
+	liveRecord struct {
+		id        DocID
+		removed   bool
+		namespace string
+		labels    []string
+		sortKey   *int64
+		conjs     []ConjID
+		keys      []Key
+		entries   []EntryID
+	}
+)
+
+/*
+BuildLiveIndex build the main index of documents added and return a LiveIndex taking over b, b
+must not be used directly afterwards; the delta merged every mergeInterval in background(<= 0 means
+only by FlushDelta), call LiveIndex.Stop to terminate the goroutine.
+*/
+func (b *IndexerBuilder) BuildLiveIndex(mergeInterval time.Duration) (*LiveIndex, error) {
+	main, err := b.BuildIndexE()
+	if err != nil {
+		return nil, err
+	}
+	stats := newLiveStats(main)
+	live := &LiveIndex{
+		builder: b,
+		dirty:   make(map[DocID]uint64),
+		delta:   b.newLiveDelta(),
+		stopCh:  make(chan struct{}),
+	}
+	live.publish(main, stats)
+	if mergeInterval > 0 {
+		live.mergeWG.Add(1)
+		go live.mergeLoop(mergeInterval)
+	}
+	return live, nil
+}
+
+// snapshot a builder of docs sharing the settings of b, so building it need not hold b; it emit no
+// build event nor mutation log, the docs were reported when added to b
+func (b *IndexerBuilder) snapshot(docs map[DocID]*Document) *IndexerBuilder {
+	s := *b
+	s.Documents = docs
+	s.emitter, s.mutationLog = nil, nil
+	s.docNamespaces = make(map[DocID]string)
+	for id := range docs {
+		if ns, ok := b.docNamespaces[id]; ok {
+			s.docNamespaces[id] = ns
+		}
+	}
+	return &s
+}
+
+func (b *IndexerBuilder) newLiveDelta() *liveDelta {
+	d := &liveDelta{
+		indexBase: indexBase{
+			idAllocator: b.newIDAllocator(),
+			fieldDesc:   make(map[BEField]*FieldDesc),
+			idToField:   make(map[uint64]*FieldDesc),
+			docConjs:    make(map[DocID][]ConjID),
+		},
+		values: newPostingEntries(make(map[Key]Entries)),
+	}
+	d.configureFields(&b.settings)
+	return d
+}
+
+func (d *liveDelta) appendWildcardEntryID(_ EntryID) {} // size-0 conjunctions matched by size, see match
+
+func (d *liveDelta) appendDocConjunction(id ConjID) {
+	d.pending.conjs = append(d.pending.conjs, id)
+}
+
+func (d *liveDelta) appendDocLabels(_ DocID, labels []string) {
+	d.pending.labels = labels
+}
+
+func (d *liveDelta) appendDocNamespace(_ DocID, ns string) {
+	d.pending.namespace = ns
+}
+
+func (d *liveDelta) appendDocSortKey(_ DocID, key int64) {
+	d.pending.sortKey = &key
+}
+
+func (d *liveDelta) appendConjFields(_ ConjID, _ []uint64) {}
+
+func (d *liveDelta) appendConjHash(_ ConjID, _ uint64) {}
+
+func (d *liveDelta) newPostingEntriesIfNeeded(_ int) *PostingEntries {
+	return d.pendingEntries
+}
+
+// index append a record of doc indexed by b, nothing appended if doc fail to be indexed
+func (d *liveDelta) index(b *IndexerBuilder, doc *Document) error {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	indexer := *b
+	indexer.memoryBudget = 0 // the budget is of building main index
+	d.pending, d.pendingEntries = &liveRecord{id: doc.ID}, newPostingEntries(make(map[Key]Entries))
+	defer func() {
+		d.pending, d.pendingEntries = nil, nil
+	}()
+	if err := indexer.buildDocEntries(d, doc); err != nil {
+		return err
+	}
+	record := d.pending
+	d.pendingEntries.eachList(func(key Key, entries Entries) {
+		for _, eid := range entries {
+			record.keys, record.entries = append(record.keys, key), append(record.entries, eid)
+			d.values.AppendEntryID(key, eid)
+		}
+	})
+	d.records = append(d.records, record)
+	return nil
+}
+
+// remove append a tombstone of doc
+func (d *liveDelta) remove(id DocID) {
+	d.records = append(d.records, &liveRecord{id: id, removed: true})
+}
+
+/*
+retrieve scan records(latest first) for docs matching queries(of ctx's namespace and labels, or
+not matching if WithComplementResults), and return the latest record of every doc records cover,
+their versions in main index are stale; options work by collectors(eg: WithVerifier, WithKeepMostSpecific)
+don't apply to the delta
+*/
+func (d *liveDelta) retrieve(ctx *RetrieveContext, records []*liveRecord, queries Assignments) (DocIDList, map[DocID]*liveRecord, error) {
+	d.mtx.RLock()
+	idAssigns, err := d.parseQueries(ctx, queries, d.values)
+	keys := make(map[Key]struct{})
+	for field, ids := range idAssigns {
+		desc := d.fieldDesc[field]
+		for _, id := range ids {
+			keys[NewKey(desc.ID, id)] = struct{}{}
+		}
+	}
+	d.mtx.RUnlock()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var result DocIDList
+	latest := make(map[DocID]*liveRecord)
+	for i := len(records) - 1; i >= 0; i-- {
+		record := records[i]
+		if _, ok := latest[record.id]; ok {
+			continue
+		}
+		latest[record.id] = record
+		if record.removed || record.namespace != ctx.namespace || !record.hasLabels(ctx.labelFilter) {
+			continue
+		}
+		if record.match(keys) != ctx.complement {
+			result = append(result, record.id)
+		}
+	}
+	return result, latest, nil
+}
+
+func (r *liveRecord) hasLabels(labels []string) bool {
+	for _, label := range labels {
+		found := false
+		for _, carried := range r.labels {
+			if carried == label {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// match whether any conjunction of r matched by query keys: include fields hit as many as its size, no exclude hit
+func (r *liveRecord) match(keys map[Key]struct{}) bool {
+	hits := make(map[ConjID][]uint64, len(r.conjs))
+	excluded := make(map[ConjID]bool)
+	for i, key := range r.keys {
+		if _, ok := keys[key]; !ok {
+			continue
+		}
+		conj := r.entries[i].GetConjID()
+		if r.entries[i].IsInclude() {
+			hits[conj] = appendFieldOnce(hits[conj], key.GetFieldID())
+		} else {
+			excluded[conj] = true
+		}
+	}
+	for _, conj := range r.conjs {
+		if !excluded[conj] && len(hits[conj]) == conj.Size() {
+			return true
+		}
+	}
+	return false
+}
+
+func (l *LiveIndex) load() *liveState {
+	return l.state.Load().(*liveState)
+}
+
+func newLiveStats(main BEIndex) *LiveStats {
+	return &LiveStats{IndexStatistics: main.Stats(), RefreshedAt: time.Now()}
+}
+
+// publish install main and its stats with records of delta, must hold mtx
+func (l *LiveIndex) publish(main BEIndex, stats *LiveStats) {
+	records := l.delta.records
+	l.state.Store(&liveState{
+		main:    main,
+		delta:   l.delta,
+		records: records[:len(records):len(records)],
+		size:    len(l.dirty),
+		seq:     l.builder.mutationSeq,
+		stats:   stats,
+	})
+}
+
+// AddDocument add doc queryable right away, a doc fail to be indexed is rolled back(the previous
+// version of same DocID restored if any) and the error returned
+func (l *LiveIndex) AddDocument(doc *Document) error {
+	if err := l.builder.checkDocument(doc); err != nil {
+		return l.builder.handleBadConj(err)
+	}
+	doc.Prepare() // before it's shared with building goroutines, see Document.Prepare
+
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	old, updated := l.builder.Documents[doc.ID]
+	oldNamespace := l.builder.docNamespaces[doc.ID]
+	l.builder.addDocument(doc)
+	err := l.delta.index(l.builder, doc)
+	if err == nil {
+		l.dirty[doc.ID] = l.builder.mutationSeq
+		state := l.load()
+		l.publish(state.main, state.stats)
+		return nil
+	}
+	if updated {
+		namespace := l.builder.namespace
+		l.builder.namespace = oldNamespace
+		l.builder.addDocument(old)
+		l.builder.namespace = namespace
+	} else {
+		l.builder.RemoveDocument(doc.ID)
+	}
+	return err
+}
+
+// RemoveDocument remove doc from the results right away
+func (l *LiveIndex) RemoveDocument(id DocID) bool {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	if !l.builder.RemoveDocument(id) {
+		return false
+	}
+	l.delta.remove(id)
+	l.dirty[id] = l.builder.mutationSeq
+	state := l.load()
+	l.publish(state.main, state.stats)
+	return true
+}
+
+/*
+FlushDelta merge the delta into main index now: the main index rebuilt from a snapshot of all
+documents without holding mutations, docs mutated after the snapshot are indexed into a fresh delta.
+*/
+func (l *LiveIndex) FlushDelta() error {
+	l.mergeMtx.Lock()
+	defer l.mergeMtx.Unlock()
+
+	l.mtx.Lock()
+	if len(l.dirty) == 0 {
+		l.mtx.Unlock()
+		return nil
+	}
+	docs := make(map[DocID]*Document, len(l.builder.Documents))
+	for id, doc := range l.builder.Documents {
+		docs[id] = doc
+	}
+	snapshot, seq := l.builder.snapshot(docs), l.builder.mutationSeq
+	l.mtx.Unlock()
+
+	main, err := snapshot.BuildIndexE()
+	if err != nil {
+		return fmt.Errorf("live index merge fail, %w", err)
+	}
+	stats := newLiveStats(main)
+
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	dirty, delta := make(map[DocID]uint64), l.builder.newLiveDelta()
+	for id, mutated := range l.dirty {
+		if mutated <= seq {
+			continue
+		}
+		dirty[id] = mutated
+		doc, ok := l.builder.Documents[id]
+		if !ok {
+			delta.remove(id)
+			continue
+		}
+		if err = delta.index(l.builder, doc); err != nil { // indexed before, hardly fail
+			return fmt.Errorf("live index merge fail, %w", err)
+		}
+	}
+	l.dirty, l.delta = dirty, delta
+	l.publish(main, stats)
+	return nil
+}
+
+func (l *LiveIndex) mergeLoop(interval time.Duration) {
+	defer l.mergeWG.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.stopCh:
+			return
+		case <-ticker.C:
+			if err := l.FlushDelta(); err != nil {
+				Logger.Errorf("%s\n", err.Error())
+			}
+		}
+	}
+}
+
+// Stop terminate the merging goroutine and wait it exit, it's safe to call more than once
+func (l *LiveIndex) Stop() {
+	l.stopOnce.Do(func() {
+		close(l.stopCh)
+	})
+	l.mergeWG.Wait()
+}
+
+// Index the main index, documents in the delta not reflected
+func (l *LiveIndex) Index() BEIndex {
+	return l.load().main
+}
+
+// DeltaSize count of documents mutated since the main index built
+func (l *LiveIndex) DeltaSize() int {
+	return l.load().size
+}
+
+// Stats the statistics of main index as of last refresh, see LiveStats
+func (l *LiveIndex) Stats() LiveStats {
+	state := l.load()
+	stats := *state.stats
+	stats.PendingMutations = state.seq - stats.MutationSeq
+	return stats
+}
+
+// RefreshStats merge the delta(see FlushDelta) so Stats reflect the mutations until now, statistics
+// of posting lists can only be recomputed over a rebuilt main index
+func (l *LiveIndex) RefreshStats() (LiveStats, error) {
+	if err := l.FlushDelta(); err != nil {
+		return l.Stats(), err
+	}
+	return l.Stats(), nil
+}
+
+/*
+Retrieve query the main index and scan the delta in parallel and union the results, WithResultSort,
+WithMaxResults and WithOrderBySortKey applied to the union; outputs of WithRetrieveTrace and
+WithValueContribution, and options work by collectors(eg: WithVerifier) only cover the main index.
+*/
+func (l *LiveIndex) Retrieve(queries Assignments, opts ...IndexOpt) (DocIDList, error) {
+	state := l.load()
+	unlimited := append(opts[:len(opts):len(opts)], WithMaxResults(0))
+
+	var deltaResult DocIDList
+	var covered map[DocID]*liveRecord
+	var deltaErr error
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ctx := newRetrieveContext(unlimited...)
+		ctx.trace, ctx.valueContribution = nil, nil
+		deltaResult, covered, deltaErr = state.delta.retrieve(ctx, state.records, queries)
+	}()
+	mainResult, err := state.main.Retrieve(queries, unlimited...)
+	wg.Wait()
+	if err == nil {
+		err = deltaErr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(DocIDList, 0, len(mainResult)+len(deltaResult))
+	for _, id := range mainResult {
+		if _, stale := covered[id]; !stale {
+			result = append(result, id)
+		}
+	}
+	result = append(result, deltaResult...)
+
+	ctx := newRetrieveContext(opts...)
+	if ctx.orderBySortKey {
+		ctx.sortKeys = make(map[DocID]int64)
+		collectSortKeys(ctx.sortKeys, state.main, result[:len(result)-len(deltaResult)])
+		for _, id := range deltaResult {
+			if key := covered[id].sortKey; key != nil {
+				ctx.sortKeys[id] = *key
+			}
+		}
+	}
+	return ctx.arrangeResult(result), nil
+}
+
+// collectSortKeys add the sort keys of ids in idx into keys
+func collectSortKeys(keys map[DocID]int64, idx BEIndex, ids DocIDList) {
+	source, ok := idx.(interface{ docSortKey(id DocID) (int64, bool) })
+	if !ok {
+		return
+	}
+	for _, id := range ids {
+		if key, ok := source.docSortKey(id); ok {
+			keys[id] = key
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package be_indexer
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/echoface/be_indexer/parser"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func newLiveTestDoc(id DocID, tag int) *Document {
+	doc := NewDocument(id)
+	doc.AddConjunction(NewConjunction().In("tag", NewIntValues(tag)))
+	return doc
+}
+
+func TestIndexerBuilder_BuildLiveIndex(t *testing.T) {
+	LogLevel = ErrorLevel
+
+	convey.Convey("test mutations queryable before merged", t, func() {
+		b := NewIndexerBuilder()
+		for id := 1; id <= 3; id++ {
+			_ = b.AddDocument(newLiveTestDoc(DocID(id), 1))
+		}
+		live, err := b.BuildLiveIndex(0)
+		convey.So(err, convey.ShouldBeNil)
+		defer live.Stop()
+
+		tag := func(v int) Assignments { return Assignments{"tag": NewIntValues(v)} }
+
+		convey.So(live.AddDocument(newLiveTestDoc(4, 1)), convey.ShouldBeNil)
+		convey.So(live.AddDocument(newLiveTestDoc(1, 2)), convey.ShouldBeNil) // update
+		convey.So(live.RemoveDocument(2), convey.ShouldBeTrue)
+		convey.So(live.RemoveDocument(5), convey.ShouldBeFalse)
+		convey.So(live.DeltaSize(), convey.ShouldEqual, 3)
+
+		result, err := live.Retrieve(tag(1), WithResultSort(func(a, b DocID) bool { return a < b }))
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(result, convey.ShouldResemble, DocIDList{3, 4})
+		result, err = live.Retrieve(tag(2))
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(result, convey.ShouldResemble, DocIDList{1})
+
+		stale, _ := live.Index().Retrieve(tag(1))
+		convey.So(len(stale), convey.ShouldEqual, 3) // main index not merged yet
+
+		result, err = live.Retrieve(tag(1), WithMaxResults(1), WithResultSort(func(a, b DocID) bool { return a > b }))
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(result, convey.ShouldResemble, DocIDList{4})
+
+		result, err = live.Retrieve(tag(1), WithComplementResults())
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(result, convey.ShouldResemble, DocIDList{1})
+
+		convey.So(live.FlushDelta(), convey.ShouldBeNil)
+		convey.So(live.DeltaSize(), convey.ShouldEqual, 0)
+		merged, _ := live.Index().Retrieve(tag(1))
+		convey.So(len(merged), convey.ShouldEqual, 2)
+		convey.So(merged, convey.ShouldContain, DocID(3))
+		convey.So(merged, convey.ShouldContain, DocID(4))
+		convey.So(live.FlushDelta(), convey.ShouldBeNil) // nothing to merge
+	})
+
+	convey.Convey("test doc failed to index rolled back", t, func() {
+		b := NewIndexerBuilder(WithBadConjBehavior(ErrorBadConj))
+		b.SetFieldParser("age", parser.NumRangeParser)
+		doc := NewDocument(1)
+		doc.AddConjunction(NewConjunction().In("age", NewStrValues("1:5")))
+		_ = b.AddDocument(doc)
+		live, err := b.BuildLiveIndex(0)
+		convey.So(err, convey.ShouldBeNil)
+		defer live.Stop()
+
+		for _, id := range []DocID{1, 2} {
+			doc = NewDocument(id)
+			doc.AddConjunction(NewConjunction().In("age", NewStrValues("bad")))
+			convey.So(live.AddDocument(doc), convey.ShouldNotBeNil)
+		}
+		result, err := live.Retrieve(Assignments{"age": NewIntValues(3)})
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(result, convey.ShouldResemble, DocIDList{1})
+		convey.So(live.FlushDelta(), convey.ShouldBeNil)
+	})
+
+	convey.Convey("test background merge and concurrent use", t, func() {
+		b := NewIndexerBuilder()
+		live, err := b.BuildLiveIndex(time.Millisecond)
+		convey.So(err, convey.ShouldBeNil)
+		defer live.Stop()
+
+		wg := sync.WaitGroup{}
+		for w := 0; w < 4; w++ {
+			wg.Add(2)
+			go func(w int) {
+				defer wg.Done()
+				for i := 0; i < 50; i++ {
+					_ = live.AddDocument(newLiveTestDoc(DocID(w*50+i+1), 1))
+				}
+			}(w)
+			go func() {
+				defer wg.Done()
+				for i := 0; i < 50; i++ {
+					_, _ = live.Retrieve(Assignments{"tag": NewIntValues(1)})
+				}
+			}()
+		}
+		wg.Wait()
+
+		result, err := live.Retrieve(Assignments{"tag": NewIntValues(1)})
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(len(result), convey.ShouldEqual, 200)
+
+		deadline := time.Now().Add(5 * time.Second)
+		for live.DeltaSize() > 0 {
+			convey.So(time.Now().Before(deadline), convey.ShouldBeTrue)
+			time.Sleep(time.Millisecond)
+		}
+		merged, _ := live.Index().Retrieve(Assignments{"tag": NewIntValues(1)})
+		convey.So(len(merged), convey.ShouldEqual, 200)
+	})
+	convey.Convey("test delta scanned agree with a built index", t, func() {
+		emitter := NewChannelEventEmitter(1024)
+		b := NewIndexerBuilder(WithEventEmitter(emitter))
+		live, err := b.BuildLiveIndex(0)
+		convey.So(err, convey.ShouldBeNil)
+		defer live.Stop()
+
+		expect := NewIndexerBuilder()
+		for id := 1; id <= 300; id++ {
+			doc := NewDocument(DocID(id))
+			switch id % 4 {
+			case 0:
+				doc.AddConjunction(NewConjunction().In("tag", NewIntValues(id%5)).NotIn("age", NewIntValues(id%3)))
+			case 1:
+				doc.AddConjunction(NewConjunction().In("tag", NewIntValues(id%5)).In("age", NewIntValues(id%3, 4)))
+			case 2:
+				doc.AddConjunction(NewConjunction().NotIn("tag", NewIntValues(id%5)))
+			default:
+				doc.AddConjunction(NewConjunction().In("age", NewIntValues(id%3)))
+			}
+			if id%7 == 0 {
+				doc.Labels = []string{"hot"}
+			}
+			convey.So(live.AddDocument(doc), convey.ShouldBeNil)
+			_ = expect.AddDocument(doc)
+		}
+		for id := 10; id <= 300; id += 10 {
+			convey.So(live.RemoveDocument(DocID(id)), convey.ShouldBeTrue)
+			expect.RemoveDocument(DocID(id))
+		}
+		convey.So(live.DeltaSize(), convey.ShouldEqual, 300)
+
+		idx := expect.BuildIndex()
+		less := WithResultSort(func(a, b DocID) bool { return a < b })
+		for tag := 0; tag < 5; tag++ {
+			for age := 0; age < 5; age++ {
+				queries := Assignments{"tag": NewIntValues(tag), "age": NewIntValues(age)}
+				for _, opts := range [][]IndexOpt{{less}, {less, WithLabelFilter("hot")}, {less, WithComplementResults()}} {
+					expected, _ := idx.Retrieve(queries, opts...)
+					result, err := live.Retrieve(queries, opts...)
+					convey.So(err, convey.ShouldBeNil)
+					convey.So(result, convey.ShouldResemble, expected)
+				}
+			}
+		}
+
+		convey.So(live.FlushDelta(), convey.ShouldBeNil)
+		convey.So(live.FlushDelta(), convey.ShouldBeNil)
+		emitter.Close()
+		indexed := 0
+		for event := range emitter.Events() {
+			if event.Type == EventDocIndexed {
+				indexed++
+			}
+		}
+		convey.So(indexed, convey.ShouldEqual, 0) // the delta never built, the merge don't re-emit docs added
+	})
+	convey.Convey("test stats stale until refreshed", t, func() {
+		b := NewIndexerBuilder()
+		for id := 1; id <= 100; id++ {
+			doc := NewDocument(DocID(id))
+			doc.AddConjunction(NewConjunction().In("tag", NewIntValues(id%10)))
+			_ = b.AddDocument(doc)
+		}
+		live, err := b.BuildLiveIndex(0)
+		convey.So(err, convey.ShouldBeNil)
+		defer live.Stop()
+
+		stats := live.Stats()
+		convey.So(stats.DocCount, convey.ShouldEqual, 100)
+		convey.So(stats.PendingMutations, convey.ShouldEqual, 0)
+		refreshedAt := stats.RefreshedAt
+
+		for id := 101; id <= 300; id++ {
+			doc := NewDocument(DocID(id))
+			doc.AddConjunction(NewConjunction().In("tag", NewIntValues(id%10)).NotIn("age", NewIntValues(id%3)))
+			convey.So(live.AddDocument(doc), convey.ShouldBeNil)
+		}
+		for id := 1; id <= 50; id++ {
+			convey.So(live.RemoveDocument(DocID(id)), convey.ShouldBeTrue)
+		}
+		stats = live.Stats()
+		convey.So(stats.DocCount, convey.ShouldEqual, 100)
+		convey.So(stats.PendingMutations, convey.ShouldEqual, 250)
+		convey.So(stats.RefreshedAt, convey.ShouldEqual, refreshedAt)
+
+		stats, err = live.RefreshStats()
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(stats.PendingMutations, convey.ShouldEqual, 0)
+		convey.So(stats.RefreshedAt.After(refreshedAt), convey.ShouldBeTrue)
+		convey.So(stats.IndexStatistics, convey.ShouldResemble, live.Index().Stats())
+		convey.So(stats.DocCount, convey.ShouldEqual, 250)
+		convey.So(stats.ConjunctionCount, convey.ShouldEqual, 250)
+	})
+}
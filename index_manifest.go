@@ -0,0 +1,233 @@
+package be_indexer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"sort"
+)
+
+const (
+	// BuilderVersion bumped when the index layout(eg: ConjID encoding) change, a Manifest only
+	// verify index built by the same version
+	BuilderVersion = 1
+)
+
+var (
+	// ErrManifestMismatch the index is not the one the manifest describe, see VerifyManifest
+	ErrManifestMismatch = errors.New("index not match manifest")
+)
+
+type (
+	// ManifestDoc the checksum of a source document and the hash of its entries in index
+	ManifestDoc struct {
+		ID          DocID  `json:"id"`
+		Checksum    string `json:"checksum"`     // see Document.Checksum
+		ContentHash string `json:"content_hash"` // hash of the entries of doc over the canonical dump
+	}
+
+	/*Manifest reproducibility report of a built index, it prove a serving index correspond exactly
+	to a document snapshot: VerifyManifest re-derive the content hash from the index and compare.
+	the canonical dump name posting lists by field and decoded value(parser.Reversible, value id for
+	others) instead of ids, entries are stable across builds, so it's independent of the value id
+	allocation and the index type(size grouped or compacted); decoding need the index verified built
+	WithValueReverseLookup.
+	*/
+	Manifest struct {
+		BuilderVersion   int    `json:"builder_version"`
+		FieldFingerprint string `json:"field_fingerprint"`        // hash of the field configuration of builder
+		SchemaVersion    string `json:"schema_version,omitempty"` // see WithSchemaVersion
+
+		DocCount         int `json:"doc_count"`
+		ConjunctionCount int `json:"conjunction_count"`
+		PostingListCount int `json:"posting_list_count"`
+		EntryCount       int `json:"entry_count"`
+
+		Docs        []ManifestDoc `json:"docs"` // sorted by id
+		ContentHash string        `json:"content_hash"`
+	}
+)
+
+// BuildIndexWithManifest build a SizeGroupedBEIndex(see BuildIndexE) along with its Manifest, the
+// index is built WithValueReverseLookup whether the builder configured or not
+func (b *IndexerBuilder) BuildIndexWithManifest() (BEIndex, *Manifest, error) {
+	reverseLookup := b.settings.ValueReverseLookup
+	b.settings.ValueReverseLookup = true
+	indexer, err := b.BuildIndexE()
+	b.settings.ValueReverseLookup = reverseLookup
+	if err != nil {
+		return nil, nil, err
+	}
+	docHashes, contentHash, err := indexContentHashes(indexer)
+	if err != nil {
+		return nil, nil, err
+	}
+	stats := indexer.Stats()
+	manifest := &Manifest{
+		BuilderVersion:   BuilderVersion,
+		FieldFingerprint: indexer.FieldFingerprint(),
+		SchemaVersion:    indexer.SchemaVersion(),
+		DocCount:         stats.DocCount,
+		ConjunctionCount: stats.ConjunctionCount,
+		PostingListCount: stats.PostingListCount,
+		EntryCount:       stats.EntryCount,
+		Docs:             make([]ManifestDoc, 0, len(b.Documents)),
+		ContentHash:      contentHash,
+	}
+	for id, doc := range b.Documents {
+		manifest.Docs = append(manifest.Docs, ManifestDoc{ID: id, Checksum: doc.Checksum(), ContentHash: docHashes[id]})
+	}
+	sort.Slice(manifest.Docs, func(i, j int) bool {
+		return manifest.Docs[i].ID < manifest.Docs[j].ID
+	})
+	return indexer, manifest, nil
+}
+
+// fieldFingerprint sha256(hex) of the default option and field options sorted by field, the
+// Canonicalizer only by whether it's set
+func fieldFingerprint(settings *IndexerSettings) string {
+	writeOption := func(h hash.Hash, name string, option FieldOption) {
+		params := make([]string, 0, len(option.ParserParams))
+		for k, v := range option.ParserParams {
+			params = append(params, k+"="+v)
+		}
+		sort.Strings(params)
+		fmt.Fprintf(h, "%s parser:%s params:%q range:%t weight:%g cap:%d requires:%s canonical:%t\n",
+			name, option.Parser, params, option.RangeQuery, option.OrderWeight, option.MaxKeyEntries, option.RequiresField,
+			option.Canonicalizer != nil)
+	}
+	h := sha256.New()
+	writeOption(h, "#default", settings.DefaultFieldOption)
+	fields := make([]BEField, 0, len(settings.FieldConfig))
+	for field := range settings.FieldConfig {
+		fields = append(fields, field)
+	}
+	sort.Slice(fields, func(i, j int) bool {
+		return fields[i] < fields[j]
+	})
+	for _, field := range fields {
+		writeOption(h, string(field), settings.FieldConfig[field])
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// FieldFingerprint the fingerprint of field configuration index built with, see Manifest
+func (bi *indexBase) FieldFingerprint() string {
+	return bi.fingerprint
+}
+
+/*
+indexContentHashes hash the canonical dump of idx: fields sorted by name, posting lists of a field
+sorted by decoded value, entries sorted, the wildcard list last; every entry line feed the hash of
+its doc, the content hash is the hash of doc hashes sorted by doc id.
+*/
+func indexContentHashes(idx BEIndex) (map[DocID]string, string, error) {
+	docs := make(map[DocID]hash.Hash)
+	writeEntry := func(line string, eid EntryID) {
+		id := eid.GetConjID().DocID()
+		h, ok := docs[id]
+		if !ok {
+			h = sha256.New()
+			docs[id] = h
+		}
+		fmt.Fprintf(h, "%s %d\n", line, eid)
+	}
+
+	descs := idx.FieldDescriptions()
+	sort.Slice(descs, func(i, j int) bool {
+		return descs[i].Field < descs[j].Field
+	})
+	for _, desc := range descs {
+		page, err := idx.DumpField(desc.Field, "", 0)
+		if err != nil {
+			return nil, "", err
+		}
+		lines := make([]string, len(page.Lists))
+		for i, list := range page.Lists {
+			value := list.Value
+			if value == "" {
+				value = fmt.Sprintf("#%d", list.Key.GetValueID())
+			}
+			lines[i] = fmt.Sprintf("%s:%q", desc.Field, value)
+		}
+		order := make([]int, len(page.Lists))
+		for i := range order {
+			order[i] = i
+		}
+		sort.SliceStable(order, func(i, j int) bool {
+			return lines[order[i]] < lines[order[j]]
+		})
+		for _, i := range order {
+			for _, eid := range page.Lists[i].Entries {
+				writeEntry(lines[i], eid)
+			}
+		}
+	}
+	for _, eid := range idx.WildcardEntries() {
+		writeEntry(string(wildcardField), eid)
+	}
+
+	ids := make(DocIDList, 0, len(docs))
+	docHashes := make(map[DocID]string, len(docs))
+	for id, h := range docs {
+		ids = append(ids, id)
+		docHashes[id] = hex.EncodeToString(h.Sum(nil))
+	}
+	sort.Sort(ids)
+	content := sha256.New()
+	for _, id := range ids {
+		fmt.Fprintf(content, "%d:%s\n", id, docHashes[id])
+	}
+	return docHashes, hex.EncodeToString(content.Sum(nil)), nil
+}
+
+// VerifyManifest re-derive the content hash of idx and compare with m, the mismatch error point to
+// the first(by id) differing doc, see Manifest
+func VerifyManifest(idx BEIndex, m Manifest) error {
+	if !idx.valueReverseLookup() {
+		return fmt.Errorf("index built without WithValueReverseLookup can't be verified")
+	}
+	if m.BuilderVersion != BuilderVersion {
+		return fmt.Errorf("%w, builder version:%d, current:%d", ErrManifestMismatch, m.BuilderVersion, BuilderVersion)
+	}
+	if m.SchemaVersion != idx.SchemaVersion() {
+		return fmt.Errorf("%w, schema version:%q, index:%q", ErrManifestMismatch, m.SchemaVersion, idx.SchemaVersion())
+	}
+	if m.FieldFingerprint != idx.FieldFingerprint() {
+		return fmt.Errorf("%w, field fingerprint:%s, index:%s", ErrManifestMismatch, m.FieldFingerprint, idx.FieldFingerprint())
+	}
+	docHashes, contentHash, err := indexContentHashes(idx)
+	if err != nil {
+		return err
+	}
+	if contentHash == m.ContentHash {
+		return nil
+	}
+	var first DocID
+	reason := ""
+	report := func(id DocID, r string) {
+		if reason == "" || id < first {
+			first, reason = id, r
+		}
+	}
+	listed := make(map[DocID]struct{}, len(m.Docs))
+	for _, doc := range m.Docs {
+		listed[doc.ID] = struct{}{}
+		if h, ok := docHashes[doc.ID]; !ok && doc.ContentHash != "" {
+			report(doc.ID, "not indexed")
+		} else if h != doc.ContentHash {
+			report(doc.ID, "content differ")
+		}
+	}
+	for id := range docHashes {
+		if _, ok := listed[id]; !ok {
+			report(id, "not in manifest")
+		}
+	}
+	if reason == "" {
+		return fmt.Errorf("%w, content hash differ", ErrManifestMismatch)
+	}
+	return fmt.Errorf("%w, doc:%d %s", ErrManifestMismatch, first, reason)
+}
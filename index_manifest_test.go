@@ -0,0 +1,139 @@
+package be_indexer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestIndexerBuilder_BuildIndexWithManifest(t *testing.T) {
+	LogLevel = ErrorLevel
+
+	newBuilder := func(changed DocID) *IndexerBuilder {
+		b := NewIndexerBuilder(WithValueReverseLookup())
+		b.ConfigField("age", FieldOption{OrderWeight: 1})
+		for id := 1; id <= 100; id++ {
+			city := fmt.Sprintf("city_%d", id%7)
+			if DocID(id) == changed {
+				city = "city_changed"
+			}
+			doc := NewDocument(DocID(id))
+			doc.AddConjunction(NewConjunction().In("tag", NewIntValues(id%10)).NotIn("city", NewStrValues(city)))
+			doc.AddConjunction(NewConjunction().InAll("age", NewIntValues(id%3, 5)))
+			if id%20 == 0 {
+				doc.AddConjunction(NewConjunction().NotIn("city", NewStrValues("sh")))
+			}
+			b.AddDocument(doc)
+		}
+		return b
+	}
+
+	convey.Convey("test rebuilt index from same docs verify", t, func() {
+		index, manifest, err := newBuilder(0).BuildIndexWithManifest()
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(manifest.BuilderVersion, convey.ShouldEqual, BuilderVersion)
+		convey.So(manifest.DocCount, convey.ShouldEqual, 100)
+		convey.So(len(manifest.Docs), convey.ShouldEqual, 100)
+		convey.So(manifest.Docs[0].ID, convey.ShouldEqual, DocID(1))
+		convey.So(VerifyManifest(index, *manifest), convey.ShouldBeNil)
+
+		data, err := json.Marshal(manifest)
+		convey.So(err, convey.ShouldBeNil)
+		var loaded Manifest
+		convey.So(json.Unmarshal(data, &loaded), convey.ShouldBeNil)
+		convey.So(loaded, convey.ShouldResemble, *manifest)
+
+		rebuilt := newBuilder(0)
+		_, again, err := rebuilt.BuildIndexWithManifest()
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(again.ContentHash, convey.ShouldEqual, manifest.ContentHash)
+		convey.So(again.FieldFingerprint, convey.ShouldEqual, manifest.FieldFingerprint)
+		convey.So(VerifyManifest(rebuilt.BuildIndex(), loaded), convey.ShouldBeNil)
+		convey.So(VerifyManifest(rebuilt.BuildCompactedIndex(), loaded), convey.ShouldBeNil)
+		convey.So(VerifyManifest(NewAtomicIndexHandle(rebuilt.BuildIndex()), loaded), convey.ShouldBeNil)
+	})
+
+	convey.Convey("test one value changed point to the differing doc", t, func() {
+		_, manifest, err := newBuilder(0).BuildIndexWithManifest()
+		convey.So(err, convey.ShouldBeNil)
+
+		changed := newBuilder(42)
+		_, changedManifest, err := changed.BuildIndexWithManifest()
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(changedManifest.Docs[41].Checksum, convey.ShouldNotEqual, manifest.Docs[41].Checksum)
+		convey.So(changedManifest.Docs[40].Checksum, convey.ShouldEqual, manifest.Docs[40].Checksum)
+
+		for _, index := range []BEIndex{changed.BuildIndex(), changed.BuildCompactedIndex()} {
+			err = VerifyManifest(index, *manifest)
+			convey.So(errors.Is(err, ErrManifestMismatch), convey.ShouldBeTrue)
+			convey.So(err.Error(), convey.ShouldContainSubstring, "doc:42 content differ")
+		}
+
+		removed := newBuilder(0)
+		removed.RemoveDocument(7)
+		err = VerifyManifest(removed.BuildIndex(), *manifest)
+		convey.So(err.Error(), convey.ShouldContainSubstring, "doc:7 not indexed")
+
+		plain := newBuilder(0)
+		plain.settings.ValueReverseLookup = false
+		_, built, err := plain.BuildIndexWithManifest() // reverse lookup enabled for the build anyway
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(built.ContentHash, convey.ShouldEqual, manifest.ContentHash)
+		err = VerifyManifest(plain.BuildIndex(), *manifest)
+		convey.So(err.Error(), convey.ShouldContainSubstring, "WithValueReverseLookup")
+
+		stale := *manifest
+		stale.BuilderVersion = BuilderVersion + 1
+		convey.So(errors.Is(VerifyManifest(newBuilder(0).BuildIndex(), stale), ErrManifestMismatch), convey.ShouldBeTrue)
+	})
+
+	convey.Convey("test field configuration fingerprint", t, func() {
+		b := newBuilder(0)
+		b.ConfigField("tag", FieldOption{OrderWeight: 2})
+		_, manifest, err := b.BuildIndexWithManifest()
+		convey.So(err, convey.ShouldBeNil)
+		_, origin, _ := newBuilder(0).BuildIndexWithManifest()
+		convey.So(manifest.FieldFingerprint, convey.ShouldNotEqual, origin.FieldFingerprint)
+	})
+
+	convey.Convey("test config lost the canonicalizer fail the verification", t, func() {
+		lower := func(value interface{}) (interface{}, error) {
+			return strings.ToLower(value.(string)), nil
+		}
+		canonical := newBuilder(0)
+		canonical.ConfigField("city", FieldOption{Canonicalizer: lower})
+		_, manifest, err := canonical.BuildIndexWithManifest()
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(VerifyManifest(canonical.BuildCompactedIndex(), *manifest), convey.ShouldBeNil)
+
+		// values are lowercase already, the content alone can't tell the canonicalizer lost
+		lost := newBuilder(0).BuildIndex()
+		_, contentHash, err := indexContentHashes(lost)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(contentHash, convey.ShouldEqual, manifest.ContentHash)
+		err = VerifyManifest(lost, *manifest)
+		convey.So(errors.Is(err, ErrManifestMismatch), convey.ShouldBeTrue)
+		convey.So(err.Error(), convey.ShouldContainSubstring, "field fingerprint")
+	})
+}
+
+func TestDocument_Checksum(t *testing.T) {
+	convey.Convey("test checksum canonical", t, func() {
+		doc := NewDocument(1)
+		doc.AddConjunction(NewConjunction().In("tag", NewIntValues(1)).NotIn("city", NewStrValues("sh")))
+		other := NewDocument(1)
+		other.AddConjunction(NewConjunction().NotIn("city", NewStrValues("sh")).In("tag", NewIntValues(1)))
+		convey.So(doc.Checksum(), convey.ShouldEqual, other.Checksum())
+
+		typed := NewDocument(1)
+		typed.AddConjunction(NewConjunction().In("tag", NewStrValues("1")).NotIn("city", NewStrValues("sh")))
+		convey.So(typed.Checksum(), convey.ShouldNotEqual, doc.Checksum())
+
+		other.SetSortKey(1)
+		convey.So(other.Checksum(), convey.ShouldNotEqual, doc.Checksum())
+	})
+}
@@ -0,0 +1,79 @@
+package be_indexer
+
+import (
+	"unsafe"
+)
+
+// cursorBytes EntriesCursor and its pointer in CursorGroup, follow the struct as it change
+const cursorBytes = int64(unsafe.Sizeof(EntriesCursor{}) + unsafe.Sizeof((*EntriesCursor)(nil)))
+
+const (
+	// approximate memory cost of retrieving scratch structures, used by WithRetrieveMemoryBudget
+	scannerBytes   int64 = 64 // FieldScanner and its pointer in FieldScanners
+	docHitBytes    int64 = 16 // a DocID in a map of collector
+	valueIDBytes   int64 = 8  // a value id parsed from query
+	docIDListBytes int64 = 4  // a DocID in a DocIDList
+)
+
+type (
+	// SizeReportingCollector a collector report the approximate memory it hold, so its growth is
+	// charged against the budget of WithRetrieveMemoryBudget; collectors not implementing it are not charged
+	SizeReportingCollector interface {
+		ResultCollector
+		MemoryUsage() int64
+	}
+)
+
+/*
+WithRetrieveMemoryBudget bound the approximate memory a retrieving drive: query value ids, cursors, scanners
+and the growth of a SizeReportingCollector(all builtin collectors are); retrieving abort with
+ErrBudgetExceeded once the budget crossed, with WithPartialResultsOnBudgetExceed the docs collected
+before returned. the accounting is approximate but monotone(never decrease within a retrieving), the
+posting lists are shared by index and not charged; budget <= 0 means no limit.
+note: WithMemoryBudget is the builder option limiting the memory of posting lists
+*/
+func WithRetrieveMemoryBudget(budget int64) IndexOpt {
+	return func(ctx *RetrieveContext) {
+		ctx.memoryBudget = budget
+	}
+}
+
+func (ctx *RetrieveContext) memoryExceeded() bool {
+	return ctx.memoryBudget > 0 && ctx.scratchMemory+ctx.collectorMemory > ctx.memoryBudget
+}
+
+func (ctx *RetrieveContext) chargeMemory(bytes int64) {
+	ctx.scratchMemory += bytes
+}
+
+func (ctx *RetrieveContext) chargeIDAssigns() {
+	for _, ids := range ctx.idAssigns {
+		ctx.scratchMemory += int64(len(ids)) * valueIDBytes
+	}
+}
+
+// chargeCollector charge the growth of collector, a collector shrinking(eg: Reset) never refund
+func (ctx *RetrieveContext) chargeCollector(collector ResultCollector) {
+	if ctx.memoryBudget <= 0 && ctx.trace == nil {
+		return
+	}
+	reporter, ok := collector.(SizeReportingCollector)
+	if !ok {
+		return
+	}
+	if usage := reporter.MemoryUsage(); usage > ctx.collectorMemory {
+		ctx.collectorMemory = usage
+	}
+}
+
+func (c *DocIDCollector) MemoryUsage() int64 {
+	return int64(cap(c.docs))*docIDListBytes + int64(len(c.hits))*docHitBytes
+}
+
+func (c *richCollector) MemoryUsage() int64 {
+	return c.DocIDCollector.MemoryUsage() + int64(len(c.best))*docHitBytes
+}
+
+func (c *weightedOrderCollector) MemoryUsage() int64 {
+	return c.DocIDCollector.MemoryUsage() + int64(len(c.best))*(docHitBytes+16)
+}
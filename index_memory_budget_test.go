@@ -0,0 +1,84 @@
+package be_indexer
+
+import (
+	"testing"
+	"unsafe"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestWithRetrieveMemoryBudget(t *testing.T) {
+	LogLevel = ErrorLevel
+
+	b := NewIndexerBuilder()
+	for id := 1; id <= 2000; id++ {
+		doc := NewDocument(DocID(id))
+		doc.AddConjunction(NewConjunction().In("tag", NewIntValues(id)))
+		b.AddDocument(doc)
+	}
+	values := make([]int, 2000)
+	for i := range values {
+		values[i] = i + 1
+	}
+	// every value open a cursor and every doc matched grow the collector
+	heavy := Assignments{"tag": NewIntValues(values...)}
+	light := Assignments{"tag": NewIntValues(1, 2, 3)}
+
+	convey.Convey("test memory accounting monotone", t, func() {
+		for _, index := range []BEIndex{b.BuildIndex(), b.BuildCompactedIndex()} {
+			lightTrace, heavyTrace := &RetrieveTrace{}, &RetrieveTrace{}
+			_, err := index.Retrieve(light, WithRetrieveTrace(lightTrace))
+			convey.So(err, convey.ShouldBeNil)
+			_, err = index.Retrieve(heavy, WithRetrieveTrace(heavyTrace))
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(lightTrace.MemoryUsed, convey.ShouldBeGreaterThan, 0)
+			convey.So(heavyTrace.MemoryUsed, convey.ShouldBeGreaterThan, 2000*cursorBytes)
+			convey.So(heavyTrace.MemoryUsed, convey.ShouldBeGreaterThan, lightTrace.MemoryUsed)
+		}
+	})
+
+	convey.Convey("test query blow a small budget", t, func() {
+		for _, index := range []BEIndex{b.BuildIndex(), b.BuildCompactedIndex()} {
+			result, err := index.Retrieve(heavy, WithRetrieveMemoryBudget(16*1024))
+			convey.So(err, convey.ShouldEqual, ErrBudgetExceeded)
+			convey.So(result, convey.ShouldBeNil)
+
+			result, err = index.Retrieve(light, WithRetrieveMemoryBudget(16*1024))
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(len(result), convey.ShouldEqual, 3)
+
+			count, err := index.RetrieveCount(heavy, WithRetrieveMemoryBudget(16*1024))
+			convey.So(err, convey.ShouldEqual, ErrBudgetExceeded)
+			convey.So(count, convey.ShouldEqual, 0)
+
+			full, err := index.Retrieve(heavy, WithRetrieveMemoryBudget(1<<30))
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(len(full), convey.ShouldEqual, 2000)
+		}
+	})
+
+	convey.Convey("test partial result when collector grow over budget", t, func() {
+		// cursors of all values fit in the budget, the docs collected not
+		index := b.BuildCompactedIndex()
+		trace := &RetrieveTrace{}
+		_, _ = index.Retrieve(heavy, WithRetrieveTrace(trace))
+		budget := trace.MemoryUsed - 4096
+
+		result, err := index.Retrieve(heavy, WithRetrieveMemoryBudget(budget), WithPartialResultsOnBudgetExceed())
+		convey.So(err, convey.ShouldEqual, ErrBudgetExceeded)
+		convey.So(len(result), convey.ShouldBeGreaterThan, 0)
+		convey.So(len(result), convey.ShouldBeLessThan, 2000)
+	})
+}
+
+// TestCursorBytes pin the size of scratch structures, every retrieving allocate them per query value
+func TestCursorBytes(t *testing.T) {
+	if unsafe.Sizeof(uintptr(0)) != 8 {
+		t.Skip("sizes pinned for 64-bit platforms")
+	}
+	convey.Convey("test cursor and scanner size pinned", t, func() {
+		convey.So(unsafe.Sizeof(EntriesCursor{}), convey.ShouldEqual, 72) // 24 bytes of it for inlined lists
+		convey.So(cursorBytes, convey.ShouldEqual, 80)
+		convey.So(int64(unsafe.Sizeof(FieldScanner{})+unsafe.Sizeof((*FieldScanner)(nil))), convey.ShouldEqual, scannerBytes)
+	})
+}
@@ -0,0 +1,110 @@
+package be_indexer
+
+type (
+	// RetrieveFunc a retrieving, see IndexMiddleware
+	RetrieveFunc func(queries Assignments, opts ...IndexOpt) (DocIDList, error)
+
+	// IndexMiddleware wrap a retrieving with cross-cutting logic(auth, rate limit, logging...), it
+	// can reject the retrieving without calling next, amend queries/opts, or inspect the result
+	IndexMiddleware func(next RetrieveFunc) RetrieveFunc
+
+	/*MiddlewareBEIndex a BEIndex serving retrievals through middlewares, like http.Handler
+	middlewares: the first middleware is the outermost one, so it see the retrieving first and
+	the result last. every retrieving api go through middlewares, the DocIDList a middleware
+	get from next is the docs collected(for RetrieveWithCollector, the collector's GetDocIDs),
+	and nil for RetrieveCount; other apis are served by inner index directly.
+	*/
+	MiddlewareBEIndex struct {
+		BEIndex
+		middlewares []IndexMiddleware
+	}
+)
+
+func NewMiddlewareBEIndex(inner BEIndex, middlewares ...IndexMiddleware) BEIndex {
+	return &MiddlewareBEIndex{
+		BEIndex:     inner,
+		middlewares: middlewares,
+	}
+}
+
+// serve run terminal through middlewares
+func (m *MiddlewareBEIndex) serve(terminal RetrieveFunc, queries Assignments, opts ...IndexOpt) (DocIDList, error) {
+	fn := terminal
+	for i := len(m.middlewares) - 1; i >= 0; i-- {
+		fn = m.middlewares[i](fn)
+	}
+	return fn(queries, opts...)
+}
+
+func (m *MiddlewareBEIndex) Retrieve(queries Assignments, opts ...IndexOpt) (DocIDList, error) {
+	return m.serve(m.BEIndex.Retrieve, queries, opts...)
+}
+
+func (m *MiddlewareBEIndex) RetrieveWithCollector(queries Assignments, collector ResultCollector, opts ...IndexOpt) error {
+	_, err := m.serve(func(queries Assignments, opts ...IndexOpt) (DocIDList, error) {
+		err := m.BEIndex.RetrieveWithCollector(queries, collector, opts...)
+		return collector.GetDocIDs(), err
+	}, queries, opts...)
+	return err
+}
+
+func (m *MiddlewareBEIndex) RetrieveRich(queries Assignments, opts ...IndexOpt) (result RichDocIDList, err error) {
+	_, err = m.serve(func(queries Assignments, opts ...IndexOpt) (DocIDList, error) {
+		var e error
+		result, e = m.BEIndex.RetrieveRich(queries, opts...)
+		return result.ToDocIDs(), e
+	}, queries, opts...)
+	return result, err
+}
+
+func (m *MiddlewareBEIndex) RetrieveWithAnalysis(queries Assignments, opts ...IndexOpt) (result DocIDList, analysis RetrievalAnalysis, err error) {
+	result, err = m.serve(func(queries Assignments, opts ...IndexOpt) (DocIDList, error) {
+		var res DocIDList
+		var e error
+		res, analysis, e = m.BEIndex.RetrieveWithAnalysis(queries, opts...)
+		return res, e
+	}, queries, opts...)
+	return result, analysis, err
+}
+
+func (m *MiddlewareBEIndex) RetrieveScoredPage(queries Assignments, pageSize int, token string, opts ...IndexOpt) (page *ScoredPage, err error) {
+	_, err = m.serve(func(queries Assignments, opts ...IndexOpt) (DocIDList, error) {
+		var e error
+		if page, e = m.BEIndex.RetrieveScoredPage(queries, pageSize, token, opts...); page == nil {
+			return nil, e
+		}
+		docs := make(DocIDList, len(page.Docs))
+		for i, doc := range page.Docs {
+			docs[i] = doc.DocID
+		}
+		return docs, e
+	}, queries, opts...)
+	return page, err
+}
+
+func (m *MiddlewareBEIndex) RetrieveCount(queries Assignments, opts ...IndexOpt) (count int, err error) {
+	_, err = m.serve(func(queries Assignments, opts ...IndexOpt) (DocIDList, error) {
+		var e error
+		count, e = m.BEIndex.RetrieveCount(queries, opts...)
+		return nil, e
+	}, queries, opts...)
+	return count, err
+}
+
+func (m *MiddlewareBEIndex) RetrieveWithNearMiss(queries Assignments, opts ...IndexOpt) (result DocIDList, misses []NearMiss, err error) {
+	result, err = m.serve(func(queries Assignments, opts ...IndexOpt) (DocIDList, error) {
+		var res DocIDList
+		var e error
+		res, misses, e = m.BEIndex.RetrieveWithNearMiss(queries, opts...)
+		return res, e
+	}, queries, opts...)
+	return result, misses, err
+}
+
+// cloneIndex keep the middlewares on the clone, see AtomicIndexHandle.Compact
+func (m *MiddlewareBEIndex) cloneIndex() BEIndex {
+	return &MiddlewareBEIndex{
+		BEIndex:     m.BEIndex.cloneIndex(),
+		middlewares: m.middlewares,
+	}
+}
@@ -0,0 +1,83 @@
+package be_indexer
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestMiddlewareBEIndex(t *testing.T) {
+	LogLevel = ErrorLevel
+
+	b := NewIndexerBuilder()
+	for id := DocID(1); id <= 5; id++ {
+		doc := NewDocument(id)
+		doc.AddConjunction(NewConjunction().In("tag", NewIntValues(1)))
+		b.AddDocument(doc)
+	}
+	errDenied := errors.New("denied")
+
+	convey.Convey("test middlewares chained in order", t, func() {
+		for _, inner := range []BEIndex{b.BuildIndex(), b.BuildCompactedIndex()} {
+			var calls []string
+			logging := func(name string) IndexMiddleware {
+				return func(next RetrieveFunc) RetrieveFunc {
+					return func(queries Assignments, opts ...IndexOpt) (DocIDList, error) {
+						calls = append(calls, name+":pre")
+						res, err := next(queries, opts...)
+						calls = append(calls, name+":post")
+						return res, err
+					}
+				}
+			}
+			auth := func(next RetrieveFunc) RetrieveFunc {
+				return func(queries Assignments, opts ...IndexOpt) (DocIDList, error) {
+					if _, ok := queries["token"]; !ok {
+						return nil, errDenied
+					}
+					delete(queries, "token")
+					return next(queries, append(opts, WithMaxResults(2))...)
+				}
+			}
+			index := NewMiddlewareBEIndex(inner, logging("a"), logging("b"), auth)
+
+			ids, err := index.Retrieve(Assignments{"tag": NewIntValues(1), "token": NewStrValues("x")})
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(len(ids), convey.ShouldEqual, 2)
+			convey.So(calls, convey.ShouldResemble, []string{"a:pre", "b:pre", "b:post", "a:post"})
+
+			_, err = index.Retrieve(Assignments{"tag": NewIntValues(1)})
+			convey.So(err, convey.ShouldEqual, errDenied)
+
+			collector := NewDocIDCollector()
+			err = index.RetrieveWithCollector(Assignments{"tag": NewIntValues(1)}, collector)
+			convey.So(err, convey.ShouldEqual, errDenied)
+			convey.So(collector.GetDocIDs(), convey.ShouldBeEmpty)
+			err = index.RetrieveWithCollector(Assignments{"tag": NewIntValues(1), "token": Values{}}, collector)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(len(collector.GetDocIDs()), convey.ShouldEqual, 5) // collector arrange its own result
+
+			count, err := index.RetrieveCount(Assignments{"tag": NewIntValues(1), "token": Values{}})
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(count, convey.ShouldEqual, 2)
+
+			_, _, err = index.RetrieveWithAnalysis(Assignments{"tag": NewIntValues(1)})
+			convey.So(err, convey.ShouldEqual, errDenied)
+			ids, analysis, err := index.RetrieveWithAnalysis(Assignments{"tag": NewIntValues(1), "token": Values{}})
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(len(ids), convey.ShouldEqual, 2)
+			convey.So(analysis.PerFieldCursorAdvancements["tag"], convey.ShouldBeGreaterThan, 0)
+
+			// non-retrieving apis served by inner
+			convey.So(index.Stats(), convey.ShouldResemble, inner.Stats())
+
+			// middlewares kept when compacted through handle
+			handle := NewAtomicIndexHandle(index)
+			_, err = handle.Compact()
+			convey.So(err, convey.ShouldBeNil)
+			_, err = handle.Retrieve(Assignments{"tag": NewIntValues(1)})
+			convey.So(err, convey.ShouldEqual, errDenied)
+		}
+	})
+}
@@ -0,0 +1,65 @@
+package be_indexer
+
+import (
+	"errors"
+)
+
+type (
+	// specificHit the most specific conjunction matched a doc so far, see WithKeepMostSpecific
+	specificHit struct {
+		conj   ConjID
+		fields []BEField
+		qkeys  []QKey // see WithValueContribution
+	}
+)
+
+/*
+WithKeepMostSpecific attribute a doc matched by several conjunctions to the most specific one only:
+the largest size(K), ties broken by the smaller conjunction index, same as RetrieveRich. hits are
+held until scanning done and then delivered once per doc, so a AttributionCollector see only the
+fields of that conjunction, and WithValueContribution count only its values; the collector receive
+the hits at the end of retrieving instead of streaming, in order of the first hit of every doc.
+*/
+func WithKeepMostSpecific() IndexOpt {
+	return func(ctx *RetrieveContext) {
+		ctx.specificHits = make(map[DocID]*specificHit)
+	}
+}
+
+// holdMostSpecific keep the hit of id by conj if it's more specific than the one held
+func (ctx *RetrieveContext) holdMostSpecific(id DocID, conj ConjID, matched FieldScanners) {
+	held, ok := ctx.specificHits[id]
+	if ok && !moreSpecific(conj, held.conj) {
+		return
+	}
+	if !ok {
+		held = &specificHit{}
+		ctx.specificHits[id] = held
+		ctx.specificOrder = append(ctx.specificOrder, id)
+	}
+	held.conj = conj
+	held.fields = matched.fields() // scanners move on, take what's needed now
+	held.qkeys = ctx.matchedQValues(conj, matched)
+}
+
+// flushMostSpecific deliver the hits held to collector, must be deferred by retrieve; hits of a
+// retrieving failed are dropped, except the partial result of ErrBudgetExceeded
+func (ctx *RetrieveContext) flushMostSpecific(collector ResultCollector, err *error) {
+	if ctx.specificHits == nil || (*err != nil && !errors.Is(*err, ErrBudgetExceeded)) {
+		return
+	}
+	for _, id := range ctx.specificOrder {
+		held := ctx.specificHits[id]
+		if e := collect(ctx, collector, id, held.conj, func() []BEField { return held.fields }); e != nil {
+			if *err == nil {
+				*err = e
+			}
+			return
+		}
+		if ctx.analysis != nil && held.conj.Size() == 0 {
+			ctx.analysis.WildcardMatchCount++
+		}
+		ctx.contributeValues(id, held.qkeys)
+	}
+	ctx.chargeCollector(collector)
+}
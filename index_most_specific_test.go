@@ -0,0 +1,50 @@
+package be_indexer
+
+import (
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestWithKeepMostSpecific(t *testing.T) {
+	LogLevel = ErrorLevel
+
+	b := NewIndexerBuilder()
+	doc := NewDocument(1)
+	doc.AddConjunction(NewConjunction().In("tag", NewIntValues(2)))                                // K=1
+	doc.AddConjunction(NewConjunction().In("tag", NewIntValues(1)).In("city", NewStrValues("sh"))) // K=2
+	_ = b.AddDocument(doc)
+	doc = NewDocument(2)
+	doc.AddConjunction(NewConjunction().In("tag", NewIntValues(1)))
+	_ = b.AddDocument(doc)
+
+	query := Assignments{"tag": NewIntValues(1, 2), "city": NewStrValues("sh")}
+	convey.Convey("test only the attribution of highest K conjunction kept", t, func() {
+		for _, index := range []BEIndex{b.BuildIndex(), b.BuildCompactedIndex()} {
+			retrieve := func(opts ...IndexOpt) (map[DocID][][]BEField, map[QKey]int) {
+				collector := &attributionCollector{newDistinctCollector(), make(map[DocID][][]BEField)}
+				contribution := make(map[QKey]int)
+				opts = append(opts, WithFieldTieBreakOrder("tag", "city"), WithValueContribution(contribution))
+				convey.So(index.RetrieveWithCollector(query, collector, opts...), convey.ShouldBeNil)
+				convey.So(len(collector.GetDocIDs()), convey.ShouldEqual, 2)
+				return collector.attributes, contribution
+			}
+
+			attributes, contribution := retrieve()
+			convey.So(len(attributes[1]), convey.ShouldEqual, 2)
+			convey.So(contribution[QKey{Field: "tag", Value: "2"}], convey.ShouldEqual, 1)
+
+			attributes, contribution = retrieve(WithKeepMostSpecific())
+			convey.So(attributes[1], convey.ShouldResemble, [][]BEField{{"tag", "city"}})
+			convey.So(attributes[2], convey.ShouldResemble, [][]BEField{{"tag"}})
+			convey.So(contribution, convey.ShouldResemble, map[QKey]int{
+				{Field: "tag", Value: "1"}:   2,
+				{Field: "city", Value: "sh"}: 1,
+			})
+
+			result, err := index.Retrieve(query, WithKeepMostSpecific())
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(len(result), convey.ShouldEqual, 2)
+		}
+	})
+}
@@ -0,0 +1,72 @@
+package be_indexer
+
+import (
+	"fmt"
+)
+
+/*
+NamespacedField the field indexed for field of documents added in namespace ns, see
+IndexerBuilder.WithFieldNamespace; it has its own FieldDesc(parser and dictionary), so config it by
+ConfigField(NamespacedField(field, ns), option), or it got the default option. WithBitsetAssign and
+WithRawIDAssign take fields as they are, use it to assign a namespaced field with them.
+*/
+func NamespacedField(field BEField, ns string) BEField {
+	if ns == "" {
+		return field
+	}
+	return BEField(fmt.Sprintf("%s#ns[%s]", field, ns))
+}
+
+/*
+WithFieldNamespace documents added later(till next call) are in namespace ns, the fields of them are
+indexed as NamespacedField, so tenants co-hosted in one index never share a dictionary even with same
+field names; "" is the default namespace, fields as they are. a document is only matched by queries
+of its namespace(see WithQueryNamespace), size-0 conjunctions included.
+*/
+func (b *IndexerBuilder) WithFieldNamespace(ns string) {
+	b.namespace = ns
+}
+
+// recordNamespace remember the namespace doc added in, see WithFieldNamespace
+func (b *IndexerBuilder) recordNamespace(id DocID) {
+	if b.namespace == "" {
+		delete(b.docNamespaces, id)
+		return
+	}
+	if b.docNamespaces == nil {
+		b.docNamespaces = make(map[DocID]string)
+	}
+	b.docNamespaces[id] = b.namespace
+}
+
+// WithQueryNamespace retrieve in namespace ns: fields of Assignments resolved as NamespacedField,
+// and only docs added in ns are matched, see IndexerBuilder.WithFieldNamespace
+func WithQueryNamespace(ns string) IndexOpt {
+	return func(ctx *RetrieveContext) {
+		ctx.namespace = ns
+	}
+}
+
+// namespaceQueries queries with fields resolved in the namespace of ctx
+func (ctx *RetrieveContext) namespaceQueries(queries Assignments) Assignments {
+	if ctx.namespace == "" {
+		return queries
+	}
+	res := make(Assignments, len(queries))
+	for field, values := range queries {
+		res[NamespacedField(field, ctx.namespace)] = values
+	}
+	return res
+}
+
+func (bi *indexBase) appendDocNamespace(id DocID, ns string) {
+	if bi.docNamespaces == nil {
+		bi.docNamespaces = make(map[DocID]string)
+	}
+	bi.docNamespaces[id] = ns
+}
+
+// inNamespace whether doc added in the namespace of ctx
+func (ctx *RetrieveContext) inNamespace(id DocID) bool {
+	return ctx.docNamespaces[id] == ctx.namespace
+}
@@ -0,0 +1,101 @@
+package be_indexer
+
+import (
+	"testing"
+
+	"github.com/echoface/be_indexer/parser"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestIndexerBuilder_WithFieldNamespace(t *testing.T) {
+	LogLevel = ErrorLevel
+
+	b := NewIndexerBuilder()
+	b.ConfigField(NamespacedField("age", "b"), FieldOption{Parser: parser.NumRangeParser})
+
+	b.WithFieldNamespace("a")
+	doc := NewDocument(1)
+	doc.AddConjunction(NewConjunction().In("city", NewStrValues("sh", "bj")))
+	_ = b.AddDocument(doc)
+	doc = NewDocument(2)
+	doc.AddConjunction(NewConjunction().NotIn("city", NewStrValues("sh")))
+	_ = b.AddDocument(doc)
+
+	b.WithFieldNamespace("b")
+	doc = NewDocument(3)
+	doc.AddConjunction(NewConjunction().In("city", NewStrValues("sh")).In("age", NewStrValues("10:20")))
+	_ = b.AddDocument(doc)
+	doc = NewDocument(4)
+	doc.AddConjunction(NewConjunction().NotIn("city", NewStrValues("bj")))
+	_ = b.AddDocument(doc)
+
+	b.WithFieldNamespace("")
+	doc = NewDocument(5)
+	doc.AddConjunction(NewConjunction().In("city", NewStrValues("sh")))
+	_ = b.AddDocument(doc)
+
+	convey.Convey("test tenants with same field names don't see each other", t, func() {
+		for _, index := range []BEIndex{b.BuildIndex(), b.BuildCompactedIndex()} {
+			sh := Assignments{"city": NewStrValues("sh")}
+
+			result, err := index.Retrieve(sh, WithQueryNamespace("a"))
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(result, convey.ShouldResemble, DocIDList{1})
+
+			result, err = index.Retrieve(Assignments{"city": NewStrValues("bj")}, WithQueryNamespace("a"))
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(len(result), convey.ShouldEqual, 2)
+			convey.So(result, convey.ShouldContain, DocID(1))
+			convey.So(result, convey.ShouldContain, DocID(2))
+
+			result, err = index.Retrieve(Assignments{"city": NewStrValues("sh"), "age": NewIntValues(15)}, WithQueryNamespace("b"))
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(len(result), convey.ShouldEqual, 2)
+			convey.So(result, convey.ShouldContain, DocID(3))
+			convey.So(result, convey.ShouldContain, DocID(4))
+
+			result, err = index.Retrieve(Assignments{"city": NewStrValues("bj")}, WithQueryNamespace("b"))
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(result, convey.ShouldBeEmpty)
+
+			result, err = index.Retrieve(sh)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(result, convey.ShouldResemble, DocIDList{5})
+
+			result, err = index.Retrieve(sh, WithQueryNamespace("c"))
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(result, convey.ShouldBeEmpty)
+
+			result, err = index.Retrieve(sh, WithQueryNamespace("a"), WithComplementResults())
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(result, convey.ShouldResemble, DocIDList{2})
+
+			var fields []BEField
+			for _, desc := range index.FieldDescriptions() {
+				fields = append(fields, desc.Field)
+			}
+			convey.So(fields, convey.ShouldContain, NamespacedField("city", "a"))
+			convey.So(fields, convey.ShouldContain, NamespacedField("city", "b"))
+			convey.So(fields, convey.ShouldContain, BEField("city"))
+		}
+	})
+
+	convey.Convey("test document moved to another namespace by adding again", t, func() {
+		b.WithFieldNamespace("b")
+		doc := NewDocument(5)
+		doc.AddConjunction(NewConjunction().In("city", NewStrValues("sh")))
+		_ = b.AddDocument(doc)
+		b.WithFieldNamespace("")
+
+		index := b.BuildIndex()
+		result, err := index.Retrieve(Assignments{"city": NewStrValues("sh")})
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(result, convey.ShouldBeEmpty)
+
+		result, err = index.Retrieve(Assignments{"city": NewStrValues("sh")}, WithQueryNamespace("b"))
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(len(result), convey.ShouldEqual, 2)
+		convey.So(result, convey.ShouldContain, DocID(4))
+		convey.So(result, convey.ShouldContain, DocID(5))
+	})
+}
@@ -0,0 +1,165 @@
+package be_indexer
+
+import (
+	"errors"
+	"sort"
+)
+
+var (
+	// ErrNearMissNotRecorded RetrieveWithNearMiss on a index built without WithNearMissAnalysis
+	ErrNearMissNotRecorded = errors.New("near miss analysis not recorded")
+)
+
+type (
+	// NearMiss a conjunction of a unmatched doc missed by exactly one predicate, see RetrieveWithNearMiss
+	NearMiss struct {
+		DocID DocID   `json:"doc_id"`
+		Conj  ConjID  `json:"conj_id"`
+		Field BEField `json:"field"` // the failing field, synthetic sub-field(eg: "apps#all[1]") reported as is
+	}
+
+	// conjHits fields of a conjunction hit by query, split by include/exclude
+	conjHits struct {
+		include []uint64
+		exclude []uint64
+	}
+)
+
+func appendFieldOnce(fields []uint64, field uint64) []uint64 {
+	for _, f := range fields {
+		if f == field {
+			return fields
+		}
+	}
+	return append(fields, field)
+}
+
+/*
+WithNearMissAnalysis record the fields of In expressions of every conjunction when building, which
+RetrieveWithNearMiss need to name the failing field; it cost a slice per conjunction, so it's off by
+default and RetrieveWithNearMiss return ErrNearMissNotRecorded without it.
+*/
+func WithNearMissAnalysis() BuilderOpt {
+	return func(builder *IndexerBuilder) {
+		builder.settings.NearMissAnalysis = true
+	}
+}
+
+// appendConjFields record field ids of the In expressions of conjunction, see nearMisses
+func (bi *indexBase) appendConjFields(id ConjID, fields []uint64) {
+	if bi.conjFields == nil {
+		bi.conjFields = make(map[ConjID][]uint64)
+	}
+	bi.conjFields[id] = fields
+}
+
+/*
+nearMisses find conjunctions of docs not in matched which missed by exactly one predicate:
+  - K-1 of its K(>=2) In expressions hit and no NotIn hit, the failing field is the In one not hit
+  - all of its K(>=1) In expressions hit and exactly one NotIn field hit, the failing field is it
+
+a conjunction need at least one predicate matched, otherwise every K=1 conjunction would be a
+near-miss of any query; so K=0 conjunctions(no In expression) are never reported, even one
+excluded by a single NotIn field. it walk every posting list the query resolve to, it's a analysis tool,
+don't use it on a latency sensitive path.
+*/
+func (bi *indexBase) nearMisses(ctx *RetrieveContext, queries Assignments, matched DocIDList, postings ...*PostingEntries) ([]NearMiss, error) {
+	if !bi.nearMissAnalysis {
+		return nil, ErrNearMissNotRecorded
+	}
+	idAssigns, err := bi.parseQueries(ctx, queries, postings...)
+	if err != nil {
+		return nil, err
+	}
+	matchedDocs := make(map[DocID]struct{}, len(matched))
+	for _, doc := range matched {
+		matchedDocs[doc] = struct{}{}
+	}
+
+	hits := make(map[ConjID]*conjHits)
+	for field, ids := range idAssigns {
+		desc := bi.fieldDesc[field]
+		for _, id := range ids {
+			key := NewKey(desc.ID, id)
+			for _, pl := range postings {
+				for _, eid := range pl.getEntries(key) {
+					conj := eid.GetConjID()
+					if _, ok := matchedDocs[conj.DocID()]; ok {
+						continue
+					}
+					h, ok := hits[conj]
+					if !ok {
+						h = &conjHits{}
+						hits[conj] = h
+					}
+					if eid.IsInclude() {
+						h.include = appendFieldOnce(h.include, desc.ID)
+					} else {
+						h.exclude = appendFieldOnce(h.exclude, desc.ID)
+					}
+				}
+			}
+		}
+	}
+
+	var res []NearMiss
+	for conj, h := range hits {
+		k := conj.Size()
+		var failing uint64
+		switch {
+		case k >= 2 && len(h.include) == k-1 && len(h.exclude) == 0:
+			found := false
+		FIELDS:
+			for _, f := range bi.conjFields[conj] {
+				for _, hit := range h.include {
+					if f == hit {
+						continue FIELDS
+					}
+				}
+				failing, found = f, true
+				break
+			}
+			if !found {
+				continue
+			}
+		case k >= 1 && len(h.include) == k && len(h.exclude) == 1:
+			failing = h.exclude[0]
+		default:
+			continue
+		}
+		res = append(res, NearMiss{DocID: conj.DocID(), Conj: conj, Field: bi.getFieldFromID(failing)})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Conj.DocID() < res[j].Conj.DocID() ||
+			(res[i].Conj.DocID() == res[j].Conj.DocID() && res[i].Conj < res[j].Conj)
+	})
+	return res, nil
+}
+
+// RetrieveWithNearMiss retrieve matched docs along with the near-misses of unmatched docs, see nearMisses
+func (bi *SizeGroupedBEIndex) RetrieveWithNearMiss(queries Assignments, opts ...IndexOpt) (DocIDList, []NearMiss, error) {
+	result, err := bi.Retrieve(queries, opts...)
+	if err != nil && !IsFieldsDropped(err) {
+		return nil, nil, err
+	}
+	compileGroups(bi.sizeEntries...)
+	misses, missErr := bi.nearMisses(newRetrieveContext(opts...), queries, result, bi.sizeEntries...)
+	if missErr != nil {
+		return result, misses, missErr
+	}
+	return result, misses, err
+}
+
+// RetrieveWithNearMiss retrieve matched docs along with the near-misses of unmatched docs, see nearMisses
+func (bi *CompactedBEIndex) RetrieveWithNearMiss(queries Assignments, opts ...IndexOpt) (DocIDList, []NearMiss, error) {
+	result, err := bi.Retrieve(queries, opts...)
+	if err != nil && !IsFieldsDropped(err) {
+		return nil, nil, err
+	}
+	bi.postingList.compile()
+	misses, missErr := bi.nearMisses(newRetrieveContext(opts...), queries, result, bi.postingList)
+	if missErr != nil {
+		return result, misses, missErr
+	}
+	return result, misses, err
+}
@@ -0,0 +1,71 @@
+package be_indexer
+
+import (
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestRetrieveWithNearMiss(t *testing.T) {
+	LogLevel = ErrorLevel
+
+	b := NewIndexerBuilder(WithNearMissAnalysis())
+	doc := NewDocument(1) // matched
+	doc.AddConjunction(NewConjunction().In("age", NewIntValues(10)).In("city", NewStrValues("sh")))
+	b.AddDocument(doc)
+
+	doc = NewDocument(2) // city missed
+	doc.AddConjunction(NewConjunction().In("age", NewIntValues(10)).In("city", NewStrValues("bj")))
+	b.AddDocument(doc)
+
+	doc = NewDocument(3) // excluded by tag
+	doc.AddConjunction(NewConjunction().In("age", NewIntValues(10)).NotIn("tag", NewIntValues(5)))
+	b.AddDocument(doc)
+
+	doc = NewDocument(4) // age and city both missed
+	doc.AddConjunction(NewConjunction().In("age", NewIntValues(20)).In("city", NewStrValues("bj")))
+	b.AddDocument(doc)
+
+	doc = NewDocument(5) // age missed, K=3
+	doc.AddConjunction(NewConjunction().
+		In("age", NewIntValues(20)).
+		In("city", NewStrValues("sh")).
+		In("tag", NewIntValues(5)))
+	b.AddDocument(doc)
+
+	doc = NewDocument(6) // K=0 excluded by tag, never reported
+	doc.AddConjunction(NewConjunction().NotIn("tag", NewIntValues(5)))
+	b.AddDocument(doc)
+
+	queries := Assignments{
+		"age":  NewIntValues(10),
+		"city": NewStrValues("sh"),
+		"tag":  NewIntValues(5),
+	}
+	convey.Convey("test retrieve with near miss", t, func() {
+		for _, index := range []BEIndex{b.BuildIndex(), b.BuildCompactedIndex()} {
+			result, misses, err := index.RetrieveWithNearMiss(queries)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(result, convey.ShouldResemble, DocIDList{1})
+
+			convey.So(len(misses), convey.ShouldEqual, 3)
+			convey.So(misses[0].DocID, convey.ShouldEqual, 2)
+			convey.So(misses[0].Field, convey.ShouldEqual, BEField("city"))
+			convey.So(misses[1].DocID, convey.ShouldEqual, 3)
+			convey.So(misses[1].Field, convey.ShouldEqual, BEField("tag"))
+			convey.So(misses[2].DocID, convey.ShouldEqual, 5)
+			convey.So(misses[2].Field, convey.ShouldEqual, BEField("age"))
+
+			conjs, _ := index.DocConjunctions(2)
+			convey.So(misses[0].Conj, convey.ShouldEqual, conjs[0])
+		}
+	})
+	convey.Convey("test near miss not recorded by default", t, func() {
+		plain := NewIndexerBuilder()
+		plain.AddDocument(doc)
+		for _, index := range []BEIndex{plain.BuildIndex(), plain.BuildCompactedIndex()} {
+			_, _, err := index.RetrieveWithNearMiss(queries)
+			convey.So(err, convey.ShouldEqual, ErrNearMissNotRecorded)
+		}
+	})
+}
@@ -0,0 +1,299 @@
+package be_indexer
+
+import (
+	"errors"
+	"fmt"
+	"github.com/echoface/be_indexer/util"
+	"runtime/debug"
+	"sort"
+)
+
+var (
+	// ErrBudgetExceeded retrieving abort because of the budget(eg: cursor advancements) used up
+	ErrBudgetExceeded = errors.New("retrieve budget exceeded")
+
+	// ErrRetrievePanic retrieving panic and recovered, see WithRecover
+	ErrRetrievePanic = errors.New("retrieve panic")
+
+	// ErrNoPositivePredicate query can only match wildcard docs, see WithRequirePositive
+	ErrNoPositivePredicate = errors.New("query has no positive predicate")
+)
+
+type (
+	// IndexOpt option for a single retrieving
+	IndexOpt func(ctx *RetrieveContext)
+
+	// RetrieveTrace counters of a single retrieving, see WithRetrieveTrace
+	RetrieveTrace struct {
+		GroupsScanned      int       // posting list groups(K groups, or the single list of CompactedBEIndex) scanned
+		CursorAdvancements int64     // posting list cursor advancements, the coarse pass of two pass included
+		CursorsCreated     int       // posting list cursors created, the coarse pass of two pass excluded
+		EntriesCursored    int       // total length of posting lists cursors created on, see CostEstimate
+		DroppedFields      []BEField // fields dropped from query by parse failures, see WithBestEffortRetrieval
+		SkippedFields      []BEField // fields assigned but no cursor created, see FieldOption.RequiresField
+		MemoryUsed         int64     // approximate memory the retrieving drove, see WithRetrieveMemoryBudget
+	}
+)
+
+func newRetrieveContext(opts ...IndexOpt) *RetrieveContext {
+	ctx := &RetrieveContext{}
+	for _, fn := range opts {
+		fn(ctx)
+	}
+	return ctx
+}
+
+func (ctx *RetrieveContext) budgetExceeded() bool {
+	return (ctx.maxAdvancements > 0 && ctx.advancements >= ctx.maxAdvancements) || ctx.memoryExceeded()
+}
+
+func (ctx *RetrieveContext) newEntriesCursor(key Key, entries Entries) *EntriesCursor {
+	if ctx.trace != nil {
+		ctx.trace.CursorsCreated++
+		ctx.trace.EntriesCursored += len(entries)
+	}
+	ctx.chargeMemory(cursorBytes)
+	if ctx.binarySearchCursors {
+		return NewBinarySearchCursor(key, entries)
+	}
+	return NewEntriesCursor(key, entries)
+}
+
+// newFieldScanner create scanner of field with its tie-break rank, see WithFieldTieBreakOrder
+func (ctx *RetrieveContext) newFieldScanner(desc *FieldDesc, cursors ...*EntriesCursor) *FieldScanner {
+	ctx.chargeMemory(scannerBytes)
+	scanner := NewFieldScanner(cursors...)
+	scanner.field = desc.Field
+	scanner.rank = uint64(len(ctx.tieBreakOrder)) + desc.ID
+	if idx, ok := ctx.tieBreakOrder[desc.Field]; ok {
+		scanner.rank = uint64(idx)
+	}
+	return scanner
+}
+
+// arrangeResult apply result sort and limit, sort first then limit
+func (ctx *RetrieveContext) arrangeResult(result DocIDList) DocIDList {
+	if ctx.orderBySortKey {
+		return ctx.arrangeBySortKey(result)
+	}
+	if ctx.resultLess != nil {
+		sort.SliceStable(result, func(i, j int) bool {
+			return ctx.resultLess(result[i], result[j])
+		})
+	}
+	if ctx.maxResults > 0 && len(result) > ctx.maxResults {
+		result = result[:ctx.maxResults]
+	}
+	return result
+}
+
+// arrangeCount the count of arrangeResult, only limit take effect
+func (ctx *RetrieveContext) arrangeCount(count int) int {
+	if ctx.maxResults > 0 && count > ctx.maxResults {
+		return ctx.maxResults
+	}
+	return count
+}
+
+// recoverFromPanic must be deferred directly by retrieve, it turn a panic of collector into
+// ErrCollectorPanic, and other panics into ErrRetrievePanic when WithRecover
+func (ctx *RetrieveContext) recoverFromPanic(err *error) {
+	if !ctx.recoverPanic && !ctx.collecting {
+		return // not recovered, the panic keep its stack
+	}
+	if r := recover(); r != nil {
+		if ctx.collecting {
+			ctx.collecting = false
+			*err = &ErrCollectorPanic{Value: r}
+			return
+		}
+		Logger.Errorf("retrieve panic recovered:%v, stack:\n%s\n", r, debug.Stack())
+		*err = fmt.Errorf("%w: %v", ErrRetrievePanic, r)
+	}
+}
+
+func (ctx *RetrieveContext) traceGroupScanned() {
+	if ctx.trace != nil {
+		ctx.trace.GroupsScanned++
+	}
+}
+
+// finishTrace must be deferred, the counters are filled even the retrieving fail
+func (ctx *RetrieveContext) finishTrace() {
+	if ctx.trace != nil {
+		ctx.trace.CursorAdvancements = ctx.advancements
+		ctx.trace.MemoryUsed = ctx.scratchMemory + ctx.collectorMemory
+	}
+}
+
+// coarsePass run the first pass of two pass retrieving, collect candidates from coarseQueries
+func (ctx *RetrieveContext) coarsePass(retrieve func(*RetrieveContext, Assignments, ResultCollector) error) error {
+	coarseCtx := &RetrieveContext{
+		maxAdvancements:     ctx.maxAdvancements,
+		memoryBudget:        ctx.memoryBudget,
+		binarySearchCursors: ctx.binarySearchCursors,
+		namespace:           ctx.namespace,
+	}
+	collector := newDistinctCollector()
+	err := retrieve(coarseCtx, ctx.coarseQueries, collector)
+	ctx.advancements += coarseCtx.advancements
+	ctx.scratchMemory += coarseCtx.scratchMemory + coarseCtx.collectorMemory
+	if err != nil {
+		return err
+	}
+	ctx.candidates = collector.GetDocIDs()
+	if ctx.candidates == nil {
+		ctx.candidates = DocIDList{}
+	}
+	sort.Sort(ctx.candidates)
+	return nil
+}
+
+// filterCandidate return whether conj can be skipped by candidates filter, and if so the min
+// ConjID not less than conj whose doc is a candidate
+func (ctx *RetrieveContext) filterCandidate(conj ConjID) (skip bool, next ConjID) {
+	if ctx.candidates == nil {
+		return false, conj
+	}
+	doc := conj.DocID()
+	idx := sort.Search(len(ctx.candidates), func(i int) bool {
+		return ctx.candidates[i] >= doc
+	})
+	if idx < len(ctx.candidates) && ctx.candidates[idx] == doc {
+		return false, conj
+	}
+	// ConjID order by <size, index> prefix first, then doc
+	prefix := uint64(conj) >> 32
+	if idx < len(ctx.candidates) {
+		return true, ConjID(prefix<<32 | uint64(ctx.candidates[idx]))
+	}
+	if len(ctx.candidates) == 0 {
+		return true, NULLENTRY.GetConjID()
+	}
+	return true, ConjID((prefix+1)<<32 | uint64(ctx.candidates[0]))
+}
+
+// WithMaxCursorAdvancements limit the total count of posting list cursor advancements,
+// retrieving abort with ErrBudgetExceeded when the limit reached; limit <= 0 means no limit.
+// it protect the index from crafted queries with popular values in untrusted environments
+func WithMaxCursorAdvancements(limit int64) IndexOpt {
+	return func(ctx *RetrieveContext) {
+		ctx.maxAdvancements = limit
+	}
+}
+
+// WithPartialResultsOnBudgetExceed make Retrieve return the docs collected before
+// the abort together with ErrBudgetExceeded, instead of a nil result
+func WithPartialResultsOnBudgetExceed() IndexOpt {
+	return func(ctx *RetrieveContext) {
+		ctx.partialOnBudgetExceed = true
+	}
+}
+
+// WithResultSort sort the final result of Retrieve by caller's comparator(eg: by campaign bid);
+// it takes effect after all docs collected, and before WithMaxResults, so combined with
+// WithMaxResults(n) the result is the top n docs ordered by less, not the first n docs matched.
+// note: RetrieveWithCollector leave the result arrangement to the collector, this option is ignored
+func WithResultSort(less func(a, b DocID) bool) IndexOpt {
+	return func(ctx *RetrieveContext) {
+		ctx.resultLess = less
+	}
+}
+
+// WithMaxResults truncate the result of Retrieve to at most n docs, n <= 0 means no limit;
+// without WithResultSort, the first n collected docs are kept.
+// note: RetrieveWithCollector leave the result arrangement to the collector, this option is ignored
+func WithMaxResults(n int) IndexOpt {
+	return func(ctx *RetrieveContext) {
+		ctx.maxResults = n
+	}
+}
+
+// WithBitsetAssign assign field with all values set in bits(bit i means value i), it avoid
+// materializing a huge Values slice when the query come as a bitset over a known value space;
+// it's merged with the Values of same field in Assignments if any
+func WithBitsetAssign(field BEField, bits *util.Bitmap) IndexOpt {
+	return func(ctx *RetrieveContext) {
+		if ctx.bitsetAssigns == nil {
+			ctx.bitsetAssigns = make(map[BEField]*util.Bitmap)
+		}
+		ctx.bitsetAssigns[field] = bits
+	}
+}
+
+// WithRawIDAssign assign field with value ids tokenized outside(eg: by a IDAllocator from
+// parser.LoadDictionary), ids skip the field parser and merged with other assigns of same field
+func WithRawIDAssign(field BEField, ids []uint64) IndexOpt {
+	return func(ctx *RetrieveContext) {
+		if ctx.rawIDAssigns == nil {
+			ctx.rawIDAssigns = make(map[BEField][]uint64)
+		}
+		ctx.rawIDAssigns[field] = append(ctx.rawIDAssigns[field], ids...)
+	}
+}
+
+// WithTwoPassRetrieval retrieve in two pass: a coarse pass with coarseQueries collect candidates,
+// then the full query pass only evaluate conjunctions of candidates, skipping the others by cursors.
+// coarseQueries must be a relaxation of the full query, every doc matched by the full query must
+// be matched by it(eg: a superset of values for a field only used in In expressions), otherwise
+// docs get lost; it pays off when the coarse query is cheap and the candidates are few
+func WithTwoPassRetrieval(coarseQueries Assignments) IndexOpt {
+	return func(ctx *RetrieveContext) {
+		ctx.twoPass = true
+		ctx.coarseQueries = coarseQueries
+	}
+}
+
+// WithRecover recover panics in the retrieve path(eg: a faulty collector or parser) and return them
+// as ErrRetrievePanic with the stack logged, so a bad query can't crash the serving goroutine
+func WithRecover() IndexOpt {
+	return func(ctx *RetrieveContext) {
+		ctx.recoverPanic = true
+	}
+}
+
+/*
+WithRequirePositive reject query with ErrNoPositivePredicate when none of its assigned values
+hit a In expression of any indexed conjunction; such a query can only match wildcard docs(whose
+conjunctions have no In expression, eg: only NotIn), which usually means everyone minus the
+excluded, a accidental broad match. it's a check on the whole query, so a query passed the guard
+still match wildcard docs as usual.
+*/
+func WithRequirePositive() IndexOpt {
+	return func(ctx *RetrieveContext) {
+		ctx.requirePositive = true
+	}
+}
+
+// WithRetrieveTrace fill trace with the counters of retrieving, for diagnosing query cost
+func WithRetrieveTrace(trace *RetrieveTrace) IndexOpt {
+	return func(ctx *RetrieveContext) {
+		ctx.trace = trace
+	}
+}
+
+// WithBinarySearchCursors make all cursors advance by pure binary search(see NewBinarySearchCursor)
+// instead of the default binary-then-linear search, it pays off for long posting lists, for lists
+// shorter than LinearSearchLengthThreshold both are equal; run BenchmarkEntriesCursor_SkipTo to
+// find where it pays off on your machine and data.
+func WithBinarySearchCursors() IndexOpt {
+	return func(ctx *RetrieveContext) {
+		ctx.binarySearchCursors = true
+	}
+}
+
+/*
+WithFieldTieBreakOrder order the scanners of fields sitting on the same EntryID when merging,
+it decide the order fields advanced and reported to AttributionCollector; fields listed come
+first in the order given, the others follow by field id(the default order without this option).
+*/
+func WithFieldTieBreakOrder(fields ...BEField) IndexOpt {
+	return func(ctx *RetrieveContext) {
+		ctx.tieBreakOrder = make(map[BEField]int, len(fields))
+		for _, field := range fields {
+			if _, ok := ctx.tieBreakOrder[field]; !ok {
+				ctx.tieBreakOrder[field] = len(ctx.tieBreakOrder)
+			}
+		}
+	}
+}
@@ -0,0 +1,301 @@
+package be_indexer
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/echoface/be_indexer/parser"
+	"github.com/echoface/be_indexer/util"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func buildOptionTestIndexes(docCnt int) (*IndexerBuilder, []BEIndex) {
+	builder := NewIndexerBuilder()
+	for i := 1; i <= docCnt; i++ {
+		doc := NewDocument(DocID(i))
+		doc.AddConjunction(NewConjunction().In("tag", NewIntValues(i%2, 10)))
+		builder.AddDocument(doc)
+	}
+	return builder, []BEIndex{builder.BuildIndex(), builder.BuildCompactedIndex()}
+}
+
+func TestWithMaxCursorAdvancements(t *testing.T) {
+	LogLevel = ErrorLevel
+
+	_, indexes := buildOptionTestIndexes(100)
+	query := Assignments{"tag": NewIntValues(10)}
+
+	for _, index := range indexes {
+		convey.Convey("test cursor advancements budget", t, func() {
+			all, err := index.Retrieve(query)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(len(all), convey.ShouldEqual, 100)
+
+			ids, err := index.Retrieve(query, WithMaxCursorAdvancements(1000))
+			convey.So(err, convey.ShouldBeNil)
+			sort.Sort(ids)
+			sort.Sort(all)
+			convey.So(ids, convey.ShouldResemble, all)
+
+			ids, err = index.Retrieve(query, WithMaxCursorAdvancements(10))
+			convey.So(err, convey.ShouldEqual, ErrBudgetExceeded)
+			convey.So(ids, convey.ShouldBeNil)
+
+			ids, err = index.Retrieve(query, WithMaxCursorAdvancements(10), WithPartialResultsOnBudgetExceed())
+			convey.So(err, convey.ShouldEqual, ErrBudgetExceeded)
+			convey.So(len(ids), convey.ShouldBeGreaterThan, 0)
+			convey.So(len(ids), convey.ShouldBeLessThan, 100)
+			convey.So(all.Sub(ids), convey.ShouldHaveLength, 100-len(ids))
+
+			collector := NewDocIDCollector()
+			err = index.RetrieveWithCollector(query, collector, WithMaxCursorAdvancements(10))
+			convey.So(err, convey.ShouldEqual, ErrBudgetExceeded)
+			convey.So(collector.GetDocIDs(), convey.ShouldResemble, ids)
+		})
+	}
+}
+
+func TestWithResultSort(t *testing.T) {
+	LogLevel = ErrorLevel
+
+	_, indexes := buildOptionTestIndexes(20)
+	query := Assignments{"tag": NewIntValues(1)}
+	bids := make(map[DocID]int)
+	for i := 1; i <= 20; i++ {
+		bids[DocID(i)] = (i * 7) % 20
+	}
+	byBidDesc := func(a, b DocID) bool {
+		return bids[a] > bids[b]
+	}
+
+	for _, index := range indexes {
+		convey.Convey("test sort result by doc attribute", t, func() {
+			ids, err := index.Retrieve(query, WithResultSort(byBidDesc))
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(len(ids), convey.ShouldEqual, 10)
+			convey.So(sort.SliceIsSorted(ids, func(i, j int) bool {
+				return bids[ids[i]] > bids[ids[j]]
+			}), convey.ShouldBeTrue)
+
+			// sort then limit: the top 3 bid docs
+			top, err := index.Retrieve(query, WithMaxResults(3), WithResultSort(byBidDesc))
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(top, convey.ShouldResemble, ids[:3])
+
+			limited, err := index.Retrieve(query, WithMaxResults(3))
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(len(limited), convey.ShouldEqual, 3)
+		})
+	}
+}
+
+func TestWithBitsetAssign(t *testing.T) {
+	LogLevel = ErrorLevel
+
+	docs, queries := BuildTestDocumentAndQueries(1000, 100, true)
+	b := NewIndexerBuilder()
+	for _, doc := range docs {
+		b.AddDocument(doc.ToDocument())
+	}
+
+	toBitmap := func(values []int) *util.Bitmap {
+		bits := util.NewBitmap()
+		for _, v := range values {
+			bits.Set(uint64(v))
+		}
+		return bits
+	}
+
+	for _, index := range []BEIndex{b.BuildIndex(), b.BuildCompactedIndex()} {
+		convey.Convey("test bitset assign retrieve same as values", t, func() {
+			for _, q := range queries {
+				expect, err := index.Retrieve(q.ToAssigns())
+				convey.So(err, convey.ShouldBeNil)
+
+				// A and C as bitset, B and D as values
+				assigns := Assignments{}
+				if len(q.B) > 0 {
+					assigns["B"] = NewIntValues(q.B...)
+				}
+				if len(q.D) > 0 {
+					assigns["D"] = NewIntValues(q.D...)
+				}
+				ids, err := index.Retrieve(assigns,
+					WithBitsetAssign("A", toBitmap(q.A)),
+					WithBitsetAssign("C", toBitmap(q.C)))
+				convey.So(err, convey.ShouldBeNil)
+
+				sort.Sort(ids)
+				sort.Sort(expect)
+				convey.So(ids, convey.ShouldResemble, expect)
+			}
+		})
+	}
+}
+
+func TestWithBinarySearchCursors(t *testing.T) {
+	LogLevel = ErrorLevel
+
+	docs, queries := BuildTestDocumentAndQueries(1000, 100, true)
+	b := NewIndexerBuilder()
+	for _, doc := range docs {
+		b.AddDocument(doc.ToDocument())
+	}
+
+	for _, index := range []BEIndex{b.BuildIndex(), b.BuildCompactedIndex()} {
+		convey.Convey("test binary search cursors retrieve same result", t, func() {
+			for _, q := range queries {
+				expect, err := index.Retrieve(q.ToAssigns())
+				convey.So(err, convey.ShouldBeNil)
+				ids, err := index.Retrieve(q.ToAssigns(), WithBinarySearchCursors())
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(ids, convey.ShouldResemble, expect)
+			}
+		})
+	}
+}
+
+func TestWithTwoPassRetrieval(t *testing.T) {
+	LogLevel = ErrorLevel
+
+	rd := rand.New(rand.NewSource(1))
+	builder := NewIndexerBuilder()
+	for i := 1; i <= 500; i++ {
+		doc := NewDocument(DocID(i))
+		for c := 0; c < 1+rd.Intn(3); c++ {
+			conj := NewConjunction()
+			if rd.Intn(4) > 0 {
+				conj.In("tag", NewIntValues(rd.Intn(20), rd.Intn(20)))
+			}
+			if rd.Intn(2) > 0 {
+				conj.In("os", NewIntValues(rd.Intn(3)))
+			}
+			if rd.Intn(2) > 0 {
+				conj.NotIn("city", NewIntValues(rd.Intn(5)))
+			}
+			if len(conj.Expressions) == 0 {
+				conj.In("tag", NewIntValues(rd.Intn(20)))
+			}
+			doc.AddConjunction(conj)
+		}
+		builder.AddDocument(doc)
+	}
+
+	for _, index := range []BEIndex{builder.BuildIndex(), builder.BuildCompactedIndex()} {
+		convey.Convey("test two pass retrieval same as single pass", t, func() {
+			for i := 0; i < 100; i++ {
+				tag, os, city := rd.Intn(20), rd.Intn(3), rd.Intn(5)
+				query := Assignments{"tag": NewIntValues(tag), "os": NewIntValues(os), "city": NewIntValues(city)}
+				// relaxation: more values for "tag"(only used in In expressions)
+				coarse := Assignments{"tag": NewIntValues(tag, (tag+1)%20), "os": NewIntValues(os), "city": NewIntValues(city)}
+
+				expect, err := index.Retrieve(query)
+				convey.So(err, convey.ShouldBeNil)
+				ids, err := index.Retrieve(query, WithTwoPassRetrieval(coarse))
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(len(ids), convey.ShouldEqual, len(expect))
+				convey.So(ids.Sub(expect), convey.ShouldBeEmpty)
+			}
+
+			// not a relaxation: only docs matched by both passes returned
+			query, coarse := Assignments{"tag": NewIntValues(1)}, Assignments{"tag": NewIntValues(2)}
+			full, _ := index.Retrieve(query)
+			candidates, _ := index.Retrieve(coarse)
+			ids, err := index.Retrieve(query, WithTwoPassRetrieval(coarse))
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(len(ids), convey.ShouldBeLessThan, len(full))
+			convey.So(ids.Sub(full), convey.ShouldBeEmpty)
+			convey.So(ids.Sub(candidates), convey.ShouldBeEmpty)
+		})
+	}
+}
+
+const (
+	panicParser = "test_panic_parser"
+)
+
+// panicAssignParser panic when parsing a query assign
+type panicAssignParser struct {
+	parser.FieldValueParser
+}
+
+func (p *panicAssignParser) ParseAssign(v interface{}) ([]uint64, error) {
+	panic(fmt.Errorf("faulty parser, value:%v", v))
+}
+
+func init() {
+	parser.RegisterBuilder(panicParser, func(alloc parser.IDAllocator) parser.FieldValueParser {
+		return &panicAssignParser{parser.NewCommonStrParser(alloc)}
+	})
+}
+
+func TestWithRecover(t *testing.T) {
+	LogLevel = ErrorLevel + 1 // recovered stack logged at error level
+
+	builder, _ := buildOptionTestIndexes(10)
+	builder.SetFieldParser("tag", panicParser)
+	query := Assignments{"tag": NewIntValues(10)}
+	for _, index := range []BEIndex{builder.BuildIndex(), builder.BuildCompactedIndex()} {
+		convey.Convey("test panic in retrieve recovered as error", t, func() {
+			convey.So(func() { _, _ = index.Retrieve(query) }, convey.ShouldPanic)
+
+			ids, err := index.Retrieve(query, WithRecover())
+			convey.So(ids, convey.ShouldBeNil)
+			convey.So(errors.Is(err, ErrRetrievePanic), convey.ShouldBeTrue)
+			convey.So(err.Error(), convey.ShouldContainSubstring, "faulty parser")
+
+			err = index.RetrieveWithCollector(query, NewDocIDCollector(), WithRecover())
+			convey.So(errors.Is(err, ErrRetrievePanic), convey.ShouldBeTrue)
+
+			ids, err = index.Retrieve(Assignments{"other": NewIntValues(10)}, WithRecover())
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(ids, convey.ShouldBeEmpty)
+		})
+	}
+	LogLevel = ErrorLevel
+}
+
+func TestWithRequirePositive(t *testing.T) {
+	LogLevel = ErrorLevel
+
+	b := NewIndexerBuilder()
+	doc := NewDocument(1)
+	doc.AddConjunction(NewConjunction().NotIn("country", NewStrValues("US")))
+	b.AddDocument(doc)
+	doc = NewDocument(2)
+	doc.AddConjunction(NewConjunction().In("country", NewStrValues("CN")))
+	b.AddDocument(doc)
+	doc = NewDocument(3)
+	doc.AddConjunction(NewConjunction().NotIn("country", NewStrValues("JP")))
+	b.AddDocument(doc)
+
+	convey.Convey("test exclusion only query with and without guard", t, func() {
+		for _, index := range []BEIndex{b.BuildIndex(), b.BuildCompactedIndex()} {
+			// "US" only hit the NotIn of doc 1, the wildcard doc 3 match it
+			ids, err := index.Retrieve(Assignments{"country": NewStrValues("US")})
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(ids, convey.ShouldResemble, DocIDList{3})
+
+			ids, err = index.Retrieve(Assignments{"country": NewStrValues("US")}, WithRequirePositive())
+			convey.So(err, convey.ShouldEqual, ErrNoPositivePredicate)
+			convey.So(ids, convey.ShouldBeNil)
+			_, err = index.Retrieve(Assignments{}, WithRequirePositive())
+			convey.So(err, convey.ShouldEqual, ErrNoPositivePredicate)
+			_, err = index.Retrieve(Assignments{"unknown": NewStrValues("US")}, WithRequirePositive())
+			convey.So(err, convey.ShouldEqual, ErrNoPositivePredicate)
+
+			// query passed the guard still match wildcard docs
+			ids, err = index.Retrieve(Assignments{"country": NewStrValues("CN")}, WithRequirePositive())
+			convey.So(err, convey.ShouldBeNil)
+			sort.Sort(ids)
+			convey.So(ids, convey.ShouldResemble, DocIDList{1, 2, 3})
+
+			collector := NewDocIDCollector()
+			err = index.RetrieveWithCollector(Assignments{"country": NewStrValues("JP")}, collector, WithRequirePositive())
+			convey.So(err, convey.ShouldEqual, ErrNoPositivePredicate)
+		}
+	})
+}
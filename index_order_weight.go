@@ -0,0 +1,78 @@
+package be_indexer
+
+type (
+	// matchWeight the rank of a conjunction matched a doc, see weightedOrderCollector
+	matchWeight struct {
+		size   int
+		weight float64
+	}
+
+	/*weightedOrderCollector the collector of Retrieve when any field has FieldOption.OrderWeight, a doc
+	ranked by the best conjunction matched it: larger size(K) first, then larger sum of OrderWeight of
+	the fields matched(synthetic sub-fields of InAll each count the weight of field), ties fall back
+	to DocID ascending; WithResultSort or WithOrderBySortKey override it.
+	*/
+	weightedOrderCollector struct {
+		*DocIDCollector
+		bi      *indexBase
+		best    map[DocID]matchWeight
+		weights []float64 // scratch of Attribute
+	}
+)
+
+func (w matchWeight) less(o matchWeight) bool {
+	return w.size < o.size || (w.size == o.size && w.weight < o.weight)
+}
+
+// newDefaultCollector the collector of Retrieve, docs ordered by field weights if any configured
+// and no other order required
+func (bi *indexBase) newDefaultCollector(ctx *RetrieveContext) ResultCollector {
+	if !bi.orderWeighted || ctx.resultLess != nil || ctx.orderBySortKey || ctx.complement {
+		return NewDocIDCollector()
+	}
+	collector := &weightedOrderCollector{
+		DocIDCollector: NewDocIDCollector(),
+		bi:             bi,
+		best:           make(map[DocID]matchWeight, 128),
+	}
+	ctx.resultLess = collector.less
+	return collector
+}
+
+func (c *weightedOrderCollector) Attribute(id DocID, conj ConjID, fields []BEField) {
+	w := matchWeight{size: conj.Size()}
+	// summed in a fixed(ascending) order, so the weight of a doc is bit-identical across retrievals,
+	// see ScoredPage; fields are few, insertion sort them into the scratch without allocating
+	weights := c.weights[:0]
+	for _, field := range fields {
+		desc, ok := c.bi.fieldDesc[field]
+		if !ok {
+			continue
+		}
+		weights = append(weights, desc.option.OrderWeight)
+		for i := len(weights) - 1; i > 0 && weights[i] < weights[i-1]; i-- {
+			weights[i], weights[i-1] = weights[i-1], weights[i]
+		}
+	}
+	for _, weight := range weights {
+		w.weight += weight
+	}
+	c.weights = weights
+	if best, ok := c.best[id]; !ok || best.less(w) {
+		c.best[id] = w
+	}
+}
+
+// less whether doc a ordered before b
+func (c *weightedOrderCollector) less(a, b DocID) bool {
+	wa, wb := c.best[a], c.best[b]
+	if wa != wb {
+		return wb.less(wa)
+	}
+	return a < b
+}
+
+func (c *weightedOrderCollector) Reset() {
+	c.DocIDCollector.Reset()
+	c.best = make(map[DocID]matchWeight, len(c.best))
+}
@@ -0,0 +1,63 @@
+package be_indexer
+
+import (
+	"sort"
+)
+
+type (
+	// OrphanEntry a posting list entry of a doc not in index, see BEIndex.FindOrphanEntries
+	OrphanEntry struct {
+		Field       BEField `json:"field"`
+		TokenID     uint64  `json:"token_id"` // value id of the posting list key, see Key.GetValueID
+		EntryID     EntryID `json:"entry_id"`
+		OrphanDocID DocID   `json:"orphan_doc_id"`
+	}
+)
+
+/*
+findOrphanEntries scan posting lists of lists and the wildcard list(key wildcardKey) for entries whose
+doc has no conjunction indexed(see DocConjunctions), sorted by field, token and entry; it's a full
+scan for integrity verification(eg: after a migration), not for serving path. lists must be
+compiled(see WithLazyCompile) before scanned.
+*/
+func (bi *indexBase) findOrphanEntries(wildcardKey Key, wildcards Entries, lists ...*PostingEntries) []OrphanEntry {
+	var res []OrphanEntry
+	check := func(key Key, entries Entries) {
+		for _, eid := range entries {
+			doc := eid.GetConjID().DocID()
+			if _, ok := bi.docConjs[doc]; ok {
+				continue
+			}
+			res = append(res, OrphanEntry{
+				Field:       bi.getFieldFromID(key.GetFieldID()),
+				TokenID:     key.GetValueID(),
+				EntryID:     eid,
+				OrphanDocID: doc,
+			})
+		}
+	}
+	check(wildcardKey, wildcards)
+	for _, pl := range lists {
+		pl.eachList(check)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Field != res[j].Field {
+			return res[i].Field < res[j].Field
+		}
+		if res[i].TokenID != res[j].TokenID {
+			return res[i].TokenID < res[j].TokenID
+		}
+		return res[i].EntryID < res[j].EntryID
+	})
+	return res
+}
+
+func (bi *SizeGroupedBEIndex) FindOrphanEntries() []OrphanEntry {
+	compileGroups(bi.sizeEntries...)
+	return bi.findOrphanEntries(bi.wildcardKey, bi.wildcardEntries.load(), bi.sizeEntries...)
+}
+
+func (bi *CompactedBEIndex) FindOrphanEntries() []OrphanEntry {
+	bi.postingList.compile()
+	return bi.findOrphanEntries(bi.wildcardKey, bi.wildcardEntries.load(), bi.postingList)
+}
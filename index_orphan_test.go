@@ -0,0 +1,62 @@
+package be_indexer
+
+import (
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestBEIndex_FindOrphanEntries(t *testing.T) {
+	LogLevel = ErrorLevel
+
+	b := NewIndexerBuilder()
+	for id := 1; id <= 20; id++ {
+		doc := NewDocument(DocID(id))
+		doc.AddConjunction(NewConjunction().In("tag", NewIntValues(id%3)).NotIn("city", NewStrValues("bj")))
+		if id%5 == 0 {
+			doc.AddConjunction(NewConjunction().NotIn("city", NewStrValues("sh")))
+		}
+		_ = b.AddDocument(doc)
+	}
+
+	convey.Convey("test entries of docs missing from index reported", t, func() {
+		sizeGrouped, compacted := b.BuildIndex().(*SizeGroupedBEIndex), b.BuildCompactedIndex().(*CompactedBEIndex)
+		for _, index := range []BEIndex{sizeGrouped, compacted, NewAtomicIndexHandle(sizeGrouped)} {
+			convey.So(index.FindOrphanEntries(), convey.ShouldBeEmpty)
+		}
+
+		// a migration lost doc 5 and doc 7 but not their entries
+		for _, docConjs := range []map[DocID][]ConjID{sizeGrouped.docConjs, compacted.docConjs} {
+			delete(docConjs, 5)
+			delete(docConjs, 7)
+		}
+		for _, index := range []BEIndex{sizeGrouped, compacted} {
+			orphans := index.FindOrphanEntries()
+			convey.So(len(orphans), convey.ShouldEqual, 6) // tag and city of both, wildcard and city of doc 5
+			fields := map[BEField]int{}
+			for _, orphan := range orphans {
+				convey.So(orphan.OrphanDocID, convey.ShouldBeIn, DocID(5), DocID(7))
+				convey.So(orphan.EntryID.GetConjID().DocID(), convey.ShouldEqual, orphan.OrphanDocID)
+				fields[orphan.Field]++
+			}
+			convey.So(fields, convey.ShouldResemble, map[BEField]int{wildcardField: 1, "tag": 2, "city": 3})
+		}
+	})
+	convey.Convey("test lazily compiled index compiled before scanned", t, func() {
+		for _, opt := range []BuilderOpt{WithLazyCompile(), WithBackgroundCompile()} {
+			lazy := NewIndexerBuilder(opt)
+			for id, doc := range b.Documents {
+				if id != 5 {
+					_ = lazy.AddDocument(doc)
+				}
+			}
+			index := lazy.BuildIndex().(*SizeGroupedBEIndex)
+			delete(index.docConjs, 7)
+			orphans := index.FindOrphanEntries()
+			convey.So(len(orphans), convey.ShouldEqual, 2) // tag and city of doc 7
+			for _, kse := range index.sizeEntries {
+				convey.So(kse.compiled(), convey.ShouldBeTrue)
+			}
+		}
+	})
+}
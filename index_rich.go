@@ -0,0 +1,67 @@
+package be_indexer
+
+type (
+	/*RichDocID a doc retrieved with the most specific conjunction matched it: the size of the largest
+	conjunction matched(0 for wildcard conjunctions), a quality signal that a size-5 match is more
+	specific than a size-1 one; and its index in Document.Cons, so for a doc whose conjunctions form a
+	specificity hierarchy(a waterfall of rules) it selects the rule applied. conjunctions of same size
+	both matched, the one added first selected.
+	*/
+	RichDocID struct {
+		DocID            DocID `json:"doc_id"`
+		MatchedConjSize  int   `json:"matched_conj_size"`
+		MatchedConjIndex int   `json:"matched_conj_index"`
+	}
+
+	RichDocIDList []RichDocID
+)
+
+// ToDocIDs the DocIDs in order, comparable with the result of Retrieve
+func (s RichDocIDList) ToDocIDs() DocIDList {
+	if s == nil {
+		return nil
+	}
+	ids := make(DocIDList, len(s))
+	for i, rich := range s {
+		ids[i] = rich.DocID
+	}
+	return ids
+}
+
+// arrangeRich apply result sort and limit(see arrangeResult) to the docs collected
+func (ctx *RetrieveContext) arrangeRich(collector *richCollector) RichDocIDList {
+	docs := ctx.arrangeResult(collector.GetDocIDs())
+	if docs == nil {
+		return nil
+	}
+	result := make(RichDocIDList, len(docs))
+	for i, id := range docs {
+		best := collector.best[id]
+		result[i] = RichDocID{DocID: id, MatchedConjSize: best.Size(), MatchedConjIndex: best.Index()}
+	}
+	return result
+}
+
+func (bi *SizeGroupedBEIndex) RetrieveRich(queries Assignments, opts ...IndexOpt) (RichDocIDList, error) {
+	ctx := newRetrieveContext(opts...)
+	collector := newRichCollector()
+	if err := bi.retrieve(ctx, queries, collector); err != nil {
+		if ctx.partialResult(err) {
+			return ctx.arrangeRich(collector), err
+		}
+		return nil, err
+	}
+	return ctx.arrangeRich(collector), nil
+}
+
+func (bi *CompactedBEIndex) RetrieveRich(queries Assignments, opts ...IndexOpt) (RichDocIDList, error) {
+	ctx := newRetrieveContext(opts...)
+	collector := newRichCollector()
+	if err := bi.retrieve(ctx, queries, collector); err != nil {
+		if ctx.partialResult(err) {
+			return ctx.arrangeRich(collector), err
+		}
+		return nil, err
+	}
+	return ctx.arrangeRich(collector), nil
+}
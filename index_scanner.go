@@ -2,6 +2,7 @@ package be_indexer
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 )
 
@@ -24,6 +25,9 @@ type (
 		key     Key
 		cursor  int // current cur cursor
 		entries Entries
+		inline  inlineEntries // storage of entries for a inlined posting list, see newInlineCursor
+
+		sortSearch bool // advance by BinarySkip/BinarySkipTo, see WithBinarySearchCursors
 	}
 	CursorGroup []*EntriesCursor
 
@@ -33,6 +37,9 @@ type (
 	FieldScanner struct {
 		current     *EntriesCursor
 		cursorGroup CursorGroup
+
+		field BEField // the field scanned, reported to AttributionCollector
+		rank  uint64  // order among scanners sitting on the same EntryID, see WithFieldTieBreakOrder
 	}
 	FieldScanners []*FieldScanner
 )
@@ -52,6 +59,35 @@ func (sc *EntriesCursor) GetCurEntryID() EntryID {
 	return sc.entries[sc.cursor]
 }
 
+// NewBinarySearchCursor create a cursor advancing by pure binary search(sort.Search) on
+// the rest entries, no linear scan for short range; it's faster for long posting lists with sparse hits
+func NewBinarySearchCursor(key Key, entries Entries) *EntriesCursor {
+	return &EntriesCursor{
+		key:        key,
+		cursor:     0,
+		entries:    entries,
+		sortSearch: true,
+	}
+}
+
+// BinarySkip move cursor to the first entry > id by sort.Search
+func (sc *EntriesCursor) BinarySkip(id EntryID) EntryID {
+	rest := sc.entries[sc.cursor:]
+	sc.cursor += sort.Search(len(rest), func(i int) bool {
+		return rest[i] > id
+	})
+	return sc.GetCurEntryID()
+}
+
+// BinarySkipTo move cursor to the first entry >= id by sort.Search
+func (sc *EntriesCursor) BinarySkipTo(id EntryID) EntryID {
+	rest := sc.entries[sc.cursor:]
+	sc.cursor += sort.Search(len(rest), func(i int) bool {
+		return rest[i] >= id
+	})
+	return sc.GetCurEntryID()
+}
+
 func (sc *EntriesCursor) LinearSkip(id EntryID) EntryID {
 	entry := sc.GetCurEntryID()
 	if entry > id {
@@ -69,6 +105,9 @@ func (sc *EntriesCursor) Skip(id EntryID) EntryID {
 	if entry > id {
 		return entry
 	}
+	if sc.sortSearch {
+		return sc.BinarySkip(id)
+	}
 
 	//according generated asm code, for a reference slice, len() have overhead
 	size := len(sc.entries)
@@ -109,6 +148,9 @@ func (sc *EntriesCursor) SkipTo(id EntryID) EntryID {
 	if entry >= id {
 		return entry
 	}
+	if sc.sortSearch {
+		return sc.BinarySkipTo(id)
+	}
 
 	//according generated asm code, for a reference slice, len() have overhead
 	size := len(sc.entries)
@@ -136,7 +178,23 @@ func (sc *EntriesCursor) SkipTo(id EntryID) EntryID {
 func (s FieldScanners) Len() int      { return len(s) }
 func (s FieldScanners) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
 func (s FieldScanners) Less(i, j int) bool {
-	return s[i].GetCurEntryID() < s[j].GetCurEntryID()
+	return scannerLess(s[i], s[j])
+}
+
+// scannerLess order scanners by current EntryID, then by rank for the same EntryID, so the order
+// of scanners(and the fields attributed) never depend on the order they were created in
+func scannerLess(a, b *FieldScanner) bool {
+	ea, eb := a.GetCurEntryID(), b.GetCurEntryID()
+	return ea < eb || (ea == eb && a.rank < b.rank)
+}
+
+// fields the fields of scanners in order
+func (s FieldScanners) fields() []BEField {
+	fields := make([]BEField, len(s))
+	for i, scanner := range s {
+		fields[i] = scanner.field
+	}
+	return fields
 }
 
 // Sort golang's internal sort.Sort method have a obvious overhead in performance.(runtime convTSlice)
@@ -150,13 +208,13 @@ func (s FieldScanners) Sort() {
 	// It could be written in this simplified form cause b-a <= 12
 	if x <= 12 { // make it seems sorted
 		for i := 6; i < x; i++ {
-			if s[i].GetCurEntryID() < s[i-6].GetCurEntryID() {
+			if scannerLess(s[i], s[i-6]) {
 				s[i], s[i-6] = s[i-6], s[i]
 			}
 		}
 	}
 	for i := 1; i < x; i++ {
-		for j := i; j > 0 && s[j].GetCurEntryID() < s[j-1].GetCurEntryID(); j-- {
+		for j := i; j > 0 && scannerLess(s[j], s[j-1]); j-- {
 			s[j], s[j-1] = s[j-1], s[j]
 		}
 	}
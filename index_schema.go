@@ -0,0 +1,50 @@
+package be_indexer
+
+import (
+	"fmt"
+)
+
+type (
+	// ErrSchemaMismatch the schema version of index is not the one query built for, see WithExpectedSchema
+	ErrSchemaMismatch struct {
+		Expected string
+		Actual   string
+	}
+)
+
+func (e *ErrSchemaMismatch) Error() string {
+	return fmt.Sprintf("schema mismatch, query expect:%q, index:%q", e.Expected, e.Actual)
+}
+
+/*
+WithSchemaVersion stamp an application defined schema version(eg: "v3", a hash of field names)
+onto the index built, exposed by BEIndex.SchemaVersion, IndexStatistics and Manifest; query code
+deployed independently check it by WithExpectedSchema, instead of silently matching nothing with a
+field renamed.
+*/
+func WithSchemaVersion(v string) BuilderOpt {
+	return func(builder *IndexerBuilder) {
+		builder.settings.SchemaVersion = v
+	}
+}
+
+// WithExpectedSchema fail retrieving with *ErrSchemaMismatch before any work if the schema version
+// of index is not v, see WithSchemaVersion
+func WithExpectedSchema(v string) IndexOpt {
+	return func(ctx *RetrieveContext) {
+		ctx.expectedSchema = &v
+	}
+}
+
+// SchemaVersion the schema version index built with, "" if not stamped
+func (bi *indexBase) SchemaVersion() string {
+	return bi.schemaVersion
+}
+
+// checkSchema the error of WithExpectedSchema, nil if not expected or matched
+func (bi *indexBase) checkSchema(ctx *RetrieveContext) error {
+	if ctx.expectedSchema == nil || *ctx.expectedSchema == bi.schemaVersion {
+		return nil
+	}
+	return &ErrSchemaMismatch{Expected: *ctx.expectedSchema, Actual: bi.schemaVersion}
+}
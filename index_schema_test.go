@@ -0,0 +1,68 @@
+package be_indexer
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestIndexerBuilder_WithSchemaVersion(t *testing.T) {
+	LogLevel = ErrorLevel
+
+	newBuilder := func(opts ...BuilderOpt) *IndexerBuilder {
+		b := NewIndexerBuilder(append(opts, WithValueReverseLookup())...)
+		doc := NewDocument(1)
+		doc.AddConjunction(NewConjunction().In("city", NewStrValues("sh")))
+		_ = b.AddDocument(doc)
+		return b
+	}
+	query := Assignments{"city": NewStrValues("sh")}
+
+	convey.Convey("test query fail fast on schema mismatch", t, func() {
+		b := newBuilder(WithSchemaVersion("v2"))
+		index := b.BuildIndex()
+		for _, index := range []BEIndex{index, b.BuildCompactedIndex(), NewAtomicIndexHandle(index)} {
+			convey.So(index.SchemaVersion(), convey.ShouldEqual, "v2")
+
+			result, err := index.Retrieve(query, WithExpectedSchema("v2"))
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(result, convey.ShouldResemble, DocIDList{1})
+
+			result, err = index.Retrieve(query, WithExpectedSchema("v1"))
+			var mismatch *ErrSchemaMismatch
+			convey.So(errors.As(err, &mismatch), convey.ShouldBeTrue)
+			convey.So(*mismatch, convey.ShouldResemble, ErrSchemaMismatch{Expected: "v1", Actual: "v2"})
+			convey.So(result, convey.ShouldBeEmpty)
+
+			_, err = index.RetrieveRich(query, WithExpectedSchema(""))
+			convey.So(errors.As(err, &mismatch), convey.ShouldBeTrue)
+
+			result, err = index.Retrieve(query) // not checked
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(result, convey.ShouldResemble, DocIDList{1})
+		}
+
+		unversioned := newBuilder().BuildIndex()
+		convey.So(unversioned.SchemaVersion(), convey.ShouldEqual, "")
+		_, err := unversioned.Retrieve(query, WithExpectedSchema(""))
+		convey.So(err, convey.ShouldBeNil)
+	})
+
+	convey.Convey("test schema version carried by stats and manifest", t, func() {
+		index, manifest, err := newBuilder(WithSchemaVersion("v2")).BuildIndexWithManifest()
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(index.Stats().SchemaVersion, convey.ShouldEqual, "v2")
+
+		data, err := json.Marshal(manifest)
+		convey.So(err, convey.ShouldBeNil)
+		var loaded Manifest
+		convey.So(json.Unmarshal(data, &loaded), convey.ShouldBeNil)
+		convey.So(loaded.SchemaVersion, convey.ShouldEqual, "v2")
+		convey.So(VerifyManifest(index, loaded), convey.ShouldBeNil)
+
+		err = VerifyManifest(newBuilder(WithSchemaVersion("v3")).BuildIndex(), loaded)
+		convey.So(errors.Is(err, ErrManifestMismatch), convey.ShouldBeTrue)
+	})
+}
@@ -0,0 +1,133 @@
+package be_indexer
+
+import (
+	"container/heap"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type (
+	// ScoredDocID a doc with its match quality: the best conjunction matched it, see weightedOrderCollector
+	ScoredDocID struct {
+		DocID           DocID   `json:"doc_id"`
+		MatchedConjSize int     `json:"matched_conj_size"`
+		Weight          float64 `json:"weight"` // sum of FieldOption.OrderWeight of the fields matched
+	}
+
+	// ScoredPage a page of RetrieveScoredPage, NextToken is empty when the last page reached
+	ScoredPage struct {
+		Docs      []ScoredDocID `json:"docs"`
+		NextToken string        `json:"next_token,omitempty"`
+	}
+
+	// scoreBoundary the last doc of a page, the next page start after it
+	scoreBoundary struct {
+		weight matchWeight
+		id     DocID
+	}
+)
+
+func encodeScoreToken(b scoreBoundary) string {
+	return fmt.Sprintf("%d.%x.%d", b.weight.size, math.Float64bits(b.weight.weight), b.id)
+}
+
+func decodeScoreToken(token string) (b scoreBoundary, err error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return b, fmt.Errorf("invalid scored page token:%s", token)
+	}
+	size, err1 := strconv.Atoi(parts[0])
+	bits, err2 := strconv.ParseUint(parts[1], 16, 64)
+	id, err3 := strconv.ParseUint(parts[2], 10, 32)
+	if err1 != nil || err2 != nil || err3 != nil {
+		return b, fmt.Errorf("invalid scored page token:%s", token)
+	}
+	return scoreBoundary{weight: matchWeight{size: size, weight: math.Float64frombits(bits)}, id: DocID(id)}, nil
+}
+
+// after whether doc id of weight w ordered after the boundary
+func (b scoreBoundary) after(w matchWeight, id DocID) bool {
+	return w.less(b.weight) || (w == b.weight && id > b.id)
+}
+
+func (bi *indexBase) newScoredCollector() *weightedOrderCollector {
+	return &weightedOrderCollector{
+		DocIDCollector: newDistinctCollector(),
+		bi:             bi,
+		best:           make(map[DocID]matchWeight, 128),
+	}
+}
+
+/*
+scoredPage the best pageSize docs of collector after the boundary encoded in token("" means from the
+best doc), selected by a bounded heap. the token is stateless, every page retrieve again, so pages
+are consistent(no duplicates, no gaps) as long as the index and queries stay the same.
+*/
+func scoredPage(collector *weightedOrderCollector, pageSize int, token string) (*ScoredPage, error) {
+	var boundary *scoreBoundary
+	if token != "" {
+		b, err := decodeScoreToken(token)
+		if err != nil {
+			return nil, err
+		}
+		boundary = &b
+	}
+
+	h := &sortKeyHeap{docs: make(DocIDList, 0, pageSize), less: collector.less}
+	remaining := 0
+	for _, id := range collector.GetDocIDs() {
+		if boundary != nil && !boundary.after(collector.best[id], id) {
+			continue
+		}
+		remaining++
+		if h.Len() < pageSize {
+			heap.Push(h, id)
+		} else if collector.less(id, h.docs[0]) {
+			h.docs[0] = id
+			heap.Fix(h, 0)
+		}
+	}
+	sort.Slice(h.docs, func(i, j int) bool {
+		return collector.less(h.docs[i], h.docs[j])
+	})
+
+	page := &ScoredPage{Docs: make([]ScoredDocID, 0, len(h.docs))}
+	for _, id := range h.docs {
+		w := collector.best[id]
+		page.Docs = append(page.Docs, ScoredDocID{DocID: id, MatchedConjSize: w.size, Weight: w.weight})
+	}
+	if remaining > len(h.docs) {
+		last := h.docs[len(h.docs)-1]
+		page.NextToken = encodeScoreToken(scoreBoundary{weight: collector.best[last], id: last})
+	}
+	return page, nil
+}
+
+// retrieveScoredPage RetrieveScoredPage of index retrieving by retrieve
+func (bi *indexBase) retrieveScoredPage(retrieve func(*RetrieveContext, Assignments, ResultCollector) error,
+	queries Assignments, pageSize int, token string, opts ...IndexOpt) (*ScoredPage, error) {
+	if pageSize <= 0 {
+		return nil, fmt.Errorf("invalid page size:%d", pageSize)
+	}
+	ctx := newRetrieveContext(opts...)
+	collector := bi.newScoredCollector()
+	if err := retrieve(ctx, queries, collector); err != nil {
+		if ctx.partialResult(err) {
+			page, _ := scoredPage(collector, pageSize, token)
+			return page, err
+		}
+		return nil, err
+	}
+	return scoredPage(collector, pageSize, token)
+}
+
+func (bi *SizeGroupedBEIndex) RetrieveScoredPage(queries Assignments, pageSize int, token string, opts ...IndexOpt) (*ScoredPage, error) {
+	return bi.retrieveScoredPage(bi.retrieve, queries, pageSize, token, opts...)
+}
+
+func (bi *CompactedBEIndex) RetrieveScoredPage(queries Assignments, pageSize int, token string, opts ...IndexOpt) (*ScoredPage, error) {
+	return bi.retrieveScoredPage(bi.retrieve, queries, pageSize, token, opts...)
+}
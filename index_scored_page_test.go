@@ -0,0 +1,82 @@
+package be_indexer
+
+import (
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestBEIndex_RetrieveScoredPage(t *testing.T) {
+	LogLevel = ErrorLevel
+
+	b := NewIndexerBuilder()
+	b.ConfigField("city", FieldOption{OrderWeight: 2})
+	b.ConfigField("tag", FieldOption{OrderWeight: 1})
+	for id := 1; id <= 100; id++ {
+		doc := NewDocument(DocID(id))
+		switch id % 4 {
+		case 0:
+			doc.AddConjunction(NewConjunction().In("tag", NewIntValues(1)))
+		case 1:
+			doc.AddConjunction(NewConjunction().In("city", NewStrValues("sh")))
+		case 2:
+			doc.AddConjunction(NewConjunction().In("tag", NewIntValues(1)).In("city", NewStrValues("sh")))
+		default:
+			doc.AddConjunction(NewConjunction().NotIn("age", NewIntValues(1)))
+		}
+		b.AddDocument(doc)
+	}
+	query := Assignments{"tag": NewIntValues(1), "city": NewStrValues("sh")}
+
+	convey.Convey("test paging through scored results", t, func() {
+		for _, index := range []BEIndex{b.BuildIndex(), b.BuildCompactedIndex(), NewMiddlewareBEIndex(b.BuildIndex())} {
+			expect, err := index.Retrieve(query)
+			convey.So(err, convey.ShouldBeNil)
+
+			var docs []ScoredDocID
+			token, pages := "", 0
+			for {
+				page, err := index.RetrieveScoredPage(query, 7, token)
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(len(page.Docs), convey.ShouldBeLessThanOrEqualTo, 7)
+				docs = append(docs, page.Docs...)
+				pages++
+				if token = page.NextToken; token == "" {
+					break
+				}
+			}
+			convey.So(len(docs), convey.ShouldEqual, len(expect))
+			convey.So(pages, convey.ShouldEqual, (len(expect)+6)/7)
+
+			seen := make(map[DocID]struct{})
+			for i, doc := range docs {
+				_, dup := seen[doc.DocID]
+				convey.So(dup, convey.ShouldBeFalse)
+				seen[doc.DocID] = struct{}{}
+				if i == 0 {
+					continue
+				}
+				prev, cur := matchWeight{docs[i-1].MatchedConjSize, docs[i-1].Weight}, matchWeight{doc.MatchedConjSize, doc.Weight}
+				convey.So(prev.less(cur), convey.ShouldBeFalse)
+				if prev == cur {
+					convey.So(docs[i-1].DocID, convey.ShouldBeLessThan, doc.DocID)
+				}
+			}
+			convey.So(docs[0], convey.ShouldResemble, ScoredDocID{DocID: 2, MatchedConjSize: 2, Weight: 3})
+			convey.So(docs[len(docs)-1], convey.ShouldResemble, ScoredDocID{DocID: 99, MatchedConjSize: 0, Weight: 0})
+
+			all, err := index.RetrieveScoredPage(query, len(expect), "")
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(all.NextToken, convey.ShouldBeEmpty)
+			convey.So(all.Docs, convey.ShouldResemble, docs)
+		}
+	})
+
+	convey.Convey("test invalid page request", t, func() {
+		index := b.BuildIndex()
+		_, err := index.RetrieveScoredPage(query, 0, "")
+		convey.So(err, convey.ShouldNotBeNil)
+		_, err = index.RetrieveScoredPage(query, 10, "not a token")
+		convey.So(err, convey.ShouldNotBeNil)
+	})
+}
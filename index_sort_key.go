@@ -0,0 +1,85 @@
+package be_indexer
+
+import (
+	"container/heap"
+	"sort"
+)
+
+type (
+	// sortKeyHeap max-heap by less(eg: sortKeyLess), the top is the worst doc kept, see arrangeBySortKey
+	sortKeyHeap struct {
+		docs DocIDList
+		less func(a, b DocID) bool
+	}
+)
+
+func (h *sortKeyHeap) Len() int           { return len(h.docs) }
+func (h *sortKeyHeap) Less(i, j int) bool { return h.less(h.docs[j], h.docs[i]) }
+func (h *sortKeyHeap) Swap(i, j int)      { h.docs[i], h.docs[j] = h.docs[j], h.docs[i] }
+func (h *sortKeyHeap) Push(x interface{}) { h.docs = append(h.docs, x.(DocID)) }
+func (h *sortKeyHeap) Pop() interface{} {
+	last := h.docs[len(h.docs)-1]
+	h.docs = h.docs[:len(h.docs)-1]
+	return last
+}
+
+// WithOrderBySortKey order the result of Retrieve by the sort key of docs(see Document.SetSortKey),
+// ascending or desc, docs without key last and ties broken by DocID; it override WithResultSort,
+// combined with WithMaxResults(n) the top n docs are selected by a bounded heap instead of a full sort.
+// note: RetrieveWithCollector leave the result arrangement to the collector, this option is ignored
+func WithOrderBySortKey(desc bool) IndexOpt {
+	return func(ctx *RetrieveContext) {
+		ctx.orderBySortKey = true
+		ctx.sortKeyDesc = desc
+	}
+}
+
+func (bi *indexBase) appendDocSortKey(id DocID, key int64) {
+	if bi.sortKeys == nil {
+		bi.sortKeys = make(map[DocID]int64)
+	}
+	bi.sortKeys[id] = key
+}
+
+func (bi *indexBase) docSortKey(id DocID) (int64, bool) {
+	key, ok := bi.sortKeys[id]
+	return key, ok
+}
+
+// sortKeyLess whether doc a ordered before b, see WithOrderBySortKey
+func (ctx *RetrieveContext) sortKeyLess(a, b DocID) bool {
+	ka, okA := ctx.sortKeys[a]
+	kb, okB := ctx.sortKeys[b]
+	switch {
+	case okA != okB:
+		return okA
+	case okA && ka != kb:
+		return (ka < kb) != ctx.sortKeyDesc
+	default:
+		return a < b
+	}
+}
+
+// arrangeBySortKey order result by sort key and keep the top maxResults(if limited) docs
+func (ctx *RetrieveContext) arrangeBySortKey(result DocIDList) DocIDList {
+	if ctx.maxResults <= 0 || len(result) <= ctx.maxResults {
+		sort.Slice(result, func(i, j int) bool {
+			return ctx.sortKeyLess(result[i], result[j])
+		})
+		return result
+	}
+	h := &sortKeyHeap{docs: make(DocIDList, 0, ctx.maxResults), less: ctx.sortKeyLess}
+	for _, id := range result {
+		if h.Len() < ctx.maxResults {
+			heap.Push(h, id)
+		} else if ctx.sortKeyLess(id, h.docs[0]) {
+			h.docs[0] = id
+			heap.Fix(h, 0)
+		}
+	}
+	top := make(DocIDList, h.Len())
+	for i := len(top) - 1; i >= 0; i-- {
+		top[i] = heap.Pop(h).(DocID)
+	}
+	return top
+}
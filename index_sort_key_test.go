@@ -0,0 +1,71 @@
+package be_indexer
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestWithOrderBySortKey(t *testing.T) {
+	LogLevel = ErrorLevel
+
+	keys := map[DocID]int64{1: 30, 2: 10, 3: 20, 4: 10, 6: -5}
+	b := NewIndexerBuilder()
+	for id := DocID(1); id <= 7; id++ {
+		doc := NewDocument(id)
+		doc.AddConjunction(NewConjunction().In("tag", NewIntValues(1)))
+		if key, ok := keys[id]; ok {
+			doc.SetSortKey(key)
+		}
+		b.AddDocument(doc)
+	}
+	queries := Assignments{"tag": NewIntValues(1)}
+
+	convey.Convey("test order by sort key", t, func() {
+		for _, index := range []BEIndex{b.BuildIndex(), b.BuildCompactedIndex()} {
+			result, err := index.Retrieve(queries, WithOrderBySortKey(false))
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(result, convey.ShouldResemble, DocIDList{6, 2, 4, 3, 1, 5, 7})
+
+			result, err = index.Retrieve(queries, WithOrderBySortKey(true))
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(result, convey.ShouldResemble, DocIDList{1, 3, 2, 4, 6, 5, 7})
+
+			// sort key override the result sort
+			result, err = index.Retrieve(queries, WithResultSort(func(a, b DocID) bool { return a > b }), WithOrderBySortKey(false))
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(result, convey.ShouldResemble, DocIDList{6, 2, 4, 3, 1, 5, 7})
+		}
+	})
+
+	convey.Convey("test order by sort key with max results", t, func() {
+		for _, index := range []BEIndex{b.BuildIndex(), b.BuildCompactedIndex()} {
+			for n := 1; n <= 8; n++ {
+				full, _ := index.Retrieve(queries, WithOrderBySortKey(true))
+				result, err := index.Retrieve(queries, WithOrderBySortKey(true), WithMaxResults(n))
+				convey.So(err, convey.ShouldBeNil)
+				if n < len(full) {
+					full = full[:n]
+				}
+				convey.So(result, convey.ShouldResemble, full)
+			}
+		}
+	})
+
+	convey.Convey("test sort key survive document serialization", t, func() {
+		doc := NewDocument(1)
+		doc.SetSortKey(42)
+		data, err := json.Marshal(doc)
+		convey.So(err, convey.ShouldBeNil)
+		decoded := &Document{}
+		convey.So(json.Unmarshal(data, decoded), convey.ShouldBeNil)
+		convey.So(decoded.SortKey, convey.ShouldNotBeNil)
+		convey.So(*decoded.SortKey, convey.ShouldEqual, 42)
+
+		data, _ = json.Marshal(NewDocument(2))
+		decoded = &Document{}
+		convey.So(json.Unmarshal(data, decoded), convey.ShouldBeNil)
+		convey.So(decoded.SortKey, convey.ShouldBeNil)
+	})
+}
@@ -0,0 +1,196 @@
+package be_indexer
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+type (
+	// IndexStatistics summary of a built BEIndex, see BEIndex.Stats
+	IndexStatistics struct {
+		DocCount           int `json:"doc_count"`            // documents with conjunction indexed
+		ConjunctionCount   int `json:"conjunction_count"`    // conjunctions indexed
+		FieldCount         int `json:"field_count"`          // fields configured, wildcard field excluded
+		PostingListCount   int `json:"posting_list_count"`   // posting lists(keys) of all K
+		EntryCount         int `json:"entry_count"`          // EntryIDs of all posting lists
+		WildcardEntryCount int `json:"wildcard_entry_count"` // EntryIDs of wildcard posting list
+
+		// SharedPostingListCount posting lists sharing the Entries of another key, see WithSharedPostingLists
+		SharedPostingListCount int `json:"shared_posting_list_count,omitempty"`
+		// SharedPostingListBytes approximate memory saved by the shared posting lists
+		SharedPostingListBytes int64 `json:"shared_posting_list_bytes,omitempty"`
+
+		// MemoryFootprint approximate memory of posting lists(wildcard one included), shared lists
+		// counted once; same accounting as WithMemoryBudget, see EstimateIndexMemory
+		MemoryFootprint int64 `json:"memory_footprint"`
+
+		// HotKeys posting lists longer than FieldOption.MaxKeyEntries of their field
+		HotKeys []HotKey `json:"hot_keys,omitempty"`
+
+		// PostingGroups posting list groups(K groups, or the single list of CompactedBEIndex), the
+		// CompiledGroups of them sorted, less only when compiled lazily, see WithLazyCompile
+		PostingGroups  int `json:"posting_groups"`
+		CompiledGroups int `json:"compiled_groups"`
+
+		// AutoCreatedFields fields not configured but used by documents(sorted), they got the
+		// default option(see WithDefaultFieldOption), a hint to tighten field configs
+		AutoCreatedFields []BEField `json:"auto_created_fields,omitempty"`
+
+		// MutationSeq sequence number of the last builder mutation the index built with(see
+		// MutationLog), replicas built from the same mutation stream compare positions by it
+		MutationSeq uint64 `json:"mutation_seq,omitempty"`
+
+		// SchemaVersion the schema version index built with, see WithSchemaVersion
+		SchemaVersion string `json:"schema_version,omitempty"`
+	}
+
+	// FieldDescription exported description of a configured field
+	FieldDescription struct {
+		Field  BEField `json:"field"`
+		ID     uint64  `json:"id"`
+		Parser string  `json:"parser"`
+
+		AutoCreated bool `json:"auto_created,omitempty"` // see IndexStatistics.AutoCreatedFields
+	}
+
+	// FieldDescriptions sorted by field id
+	FieldDescriptions []FieldDescription
+)
+
+func (kse *PostingEntries) statistics(stats *IndexStatistics) {
+	l := kse.load()
+	stats.PostingListCount += l.keyCount()
+	stats.MemoryFootprint += int64(l.keyCount()) * postingKeyBytes
+	heads := make(map[*EntryID]struct{}, len(l.plEntries)+len(l.spilled))
+	count := func(entries Entries) {
+		stats.EntryCount += len(entries)
+		if len(entries) == 0 {
+			return
+		}
+		if _, ok := heads[&entries[0]]; ok {
+			stats.SharedPostingListCount++
+			stats.SharedPostingListBytes += int64(len(entries)) * entryIDBytes
+			return
+		}
+		heads[&entries[0]] = struct{}{}
+		stats.MemoryFootprint += int64(len(entries)) * entryIDBytes
+	}
+	for _, entries := range l.plEntries {
+		count(entries)
+	}
+	for _, entries := range l.spilled {
+		count(entries)
+	}
+	for _, il := range l.inlined {
+		if _, spilled := il.spilled(); !spilled {
+			stats.EntryCount += il.len()
+			stats.MemoryFootprint += int64(il.len()) * entryIDBytes
+		}
+	}
+}
+
+func (bi *indexBase) baseStatistics() IndexStatistics {
+	stats := IndexStatistics{
+		DocCount:   len(bi.docConjs),
+		FieldCount: len(bi.fieldDesc) - 1,
+
+		MutationSeq:   bi.mutationSeq,
+		SchemaVersion: bi.schemaVersion,
+	}
+	for _, conjs := range bi.docConjs {
+		stats.ConjunctionCount += len(conjs)
+	}
+	for field, desc := range bi.fieldDesc {
+		if desc.autoCreated {
+			stats.AutoCreatedFields = append(stats.AutoCreatedFields, field)
+		}
+	}
+	sort.Slice(stats.AutoCreatedFields, func(i, j int) bool {
+		return stats.AutoCreatedFields[i] < stats.AutoCreatedFields[j]
+	})
+	return stats
+}
+
+func (bi *indexBase) FieldDescriptions() FieldDescriptions {
+	res := make(FieldDescriptions, 0, len(bi.fieldDesc))
+	for _, desc := range bi.fieldDesc {
+		if desc.Field == wildcardField {
+			continue
+		}
+		res = append(res, FieldDescription{
+			Field:       desc.Field,
+			ID:          desc.ID,
+			Parser:      desc.ParserName,
+			AutoCreated: desc.autoCreated,
+		})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].ID < res[j].ID
+	})
+	return res
+}
+
+func (bi *SizeGroupedBEIndex) Stats() IndexStatistics {
+	stats := bi.baseStatistics()
+	stats.WildcardEntryCount = len(bi.wildcardEntries.load())
+	stats.MemoryFootprint = int64(len(bi.wildcardEntries.load())) * entryIDBytes
+	for _, sizeEntries := range bi.sizeEntries {
+		sizeEntries.statistics(&stats)
+	}
+	stats.HotKeys = bi.hotKeys(bi.sizeEntries...)
+	compileProgress(&stats, bi.sizeEntries...)
+	return stats
+}
+
+func (bi *CompactedBEIndex) Stats() IndexStatistics {
+	stats := bi.baseStatistics()
+	stats.WildcardEntryCount = len(bi.wildcardEntries.load())
+	stats.MemoryFootprint = int64(len(bi.wildcardEntries.load())) * entryIDBytes
+	bi.postingList.statistics(&stats)
+	stats.HotKeys = bi.hotKeys(bi.postingList)
+	compileProgress(&stats, bi.postingList)
+	return stats
+}
+
+/*
+streamStats send a snapshot of stats() right away then every interval, until ctx done; the channel
+closed when the feeding goroutine exit, a closed channel returned for interval <= 0. the channel
+buffer one snapshot and a newer one replace it if not received yet, so a slow consumer get the latest
+snapshot taken(at most an interval old) instead of a backlog.
+*/
+func streamStats(ctx context.Context, interval time.Duration, stats func() IndexStatistics) <-chan IndexStatistics {
+	ch := make(chan IndexStatistics, 1)
+	if interval <= 0 {
+		close(ch)
+		return ch
+	}
+	go func() {
+		defer close(ch)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			snapshot := stats()
+			select { // drop the stale one, the only sender never block on the emptied buffer
+			case <-ch:
+			default:
+			}
+			ch <- snapshot
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+func (bi *SizeGroupedBEIndex) StreamStats(ctx context.Context, interval time.Duration) <-chan IndexStatistics {
+	return streamStats(ctx, interval, bi.Stats)
+}
+
+func (bi *CompactedBEIndex) StreamStats(ctx context.Context, interval time.Duration) <-chan IndexStatistics {
+	return streamStats(ctx, interval, bi.Stats)
+}
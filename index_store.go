@@ -0,0 +1,129 @@
+package be_indexer
+
+import (
+	"fmt"
+	"sync"
+)
+
+type (
+	// VersionID identify a committed index in VersionedIndexStore, monotonically increasing from 1
+	VersionID uint64
+
+	indexVersion struct {
+		id    VersionID
+		index BEIndex
+	}
+
+	/*VersionedIndexStore keep the last N built indexes, so operator can roll back to
+	a previous version when a newly built index has bug(wrong documents, missing fields...)
+	it's safe for concurrent use.
+	*/
+	VersionedIndexStore struct {
+		mtx         sync.RWMutex
+		maxVersions int
+		lastID      VersionID
+		versions    []indexVersion // ordered from oldest to newest, the last one is current
+
+		// auto compaction, see WithAutoCompact
+		autoCompact      bool
+		compactThreshold float64
+		compactRuns      int
+		compactStats     CompactStats
+	}
+)
+
+func NewVersionedIndexStore(maxVersions int, opts ...StoreOpt) *VersionedIndexStore {
+	if maxVersions <= 0 {
+		panic(fmt.Errorf("maxVersions must be positive, got:%d", maxVersions))
+	}
+	s := &VersionedIndexStore{
+		maxVersions: maxVersions,
+		versions:    make([]indexVersion, 0, maxVersions),
+	}
+	for _, fn := range opts {
+		fn(s)
+	}
+	return s
+}
+
+// Commit make idx(or its compacted clone, see WithAutoCompact) the current version, the oldest
+// versions beyond maxVersions will be evicted
+func (s *VersionedIndexStore) Commit(idx BEIndex) VersionID {
+	if idx == nil {
+		panic(fmt.Errorf("nil index not allow"))
+	}
+	idx, stats := s.compactFragmented(idx)
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if stats != nil {
+		s.compactRuns++
+		s.compactStats.ListsRewritten += stats.ListsRewritten
+		s.compactStats.EntriesDropped += stats.EntriesDropped
+		s.compactStats.KeysDropped += stats.KeysDropped
+		s.compactStats.BytesReclaimed += stats.BytesReclaimed
+	}
+
+	s.lastID++
+	s.versions = append(s.versions, indexVersion{id: s.lastID, index: idx})
+	if evict := len(s.versions) - s.maxVersions; evict > 0 {
+		for i := 0; i < evict; i++ {
+			s.versions[i] = indexVersion{} // release reference of evicted index
+		}
+		s.versions = append(s.versions[:0], s.versions[evict:]...)
+	}
+	return s.lastID
+}
+
+// Get return the index of version v if it's not evicted(or rolled back)
+func (s *VersionedIndexStore) Get(v VersionID) (BEIndex, bool) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	for _, version := range s.versions {
+		if version.id == v {
+			return version.index, true
+		}
+	}
+	return nil, false
+}
+
+// Current return the latest committed index, nil if nothing committed
+func (s *VersionedIndexStore) Current() BEIndex {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	if len(s.versions) == 0 {
+		return nil
+	}
+	return s.versions[len(s.versions)-1].index
+}
+
+/*
+Rollback drop the current version and make the previous version current, return the new current index
+nil returned if there is no previous version, in this case the current version is kept.
+a rolled back VersionID will never be reused.
+*/
+func (s *VersionedIndexStore) Rollback() BEIndex {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if len(s.versions) < 2 {
+		return nil
+	}
+	s.versions[len(s.versions)-1] = indexVersion{}
+	s.versions = s.versions[:len(s.versions)-1]
+	return s.versions[len(s.versions)-1].index
+}
+
+// ListVersions return all kept versions, ordered from oldest to newest
+func (s *VersionedIndexStore) ListVersions() []VersionID {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	res := make([]VersionID, 0, len(s.versions))
+	for _, version := range s.versions {
+		res = append(res, version.id)
+	}
+	return res
+}
@@ -0,0 +1,155 @@
+package be_indexer
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestVersionedIndexStore(t *testing.T) {
+	LogLevel = ErrorLevel
+
+	convey.Convey("test versioned index store", t, func() {
+		store := NewVersionedIndexStore(3)
+		convey.So(store.Current(), convey.ShouldBeNil)
+		convey.So(store.Rollback(), convey.ShouldBeNil)
+
+		var indexes []BEIndex
+		for i := 0; i < 4; i++ {
+			builder := NewIndexerBuilder()
+			doc := NewDocument(DocID(i + 1))
+			doc.AddConjunction(NewConjunction().In("tag", NewIntValues(1)))
+			builder.AddDocument(doc)
+			indexes = append(indexes, builder.BuildIndex())
+		}
+
+		convey.So(store.Commit(indexes[0]), convey.ShouldEqual, VersionID(1))
+		convey.So(store.Commit(indexes[1]), convey.ShouldEqual, VersionID(2))
+		convey.So(store.Commit(indexes[2]), convey.ShouldEqual, VersionID(3))
+		convey.So(store.Commit(indexes[3]), convey.ShouldEqual, VersionID(4))
+
+		// version 1 evicted
+		convey.So(store.ListVersions(), convey.ShouldResemble, []VersionID{2, 3, 4})
+		_, ok := store.Get(1)
+		convey.So(ok, convey.ShouldBeFalse)
+		idx, ok := store.Get(2)
+		convey.So(ok, convey.ShouldBeTrue)
+		convey.So(idx, convey.ShouldEqual, indexes[1])
+		convey.So(store.Current(), convey.ShouldEqual, indexes[3])
+
+		ids, err := store.Current().Retrieve(Assignments{"tag": NewIntValues(1)})
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(ids, convey.ShouldResemble, DocIDList{4})
+
+		convey.So(store.Rollback(), convey.ShouldEqual, indexes[2])
+		convey.So(store.Current(), convey.ShouldEqual, indexes[2])
+		convey.So(store.ListVersions(), convey.ShouldResemble, []VersionID{2, 3})
+
+		// version id never reused
+		convey.So(store.Commit(indexes[3]), convey.ShouldEqual, VersionID(5))
+
+		convey.So(store.Rollback(), convey.ShouldEqual, indexes[2])
+		convey.So(store.Rollback(), convey.ShouldEqual, indexes[1])
+		convey.So(store.Rollback(), convey.ShouldBeNil)
+		convey.So(store.Current(), convey.ShouldEqual, indexes[1])
+	})
+}
+
+func buildFragmentedIndex(docCnt int, compacted bool) BEIndex {
+	builder := NewIndexerBuilder()
+	for i := 1; i <= docCnt; i++ {
+		doc := NewDocument(DocID(i))
+		// duplicated values produce duplicated entries
+		doc.AddConjunction(NewConjunction().In("tag", NewIntValues(1, 1, 1, 1)))
+		builder.AddDocument(doc)
+	}
+	if compacted {
+		return builder.BuildCompactedIndex()
+	}
+	return builder.BuildIndex()
+}
+
+func TestVersionedIndexStore_AutoCompact(t *testing.T) {
+	LogLevel = ErrorLevel
+
+	convey.Convey("test commit compact only when fragmentation exceed threshold", t, func() {
+		for _, compacted := range []bool{false, true} {
+			index := buildFragmentedIndex(10, compacted)
+			convey.So(index.fragmentation(), convey.ShouldAlmostEqual, 0.75)
+
+			store := NewVersionedIndexStore(2, WithAutoCompact(0.8))
+			v := store.Commit(index)
+			current, ok := store.Get(v)
+			convey.So(ok, convey.ShouldBeTrue)
+			convey.So(current, convey.ShouldEqual, index)
+			runs, _ := store.AutoCompactStats()
+			convey.So(runs, convey.ShouldEqual, 0)
+
+			store = NewVersionedIndexStore(2, WithAutoCompact(0.5))
+			v = store.Commit(index)
+			current, ok = store.Get(v)
+			convey.So(ok, convey.ShouldBeTrue)
+			convey.So(current, convey.ShouldNotEqual, index)
+			convey.So(current.fragmentation(), convey.ShouldEqual, 0)
+			convey.So(index.fragmentation(), convey.ShouldAlmostEqual, 0.75) // origin not touched
+			runs, stats := store.AutoCompactStats()
+			convey.So(runs, convey.ShouldEqual, 1)
+			convey.So(stats.EntriesDropped, convey.ShouldEqual, 30)
+
+			for _, idx := range []BEIndex{index, current} {
+				ids, err := idx.Retrieve(Assignments{"tag": NewIntValues(1)})
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(len(ids), convey.ShouldEqual, 10)
+				docs, total, err := idx.WildcardDocs(0, 10)
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(total, convey.ShouldEqual, 0)
+				convey.So(docs, convey.ShouldBeEmpty)
+			}
+		}
+
+		store := NewVersionedIndexStore(2)
+		index := buildFragmentedIndex(10, false)
+		store.Commit(index)
+		convey.So(store.Current(), convey.ShouldEqual, index)
+	})
+
+	convey.Convey("test committed index compacted while the old one serving reads", t, func() {
+		store := NewVersionedIndexStore(2, WithAutoCompact(0.5))
+		old := buildFragmentedIndex(100, false)
+		store.Commit(old)
+
+		var stop, reads, failures int64
+		wg := sync.WaitGroup{}
+		for r := 0; r < 4; r++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for atomic.LoadInt64(&stop) == 0 {
+					ids, err := old.Retrieve(Assignments{"tag": NewIntValues(1)})
+					if err != nil || len(ids) != 100 {
+						atomic.AddInt64(&failures, 1)
+					}
+					atomic.AddInt64(&reads, 1)
+				}
+			}()
+		}
+		for i := 0; i < 5; i++ {
+			store.Commit(buildFragmentedIndex(100, i%2 == 0))
+			convey.So(store.Current().fragmentation(), convey.ShouldEqual, 0)
+		}
+		for atomic.LoadInt64(&reads) == 0 {
+			runtime.Gosched()
+		}
+		atomic.StoreInt64(&stop, 1)
+		wg.Wait()
+
+		runs, stats := store.AutoCompactStats()
+		convey.So(runs, convey.ShouldEqual, 6)
+		convey.So(stats.BytesReclaimed, convey.ShouldBeGreaterThan, 0)
+		convey.So(atomic.LoadInt64(&failures), convey.ShouldEqual, 0)
+		convey.So(old.fragmentation(), convey.ShouldAlmostEqual, 0.75)
+	})
+}
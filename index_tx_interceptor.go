@@ -0,0 +1,27 @@
+package be_indexer
+
+type (
+	/*IndexingBETx the posting list entries a field expression of a conjunction going to commit:
+	EID appended to the posting list of every key in Keys(of the conjunction's K group); for
+	field values parsed into multiple ids(or InAll values) there are multiple Keys, an Absent
+	expression has the single key of the field's absent desc. see WithTxInterceptor
+	*/
+	IndexingBETx struct {
+		Field BEField
+		EID   EntryID
+		Keys  []Key
+	}
+)
+
+/*
+WithTxInterceptor call fn on the IndexingBETx of every field expression before it committed to the
+posting lists, fn can rewrite tx.EID/tx.Keys in place(eg: remap entry ids for sharding); a error
+returned by fn fail the conjunction, handled by BadConjBehavior like a value can't be parsed.
+the index trust what fn produced: a EID of other conjunction, or a Key of unknown field, make
+retrieving return wrong docs(or panic).
+*/
+func WithTxInterceptor(fn func(tx *IndexingBETx) error) BuilderOpt {
+	return func(builder *IndexerBuilder) {
+		builder.txInterceptor = fn
+	}
+}
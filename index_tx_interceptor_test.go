@@ -0,0 +1,72 @@
+package be_indexer
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func newTxInterceptorTestBuilder(opts ...BuilderOpt) *IndexerBuilder {
+	b := NewIndexerBuilder(opts...)
+	doc := NewDocument(1)
+	doc.AddConjunction(NewConjunction().In("tag", NewIntValues(1, 2)).NotIn("city", NewStrValues("bj")))
+	_ = b.AddDocument(doc)
+	doc = NewDocument(2)
+	doc.AddConjunction(NewConjunction().In("tag", NewIntValues(2)).In("city", NewStrValues("sh")))
+	_ = b.AddDocument(doc)
+	return b
+}
+
+func TestIndexerBuilder_WithTxInterceptor(t *testing.T) {
+	LogLevel = ErrorLevel
+
+	convey.Convey("test interceptor see every expression before committed", t, func() {
+		keys := map[BEField]int{}
+		b := newTxInterceptorTestBuilder(WithTxInterceptor(func(tx *IndexingBETx) error {
+			keys[tx.Field] += len(tx.Keys)
+			return nil
+		}))
+		index := b.BuildIndex()
+		convey.So(keys, convey.ShouldResemble, map[BEField]int{"tag": 3, "city": 2})
+
+		result, err := index.Retrieve(Assignments{"tag": NewIntValues(2), "city": NewStrValues("sh")})
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(len(result), convey.ShouldEqual, 2)
+	})
+
+	convey.Convey("test interceptor remap entry ids", t, func() {
+		shard := func(tx *IndexingBETx) error { // move docs to shard range 1000+
+			conj := tx.EID.GetConjID()
+			tx.EID = NewEntryID(NewConjID(conj.DocID()+1000, conj.Index(), conj.Size()), tx.EID.IsInclude())
+			return nil
+		}
+		b := newTxInterceptorTestBuilder(WithTxInterceptor(shard))
+		for _, index := range []BEIndex{b.BuildIndex(), b.BuildCompactedIndex()} {
+			result, err := index.Retrieve(Assignments{"tag": NewIntValues(2), "city": NewStrValues("sh")})
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(len(result), convey.ShouldEqual, 2)
+			convey.So(result, convey.ShouldContain, DocID(1001))
+			convey.So(result, convey.ShouldContain, DocID(1002))
+		}
+	})
+
+	convey.Convey("test interceptor error handled by BadConjBehavior", t, func() {
+		reject := func(tx *IndexingBETx) error {
+			if tx.Field == "city" && tx.EID.IsInclude() {
+				return errors.New("city not allowed")
+			}
+			return nil
+		}
+		b := newTxInterceptorTestBuilder(WithTxInterceptor(reject), WithBadConjBehavior(SkipBadConj))
+		for _, index := range []BEIndex{b.BuildIndex(), b.BuildCompactedIndex()} {
+			result, err := index.Retrieve(Assignments{"tag": NewIntValues(2), "city": NewStrValues("sh")})
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(result, convey.ShouldResemble, DocIDList{1})
+		}
+
+		b = newTxInterceptorTestBuilder(WithTxInterceptor(reject), WithBadConjBehavior(ErrorBadConj))
+		_, err := b.BuildIndexE()
+		convey.So(err, convey.ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,107 @@
+package be_indexer
+
+import (
+	"github.com/echoface/be_indexer/parser"
+)
+
+type (
+	// VerifyFunc exact check whether the conjunction of eid match the value assigned to field, it's
+	// called only for the ids parsed by a parser.Approximate parser, see FieldOption.Verify
+	VerifyFunc func(eid EntryID, field BEField, assigned interface{}) bool
+
+	// approxSupport the query values a approximate posting list key parsed from and how to verify them
+	approxSupport struct {
+		field  BEField
+		verify VerifyFunc
+		values []interface{}
+	}
+)
+
+/*
+WithVerifier verify the approximate matches of field by verify, it override FieldOption.Verify for
+this retrieving. a parser declared parser.Approximate may return a superset of candidates, a
+conjunction whose cursors of the field all sit on approximate ids is accepted only when verify return
+true for one of the query values the ids parsed from; exact parsers are unaffected.
+note: NotIn of approximate field is not verified, it exclude by the superset
+*/
+func WithVerifier(field BEField, verify VerifyFunc) IndexOpt {
+	return func(ctx *RetrieveContext) {
+		if ctx.verifiers == nil {
+			ctx.verifiers = make(map[BEField]VerifyFunc)
+		}
+		ctx.verifiers[field] = verify
+	}
+}
+
+// isApproximate whether the parser of field declare parser.Approximate
+func isApproximate(desc *FieldDesc) bool {
+	approx, ok := desc.Parser.(parser.Approximate)
+	return ok && approx.Approximate()
+}
+
+// verifierOf the verifier of field, the query time one win
+func (ctx *RetrieveContext) verifierOf(desc *FieldDesc, field BEField) VerifyFunc {
+	if verify, ok := ctx.verifiers[field]; ok {
+		return verify
+	}
+	return desc.option.Verify
+}
+
+// recordApproximate remember the query value the approximate keys of ids parsed from, no-op for exact
+// parser or field without verifier
+func (ctx *RetrieveContext) recordApproximate(desc *FieldDesc, field BEField, value interface{}, ids []uint64) {
+	if value == WildcardValue || !isApproximate(desc) {
+		return
+	}
+	verify := ctx.verifierOf(desc, field)
+	if verify == nil {
+		return
+	}
+	if ctx.approxKeys == nil {
+		ctx.approxKeys = make(map[Key]*approxSupport)
+	}
+	for _, id := range ids {
+		key := NewKey(desc.ID, id)
+		support, ok := ctx.approxKeys[key]
+		if !ok {
+			support = &approxSupport{field: field, verify: verify}
+			ctx.approxKeys[key] = support
+		}
+		support.values = append(support.values, value)
+	}
+}
+
+// verifyApproximate false if any matched field support conj by approximate keys only and none of them verified
+func (ctx *RetrieveContext) verifyApproximate(conj ConjID, matched FieldScanners) bool {
+	if len(ctx.approxKeys) == 0 {
+		return true
+	}
+SCANNER:
+	for _, scanner := range matched {
+		var supports []*approxSupport
+		var eid EntryID
+		for _, cursor := range scanner.cursorGroup {
+			if cursor.GetCurEntryID().GetConjID() != conj {
+				continue
+			}
+			support, ok := ctx.approxKeys[cursor.key]
+			if !ok {
+				continue SCANNER // exact support
+			}
+			eid = cursor.GetCurEntryID()
+			supports = append(supports, support)
+		}
+		if len(supports) == 0 {
+			continue
+		}
+		for _, support := range supports {
+			for _, value := range support.values {
+				if support.verify(eid, support.field, value) {
+					continue SCANNER
+				}
+			}
+		}
+		return false
+	}
+	return true
+}
@@ -0,0 +1,136 @@
+package be_indexer
+
+import (
+	"testing"
+
+	"github.com/echoface/be_indexer/parser"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+const (
+	prefixParser = "test_prefix_parser"
+)
+
+// prefixBucketParser index and query string by its first two runes, a superset of exact match
+type prefixBucketParser struct {
+	parser.FieldValueParser
+}
+
+func (p *prefixBucketParser) bucket(v interface{}) interface{} {
+	if s, ok := v.(string); ok && len(s) > 2 {
+		return s[:2]
+	}
+	return v
+}
+
+func (p *prefixBucketParser) ParseValue(v interface{}) ([]uint64, error) {
+	return p.FieldValueParser.ParseValue(p.bucket(v))
+}
+
+func (p *prefixBucketParser) ParseAssign(v interface{}) ([]uint64, error) {
+	return p.FieldValueParser.ParseAssign(p.bucket(v))
+}
+
+func (p *prefixBucketParser) Approximate() bool {
+	return true
+}
+
+func init() {
+	parser.RegisterBuilder(prefixParser, func(alloc parser.IDAllocator) parser.FieldValueParser {
+		return &prefixBucketParser{parser.NewCommonStrParser(alloc)}
+	})
+}
+
+func TestWithVerifier(t *testing.T) {
+	LogLevel = ErrorLevel
+
+	cities := map[DocID]string{1: "shanghai", 2: "shenzhen", 3: "beijing", 4: "shanghai", 5: "shenzhen"}
+	verify := func(eid EntryID, field BEField, assigned interface{}) bool {
+		return cities[eid.GetConjID().DocID()] == assigned
+	}
+
+	build := func(option FieldOption) *IndexerBuilder {
+		b := NewIndexerBuilder()
+		b.ConfigField("city", option)
+		for id := DocID(1); id <= 3; id++ {
+			doc := NewDocument(id)
+			doc.AddConjunction(NewConjunction().In("city", NewStrValues(cities[id])))
+			b.AddDocument(doc)
+		}
+		doc := NewDocument(4)
+		doc.AddConjunction(NewConjunction().In("city", NewStrValues(cities[4])).In("tag", NewIntValues(1)))
+		b.AddDocument(doc)
+		doc = NewDocument(5) // approximate support only for city, exact support for tag
+		doc.AddConjunction(NewConjunction().In("city", NewStrValues(cities[5])).In("tag", NewIntValues(1)))
+		b.AddDocument(doc)
+		return b
+	}
+
+	convey.Convey("test prefix parser consistency", t, func() {
+		p := parser.NewParser(prefixParser, parser.NewIDAllocatorImpl())
+		err := parser.VerifyParserConsistency(p, []interface{}{"shanghai", "shenzhen", "x"})
+		convey.So(err, convey.ShouldBeNil)
+	})
+
+	convey.Convey("test approximate match without verifier", t, func() {
+		b := build(FieldOption{Parser: prefixParser})
+		for _, index := range []BEIndex{b.BuildIndex(), b.BuildCompactedIndex()} {
+			result, err := index.Retrieve(Assignments{"city": NewStrValues("shanghai")})
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(len(result), convey.ShouldEqual, 2)
+			convey.So(result.Contain(1) && result.Contain(2), convey.ShouldBeTrue)
+		}
+	})
+
+	convey.Convey("test false positive rejected by verifier of field option", t, func() {
+		b := build(FieldOption{Parser: prefixParser, Verify: verify})
+		for _, index := range []BEIndex{b.BuildIndex(), b.BuildCompactedIndex()} {
+			result, err := index.Retrieve(Assignments{"city": NewStrValues("shanghai")})
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(result, convey.ShouldResemble, DocIDList{1})
+
+			result, err = index.Retrieve(Assignments{"city": NewStrValues("shanghai"), "tag": NewIntValues(1)})
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(len(result), convey.ShouldEqual, 2)
+			convey.So(result.Contain(1) && result.Contain(4), convey.ShouldBeTrue)
+
+			// one of the assigned values verified is enough
+			result, err = index.Retrieve(Assignments{"city": NewStrValues("shanghai", "shenzhen", "beijing")})
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(len(result), convey.ShouldEqual, 3)
+
+			count, err := index.RetrieveCount(Assignments{"city": NewStrValues("shenzhen"), "tag": NewIntValues(1)})
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(count, convey.ShouldEqual, 2)
+		}
+	})
+
+	convey.Convey("test query time verifier override field option", t, func() {
+		b := build(FieldOption{Parser: prefixParser, Verify: verify})
+		acceptAll := func(eid EntryID, field BEField, assigned interface{}) bool {
+			return true
+		}
+		for _, index := range []BEIndex{b.BuildIndex(), b.BuildCompactedIndex()} {
+			result, err := index.Retrieve(Assignments{"city": NewStrValues("shanghai")}, WithVerifier("city", acceptAll))
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(len(result), convey.ShouldEqual, 2)
+		}
+		b = build(FieldOption{Parser: prefixParser})
+		for _, index := range []BEIndex{b.BuildIndex(), b.BuildCompactedIndex()} {
+			result, err := index.Retrieve(Assignments{"city": NewStrValues("shenzhen")}, WithVerifier("city", verify))
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(result, convey.ShouldResemble, DocIDList{2})
+		}
+	})
+
+	convey.Convey("test exact parser unaffected by verifier", t, func() {
+		b := build(FieldOption{Parser: parser.CommonParser, Verify: func(EntryID, BEField, interface{}) bool {
+			return false
+		}})
+		for _, index := range []BEIndex{b.BuildIndex(), b.BuildCompactedIndex()} {
+			result, err := index.Retrieve(Assignments{"city": NewStrValues("shanghai")})
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(result, convey.ShouldResemble, DocIDList{1})
+		}
+	})
+}
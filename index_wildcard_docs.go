@@ -0,0 +1,49 @@
+package be_indexer
+
+import (
+	"fmt"
+	"sort"
+)
+
+/*
+wildcardDocs the page [offset, offset+limit) of docs matched by a empty assignment, sorted by id,
+and the total count; that's docs of wildcard(size-0) conjunctions, exclusion-only ones included
+as nothing assigned is excluded, and docs of conjunctions only made of Absent expressions. the docs
+come from retrieve with the empty assignment, so they can't drift from what Retrieve(Assignments{})
+return; retrieved and sorted once on the first page, a built index never change its matches(Compact
+only drop duplicates), every page after is a slice of it.
+*/
+func (bi *indexBase) wildcardDocsPage(retrieve func(*RetrieveContext, Assignments, ResultCollector) error, offset, limit int) (DocIDList, int, error) {
+	if offset < 0 || limit <= 0 {
+		return nil, 0, fmt.Errorf("invalid page offset:%d limit:%d", offset, limit)
+	}
+	docs, ok := bi.wildcardDocs.Load().(DocIDList)
+	if !ok {
+		collector := newDistinctCollector()
+		if err := retrieve(newRetrieveContext(), Assignments{}, collector); err != nil {
+			return nil, 0, err
+		}
+		docs = collector.GetDocIDs()
+		if docs == nil {
+			docs = DocIDList{}
+		}
+		sort.Sort(docs)
+		bi.wildcardDocs.Store(docs)
+	}
+	if offset >= len(docs) {
+		return DocIDList{}, len(docs), nil
+	}
+	end := len(docs)
+	if limit < end-offset {
+		end = offset + limit
+	}
+	return append(DocIDList{}, docs[offset:end]...), len(docs), nil
+}
+
+func (bi *SizeGroupedBEIndex) WildcardDocs(offset, limit int) (DocIDList, int, error) {
+	return bi.wildcardDocsPage(bi.retrieve, offset, limit)
+}
+
+func (bi *CompactedBEIndex) WildcardDocs(offset, limit int) (DocIDList, int, error) {
+	return bi.wildcardDocsPage(bi.retrieve, offset, limit)
+}
@@ -0,0 +1,80 @@
+package be_indexer
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestBEIndex_WildcardDocs(t *testing.T) {
+	LogLevel = ErrorLevel
+
+	b := NewIndexerBuilder()
+	for id := 1; id <= 120; id++ {
+		doc := NewDocument(DocID(id))
+		switch {
+		case id <= 50: // exclusion-only
+			doc.AddConjunction(NewConjunction().NotIn("city", NewStrValues("sh")))
+		case id <= 60:
+			doc.AddConjunction(NewConjunction().Absent("vip"))
+		case id <= 70: // matched by two size-0 conjunctions, listed once
+			doc.AddConjunction(NewConjunction().NotIn("city", NewStrValues("bj")))
+			doc.AddConjunction(NewConjunction().NotIn("age", NewIntValues(1)))
+		default:
+			doc.AddConjunction(NewConjunction().In("tag", NewIntValues(1)))
+		}
+		_ = b.AddDocument(doc)
+	}
+
+	convey.Convey("test pages concatenate to the empty assignment result", t, func() {
+		index := b.BuildIndex()
+		for _, index := range []BEIndex{index, b.BuildCompactedIndex(), NewAtomicIndexHandle(index)} {
+			expect, err := index.Retrieve(Assignments{})
+			convey.So(err, convey.ShouldBeNil)
+			expect = distinctDocs(expect)
+			sort.Sort(expect)
+			convey.So(len(expect), convey.ShouldEqual, 70)
+
+			var all DocIDList
+			for offset := 0; ; offset += 9 {
+				page, total, err := index.WildcardDocs(offset, 9)
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(total, convey.ShouldEqual, 70)
+				if len(page) == 0 {
+					break
+				}
+				convey.So(len(page), convey.ShouldBeLessThanOrEqualTo, 9)
+				all = append(all, page...)
+			}
+			convey.So(all, convey.ShouldResemble, expect)
+
+			page, total, err := index.WildcardDocs(65, 100)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(total, convey.ShouldEqual, 70)
+			convey.So(page, convey.ShouldResemble, expect[65:])
+
+			_, _, err = index.WildcardDocs(-1, 10)
+			convey.So(err, convey.ShouldNotBeNil)
+			_, _, err = index.WildcardDocs(0, 0)
+			convey.So(err, convey.ShouldNotBeNil)
+		}
+	})
+
+	convey.Convey("test docs retrieved once and paged from then on", t, func() {
+		index := b.BuildCompactedIndex().(*CompactedBEIndex)
+		convey.So(index.wildcardDocs.Load(), convey.ShouldBeNil)
+		page, _, err := index.WildcardDocs(0, 5)
+		convey.So(err, convey.ShouldBeNil)
+		cached := index.wildcardDocs.Load().(DocIDList)
+		convey.So(len(cached), convey.ShouldEqual, 70)
+		convey.So(page, convey.ShouldResemble, cached[:5])
+
+		_, err = index.Compact()
+		convey.So(err, convey.ShouldBeNil)
+		page, total, err := index.WildcardDocs(5, 5)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(total, convey.ShouldEqual, 70)
+		convey.So(page, convey.ShouldResemble, cached[5:10])
+	})
+}
@@ -0,0 +1,137 @@
+package be_indexer
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	MutationAdd    = "add"
+	MutationRemove = "remove"
+	MutationUpdate = "update" // a doc added again with a DocID already added
+)
+
+type (
+	/*MutationLog audit hook of document mutations, see WithMutationLog; it's called synchronously
+	by the mutating call, with a sequence number monotonically increasing within the builder(start
+	from 1), so it should not block. ConcurrentIndexerBuilder call it under its lock, the order of
+	calls is the order of sequence numbers.
+	*/
+	MutationLog interface {
+		OnAdd(seq uint64, at time.Time, id DocID, conjCount int)
+		OnRemove(seq uint64, at time.Time, id DocID)
+		OnUpdate(seq uint64, at time.Time, id DocID)
+	}
+
+	// MutationRecord a line of JSONMutationLog
+	MutationRecord struct {
+		Seq          uint64    `json:"seq"`
+		Time         time.Time `json:"time"`
+		Op           string    `json:"op"`
+		DocID        DocID     `json:"doc_id"`
+		Conjunctions int       `json:"conjunctions,omitempty"` // only for MutationAdd
+	}
+
+	/*JSONMutationLog append MutationRecord as JSON lines to a buffered writer, the buffer flushed
+	when index built(the point mutations take effect) or Flush called; the first write error is kept
+	and returned by Flush, records after it are dropped.
+	*/
+	JSONMutationLog struct {
+		mtx sync.Mutex
+		w   *bufio.Writer
+		enc *json.Encoder
+		err error
+	}
+)
+
+// WithMutationLog log every AddDocument/RemoveDocument/Reset of builder to log
+func WithMutationLog(log MutationLog) BuilderOpt {
+	return func(builder *IndexerBuilder) {
+		builder.mutationLog = log
+	}
+}
+
+func NewJSONMutationLog(w io.Writer) *JSONMutationLog {
+	buffered := bufio.NewWriter(w)
+	return &JSONMutationLog{
+		w:   buffered,
+		enc: json.NewEncoder(buffered),
+	}
+}
+
+func (l *JSONMutationLog) write(record *MutationRecord) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	if l.err != nil {
+		return
+	}
+	l.err = l.enc.Encode(record)
+}
+
+func (l *JSONMutationLog) OnAdd(seq uint64, at time.Time, id DocID, conjCount int) {
+	l.write(&MutationRecord{Seq: seq, Time: at, Op: MutationAdd, DocID: id, Conjunctions: conjCount})
+}
+
+func (l *JSONMutationLog) OnRemove(seq uint64, at time.Time, id DocID) {
+	l.write(&MutationRecord{Seq: seq, Time: at, Op: MutationRemove, DocID: id})
+}
+
+func (l *JSONMutationLog) OnUpdate(seq uint64, at time.Time, id DocID) {
+	l.write(&MutationRecord{Seq: seq, Time: at, Op: MutationUpdate, DocID: id})
+}
+
+// Flush write buffered records to the underlying writer
+func (l *JSONMutationLog) Flush() error {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	if l.err != nil {
+		return l.err
+	}
+	l.err = l.w.Flush()
+	return l.err
+}
+
+func (b *IndexerBuilder) logAdd(doc *Document, updated bool) {
+	b.mutationSeq++
+	if b.mutationLog == nil {
+		return
+	}
+	if updated {
+		b.mutationLog.OnUpdate(b.mutationSeq, time.Now(), doc.ID)
+		return
+	}
+	b.mutationLog.OnAdd(b.mutationSeq, time.Now(), doc.ID, len(doc.Cons))
+}
+
+func (b *IndexerBuilder) logRemove(ids ...DocID) {
+	if b.mutationLog == nil {
+		b.mutationSeq += uint64(len(ids))
+		return
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i] < ids[j]
+	})
+	for _, id := range ids {
+		b.mutationSeq++
+		b.mutationLog.OnRemove(b.mutationSeq, time.Now(), id)
+	}
+}
+
+// flushMutationLog flush the log if it's buffered(has Flush method like JSONMutationLog)
+func (b *IndexerBuilder) flushMutationLog() {
+	flusher, ok := b.mutationLog.(interface{ Flush() error })
+	if !ok {
+		return
+	}
+	if err := flusher.Flush(); err != nil {
+		Logger.Errorf("flush mutation log fail:%s", err.Error())
+	}
+}
+
+func (bi *indexBase) setMutationSeq(seq uint64) {
+	bi.mutationSeq = seq
+}
@@ -0,0 +1,142 @@
+package be_indexer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+type recordMutationLog struct {
+	records []MutationRecord
+}
+
+func (l *recordMutationLog) OnAdd(seq uint64, at time.Time, id DocID, conjCount int) {
+	l.records = append(l.records, MutationRecord{Seq: seq, Time: at, Op: MutationAdd, DocID: id, Conjunctions: conjCount})
+}
+
+func (l *recordMutationLog) OnRemove(seq uint64, at time.Time, id DocID) {
+	l.records = append(l.records, MutationRecord{Seq: seq, Time: at, Op: MutationRemove, DocID: id})
+}
+
+func (l *recordMutationLog) OnUpdate(seq uint64, at time.Time, id DocID) {
+	l.records = append(l.records, MutationRecord{Seq: seq, Time: at, Op: MutationUpdate, DocID: id})
+}
+
+func mutationTestDoc(id DocID) *Document {
+	doc := NewDocument(id)
+	doc.AddConjunction(NewConjunction().In("tag", NewIntValues(int(id))))
+	return doc
+}
+
+func TestWithMutationLog(t *testing.T) {
+	LogLevel = ErrorLevel
+
+	convey.Convey("test mutation log of builder", t, func() {
+		log := &recordMutationLog{}
+		b := NewIndexerBuilder(WithMutationLog(log))
+		b.AddDocument(mutationTestDoc(1))
+		b.AddDocument(mutationTestDoc(2))
+		b.AddDocument(mutationTestDoc(1))
+		convey.So(b.RemoveDocument(2), convey.ShouldBeTrue)
+		convey.So(b.RemoveDocument(2), convey.ShouldBeFalse)
+		b.AddDocument(mutationTestDoc(3))
+		b.Reset()
+
+		ops := make([]string, 0, len(log.records))
+		for i, record := range log.records {
+			convey.So(record.Seq, convey.ShouldEqual, uint64(i+1))
+			ops = append(ops, record.Op)
+		}
+		convey.So(ops, convey.ShouldResemble, []string{
+			MutationAdd, MutationAdd, MutationUpdate, MutationRemove, MutationAdd, MutationRemove, MutationRemove,
+		})
+		convey.So(log.records[0].Conjunctions, convey.ShouldEqual, 1)
+		convey.So(log.records[5].DocID, convey.ShouldEqual, 1)
+		convey.So(log.records[6].DocID, convey.ShouldEqual, 3)
+
+		b.AddDocument(mutationTestDoc(4))
+		for _, index := range []BEIndex{b.BuildIndex(), b.BuildCompactedIndex()} {
+			convey.So(index.Stats().MutationSeq, convey.ShouldEqual, 8)
+		}
+	})
+
+	convey.Convey("test mutation seq without log", t, func() {
+		b := NewIndexerBuilder()
+		b.AddDocument(mutationTestDoc(1))
+		b.RemoveDocument(1)
+		convey.So(b.BuildIndex().Stats().MutationSeq, convey.ShouldEqual, 2)
+	})
+}
+
+func TestJSONMutationLog(t *testing.T) {
+	LogLevel = ErrorLevel
+
+	convey.Convey("test json mutation log flushed when index built", t, func() {
+		buf := &bytes.Buffer{}
+		b := NewIndexerBuilder(WithMutationLog(NewJSONMutationLog(buf)))
+		b.AddDocument(mutationTestDoc(1))
+		convey.So(buf.Len(), convey.ShouldEqual, 0)
+
+		b.BuildIndex()
+		record := MutationRecord{}
+		convey.So(json.Unmarshal(buf.Bytes(), &record), convey.ShouldBeNil)
+		convey.So(record.Seq, convey.ShouldEqual, 1)
+		convey.So(record.Op, convey.ShouldEqual, MutationAdd)
+		convey.So(record.DocID, convey.ShouldEqual, 1)
+	})
+
+	convey.Convey("test json mutation log order under concurrent mutations", t, func() {
+		buf := &bytes.Buffer{}
+		log := NewJSONMutationLog(buf)
+		b := NewConcurrentIndexerBuilder(WithMutationLog(log))
+
+		wg := sync.WaitGroup{}
+		for g := 0; g < 8; g++ {
+			wg.Add(1)
+			go func(g int) {
+				defer wg.Done()
+				for i := 0; i < 100; i++ {
+					id := DocID(g*100 + i + 1)
+					b.AddDocument(mutationTestDoc(id))
+					if i%10 == 0 {
+						b.RemoveDocument(id)
+					}
+				}
+			}(g)
+		}
+		wg.Wait()
+
+		index := b.BuildIndex()
+		convey.So(index.Stats().MutationSeq, convey.ShouldEqual, 880)
+
+		var records []MutationRecord
+		scanner := bufio.NewScanner(buf)
+		for scanner.Scan() {
+			record := MutationRecord{}
+			convey.So(json.Unmarshal(scanner.Bytes(), &record), convey.ShouldBeNil)
+			records = append(records, record)
+		}
+		convey.So(len(records), convey.ShouldEqual, 880)
+
+		added := make(map[DocID]bool)
+		for i, record := range records {
+			convey.So(record.Seq, convey.ShouldEqual, uint64(i+1))
+			if i > 0 {
+				convey.So(record.Time.Before(records[i-1].Time), convey.ShouldBeFalse)
+			}
+			switch record.Op {
+			case MutationAdd:
+				added[record.DocID] = true
+			case MutationRemove:
+				convey.So(added[record.DocID], convey.ShouldBeTrue) // removal logged after its add
+			}
+		}
+		convey.So(len(added), convey.ShouldEqual, 800)
+		convey.So(log.Flush(), convey.ShouldBeNil)
+	})
+}
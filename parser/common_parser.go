@@ -2,10 +2,12 @@ package parser
 
 import (
 	"fmt"
-	"reflect"
 )
 
 type (
+	// CommonStrParser tokenize a value as it is, numbers in canonical form(see canonicalToken), so
+	// 25, int64(25), 25.0 and json.Number("25") are the same token as string "25", 1.5 and
+	// json.Number("1.5") the token "1.5"; strings are never reformatted("025" is not "25")
 	CommonStrParser struct {
 		idAlloc IDAllocator
 	}
@@ -18,6 +20,9 @@ func NewCommonStrParser(allocator IDAllocator) FieldValueParser {
 }
 
 func (p *CommonStrParser) ParseAssign(v interface{}) (values []uint64, e error) {
+	if e = rejectRange(v); e != nil {
+		return nil, e
+	}
 	switch t := v.(type) {
 	case string:
 		v, found := p.idAlloc.FindStringID(&t)
@@ -25,16 +30,19 @@ func (p *CommonStrParser) ParseAssign(v interface{}) (values []uint64, e error)
 			return
 		}
 		return []uint64{v}, nil
-	case int, uint, uint8, int8, int32, uint32, int64, uint64, float64, float32:
-		s := fmt.Sprintf("%v", t)
+	default:
+		s, ok, err := canonicalToken(t)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, fmt.Errorf("value type [%T] not support", v)
+		}
 		v, found := p.idAlloc.FindStringID(&s)
 		if !found {
 			return
 		}
 		return []uint64{v}, nil
-	default:
-		valueType := reflect.TypeOf(v)
-		return nil, fmt.Errorf("value type [%s] not support", valueType.String())
 	}
 }
 
@@ -42,11 +50,19 @@ func (p *CommonStrParser) ParseValue(v interface{}) ([]uint64, error) {
 	switch value := v.(type) {
 	case string:
 		return []uint64{p.idAlloc.AllocStringID(value)}, nil
-	case int, uint, uint8, int8, int32, uint32, int64, uint64, float32, float64:
-		s := fmt.Sprintf("%v", value)
-		return []uint64{p.idAlloc.AllocStringID(s)}, nil
 	default:
-		valueType := reflect.TypeOf(v)
-		return nil, fmt.Errorf("value type [%s] not support", valueType.String())
+		s, ok, err := canonicalToken(value)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, fmt.Errorf("value type [%T] not support", v)
+		}
+		return []uint64{p.idAlloc.AllocStringID(s)}, nil
 	}
 }
+
+// ValueOf implement Reversible
+func (p *CommonStrParser) ValueOf(id uint64) (string, bool) {
+	return reverseString(p.idAlloc, id)
+}
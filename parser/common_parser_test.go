@@ -1,8 +1,13 @@
 package parser
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
 	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
 )
 
 func TestCommonStrParser_ParseValue(t *testing.T) {
@@ -30,3 +35,76 @@ func TestCommonStrParser_ParseValue(t *testing.T) {
 	r, e = parser.ParseValue([3]string{"gonghuan", "hello", "1"})
 	fmt.Println("result:", r, e)
 }
+
+func TestNumberCanonicalization(t *testing.T) {
+	numbers := []interface{}{
+		int(25), int8(25), int16(25), int32(25), int64(25),
+		uint(25), uint8(25), uint16(25), uint32(25), uint64(25),
+		float32(25), float64(25), json.Number("25"), json.Number("25.0"), "25",
+	}
+	parsers := map[string]func() FieldValueParser{
+		"common":    func() FieldValueParser { return NewCommonStrParser(NewIDAllocatorImpl()) },
+		"num_range": func() FieldValueParser { return NewNumRangeParser(NewIDAllocatorImpl()) },
+	}
+	for name, newParser := range parsers {
+		convey.Convey(fmt.Sprintf("test %s parser give numbers of all types the same id", name), t, func() {
+			p := newParser()
+			expect, err := p.ParseValue(numbers[0])
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(len(expect), convey.ShouldEqual, 1)
+			for _, v := range numbers {
+				ids, err := p.ParseValue(v)
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(ids, convey.ShouldResemble, expect)
+
+				ids, err = p.ParseAssign(v)
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(ids, convey.ShouldResemble, expect)
+			}
+
+			if name != "num_range" {
+				return
+			}
+			for _, v := range []interface{}{25.5, float32(25.5), json.Number("25.5"), math.NaN(), math.Inf(1)} {
+				_, err = p.ParseValue(v)
+				convey.So(errors.Is(err, ErrNonIntegralNumber), convey.ShouldBeTrue)
+				_, err = p.ParseAssign(v)
+				convey.So(errors.Is(err, ErrNonIntegralNumber), convey.ShouldBeTrue)
+			}
+		})
+	}
+
+	convey.Convey("test common parser tokenize fractional numbers", t, func() {
+		alloc := NewIDAllocatorImpl()
+		p := NewCommonStrParser(alloc)
+		expect, err := p.ParseValue("25.5")
+		convey.So(err, convey.ShouldBeNil)
+		for _, v := range []interface{}{25.5, float32(25.5), json.Number("25.5"), json.Number("2.55e1")} {
+			ids, err := p.ParseValue(v)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(ids, convey.ShouldResemble, expect)
+			ids, err = p.ParseAssign(v)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(ids, convey.ShouldResemble, expect)
+		}
+
+		ids, err := p.ParseValue(float32(0.1))
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(ids, convey.ShouldResemble, []uint64{alloc.AllocStringID("0.1")})
+		_, err = p.ParseValue(math.NaN())
+		convey.So(err, convey.ShouldBeNil)
+	})
+
+	convey.Convey("test big numbers", t, func() {
+		p := NewCommonStrParser(NewIDAllocatorImpl())
+		ids, _ := p.ParseValue(uint64(1 << 63))
+		convey.So(ids, convey.ShouldNotBeEmpty)
+		res, _ := p.ParseAssign(json.Number("9223372036854775808"))
+		convey.So(res, convey.ShouldResemble, ids)
+		res, _ = p.ParseAssign(float64(1 << 63))
+		convey.So(res, convey.ShouldResemble, ids)
+
+		_, err := NewNumRangeParser(NewIDAllocatorImpl()).ParseValue(uint64(1 << 63))
+		convey.So(err, convey.ShouldNotBeNil) // out of int64
+	})
+}
@@ -0,0 +1,116 @@
+package parser
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+)
+
+/*
+value dictionary format, JSON lines:
+
+	line 1, header: {"field":"age","parser":"#num_range","params":{...}}
+	other lines, one per value: {"s":"shoes","id":3} for string value, {"n":18,"id":1} for number value
+
+a field whose parser implement HashTokenizer export the header with HashParams only, bz its ids
+can't be looked up by value, the query side must tokenize with the same params
+*/
+type (
+	DictionaryHeader struct {
+		Field  string            `json:"field"`
+		Parser string            `json:"parser"`
+		Params map[string]string `json:"params,omitempty"`
+	}
+
+	dictionaryEntry struct {
+		Str *string `json:"s,omitempty"`
+		Num *int64  `json:"n,omitempty"`
+		ID  uint64  `json:"id"`
+	}
+
+	// HashTokenizer parser tokenize value by hash/signature instead of IDAllocator lookup
+	HashTokenizer interface {
+		HashParams() map[string]string
+	}
+)
+
+// WriteDictionary write header and values of alloc whose id accepted by keep, numeric select the
+// number values(AllocNumID) instead of string values(AllocStringID)
+func WriteDictionary(w io.Writer, header DictionaryHeader, alloc IDAllocator, numeric bool, keep func(id uint64) bool) error {
+	impl, ok := alloc.(*IDAllocatorImpl)
+	if !ok {
+		return fmt.Errorf("allocator type:%T not support dictionary export", alloc)
+	}
+
+	entries := make([]dictionaryEntry, 0)
+	if numeric {
+		for v, id := range impl.numBox {
+			if keep(id) {
+				num := v
+				entries = append(entries, dictionaryEntry{Num: &num, ID: id})
+			}
+		}
+	} else {
+		for v, id := range impl.strBox {
+			if keep(id) {
+				str := v
+				entries = append(entries, dictionaryEntry{Str: &str, ID: id})
+			}
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].ID < entries[j].ID
+	})
+
+	bw := bufio.NewWriter(w)
+	encoder := json.NewEncoder(bw)
+	if err := encoder.Encode(&header); err != nil {
+		return err
+	}
+	for idx := range entries {
+		if err := encoder.Encode(&entries[idx]); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// LoadDictionary reconstruct a IDAllocator from dictionary written by WriteDictionary, values get
+// exactly the exported ids, so it can tokenize query values for the exported index; it's not
+// reversible, the dictionary itself is the reverse
+func LoadDictionary(r io.Reader) (IDAllocator, error) {
+	alloc := newIDAllocatorImpl(false)
+
+	decoder := json.NewDecoder(r)
+	header := DictionaryHeader{}
+	if err := decoder.Decode(&header); err != nil {
+		return nil, fmt.Errorf("decode dictionary header fail:%s", err.Error())
+	}
+	for line := 2; ; line++ {
+		entry := dictionaryEntry{}
+		err := decoder.Decode(&entry)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("decode dictionary line:%d fail:%s", line, err.Error())
+		}
+		switch {
+		case entry.Str != nil && entry.Num == nil:
+			if id, ok := alloc.strBox[*entry.Str]; ok && id != entry.ID {
+				return nil, fmt.Errorf("dictionary line:%d value:%s has conflict ids", line, *entry.Str)
+			}
+			alloc.strBox[*entry.Str] = entry.ID
+		case entry.Num != nil && entry.Str == nil:
+			if id, ok := alloc.numBox[*entry.Num]; ok && id != entry.ID {
+				return nil, fmt.Errorf("dictionary line:%d value:%d has conflict ids", line, *entry.Num)
+			}
+			alloc.numBox[*entry.Num] = entry.ID
+		default:
+			return nil, fmt.Errorf("dictionary line:%d need exactly one of s/n", line)
+		}
+	}
+	return alloc, nil
+}
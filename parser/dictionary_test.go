@@ -0,0 +1,55 @@
+package parser
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestDictionary_RoundTrip(t *testing.T) {
+	convey.Convey("test dictionary write and load", t, func() {
+		alloc := NewIDAllocatorImpl()
+		alloc.(Preloader).Preload([]string{"us", "cn", "jp"})
+		alloc.AllocNumID(18)
+		alloc.AllocNumID(30)
+
+		buf := &bytes.Buffer{}
+		header := DictionaryHeader{Field: "country", Parser: CommonParser}
+		keep := func(id uint64) bool { return id != 1 }
+		convey.So(WriteDictionary(buf, header, alloc, false, keep), convey.ShouldBeNil)
+		convey.So(buf.String(), convey.ShouldEqual,
+			"{\"field\":\"country\",\"parser\":\"#common\"}\n{\"s\":\"us\",\"id\":0}\n{\"s\":\"jp\",\"id\":2}\n")
+
+		loaded, err := LoadDictionary(buf)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(loaded.TotalIDCount(), convey.ShouldEqual, 2)
+		for _, v := range []string{"us", "jp"} {
+			expect, _ := alloc.FindStringID(&v)
+			id, found := loaded.FindStringID(&v)
+			convey.So(found, convey.ShouldBeTrue)
+			convey.So(id, convey.ShouldEqual, expect)
+		}
+
+		buf.Reset()
+		keepAll := func(uint64) bool { return true }
+		convey.So(WriteDictionary(buf, header, alloc, true, keepAll), convey.ShouldBeNil)
+		loaded, err = LoadDictionary(buf)
+		convey.So(err, convey.ShouldBeNil)
+		id, found := loaded.FindNumID(30)
+		convey.So(found, convey.ShouldBeTrue)
+		convey.So(id, convey.ShouldEqual, 1)
+	})
+
+	convey.Convey("test load bad dictionary", t, func() {
+		_, err := LoadDictionary(strings.NewReader(""))
+		convey.So(err, convey.ShouldNotBeNil)
+
+		_, err = LoadDictionary(strings.NewReader("{\"field\":\"f\"}\n{\"id\":1}\n"))
+		convey.So(err, convey.ShouldNotBeNil)
+
+		_, err = LoadDictionary(strings.NewReader("{\"field\":\"f\"}\n{\"s\":\"a\",\"id\":1}\n{\"s\":\"a\",\"id\":2}\n"))
+		convey.So(err, convey.ShouldNotBeNil)
+	})
+}
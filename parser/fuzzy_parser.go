@@ -0,0 +1,167 @@
+package parser
+
+import (
+	"fmt"
+	"strconv"
+)
+
+/*
+FuzzyParser match string term within a edit(levenshtein) distance, for typo-tolerant keyword.
+ParseValue alloc id for the indexed term and insert it into a BK-tree; ParseAssign search
+the BK-tree for stored terms within distance of query term and return their ids.
+cost: a query visit only the subtrees whose edge distance in [d-distance, d+distance], each
+visit compute a O(len(a)*len(b)) edit distance; the visited nodes grow fast with distance,
+so distance capped to maxFuzzyDistance.
+params(see Configure):
+
+	distance: max edit distance to match, [0, 2], default 1
+*/
+type (
+	FuzzyParser struct {
+		idAlloc  IDAllocator
+		distance int
+		root     *bkNode
+	}
+
+	bkNode struct {
+		term     []rune
+		id       uint64
+		children map[int]*bkNode // edit distance -> child
+	}
+)
+
+const (
+	maxFuzzyDistance = 2
+)
+
+func NewFuzzyParser(allocator IDAllocator) FieldValueParser {
+	return &FuzzyParser{
+		idAlloc:  allocator,
+		distance: 1,
+	}
+}
+
+// Configure implement Configurable, must be called before parsing any value
+func (p *FuzzyParser) Configure(params map[string]string) (err error) {
+	for k, v := range params {
+		switch k {
+		case "distance":
+			if p.distance, err = strconv.Atoi(v); err != nil || p.distance < 0 || p.distance > maxFuzzyDistance {
+				return fmt.Errorf("invalid distance:%s, need a int in [0, %d]", v, maxFuzzyDistance)
+			}
+		default:
+			return fmt.Errorf("unknown fuzzy parser param:%s", k)
+		}
+	}
+	return nil
+}
+
+func (p *FuzzyParser) toTerm(v interface{}) (string, error) {
+	switch t := v.(type) {
+	case string:
+		return t, nil
+	case int, uint, uint8, int8, int32, uint32, int64, uint64, float32, float64:
+		return fmt.Sprintf("%v", t), nil
+	default:
+		return "", fmt.Errorf("value type [%T] not support", v)
+	}
+}
+
+func (p *FuzzyParser) ParseValue(v interface{}) ([]uint64, error) {
+	term, err := p.toTerm(v)
+	if err != nil {
+		return nil, err
+	}
+	id := p.idAlloc.AllocStringID(term)
+	p.insert([]rune(term), id)
+	return []uint64{id}, nil
+}
+
+func (p *FuzzyParser) ParseAssign(v interface{}) (res []uint64, err error) {
+	if err = rejectRange(v); err != nil {
+		return nil, err
+	}
+	term, err := p.toTerm(v)
+	if err != nil {
+		return nil, err
+	}
+	if p.root == nil {
+		return nil, nil
+	}
+	query := []rune(term)
+	candidates := []*bkNode{p.root}
+	for len(candidates) > 0 {
+		node := candidates[len(candidates)-1]
+		candidates = candidates[:len(candidates)-1]
+
+		d := editDistance(query, node.term)
+		if d <= p.distance {
+			res = append(res, node.id)
+		}
+		for edge, child := range node.children {
+			if edge >= d-p.distance && edge <= d+p.distance {
+				candidates = append(candidates, child)
+			}
+		}
+	}
+	return res, nil
+}
+
+func (p *FuzzyParser) insert(term []rune, id uint64) {
+	if p.root == nil {
+		p.root = &bkNode{term: term, id: id}
+		return
+	}
+	node := p.root
+	for {
+		d := editDistance(term, node.term)
+		if d == 0 { // indexed before
+			return
+		}
+		child, ok := node.children[d]
+		if !ok {
+			if node.children == nil {
+				node.children = make(map[int]*bkNode)
+			}
+			node.children[d] = &bkNode{term: term, id: id}
+			return
+		}
+		node = child
+	}
+}
+
+// editDistance levenshtein distance of two rune slice
+func editDistance(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = minOf3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+func minOf3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+// ValueOf implement Reversible
+func (p *FuzzyParser) ValueOf(id uint64) (string, bool) {
+	return reverseString(p.idAlloc, id)
+}
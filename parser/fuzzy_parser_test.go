@@ -0,0 +1,54 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestFuzzyParser(t *testing.T) {
+	convey.Convey("test fuzzy parser match within edit distance", t, func() {
+		p := NewFuzzyParser(NewIDAllocatorImpl())
+
+		ids, err := p.ParseAssign("shoes")
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(ids, convey.ShouldBeEmpty)
+
+		shoes, err := p.ParseValue("shoes")
+		convey.So(err, convey.ShouldBeNil)
+		boots, _ := p.ParseValue("boots")
+		_, _ = p.ParseValue("laptop")
+
+		ids, _ = p.ParseAssign("shoes")
+		convey.So(ids, convey.ShouldResemble, shoes)
+		ids, _ = p.ParseAssign("shoez") // one substitution
+		convey.So(ids, convey.ShouldResemble, shoes)
+		ids, _ = p.ParseAssign("shos") // one deletion
+		convey.So(ids, convey.ShouldResemble, shoes)
+		ids, _ = p.ParseAssign("boots!") // one insertion
+		convey.So(ids, convey.ShouldResemble, boots)
+
+		ids, _ = p.ParseAssign("shozz") // two edits
+		convey.So(ids, convey.ShouldBeEmpty)
+
+		_, err = p.ParseAssign([]int{1})
+		convey.So(err, convey.ShouldNotBeNil)
+	})
+
+	convey.Convey("test fuzzy parser distance param", t, func() {
+		p := NewFuzzyParser(NewIDAllocatorImpl())
+		convey.So(p.(Configurable).Configure(map[string]string{"distance": "3"}), convey.ShouldNotBeNil)
+		convey.So(p.(Configurable).Configure(map[string]string{"unknown": "1"}), convey.ShouldNotBeNil)
+		convey.So(p.(Configurable).Configure(map[string]string{"distance": "2"}), convey.ShouldBeNil)
+
+		shoes, _ := p.ParseValue("shoes")
+		ids, _ := p.ParseAssign("shozz")
+		convey.So(ids, convey.ShouldResemble, shoes)
+	})
+
+	convey.Convey("test edit distance", t, func() {
+		convey.So(editDistance([]rune("kitten"), []rune("sitting")), convey.ShouldEqual, 3)
+		convey.So(editDistance([]rune(""), []rune("abc")), convey.ShouldEqual, 3)
+		convey.So(editDistance([]rune("中文"), []rune("中国")), convey.ShouldEqual, 1)
+	})
+}
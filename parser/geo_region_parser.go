@@ -0,0 +1,105 @@
+package parser
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+/*
+GeoRegionParser index a region name as it is, and resolve a query region up through
+the region hierarchy(city->state->country), so a rule targeting "California" will
+match a query for "San Francisco" when "San Francisco" belong to "California".
+param "levels" limit how many levels up a query region resolved, eg: with levels=1 a query for
+"San Francisco" match rules of "San Francisco" and "California" but not "US"; 0(default) means no limit
+*/
+type (
+	GeoRegionParser struct {
+		idAlloc IDAllocator
+		parents map[string]string // region -> parent region
+		levels  int               // see Configure
+	}
+)
+
+// NewGeoRegionParser hierarchy describe each region's direct parent, eg: {"San Francisco": "California", "California": "US"}
+func NewGeoRegionParser(allocator IDAllocator, hierarchy map[string]string) FieldValueParser {
+	parents := make(map[string]string, len(hierarchy))
+	for region, parent := range hierarchy {
+		parents[region] = parent
+	}
+	return &GeoRegionParser{
+		idAlloc: allocator,
+		parents: parents,
+	}
+}
+
+// NewGeoRegionParserBuilder create a Builder can be registered by RegisterBuilder
+func NewGeoRegionParserBuilder(hierarchy map[string]string) Builder {
+	return func(allocator IDAllocator) FieldValueParser {
+		return NewGeoRegionParser(allocator, hierarchy)
+	}
+}
+
+// Ancestors return region itself and all the regions it belongs to, from nearest to farthest
+func (p *GeoRegionParser) Ancestors(region string) []string {
+	res := []string{region}
+	visited := map[string]struct{}{region: {}}
+	for parent, ok := p.parents[region]; ok; parent, ok = p.parents[parent] {
+		if _, loop := visited[parent]; loop { // bad hierarchy config, stop here
+			break
+		}
+		visited[parent] = struct{}{}
+		res = append(res, parent)
+	}
+	return res
+}
+
+// Configure implement Configurable, must be called before parsing any value
+func (p *GeoRegionParser) Configure(params map[string]string) (err error) {
+	for k, v := range params {
+		switch k {
+		case "levels":
+			if p.levels, err = strconv.Atoi(v); err != nil || p.levels < 0 {
+				return fmt.Errorf("invalid levels:%s, need a non-negative int", v)
+			}
+		default:
+			return fmt.Errorf("unknown geo region parser param:%s", k)
+		}
+	}
+	return nil
+}
+
+// ParseAssign parse query region into ids of the region and its ancestors(up to levels)
+func (p *GeoRegionParser) ParseAssign(v interface{}) (res []uint64, err error) {
+	if err = rejectRange(v); err != nil {
+		return nil, err
+	}
+	region, ok := v.(string)
+	if !ok {
+		return nil, fmt.Errorf("geo region need string value, got type [%s]", reflect.TypeOf(v))
+	}
+	regions := p.Ancestors(region)
+	if p.levels > 0 && len(regions) > p.levels+1 {
+		regions = regions[:p.levels+1]
+	}
+	for _, name := range regions {
+		if id, found := p.idAlloc.FindStringID(&name); found {
+			res = append(res, id)
+		}
+	}
+	return res, nil
+}
+
+// ParseValue parse indexing region into id of the region itself
+func (p *GeoRegionParser) ParseValue(v interface{}) ([]uint64, error) {
+	region, ok := v.(string)
+	if !ok {
+		return nil, fmt.Errorf("geo region need string value, got type [%s]", reflect.TypeOf(v))
+	}
+	return []uint64{p.idAlloc.AllocStringID(region)}, nil
+}
+
+// ValueOf implement Reversible
+func (p *GeoRegionParser) ValueOf(id uint64) (string, bool) {
+	return reverseString(p.idAlloc, id)
+}
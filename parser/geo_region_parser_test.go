@@ -0,0 +1,63 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestGeoRegionParser(t *testing.T) {
+	convey.Convey("test geo region parser", t, func() {
+		hierarchy := map[string]string{
+			"San Francisco": "California",
+			"Los Angeles":   "California",
+			"California":    "US",
+			"Seattle":       "Washington",
+			"Washington":    "US",
+			"A":             "B",
+			"B":             "A",
+		}
+		idGen := NewIDAllocatorImpl()
+		p := NewGeoRegionParser(idGen, hierarchy)
+
+		caID, err := p.ParseValue("California")
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(len(caID), convey.ShouldEqual, 1)
+
+		ids, err := p.ParseAssign("San Francisco")
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(ids, convey.ShouldResemble, caID)
+
+		ids, err = p.ParseAssign("Seattle")
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(len(ids), convey.ShouldEqual, 0)
+
+		usID, _ := p.ParseValue("US")
+		ids, err = p.ParseAssign("Seattle")
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(ids, convey.ShouldResemble, usID)
+
+		convey.So(p.(*GeoRegionParser).Ancestors("A"), convey.ShouldResemble, []string{"A", "B"})
+
+		_, err = p.ParseValue(12)
+		convey.So(err, convey.ShouldNotBeNil)
+		_, err = p.ParseAssign(12)
+		convey.So(err, convey.ShouldNotBeNil)
+	})
+	convey.Convey("test geo region parser levels param", t, func() {
+		hierarchy := map[string]string{
+			"San Francisco": "California",
+			"California":    "US",
+		}
+		p := NewGeoRegionParser(NewIDAllocatorImpl(), hierarchy)
+		convey.So(p.(Configurable).Configure(map[string]string{"levels": "-1"}), convey.ShouldNotBeNil)
+		convey.So(p.(Configurable).Configure(map[string]string{"unknown": "1"}), convey.ShouldNotBeNil)
+		convey.So(p.(Configurable).Configure(map[string]string{"levels": "1"}), convey.ShouldBeNil)
+
+		caID, _ := p.ParseValue("California")
+		_, _ = p.ParseValue("US")
+		ids, err := p.ParseAssign("San Francisco")
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(ids, convey.ShouldResemble, caID)
+	})
+}
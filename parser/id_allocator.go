@@ -8,18 +8,49 @@ type (
 		FindNumID(v int64) (value uint64, found bool)
 		FindStringID(v *string) (value uint64, found bool)
 	}
+
+	// Preloader allocator can assign string ids for values in order before any other allocation,
+	// so ids are stable regardless of ingest order; a value already allocated keep its id
+	Preloader interface {
+		Preload(values []string)
+	}
 	/*用于将不同类型的值ID化，用于构造Index的PostingList，减少重复值*/
 	IDAllocatorImpl struct {
 		numBox map[int64]uint64  //用于将整形数字重新安排
 		strBox map[string]uint64 //将string转变成紧凑的ID
+
+		numRev map[uint64]int64 // reverse of numBox, nil unless reversible, see ReverseIDAllocator
+		strRev map[uint64]string
+	}
+
+	// ReverseIDAllocator allocator can lookup the value from a allocated id, see NewReversibleIDAllocator
+	ReverseIDAllocator interface {
+		NumValue(id uint64) (v int64, found bool)
+		StringValue(id uint64) (v string, found bool)
 	}
 )
 
+// NewIDAllocatorImpl a allocator without reverse lookup, its ReverseIDAllocator methods find nothing
 func NewIDAllocatorImpl() IDAllocator {
-	return &IDAllocatorImpl{
+	return newIDAllocatorImpl(false)
+}
+
+// NewReversibleIDAllocator a allocator keep the id->value maps for ReverseIDAllocator(eg: decoding
+// values for export, see Reversible), it cost a map entry more per value
+func NewReversibleIDAllocator() IDAllocator {
+	return newIDAllocatorImpl(true)
+}
+
+func newIDAllocatorImpl(reversible bool) *IDAllocatorImpl {
+	alloc := &IDAllocatorImpl{
 		numBox: make(map[int64]uint64),
 		strBox: make(map[string]uint64),
 	}
+	if reversible {
+		alloc.numRev = make(map[uint64]int64)
+		alloc.strRev = make(map[uint64]string)
+	}
+	return alloc
 }
 
 func (alloc *IDAllocatorImpl) TotalIDCount() uint64 {
@@ -45,6 +76,9 @@ func (alloc *IDAllocatorImpl) AllocNumID(v int64) uint64 {
 	}
 	id := uint64(len(alloc.numBox))
 	alloc.numBox[v] = id
+	if alloc.numRev != nil {
+		alloc.numRev[id] = v
+	}
 	return id
 }
 
@@ -55,5 +89,24 @@ func (alloc *IDAllocatorImpl) AllocStringID(v string) uint64 {
 
 	id := uint64(len(alloc.strBox))
 	alloc.strBox[v] = id
+	if alloc.strRev != nil {
+		alloc.strRev[id] = v
+	}
 	return id
 }
+
+func (alloc *IDAllocatorImpl) Preload(values []string) {
+	for _, v := range values {
+		alloc.AllocStringID(v)
+	}
+}
+
+func (alloc *IDAllocatorImpl) NumValue(id uint64) (v int64, found bool) {
+	v, found = alloc.numRev[id]
+	return
+}
+
+func (alloc *IDAllocatorImpl) StringValue(id uint64) (v string, found bool) {
+	v, found = alloc.strRev[id]
+	return
+}
@@ -0,0 +1,60 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestIDAllocatorImpl_Preload(t *testing.T) {
+	convey.Convey("test preload values get sequential ids", t, func() {
+		alloc := NewIDAllocatorImpl()
+		alloc.(Preloader).Preload([]string{"us", "cn", "jp", "cn"})
+
+		for idx, v := range []string{"us", "cn", "jp"} {
+			id, found := alloc.FindStringID(&v)
+			convey.So(found, convey.ShouldBeTrue)
+			convey.So(id, convey.ShouldEqual, uint64(idx))
+		}
+		convey.So(alloc.TotalIDCount(), convey.ShouldEqual, 3)
+		convey.So(alloc.AllocStringID("uk"), convey.ShouldEqual, 3)
+		convey.So(alloc.AllocStringID("jp"), convey.ShouldEqual, 2)
+	})
+}
+
+func TestIDAllocatorImpl_Reverse(t *testing.T) {
+	convey.Convey("test only reversible allocator lookup values", t, func() {
+		plain, reversible := NewIDAllocatorImpl(), NewReversibleIDAllocator()
+		for _, alloc := range []IDAllocator{plain, reversible} {
+			alloc.AllocStringID("us")
+			alloc.AllocNumID(18)
+		}
+
+		_, found := plain.(ReverseIDAllocator).StringValue(0)
+		convey.So(found, convey.ShouldBeFalse)
+		_, found = plain.(ReverseIDAllocator).NumValue(0)
+		convey.So(found, convey.ShouldBeFalse)
+
+		str, found := reversible.(ReverseIDAllocator).StringValue(0)
+		convey.So(found, convey.ShouldBeTrue)
+		convey.So(str, convey.ShouldEqual, "us")
+		num, found := reversible.(ReverseIDAllocator).NumValue(0)
+		convey.So(found, convey.ShouldBeTrue)
+		convey.So(num, convey.ShouldEqual, 18)
+	})
+}
+
+// minimalAllocator a allocator implemented outside, it need not preload
+type minimalAllocator struct{ IDAllocator }
+
+var _ IDAllocator = minimalAllocator{}
+
+func TestPreloader_Optional(t *testing.T) {
+	convey.Convey("test preload is a optional capability", t, func() {
+		var alloc IDAllocator = minimalAllocator{IDAllocator: NewIDAllocatorImpl()}
+		_, ok := alloc.(Preloader)
+		convey.So(ok, convey.ShouldBeFalse)
+		_, ok = NewIDAllocatorImpl().(Preloader)
+		convey.So(ok, convey.ShouldBeTrue)
+	})
+}
@@ -6,8 +6,10 @@ import "fmt"
 
 const (
 	// inner register parser can't be override, customized parser can't use prefix "#"
-	CommonParser   = "#common"
-	NumRangeParser = "#num_range"
+	CommonParser     = "#common"
+	NumRangeParser   = "#num_range"
+	VectorSimParser  = "#vector"
+	FuzzyMatchParser = "#fuzzy"
 )
 
 var (
@@ -22,6 +24,8 @@ func init() {
 	factory = make(map[string]Builder)
 	factory[CommonParser] = NewCommonStrParser
 	factory[NumRangeParser] = NewNumRangeParser
+	factory[VectorSimParser] = NewVectorParser
+	factory[FuzzyMatchParser] = NewFuzzyParser
 }
 
 // register override other will panic to avoid wrong value id be use in indexing
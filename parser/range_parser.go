@@ -2,6 +2,7 @@ package parser
 
 import (
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 )
@@ -9,11 +10,22 @@ import (
 /*
 NumberRangeParser parse syntax like format: start:end:step
 step ist optional, it will generate start, start+step, start+2*stem ....
+a single number is accepted too, as a string in base configured or a number value(json.Number and
+float of integral value included, a float with fraction rejected with ErrNonIntegralNumber).
+numbers are signed int64 with a optional sign("-0x1F:0x1F"), string numbers(value and query) are
+parsed in base configured; ids are allocated(not derived from the value), and RangeValue compare
+the parsed int64 values, so negative numbers keep their order without any offset mapping.
+params(see Configure):
+
+	base: radix of string numbers, 2~36, or 0 to detect by prefix(0x, 0o, 0b, 0), default 10;
+	      base 16 accept a optional 0x prefix
 */
 type (
 	// format: start:end:step step ist optional
 	NumberRangeParser struct {
 		idAlloc IDAllocator
+		indexed map[int64]uint64 // numbers indexed by this parser, for RangeValue query
+		base    int
 	}
 
 	numRangeOption struct {
@@ -26,7 +38,39 @@ type (
 func NewNumRangeParser(allocator IDAllocator) FieldValueParser {
 	return &NumberRangeParser{
 		idAlloc: allocator,
+		indexed: make(map[int64]uint64),
+		base:    10,
+	}
+}
+
+// Configure implement Configurable, must be called before parsing any value
+func (p *NumberRangeParser) Configure(params map[string]string) (err error) {
+	for k, v := range params {
+		switch k {
+		case "base":
+			if p.base, err = strconv.Atoi(v); err != nil || p.base == 1 || p.base < 0 || p.base > 36 {
+				return fmt.Errorf("invalid base:%s, need 0 or a int in [2, 36]", v)
+			}
+		default:
+			return fmt.Errorf("unknown number range parser param:%s", k)
+		}
+	}
+	return nil
+}
+
+// parseInt parse a signed string number in p.base
+func (p *NumberRangeParser) parseInt(s string) (int64, error) {
+	if p.base != 16 {
+		return strconv.ParseInt(s, p.base, 64)
+	}
+	sign := ""
+	if strings.HasPrefix(s, "-") || strings.HasPrefix(s, "+") {
+		sign, s = s[:1], s[1:]
 	}
+	if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") {
+		s = s[2:]
+	}
+	return strconv.ParseInt(sign+s, 16, 64)
 }
 
 // format: start:end:step
@@ -39,14 +83,14 @@ func (p *NumberRangeParser) parseOption(v string) *numRangeOption {
 		return nil
 	}
 	var err error
-	if opt.end, err = strconv.ParseInt(vs[1], 10, 64); err != nil {
+	if opt.end, err = p.parseInt(vs[1]); err != nil {
 		return nil
 	}
-	if opt.start, err = strconv.ParseInt(vs[0], 10, 64); err != nil {
+	if opt.start, err = p.parseInt(vs[0]); err != nil {
 		return nil
 	}
 	if len(vs) > 2 {
-		if opt.step, err = strconv.ParseInt(vs[2], 10, 64); err != nil {
+		if opt.step, err = p.parseInt(vs[2]); err != nil || opt.step <= 0 {
 			return nil
 		}
 	}
@@ -55,7 +99,15 @@ func (p *NumberRangeParser) parseOption(v string) *numRangeOption {
 
 // get api
 func (p *NumberRangeParser) ParseAssign(v interface{}) (res []uint64, err error) {
-	num, err := parseNumber(v)
+	if r, ok := v.(RangeValue); ok {
+		return p.parseRangeAssign(r), nil
+	}
+	var num int64
+	if str, ok := v.(string); ok {
+		num, err = p.parseInt(str)
+	} else {
+		num, err = parseNumber(v)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -68,15 +120,68 @@ func (p *NumberRangeParser) ParseAssign(v interface{}) (res []uint64, err error)
 // parse api
 func (p *NumberRangeParser) ParseValue(v interface{}) (res []uint64, err error) {
 	content, ok := v.(string)
-	if !ok {
-		return nil, fmt.Errorf("not a format string value")
+	if !ok || !strings.Contains(content, ":") { // a single number, same as it's queried
+		var num int64
+		if ok {
+			num, err = p.parseInt(content)
+		} else {
+			num, err = parseNumber(v)
+		}
+		if err != nil {
+			return nil, err
+		}
+		id := p.idAlloc.AllocNumID(num)
+		p.indexed[num] = id
+		return []uint64{id}, nil
 	}
 	opt := p.parseOption(content)
 	if opt == nil {
 		return nil, fmt.Errorf("invalid format like start:end:step")
 	}
 	for s := opt.start; s <= opt.end; s += opt.step {
-		res = append(res, p.idAlloc.AllocNumID(s))
+		id := p.idAlloc.AllocNumID(s)
+		p.indexed[s] = id
+		res = append(res, id)
 	}
 	return res, nil
 }
+
+// parseRangeAssign ids of indexed numbers within [lo, hi] in order of the numbers, walk the range or
+// the indexed numbers whichever is smaller, so the cost is O(min(hi-lo+1, indexed numbers))
+func (p *NumberRangeParser) parseRangeAssign(r RangeValue) (res []uint64) {
+	if r.Lo > r.Hi {
+		return nil
+	}
+	if span := uint64(r.Hi - r.Lo); span < uint64(len(p.indexed)) {
+		for n := r.Lo; ; n++ {
+			if id, ok := p.indexed[n]; ok {
+				res = append(res, id)
+			}
+			if n == r.Hi {
+				break
+			}
+		}
+		return res
+	}
+	var nums []int64
+	for n := range p.indexed {
+		if n >= r.Lo && n <= r.Hi {
+			nums = append(nums, n)
+		}
+	}
+	sort.Slice(nums, func(i, j int) bool { return nums[i] < nums[j] })
+	for _, n := range nums {
+		res = append(res, p.indexed[n])
+	}
+	return res
+}
+
+// RangeAware implement RangeAware, RangeValue resolved by the indexed numbers
+func (p *NumberRangeParser) RangeAware() bool {
+	return true
+}
+
+// ValueOf implement Reversible
+func (p *NumberRangeParser) ValueOf(id uint64) (string, bool) {
+	return reverseNumber(p.idAlloc, id)
+}
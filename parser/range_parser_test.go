@@ -0,0 +1,115 @@
+package parser
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestNumberRangeParser_RangeValue(t *testing.T) {
+	convey.Convey("test range value query", t, func() {
+		p := NewNumRangeParser(NewIDAllocatorImpl())
+		ids10, _ := p.ParseValue("10:12")
+		ids20, _ := p.ParseValue("20:30:5")
+
+		res, err := p.ParseAssign(RangeValue{Lo: 11, Hi: 20})
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(res, convey.ShouldResemble, []uint64{ids10[1], ids10[2], ids20[0]})
+
+		res, _ = p.ParseAssign(RangeValue{Lo: -1 << 63, Hi: 1<<63 - 1}) // walk indexed numbers
+		convey.So(len(res), convey.ShouldEqual, 6)
+
+		res, _ = p.ParseAssign(RangeValue{Lo: 13, Hi: 19})
+		convey.So(res, convey.ShouldBeEmpty)
+		res, _ = p.ParseAssign(RangeValue{Lo: 20, Hi: 10})
+		convey.So(res, convey.ShouldBeEmpty)
+	})
+
+	convey.Convey("test exact match parser reject range value", t, func() {
+		for _, p := range []FieldValueParser{
+			NewCommonStrParser(NewIDAllocatorImpl()),
+			NewGeoRegionParser(NewIDAllocatorImpl(), nil),
+			NewFuzzyParser(NewIDAllocatorImpl()),
+		} {
+			_, err := p.ParseAssign(RangeValue{Lo: 1, Hi: 2})
+			convey.So(errors.Is(err, ErrRangeNotSupported), convey.ShouldBeTrue)
+			convey.So(err.Error(), convey.ShouldContainSubstring, "[1, 2]")
+		}
+	})
+}
+
+func TestNumberRangeParser_Base(t *testing.T) {
+	convey.Convey("test hex input", t, func() {
+		p := NewNumRangeParser(NewIDAllocatorImpl())
+		convey.So(p.(Configurable).Configure(map[string]string{"base": "16"}), convey.ShouldBeNil)
+
+		ids, err := p.ParseValue("0x1E:0x20")
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(len(ids), convey.ShouldEqual, 3)
+		res, err := p.ParseAssign("1f")
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(res, convey.ShouldResemble, []uint64{ids[1]})
+		res, _ = p.ParseAssign("0X1F")
+		convey.So(res, convey.ShouldResemble, []uint64{ids[1]})
+		res, _ = p.ParseAssign(31) // number value is not affected by base
+		convey.So(res, convey.ShouldResemble, []uint64{ids[1]})
+		_, err = p.ParseAssign("0x1G")
+		convey.So(err, convey.ShouldNotBeNil)
+
+		p = NewNumRangeParser(NewIDAllocatorImpl())
+		convey.So(p.(Configurable).Configure(map[string]string{"base": "0"}), convey.ShouldBeNil)
+		ids, err = p.ParseValue("0x10:0b10010:0o1") // 16, 17, 18
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(len(ids), convey.ShouldEqual, 3)
+		res, _ = p.ParseAssign("18")
+		convey.So(res, convey.ShouldResemble, []uint64{ids[2]})
+
+		for _, base := range []string{"1", "37", "-2", "x"} {
+			p = NewNumRangeParser(NewIDAllocatorImpl())
+			convey.So(p.(Configurable).Configure(map[string]string{"base": base}), convey.ShouldNotBeNil)
+		}
+		convey.So(p.(Configurable).Configure(map[string]string{"radix": "16"}), convey.ShouldNotBeNil)
+	})
+
+	convey.Convey("test negative values and ordering", t, func() {
+		for _, base := range []string{"10", "16"} {
+			p := NewNumRangeParser(NewIDAllocatorImpl())
+			convey.So(p.(Configurable).Configure(map[string]string{"base": base}), convey.ShouldBeNil)
+
+			pos, err := p.ParseValue("1:3")
+			convey.So(err, convey.ShouldBeNil)
+			neg, err := p.ParseValue("-3:-1")
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(len(neg), convey.ShouldEqual, 3)
+
+			res, _ := p.ParseAssign("-2")
+			convey.So(res, convey.ShouldResemble, []uint64{neg[1]})
+			res, _ = p.ParseAssign(int64(-3))
+			convey.So(res, convey.ShouldResemble, []uint64{neg[0]})
+
+			// ids allocated in ingest order, range compare the values and keep their order
+			res, _ = p.ParseAssign(RangeValue{Lo: -2, Hi: 1})
+			convey.So(res, convey.ShouldResemble, []uint64{neg[1], neg[2], pos[0]})
+			res, _ = p.ParseAssign(RangeValue{Lo: -1 << 63, Hi: 1<<63 - 1})
+			convey.So(res, convey.ShouldResemble, []uint64{neg[0], neg[1], neg[2], pos[0], pos[1], pos[2]})
+			res, _ = p.ParseAssign(RangeValue{Lo: -1 << 63, Hi: -1})
+			convey.So(res, convey.ShouldResemble, []uint64{neg[0], neg[1], neg[2]})
+
+			if base == "16" {
+				ids, err := p.ParseValue("-0x11:-0xF:0x2") // -17, -15
+				convey.So(err, convey.ShouldBeNil)
+				res, _ = p.ParseAssign(RangeValue{Lo: -16, Hi: -4})
+				convey.So(res, convey.ShouldResemble, []uint64{ids[1]})
+			}
+		}
+	})
+
+	convey.Convey("test invalid step", t, func() {
+		p := NewNumRangeParser(NewIDAllocatorImpl())
+		_, err := p.ParseValue("1:10:0")
+		convey.So(err, convey.ShouldNotBeNil)
+		_, err = p.ParseValue("1:10:-1")
+		convey.So(err, convey.ShouldNotBeNil)
+	})
+}
@@ -1,13 +1,27 @@
 package parser
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
 	"reflect"
 	"strconv"
 )
 
+var (
+	ErrRangeNotSupported = errors.New("range value not supported by exact match parser")
+
+	// ErrNonIntegralNumber a float(or json.Number) with fraction given where only integers are tokenized
+	ErrNonIntegralNumber = errors.New("non-integral number")
+)
+
 type (
 	// v type only string like and number like input
+	// a parser must keep build time(ParseValue) and query time(ParseAssign) consistent:
+	// for any value v, every id of ParseValue(v) must be in ParseAssign(v), otherwise a query
+	// with exactly the indexed value can't hit it; ParseAssign may return more ids(eg: GeoRegionParser
+	// resolve ancestors), see VerifyParserConsistency
 	FieldValueParser interface {
 		// parse query assign value into id-encoded ids
 		ParseAssign(v interface{}) ([]uint64, error)
@@ -15,28 +29,156 @@ type (
 		// parse bool expression value into id-encoded ids
 		ParseValue(v interface{}) ([]uint64, error)
 	}
+
+	// Configurable parser accept params, eg: FieldOption.ParserParams
+	Configurable interface {
+		Configure(params map[string]string) error
+	}
+
+	// RangeValue a query value match all indexed numbers within [Lo, Hi], only range aware parser(eg:
+	// NumberRangeParser) accept it, exact match parsers reject it with ErrRangeNotSupported
+	RangeValue struct {
+		Lo int64
+		Hi int64
+	}
+
+	// RangeAware capability declaration of parser resolving RangeValue in ParseAssign, a field
+	// queried by range(see FieldOption.RangeQuery) require its parser declare it
+	RangeAware interface {
+		RangeAware() bool
+	}
+
+	// Approximate capability declaration of parser whose ParseAssign may return a superset of the ids
+	// truly matched(eg: bucket values by prefix to keep dictionary small), conjunctions supported by
+	// such ids only are post-verified when a verifier configured, see be_indexer.FieldOption.Verify
+	Approximate interface {
+		Approximate() bool
+	}
+
+	// Reversible parser can decode a id of ParseValue back to the source value, the builtin parsers
+	// only can with a reversible allocator, see NewReversibleIDAllocator
+	Reversible interface {
+		ValueOf(id uint64) (string, bool)
+	}
 )
 
-func parseNumber(v interface{}) (n int64, err error) {
-	vf := reflect.ValueOf(v)
-	switch tv := v.(type) {
+func (r RangeValue) String() string {
+	return fmt.Sprintf("[%d, %d]", r.Lo, r.Hi)
+}
+
+// rejectRange return ErrRangeNotSupported for a RangeValue
+func rejectRange(v interface{}) error {
+	if r, ok := v.(RangeValue); ok {
+		return fmt.Errorf("%w, value:%s", ErrRangeNotSupported, r)
+	}
+	return nil
+}
+
+func reverseString(alloc IDAllocator, id uint64) (string, bool) {
+	if reverser, ok := alloc.(ReverseIDAllocator); ok {
+		return reverser.StringValue(id)
+	}
+	return "", false
+}
+
+func reverseNumber(alloc IDAllocator, id uint64) (string, bool) {
+	if reverser, ok := alloc.(ReverseIDAllocator); ok {
+		if v, found := reverser.NumValue(id); found {
+			return strconv.FormatInt(v, 10), true
+		}
+	}
+	return "", false
+}
+
+/*
+canonicalNumber the canonical decimal form of a number value: every integer type, json.Number and
+float of a integral value give the same string for the same number, so a value indexed as int(from
+code) match a query of float64(from encoding/json); ok is false if v is not a number type, a float
+with fraction(or NaN, Inf) fail with ErrNonIntegralNumber.
+*/
+func canonicalNumber(v interface{}) (s string, ok bool, err error) {
+	switch t := v.(type) {
 	case int, int8, int16, int32, int64:
-		return vf.Int(), nil
+		return strconv.FormatInt(reflect.ValueOf(v).Int(), 10), true, nil
 	case uint, uint8, uint16, uint32, uint64:
-		return int64(vf.Uint()), nil
-	case float64, float32:
-		return int64(vf.Float()), nil
-	case string:
-		return strconv.ParseInt(tv, 10, 64)
+		return strconv.FormatUint(reflect.ValueOf(v).Uint(), 10), true, nil
+	case float32:
+		s, err = canonicalFloat(float64(t), v)
+		return s, true, err
+	case float64:
+		s, err = canonicalFloat(t, v)
+		return s, true, err
+	case json.Number:
+		if n, e := strconv.ParseInt(string(t), 10, 64); e == nil {
+			return strconv.FormatInt(n, 10), true, nil
+		}
+		if n, e := strconv.ParseUint(string(t), 10, 64); e == nil {
+			return strconv.FormatUint(n, 10), true, nil
+		}
+		f, e := t.Float64()
+		if e != nil {
+			return "", true, fmt.Errorf("invalid json number:%s", t)
+		}
+		s, err = canonicalFloat(f, v)
+		return s, true, err
+	default:
+		return "", false, nil
+	}
+}
+
+// canonicalFloat the exact decimal integer of f, v the source value for error message
+func canonicalFloat(f float64, v interface{}) (string, error) {
+	if f != math.Trunc(f) || math.IsInf(f, 0) {
+		return "", fmt.Errorf("%w:%v(%T), index it as a string value for exact token match", ErrNonIntegralNumber, v, v)
+	}
+	switch {
+	case f >= -(1<<63) && f < 1<<63:
+		return strconv.FormatInt(int64(f), 10), nil
+	case f >= 0 && f < 1<<64:
+		return strconv.FormatUint(uint64(f), 10), nil
 	default:
-		return 0, fmt.Errorf("not supprted number type:%+v", v)
+		return strconv.FormatFloat(f, 'f', 0, 64), nil
+	}
+}
+
+// canonicalToken like canonicalNumber, but a float with fraction is formatted in its shortest
+// decimal(1.5 as "1.5") instead of rejected, for parsers tokenize values as strings
+func canonicalToken(v interface{}) (s string, ok bool, err error) {
+	s, ok, err = canonicalNumber(v)
+	if !errors.Is(err, ErrNonIntegralNumber) {
+		return s, ok, err
+	}
+	switch t := v.(type) {
+	case float32:
+		return strconv.FormatFloat(float64(t), 'g', -1, 32), true, nil
+	case float64:
+		return strconv.FormatFloat(t, 'g', -1, 64), true, nil
+	default: // json.Number, valid as canonicalNumber parsed it
+		f, _ := v.(json.Number).Float64()
+		return strconv.FormatFloat(f, 'g', -1, 64), true, nil
+	}
+}
+
+// parseNumber a number value(see canonicalNumber) or a decimal string as int64
+func parseNumber(v interface{}) (n int64, err error) {
+	s, ok, err := canonicalNumber(v)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		str, isStr := v.(string)
+		if !isStr {
+			return 0, fmt.Errorf("not supprted number type:%+v", v)
+		}
+		s = str
 	}
+	return strconv.ParseInt(s, 10, 64)
 }
 
 func IsValidValueType(v interface{}) bool {
 	switch v.(type) {
 	case int, int8, int16, int32, int64,
-		uint, uint8, uint16, uint32, uint64, float32, float64, string:
+		uint, uint8, uint16, uint32, uint64, float32, float64, string, json.Number:
 		return true
 	default:
 		return false
@@ -0,0 +1,213 @@
+package parser
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"reflect"
+	"strconv"
+)
+
+/*
+VectorParser match embedding vectors by cosine similarity instead of exact value.
+ParseValue give each distinct indexed vector a token id, and bucket it by SimHash(random
+hyperplane LSH) signature bands; ParseAssign only compare the query vector with the vectors
+sharing at least one band, and return token ids of those within the cosine threshold.
+params(see Configure):
+
+	threshold: min cosine similarity to match, default 0.9
+	bands: number of 8bit bands the 64bit signature split into, [1, 8], default 8; less bands
+	       means more candidates per query, higher recall and cost
+	seed: random seed of the hyperplanes, default 1
+*/
+type (
+	VectorParser struct {
+		idAlloc IDAllocator
+
+		threshold float64
+		bands     int
+		seed      int64
+
+		dimension   int
+		hyperplanes [][]float64      // 64 random hyperplanes
+		vectors     [][]float64      // normalized indexed vectors
+		tokens      []uint64         // token id of vectors
+		vectorIdx   map[string]int   // vector literal -> index of vectors
+		buckets     map[uint64][]int // band key -> index of vectors
+	}
+)
+
+const (
+	vectorSignatureBits = 64
+	vectorBandBits      = 8
+)
+
+func NewVectorParser(allocator IDAllocator) FieldValueParser {
+	return &VectorParser{
+		idAlloc:   allocator,
+		threshold: 0.9,
+		bands:     vectorSignatureBits / vectorBandBits,
+		seed:      1,
+		vectorIdx: make(map[string]int),
+		buckets:   make(map[uint64][]int),
+	}
+}
+
+// Configure implement Configurable, must be called before parsing any value
+func (p *VectorParser) Configure(params map[string]string) (err error) {
+	for k, v := range params {
+		switch k {
+		case "threshold":
+			if p.threshold, err = strconv.ParseFloat(v, 64); err != nil || p.threshold < -1 || p.threshold > 1 {
+				return fmt.Errorf("invalid threshold:%s, need a float in [-1, 1]", v)
+			}
+		case "bands":
+			if p.bands, err = strconv.Atoi(v); err != nil || p.bands < 1 || p.bands > vectorSignatureBits/vectorBandBits {
+				return fmt.Errorf("invalid bands:%s, need a int in [1, 8]", v)
+			}
+		case "seed":
+			if p.seed, err = strconv.ParseInt(v, 10, 64); err != nil {
+				return fmt.Errorf("invalid seed:%s", v)
+			}
+		default:
+			return fmt.Errorf("unknown vector parser param:%s", k)
+		}
+	}
+	return nil
+}
+
+// HashParams implement HashTokenizer, params needed to reproduce the signature of a vector
+func (p *VectorParser) HashParams() map[string]string {
+	return map[string]string{
+		"threshold": strconv.FormatFloat(p.threshold, 'g', -1, 64),
+		"bands":     strconv.Itoa(p.bands),
+		"seed":      strconv.FormatInt(p.seed, 10),
+	}
+}
+
+func (p *VectorParser) toVector(v interface{}) ([]float64, error) {
+	var vec []float64
+	switch t := v.(type) {
+	case []float32:
+		vec = make([]float64, len(t))
+		for i, f := range t {
+			vec[i] = float64(f)
+		}
+	case []float64:
+		vec = make([]float64, len(t))
+		copy(vec, t)
+	default:
+		return nil, fmt.Errorf("vector parser need []float32/[]float64, got type [%s]", reflect.TypeOf(v))
+	}
+	if p.dimension == 0 && len(vec) > 0 {
+		p.initHyperplanes(len(vec))
+	}
+	if len(vec) == 0 || len(vec) != p.dimension {
+		return nil, fmt.Errorf("vector dimension mismatch, expect:%d got:%d", p.dimension, len(vec))
+	}
+	var norm float64
+	for _, f := range vec {
+		norm += f * f
+	}
+	if norm == 0 {
+		return nil, fmt.Errorf("zero vector not allowed")
+	}
+	norm = math.Sqrt(norm)
+	for i := range vec {
+		vec[i] /= norm
+	}
+	return vec, nil
+}
+
+func (p *VectorParser) initHyperplanes(dimension int) {
+	rd := rand.New(rand.NewSource(p.seed))
+	p.dimension = dimension
+	p.hyperplanes = make([][]float64, vectorSignatureBits)
+	for i := range p.hyperplanes {
+		plane := make([]float64, dimension)
+		for j := range plane {
+			plane[j] = rd.NormFloat64()
+		}
+		p.hyperplanes[i] = plane
+	}
+}
+
+func (p *VectorParser) signature(vec []float64) (sig uint64) {
+	for i, plane := range p.hyperplanes {
+		var dot float64
+		for j, f := range vec {
+			dot += f * plane[j]
+		}
+		if dot >= 0 {
+			sig |= 1 << uint(i)
+		}
+	}
+	return sig
+}
+
+// bandKeys <band index(8bit)> | <band bits(8bit)>
+func (p *VectorParser) bandKeys(sig uint64) []uint64 {
+	keys := make([]uint64, p.bands)
+	for i := 0; i < p.bands; i++ {
+		keys[i] = uint64(i)<<vectorBandBits | (sig>>(uint(i)*vectorBandBits))&0xFF
+	}
+	return keys
+}
+
+// ParseValue return the token id of vector, similar vectors get different token ids
+func (p *VectorParser) ParseValue(v interface{}) ([]uint64, error) {
+	vec, err := p.toVector(v)
+	if err != nil {
+		return nil, err
+	}
+	literal := fmt.Sprintf("#vector:%v", vec)
+	if idx, ok := p.vectorIdx[literal]; ok {
+		return []uint64{p.tokens[idx]}, nil
+	}
+	idx := len(p.vectors)
+	token := p.idAlloc.AllocStringID(literal)
+	p.vectors = append(p.vectors, vec)
+	p.tokens = append(p.tokens, token)
+	p.vectorIdx[literal] = idx
+	for _, key := range p.bandKeys(p.signature(vec)) {
+		p.buckets[key] = append(p.buckets[key], idx)
+	}
+	return []uint64{token}, nil
+}
+
+// ParseAssign return token ids of indexed vectors within the cosine similarity threshold
+func (p *VectorParser) ParseAssign(v interface{}) (res []uint64, err error) {
+	if p.dimension == 0 { // nothing indexed
+		return nil, nil
+	}
+	vec, err := p.toVector(v)
+	if err != nil {
+		return nil, err
+	}
+	checked := make(map[int]struct{})
+	for _, key := range p.bandKeys(p.signature(vec)) {
+		for _, idx := range p.buckets[key] {
+			if _, ok := checked[idx]; ok {
+				continue
+			}
+			checked[idx] = struct{}{}
+			if cosine(vec, p.vectors[idx]) >= p.threshold {
+				res = append(res, p.tokens[idx])
+			}
+		}
+	}
+	return res, nil
+}
+
+// cosine of two normalized vector
+func cosine(a, b []float64) (dot float64) {
+	for i := range a {
+		dot += a[i] * b[i]
+	}
+	return dot
+}
+
+// ValueOf implement Reversible
+func (p *VectorParser) ValueOf(id uint64) (string, bool) {
+	return reverseString(p.idAlloc, id)
+}
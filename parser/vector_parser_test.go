@@ -0,0 +1,49 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestVectorParser(t *testing.T) {
+	convey.Convey("test vector parser match by cosine similarity", t, func() {
+		p := NewVectorParser(NewIDAllocatorImpl())
+		convey.So(p.(Configurable).Configure(map[string]string{"threshold": "0.95", "bands": "8"}), convey.ShouldBeNil)
+
+		ids, err := p.ParseAssign([]float32{1, 0, 0, 0})
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(ids, convey.ShouldBeEmpty)
+
+		x, err := p.ParseValue([]float32{1, 0.05, 0, 0})
+		convey.So(err, convey.ShouldBeNil)
+		y, err := p.ParseValue([]float32{0, 1, 0, 0})
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(x, convey.ShouldNotResemble, y)
+
+		same, _ := p.ParseValue([]float32{1, 0.05, 0, 0}) // same vector, same token
+		convey.So(same, convey.ShouldResemble, x)
+
+		ids, err = p.ParseAssign([]float32{1, 0, 0, 0})
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(ids, convey.ShouldResemble, x)
+
+		ids, err = p.ParseAssign([]float32{0, 1, 0.01, 0})
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(ids, convey.ShouldResemble, y)
+
+		ids, err = p.ParseAssign([]float32{0, 0, 1, 1})
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(ids, convey.ShouldBeEmpty)
+
+		_, err = p.ParseAssign([]float32{1, 0})
+		convey.So(err, convey.ShouldNotBeNil)
+		_, err = p.ParseValue("1,0,0,0")
+		convey.So(err, convey.ShouldNotBeNil)
+		_, err = p.ParseValue([]float32{0, 0, 0, 0})
+		convey.So(err, convey.ShouldNotBeNil)
+
+		convey.So(p.(Configurable).Configure(map[string]string{"threshold": "2"}), convey.ShouldNotBeNil)
+		convey.So(p.(Configurable).Configure(map[string]string{"unknown": "2"}), convey.ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,30 @@
+package parser
+
+import "fmt"
+
+// VerifyParserConsistency check parser p hold the relationship required by FieldValueParser:
+// a query with exactly the indexed value must hit it, that is every id ParseValue(v) produce must
+// be found in ParseAssign(v); samples can't be parsed by either method are skipped.
+// it allocate ids for samples, so use a parser with a throwaway IDAllocator
+func VerifyParserConsistency(p FieldValueParser, samples []interface{}) error {
+	for _, v := range samples {
+		valueIDs, err := p.ParseValue(v)
+		if err != nil {
+			continue
+		}
+		assignIDs, err := p.ParseAssign(v)
+		if err != nil {
+			continue
+		}
+		assigned := make(map[uint64]struct{}, len(assignIDs))
+		for _, id := range assignIDs {
+			assigned[id] = struct{}{}
+		}
+		for _, id := range valueIDs {
+			if _, ok := assigned[id]; !ok {
+				return fmt.Errorf("parser inconsistent for value:%+v, ParseValue:%v ParseAssign:%v", v, valueIDs, assignIDs)
+			}
+		}
+	}
+	return nil
+}
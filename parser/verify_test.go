@@ -0,0 +1,41 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+// trimOnBuildParser trim value only when indexing, a typical inconsistent parser
+type trimOnBuildParser struct {
+	idAlloc IDAllocator
+}
+
+func (p *trimOnBuildParser) ParseAssign(v interface{}) ([]uint64, error) {
+	s := v.(string)
+	if id, ok := p.idAlloc.FindStringID(&s); ok {
+		return []uint64{id}, nil
+	}
+	return nil, nil
+}
+
+func (p *trimOnBuildParser) ParseValue(v interface{}) ([]uint64, error) {
+	return []uint64{p.idAlloc.AllocStringID(strings.TrimSpace(v.(string)))}, nil
+}
+
+func TestVerifyParserConsistency(t *testing.T) {
+	convey.Convey("test verify parser consistency", t, func() {
+		samples := []interface{}{"a", " b ", 12}
+
+		convey.So(VerifyParserConsistency(NewCommonStrParser(NewIDAllocatorImpl()), samples), convey.ShouldBeNil)
+		convey.So(VerifyParserConsistency(NewNumRangeParser(NewIDAllocatorImpl()), samples), convey.ShouldBeNil)
+		convey.So(VerifyParserConsistency(NewGeoRegionParser(NewIDAllocatorImpl(), map[string]string{
+			"a": "b",
+		}), []interface{}{"a", "b"}), convey.ShouldBeNil)
+
+		p := &trimOnBuildParser{idAlloc: NewIDAllocatorImpl()}
+		convey.So(VerifyParserConsistency(p, []interface{}{"a"}), convey.ShouldBeNil)
+		convey.So(VerifyParserConsistency(p, []interface{}{"a", " b "}), convey.ShouldNotBeNil)
+	})
+}
@@ -1,8 +1,11 @@
 package be_indexer
 
 import (
+	"encoding/binary"
 	"fmt"
+	"hash/fnv"
 	"sort"
+	"sync/atomic"
 )
 
 const (
@@ -11,6 +14,9 @@ const (
 	MaxBEFieldID uint64 = 0xFF             // 8bit
 	MaxBEValueID uint64 = 0xFFFFFFFFFFFFFF // 56bit
 
+	// approximate memory cost of posting lists, used by memory budget
+	entryIDBytes    int64 = 8
+	postingKeyBytes int64 = 48 // key, slice header and map overhead
 )
 
 type (
@@ -21,14 +27,58 @@ type (
 	EntryID uint64
 	Entries []EntryID
 
-	// PostingEntries posting list entries(sorted); eg: <age, 15>: []EntryID{1, 2, 3}
+	// CompactStats statistics of a BEIndex.Compact run
+	CompactStats struct {
+		ListsRewritten int   // posting lists rewritten
+		EntriesDropped int   // duplicated EntryIDs removed
+		KeysDropped    int   // keys with empty posting list removed
+		BytesReclaimed int64 // approximate memory released
+	}
+
+	/*PostingEntries posting list entries(sorted); eg: <age, 15>: []EntryID{1, 2, 3}. lists once built
+	are never rewritten in place: compact build a new postingLists and swap it in atomically, so readers
+	(Retrieve, Dump*, Stats...) can run meanwhile, every reading begin with load*/
 	PostingEntries struct {
+		lists atomic.Value // *postingLists
+
+		lazy *lazyCompile // compile state of lazy compiled group, nil if compiled when built, see WithLazyCompile
+	}
+
+	// postingLists the posting lists of a PostingEntries, see PostingEntries.load
+	postingLists struct {
 		maxLen    int64 // max length of Entries
 		avgLen    int64 // avg length of Entries
 		plEntries map[Key]Entries
+		inlined   map[Key]inlineEntries // lists frozen after sorted, short ones inline, see inlineSmallLists
+		spilled   []Entries             // lists of inlined too long to inline
 	}
 )
 
+// atomicEntries a Entries swapped as a whole, like PostingEntries(eg: the wildcard list), see compact
+type atomicEntries struct {
+	v atomic.Value // Entries
+}
+
+func (a *atomicEntries) load() Entries {
+	entries, _ := a.v.Load().(Entries)
+	return entries
+}
+
+func (a *atomicEntries) store(entries Entries) {
+	a.v.Store(entries)
+}
+
+func newPostingEntries(plEntries map[Key]Entries) *PostingEntries {
+	kse := &PostingEntries{}
+	kse.lists.Store(&postingLists{plEntries: plEntries})
+	return kse
+}
+
+// load the current lists, a compact swapping a new one in never change what's loaded
+func (kse *PostingEntries) load() *postingLists {
+	return kse.lists.Load().(*postingLists)
+}
+
 //NewKey API
 func NewKey(fieldID uint64, valueID uint64) Key {
 	if fieldID > MaxBEFieldID || valueID > MaxBEValueID {
@@ -58,12 +108,12 @@ func (s Entries) DocString() []string {
 	return res
 }
 
-//NewEntryID |-- ConjID(48bit) --|-- empty(15bit) -- | --incl/excl(1bit) --|
+//NewEntryID |-- ConjID(56bit) --|-- empty(7bit) -- | --incl/excl(1bit) --|
 func NewEntryID(id ConjID, incl bool) EntryID {
 	if !incl {
-		return EntryID(id << 16)
+		return EntryID(id << 8)
 	}
-	return EntryID((id << 16) | 0x01)
+	return EntryID((id << 8) | 0x01)
 }
 
 func (entry EntryID) IsExclude() bool {
@@ -75,7 +125,7 @@ func (entry EntryID) IsInclude() bool {
 }
 
 func (entry EntryID) GetConjID() ConjID {
-	return ConjID(entry >> 16)
+	return ConjID(entry >> 8)
 }
 
 func (entry EntryID) IsNULLEntry() bool {
@@ -90,31 +140,165 @@ func (entry EntryID) DocString() string {
 }
 
 func (kse *PostingEntries) AppendEntryID(key Key, id EntryID) {
-	entries, hit := kse.plEntries[key]
-	if !hit {
-		kse.plEntries[key] = Entries{id}
-	}
-	entries = append(entries, id)
-	kse.plEntries[key] = entries
+	l := kse.load()
+	l.plEntries[key] = append(l.plEntries[key], id)
 }
 
 func (kse *PostingEntries) getEntries(key Key) Entries {
-	if entries, hit := kse.plEntries[key]; hit {
+	l := kse.load()
+	if entries, hit := l.plEntries[key]; hit {
 		return entries
 	}
+	if il, hit := l.inlined[key]; hit {
+		if idx, ok := il.spilled(); ok {
+			return l.spilled[idx]
+		}
+		return il.entries()
+	}
 	return nil
 }
 
 func (kse *PostingEntries) makeEntriesSorted() {
-	var total int64
-	for _, entries := range kse.plEntries {
+	l := kse.load()
+	for _, entries := range l.plEntries {
 		sort.Sort(entries)
-		if kse.maxLen < int64(len(entries)) {
-			kse.maxLen = int64(len(entries))
+	}
+	l.updateLenStats()
+}
+
+// lenStats avg and max length of the lists
+func (kse *PostingEntries) lenStats() (avgLen, maxLen int64) {
+	l := kse.load()
+	return l.avgLen, l.maxLen
+}
+
+func (l *postingLists) updateLenStats() {
+	var total int64
+	l.maxLen, l.avgLen = 0, 0
+	l.eachList(func(_ Key, entries Entries) {
+		if l.maxLen < int64(len(entries)) {
+			l.maxLen = int64(len(entries))
 		}
 		total += int64(len(entries))
+	})
+	if cnt := l.keyCount(); cnt > 0 {
+		l.avgLen = total / int64(cnt)
+	}
+}
+
+func entriesHash(entries Entries) uint64 {
+	h := fnv.New64a()
+	buf := make([]byte, 8)
+	for _, eid := range entries {
+		binary.LittleEndian.PutUint64(buf, uint64(eid))
+		_, _ = h.Write(buf)
 	}
-	if len(kse.plEntries) > 0 {
-		kse.avgLen = total / int64(len(kse.plEntries))
+	return h.Sum64()
+}
+
+func entriesEqual(a, b Entries) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// sameEntries whether a and b are the same slice(share the backing array), not only equal
+func sameEntries(a, b Entries) bool {
+	return len(a) == len(b) && (len(a) == 0 || &a[0] == &b[0])
+}
+
+/*
+shareEntries make keys with identical(sorted) posting list share one Entries, it's common when
+conjunctions list a set of values for a field(eg: city in [sh, bj, gz]) so every value get the
+same EntryIDs; lists grouped by hash of content then compared fully, so a hash collision never
+share different lists. it must be called after sorted, shared lists must never be written in place.
+*/
+func (kse *PostingEntries) shareEntries() (shared int) {
+	l := kse.load()
+	canonical := make(map[uint64][]Entries)
+	for key, entries := range l.plEntries {
+		if len(entries) == 0 {
+			continue
+		}
+		hash := entriesHash(entries)
+		found := false
+		for _, candidate := range canonical[hash] {
+			if entriesEqual(candidate, entries) {
+				if !sameEntries(candidate, entries) {
+					l.plEntries[key] = candidate
+					shared++
+				}
+				found = true
+				break
+			}
+		}
+		if !found {
+			canonical[hash] = append(canonical[hash], entries)
+		}
+	}
+	return shared
+}
+
+// compactEntries drop duplicated EntryIDs of a sorted Entries and shrink its capacity,
+// a new slice returned if anything changed, so readers holding the old one are not affected
+func compactEntries(entries Entries, stats *CompactStats) Entries {
+	uniq := 0
+	for i := range entries {
+		if i == 0 || entries[i] != entries[i-1] {
+			uniq++
+		}
+	}
+	if uniq == len(entries) && cap(entries) == len(entries) {
+		return entries
+	}
+	res := make(Entries, 0, uniq)
+	for i, eid := range entries {
+		if i == 0 || eid != entries[i-1] {
+			res = append(res, eid)
+		}
+	}
+	stats.ListsRewritten++
+	stats.EntriesDropped += len(entries) - uniq
+	stats.BytesReclaimed += int64(cap(entries)-uniq) * 8
+	return res
+}
+
+/*
+compact rewrite the posting lists into a new postingLists then swap it in, the current one is never
+written(see compactEntries), so readers loaded it keep a consistent view; it must be called after
+compiled and never concurrently with itself
+*/
+func (kse *PostingEntries) compact(stats *CompactStats) {
+	current := kse.load()
+	lists := &postingLists{plEntries: make(map[Key]Entries, current.keyCount())}
+	type listRef struct {
+		head *EntryID
+		size int
+	}
+	compacted := make(map[listRef]Entries) // keep lists shared(see shareEntries) shared after compacted
+	current.eachList(func(key Key, entries Entries) {
+		if len(entries) == 0 {
+			stats.KeysDropped++
+			stats.BytesReclaimed += int64(cap(entries))*8 + 32 // 8 bytes key + 24 bytes slice header
+			return
+		}
+		ref := listRef{head: &entries[0], size: len(entries)}
+		if res, ok := compacted[ref]; ok {
+			lists.plEntries[key] = res
+			return
+		}
+		lists.plEntries[key] = compactEntries(entries, stats)
+		compacted[ref] = lists.plEntries[key]
+	})
+	if current.inlined != nil {
+		lists.inlineSmallLists()
 	}
+	lists.updateLenStats() // compactEntries keep order, no need to sort(and write) the shared lists
+	kse.lists.Store(lists)
 }
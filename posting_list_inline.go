@@ -0,0 +1,142 @@
+package be_indexer
+
+const (
+	// inlineEntriesCap posting lists up to it entries stored inline, see inlineEntries
+	inlineEntriesCap = 3
+
+	// spilledEntry first slot of a inlineEntries whose list is too long to inline, the second slot is
+	// index of the list in PostingEntries.spilled; like NULLENTRY it has the unused bits of EntryID
+	// set, so it's never a valid EntryID
+	spilledEntry EntryID = NULLENTRY - 1
+)
+
+type (
+	/*inlineEntries a short posting list stored in the map value itself instead of a Entries: most
+	keys of a real index hold 1~3 EntryIDs(eg: a campaign id, a rare tag), the slice header(24 bytes)
+	plus a separately allocated backing array cost more than the entries. it's exactly as large as a
+	slice header, pointer free(the map of them is never scanned by GC), unused slots are NULLENTRY;
+	a longer list spill into PostingEntries.spilled, see spilledEntry and inlineSmallLists
+	*/
+	inlineEntries [inlineEntriesCap]EntryID
+)
+
+func newInlineEntries(entries Entries) (il inlineEntries) {
+	for i := range il {
+		il[i] = NULLENTRY
+	}
+	copy(il[:], entries)
+	return il
+}
+
+func newSpilledEntries(index int) inlineEntries {
+	return inlineEntries{spilledEntry, EntryID(index), NULLENTRY}
+}
+
+// spilled the index of the list in PostingEntries.spilled, false if the list is inlined
+func (il *inlineEntries) spilled() (int, bool) {
+	if il[0] != spilledEntry {
+		return 0, false
+	}
+	return int(il[1]), true
+}
+
+func (il *inlineEntries) len() int {
+	n := 0
+	for n < len(il) && il[n] != NULLENTRY {
+		n++
+	}
+	return n
+}
+
+// entries the inlined entries as a Entries, sharing memory with il
+func (il *inlineEntries) entries() Entries {
+	n := il.len()
+	return il[:n:n]
+}
+
+/*
+inlineSmallLists freeze the posting lists built in plEntries into inlined: lists no longer than
+inlineEntriesCap stored inline, the others spilled, so the group keep a single map(two maps cost
+more, map capacity grow by power of 2). it must be called after sorted, and only on a group never
+written later: not for lists shared by keys(see shareEntries, inlining copy them per key), nor
+for groups compiled lazily(queries read the map while compiling, see WithLazyCompile). readers go
+through getEntries/eachList/appendKeyCursors, so the representation is invisible out of here.
+*/
+func (kse *PostingEntries) inlineSmallLists() {
+	kse.load().inlineSmallLists()
+}
+
+func (l *postingLists) inlineSmallLists() {
+	if len(l.plEntries) == 0 {
+		return
+	}
+	inlined := make(map[Key]inlineEntries, len(l.plEntries))
+	var spilled []Entries
+	for key, entries := range l.plEntries {
+		if len(entries) > 0 && len(entries) <= inlineEntriesCap {
+			inlined[key] = newInlineEntries(entries)
+			continue
+		}
+		inlined[key] = newSpilledEntries(len(spilled))
+		spilled = append(spilled, entries)
+	}
+	l.plEntries, l.inlined, l.spilled = nil, inlined, spilled
+}
+
+// keyCount count of posting lists
+func (kse *PostingEntries) keyCount() int {
+	return kse.load().keyCount()
+}
+
+func (l *postingLists) keyCount() int {
+	return len(l.plEntries) + len(l.inlined)
+}
+
+// eachList call fn with every posting list, a inlined one passed as a copy, never write it
+func (kse *PostingEntries) eachList(fn func(key Key, entries Entries)) {
+	kse.load().eachList(fn)
+}
+
+func (l *postingLists) eachList(fn func(key Key, entries Entries)) {
+	for key, entries := range l.plEntries {
+		fn(key, entries)
+	}
+	for key, il := range l.inlined {
+		if idx, ok := il.spilled(); ok {
+			fn(key, l.spilled[idx])
+			continue
+		}
+		copied := il
+		fn(key, copied.entries())
+	}
+}
+
+// appendKeyCursors append the cursor(s) of posting list key in kse to pls, nothing if there is no list
+func (ctx *RetrieveContext) appendKeyCursors(pls CursorGroup, key Key, kse *PostingEntries) CursorGroup {
+	l := kse.load()
+	il, ok := l.inlined[key]
+	if !ok {
+		if entries := l.plEntries[key]; len(entries) > 0 {
+			return append(pls, ctx.newEntriesCursor(key, entries))
+		}
+		return pls
+	}
+	if idx, spilled := il.spilled(); spilled {
+		if entries := l.spilled[idx]; len(entries) > 0 {
+			return append(pls, ctx.newEntriesCursor(key, entries))
+		}
+		return pls
+	}
+	return append(pls, ctx.newInlineCursor(key, il))
+}
+
+// newInlineCursor a cursor over its own copy of il, so scanning a inlined list allocate nothing but the cursor
+func (ctx *RetrieveContext) newInlineCursor(key Key, il inlineEntries) *EntriesCursor {
+	cursor := ctx.newEntriesCursor(key, nil)
+	cursor.inline = il
+	cursor.entries = cursor.inline.entries()
+	if ctx.trace != nil {
+		ctx.trace.EntriesCursored += len(cursor.entries)
+	}
+	return cursor
+}
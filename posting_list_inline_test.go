@@ -0,0 +1,139 @@
+package be_indexer
+
+import (
+	"fmt"
+	"math/rand"
+	"runtime"
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestPostingEntries_InlineSmallLists(t *testing.T) {
+	convey.Convey("test short posting lists inlined", t, func() {
+		kse := newPostingEntries(map[Key]Entries{
+			1: {3},
+			2: {1, 2, 3},
+			3: {1, 2, 3, 4},
+			4: {},
+			5: {5, 5},
+		})
+		kse.inlineSmallLists()
+		convey.So(len(kse.load().inlined), convey.ShouldEqual, 5)
+		convey.So(len(kse.load().spilled), convey.ShouldEqual, 2)
+		convey.So(kse.load().plEntries, convey.ShouldBeNil)
+		convey.So(kse.keyCount(), convey.ShouldEqual, 5)
+		convey.So(kse.getEntries(1), convey.ShouldResemble, Entries{3})
+		convey.So(kse.getEntries(2), convey.ShouldResemble, Entries{1, 2, 3})
+		convey.So(kse.getEntries(3), convey.ShouldResemble, Entries{1, 2, 3, 4})
+		convey.So(kse.getEntries(6), convey.ShouldBeNil)
+
+		stats := IndexStatistics{}
+		kse.statistics(&stats)
+		convey.So(stats.PostingListCount, convey.ShouldEqual, 5)
+		convey.So(stats.EntryCount, convey.ShouldEqual, 10)
+
+		compacted := CompactStats{}
+		kse.compact(&compacted)
+		convey.So(kse.getEntries(5), convey.ShouldResemble, Entries{5})
+		convey.So(compacted.EntriesDropped, convey.ShouldEqual, 1)
+		convey.So(compacted.KeysDropped, convey.ShouldEqual, 1)
+		convey.So(kse.keyCount(), convey.ShouldEqual, 4)
+		convey.So(len(kse.load().spilled), convey.ShouldEqual, 1)
+	})
+}
+
+func TestEntriesCursor_Inline(t *testing.T) {
+	convey.Convey("test inline cursor skip across the inline boundary", t, func() {
+		for _, opts := range [][]IndexOpt{nil, {WithBinarySearchCursors()}} {
+			for length := 1; length <= inlineEntriesCap+2; length++ {
+				kse := newPostingEntries(make(map[Key]Entries))
+				for i := 0; i < length; i++ {
+					kse.AppendEntryID(1, EntryID(10*(i+1)))
+				}
+				kse.makeEntriesSorted()
+				kse.inlineSmallLists()
+				il := kse.load().inlined[1]
+				_, spilled := il.spilled()
+				convey.So(spilled, convey.ShouldEqual, length > inlineEntriesCap)
+
+				for target := EntryID(0); target <= EntryID(10*length+10); target += 5 {
+					pls := newRetrieveContext(opts...).appendKeyCursors(nil, 1, kse)
+					convey.So(len(pls), convey.ShouldEqual, 1)
+					expect := NewEntriesCursor(1, kse.getEntries(1))
+					convey.So(pls[0].SkipTo(target), convey.ShouldEqual, expect.SkipTo(target))
+					convey.So(pls[0].Skip(target), convey.ShouldEqual, expect.Skip(target))
+				}
+			}
+		}
+	})
+}
+
+// newZipfPostingEntries posting lists of keys whose length follow a zipf distribution, most
+// keys hold 1~3 entries and a few are long, like the values of a real index
+func newZipfPostingEntries(keys int) *PostingEntries {
+	r := rand.New(rand.NewSource(1))
+	zipf := rand.NewZipf(r, 1.5, 2, 4096)
+	kse := newPostingEntries(make(map[Key]Entries, keys))
+	for key := 0; key < keys; key++ {
+		for i := uint64(0); i <= zipf.Uint64(); i++ {
+			kse.AppendEntryID(Key(key), EntryID(r.Int63()))
+		}
+	}
+	kse.makeEntriesSorted()
+	return kse
+}
+
+// BenchmarkPostingEntries_Inline heap retained by posting lists of zipf distributed length, with
+// and without short lists inlined, see inlineEntries
+func BenchmarkPostingEntries_Inline(b *testing.B) {
+	const keys = 100000
+	for _, inline := range []bool{false, true} {
+		b.Run(fmt.Sprintf("inline:%t", inline), func(b *testing.B) {
+			var bytes int64
+			for i := 0; i < b.N; i++ {
+				var before, after runtime.MemStats
+				runtime.GC()
+				runtime.ReadMemStats(&before)
+				kse := newZipfPostingEntries(keys)
+				if inline {
+					kse.inlineSmallLists()
+				}
+				runtime.GC()
+				runtime.ReadMemStats(&after)
+				runtime.KeepAlive(kse)
+				bytes = int64(after.HeapAlloc) - int64(before.HeapAlloc)
+			}
+			b.ReportMetric(float64(bytes)/keys, "bytes/key")
+		})
+	}
+}
+
+func TestBEIndex_InlinedPostingLists(t *testing.T) {
+	LogLevel = ErrorLevel
+
+	convey.Convey("test retrieve lists around the inline boundary", t, func() {
+		b := NewIndexerBuilder()
+		id := DocID(1)
+		for tag := 1; tag <= inlineEntriesCap+2; tag++ {
+			for i := 0; i < tag; i++ {
+				doc := NewDocument(id)
+				doc.AddConjunction(NewConjunction().In("tag", NewIntValues(tag)).NotIn("age", NewIntValues(int(id))))
+				_ = b.AddDocument(doc)
+				id++
+			}
+		}
+		for _, index := range []BEIndex{b.BuildIndex(), b.BuildCompactedIndex()} {
+			for tag := 1; tag <= inlineEntriesCap+2; tag++ {
+				result, err := index.Retrieve(Assignments{"tag": NewIntValues(tag)})
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(len(result), convey.ShouldEqual, tag)
+
+				result, err = index.Retrieve(Assignments{"tag": NewIntValues(tag), "age": NewIntValues(tag*(tag-1)/2 + 1)})
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(len(result), convey.ShouldEqual, tag-1)
+			}
+			convey.So(index.Stats().EntryCount, convey.ShouldEqual, 30)
+		}
+	})
+}
@@ -124,3 +124,114 @@ func TestPostingList_SkipTo2(t *testing.T) {
 		fmt.Println("curent cursor:", scg.current.cursor)
 	})
 }
+
+func TestCompactEntries(t *testing.T) {
+	convey.Convey("test compact entries", t, func() {
+		stats := CompactStats{}
+		entries := make(Entries, 0, 16)
+		entries = append(entries, 1, 2, 2, 3, 3, 3, 10)
+		res := compactEntries(entries, &stats)
+		convey.So(res, convey.ShouldResemble, Entries{1, 2, 3, 10})
+		convey.So(cap(res), convey.ShouldEqual, 4)
+		convey.So(stats, convey.ShouldResemble, CompactStats{
+			ListsRewritten: 1,
+			EntriesDropped: 3,
+			BytesReclaimed: 12 * 8,
+		})
+		// the original slice is not modified
+		convey.So(entries, convey.ShouldResemble, Entries{1, 2, 2, 3, 3, 3, 10})
+
+		res2 := compactEntries(res, &stats)
+		convey.So(res2, convey.ShouldResemble, res)
+		convey.So(stats.ListsRewritten, convey.ShouldEqual, 1)
+	})
+}
+
+func TestBinarySearchCursor(t *testing.T) {
+	convey.Convey("binary search cursor skip test", t, func() {
+		sc := NewBinarySearchCursor(NewKey(1, 2), []EntryID{1, 2, 3, 10, 10, 10, 11, 12, 15, 15, 22, 111, 111})
+		convey.So(sc.Skip(0), convey.ShouldEqual, 1)
+		convey.So(sc.Skip(1), convey.ShouldEqual, 2)
+		convey.So(sc.Skip(10), convey.ShouldEqual, 11)
+		convey.So(sc.Skip(15), convey.ShouldEqual, 22)
+		convey.So(sc.Skip(111), convey.ShouldEqual, NULLENTRY)
+		convey.So(sc.Skip(2), convey.ShouldEqual, NULLENTRY)
+	})
+
+	convey.Convey("binary search cursor skip to test", t, func() {
+		sc := NewBinarySearchCursor(NewKey(1, 2), []EntryID{1, 2, 3, 10, 10, 10, 11, 12, 15, 15, 22, 111, 111})
+		convey.So(sc.SkipTo(0), convey.ShouldEqual, 1)
+		convey.So(sc.SkipTo(1), convey.ShouldEqual, 1)
+		convey.So(sc.SkipTo(3), convey.ShouldEqual, 3)
+		convey.So(sc.SkipTo(10), convey.ShouldEqual, 10)
+		convey.So(sc.cursor, convey.ShouldEqual, 3)
+		convey.So(sc.SkipTo(11), convey.ShouldEqual, 11)
+		convey.So(sc.SkipTo(16), convey.ShouldEqual, 22)
+		convey.So(sc.SkipTo(111), convey.ShouldEqual, 111)
+		convey.So(sc.cursor, convey.ShouldEqual, len(sc.entries)-2)
+		convey.So(sc.SkipTo(1000), convey.ShouldEqual, NULLENTRY)
+	})
+}
+
+// BenchmarkEntriesCursor_SkipTo compare the default cursor and binary search cursor on
+// different posting list length and advancement stride(how far a SkipTo jumps)
+func BenchmarkEntriesCursor_SkipTo(b *testing.B) {
+	for _, length := range []int{16, 256, 4096, 65536} {
+		entries := make(Entries, length)
+		for i := range entries {
+			entries[i] = EntryID(i * 2)
+		}
+		for _, stride := range []int{1, 16, 512} {
+			if stride >= length {
+				continue
+			}
+			b.Run(fmt.Sprintf("default/len:%d/stride:%d", length, stride), func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					sc := NewEntriesCursor(0, entries)
+					for id := EntryID(0); !sc.GetCurEntryID().IsNULLEntry(); id += EntryID(stride * 2) {
+						sc.SkipTo(id)
+					}
+				}
+			})
+			b.Run(fmt.Sprintf("binary/len:%d/stride:%d", length, stride), func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					sc := NewBinarySearchCursor(0, entries)
+					for id := EntryID(0); !sc.GetCurEntryID().IsNULLEntry(); id += EntryID(stride * 2) {
+						sc.SkipTo(id)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestPostingEntries_ShareEntries(t *testing.T) {
+	convey.Convey("test identical posting lists shared", t, func() {
+		kse := newPostingEntries(map[Key]Entries{
+			1: {1, 2, 3},
+			2: {1, 2, 3},
+			3: {1, 2, 4},
+			4: {1, 2, 3},
+			5: {},
+		})
+		convey.So(kse.shareEntries(), convey.ShouldEqual, 2)
+		convey.So(sameEntries(kse.load().plEntries[1], kse.load().plEntries[2]), convey.ShouldBeTrue)
+		convey.So(sameEntries(kse.load().plEntries[1], kse.load().plEntries[4]), convey.ShouldBeTrue)
+		convey.So(sameEntries(kse.load().plEntries[1], kse.load().plEntries[3]), convey.ShouldBeFalse)
+		convey.So(kse.load().plEntries[3], convey.ShouldResemble, Entries{1, 2, 4})
+		convey.So(kse.shareEntries(), convey.ShouldEqual, 0) // shared already
+
+		stats := IndexStatistics{}
+		kse.statistics(&stats)
+		convey.So(stats.SharedPostingListCount, convey.ShouldEqual, 2)
+		convey.So(stats.EntryCount, convey.ShouldEqual, 12)
+
+		// still shared after compacted
+		kse.load().plEntries[6] = Entries{7, 7}
+		kse.load().plEntries[7] = kse.load().plEntries[6]
+		kse.compact(&CompactStats{})
+		convey.So(kse.load().plEntries[6], convey.ShouldResemble, Entries{7})
+		convey.So(sameEntries(kse.load().plEntries[6], kse.load().plEntries[7]), convey.ShouldBeTrue)
+		convey.So(sameEntries(kse.load().plEntries[1], kse.load().plEntries[2]), convey.ShouldBeTrue)
+	})
+}
@@ -0,0 +1,52 @@
+package util
+
+import "math/bits"
+
+// Bitmap a simple uncompressed bitset, bit i present means value i is set
+type Bitmap struct {
+	words []uint64
+}
+
+func NewBitmap(values ...uint64) *Bitmap {
+	b := &Bitmap{}
+	for _, v := range values {
+		b.Set(v)
+	}
+	return b
+}
+
+func (b *Bitmap) Set(i uint64) {
+	idx := int(i >> 6)
+	for idx >= len(b.words) {
+		b.words = append(b.words, 0)
+	}
+	b.words[idx] |= 1 << (i & 63)
+}
+
+func (b *Bitmap) Contains(i uint64) bool {
+	idx := int(i >> 6)
+	if idx >= len(b.words) {
+		return false
+	}
+	return b.words[idx]&(1<<(i&63)) != 0
+}
+
+func (b *Bitmap) Count() (cnt int) {
+	for _, w := range b.words {
+		cnt += bits.OnesCount64(w)
+	}
+	return cnt
+}
+
+// ForEach iterate set bits in ascending order, stop when fn return false
+func (b *Bitmap) ForEach(fn func(i uint64) bool) {
+	for idx, w := range b.words {
+		for w != 0 {
+			tz := bits.TrailingZeros64(w)
+			if !fn(uint64(idx)<<6 | uint64(tz)) {
+				return
+			}
+			w &= w - 1
+		}
+	}
+}